@@ -0,0 +1,109 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// AdoptCommon is a migration step bringing a legacy table to the conventions of model.Common, so that models embedding it
+// can manage the table: it adds the missing create_time, update_time, delete_time and version columns, backfills them,
+// and indexes update_time and the soft-deleted rows. The primary key is left alone.
+// It runs outside of the migration transaction, each statement being idempotent, and builds the indexes concurrently.
+// Timestamps get a default of now(), for the legacy code inserting rows without them.
+type AdoptCommon struct {
+	// Table to adopt
+	Table string
+	// CreateTime is an SQL expression backfilling create_time, e.g. a legacy created column, now() if empty
+	CreateTime string
+	// UpdateTime is an SQL expression backfilling update_time, create_time if empty
+	UpdateTime string
+	// DeleteTime is an SQL expression backfilling delete_time, NULL for live rows, e.g. CASE WHEN deleted THEN modified END.
+	// No row is soft-deleted if empty.
+	DeleteTime string
+}
+
+// Apply adds and backfills the columns, then builds the indexes.
+func (s AdoptCommon) Apply(ctx context.Context, db orm.DB) error {
+	if _, ok := db.(*pg.Tx); ok {
+		return errConcurrentInTx
+	}
+
+	if err := Statements(s.columnStatements()).Apply(ctx, db); err != nil {
+		return fmt.Errorf("adopting %s: %w", s.Table, err)
+	}
+
+	for _, index := range s.indexes() {
+		if err := index.Apply(ctx, db); err != nil {
+			return fmt.Errorf("adopting %s: %w", s.Table, err)
+		}
+	}
+
+	return nil
+}
+
+// NonTransactional always returns true: the indexes are built concurrently.
+func (s AdoptCommon) NonTransactional() bool {
+	return true
+}
+
+// Statements returns the statements adding the columns and building the indexes.
+func (s AdoptCommon) Statements() []string {
+	statements := s.columnStatements()
+	for _, index := range s.indexes() {
+		statements = append(statements, index.Statements()...)
+	}
+
+	return statements
+}
+
+// columnStatements returns the statements adding and backfilling the columns.
+func (s AdoptCommon) columnStatements() []string {
+	updateTime := s.UpdateTime
+	if updateTime == "" && s.CreateTime != "" {
+		updateTime = "create_time"
+	}
+
+	statements := s.timestamp("create_time", s.CreateTime)
+	statements = append(statements, s.timestamp("update_time", updateTime)...)
+
+	statements = append(statements, formatQuery("ALTER TABLE ? ADD COLUMN IF NOT EXISTS delete_time timestamptz", pg.Ident(s.Table)))
+	if s.DeleteTime != "" {
+		statements = append(statements, formatQuery("UPDATE ? SET delete_time = (?) WHERE delete_time IS NULL AND (?) IS NOT NULL",
+			pg.Ident(s.Table), pg.Safe(s.DeleteTime), pg.Safe(s.DeleteTime)))
+	}
+
+	return append(statements,
+		formatQuery("ALTER TABLE ? ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1", pg.Ident(s.Table)))
+}
+
+// timestamp returns the statements adding the not null timestamp column col, backfilled with expr if not empty.
+// Without expr, existing rows get the time of the migration without being rewritten.
+func (s AdoptCommon) timestamp(col, expr string) []string {
+	table := pg.Ident(s.Table)
+	if expr == "" {
+		return []string{formatQuery("ALTER TABLE ? ADD COLUMN IF NOT EXISTS ? timestamptz NOT NULL DEFAULT now()", table, pg.Ident(col))}
+	}
+
+	return []string{
+		formatQuery("ALTER TABLE ? ADD COLUMN IF NOT EXISTS ? timestamptz", table, pg.Ident(col)),
+		formatQuery("UPDATE ? SET ? = coalesce(?, now()) WHERE ? IS NULL", table, pg.Ident(col), pg.Safe(expr), pg.Ident(col)),
+		formatQuery("ALTER TABLE ? ALTER COLUMN ? SET DEFAULT now(), ALTER COLUMN ? SET NOT NULL", table, pg.Ident(col), pg.Ident(col)),
+	}
+}
+
+// indexes returns the steps building the indexes on update_time, for incremental scans, and on the soft-deleted rows, for purges.
+func (s AdoptCommon) indexes() []CreateIndexConcurrently {
+	name := s.Table
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return []CreateIndexConcurrently{
+		{Name: name + "_update_time_idx", Table: s.Table, Columns: []string{"update_time"}},
+		{Name: name + "_delete_time_idx", Table: s.Table, Columns: []string{"delete_time"}, Where: "delete_time IS NOT NULL"},
+	}
+}
@@ -0,0 +1,108 @@
+package persistsql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type adoptedOrder struct {
+	tableName struct{} `pg:"test_adopted_orders"`
+
+	model.Common
+	Total int
+}
+
+func TestAdoptCommonStatements(t *testing.T) {
+	step := AdoptCommon{Table: "billing.orders", CreateTime: "created", DeleteTime: "CASE WHEN deleted THEN modified END"}
+	if !step.NonTransactional() {
+		t.Error("NonTransactional() = false")
+	}
+
+	want := []string{
+		`ALTER TABLE "billing"."orders" ADD COLUMN IF NOT EXISTS "create_time" timestamptz`,
+		`UPDATE "billing"."orders" SET "create_time" = coalesce(created, now()) WHERE "create_time" IS NULL`,
+		`ALTER TABLE "billing"."orders" ALTER COLUMN "create_time" SET DEFAULT now(), ALTER COLUMN "create_time" SET NOT NULL`,
+		`ALTER TABLE "billing"."orders" ADD COLUMN IF NOT EXISTS "update_time" timestamptz`,
+		`UPDATE "billing"."orders" SET "update_time" = coalesce(create_time, now()) WHERE "update_time" IS NULL`,
+		`ALTER TABLE "billing"."orders" ALTER COLUMN "update_time" SET DEFAULT now(), ALTER COLUMN "update_time" SET NOT NULL`,
+		`ALTER TABLE "billing"."orders" ADD COLUMN IF NOT EXISTS delete_time timestamptz`,
+		`UPDATE "billing"."orders" SET delete_time = (CASE WHEN deleted THEN modified END) WHERE delete_time IS NULL AND (CASE WHEN deleted THEN modified END) IS NOT NULL`,
+		`ALTER TABLE "billing"."orders" ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1`,
+		`CREATE INDEX CONCURRENTLY IF NOT EXISTS "orders_update_time_idx" ON "billing"."orders" (update_time)`,
+		`CREATE INDEX CONCURRENTLY IF NOT EXISTS "orders_delete_time_idx" ON "billing"."orders" (delete_time) WHERE delete_time IS NOT NULL`,
+	}
+	if got := step.Statements(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Statements() = %q, want %q", got, want)
+	}
+
+	// Without backfill expressions, the timestamps are added in a single statement each.
+	if got := (AdoptCommon{Table: "orders"}).columnStatements(); len(got) != 4 ||
+		got[0] != `ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "create_time" timestamptz NOT NULL DEFAULT now()` {
+		t.Errorf("columnStatements() = %q", got)
+	}
+
+	if err := step.Apply(context.Background(), &pg.Tx{}); err != errConcurrentInTx {
+		t.Errorf("Apply() in a transaction = %v, want errConcurrentInTx", err)
+	}
+}
+
+func TestAdoptCommon(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+
+	t.Cleanup(func() {
+		_, _ = p.db.ExecContext(ctx, "DROP TABLE IF EXISTS test_adopted_orders")
+	})
+	if _, err := p.db.ExecContext(ctx, `
+		DROP TABLE IF EXISTS test_adopted_orders;
+		CREATE TABLE test_adopted_orders (id uuid PRIMARY KEY, total bigint NOT NULL, created timestamptz, deleted boolean NOT NULL, modified timestamptz);
+		INSERT INTO test_adopted_orders VALUES
+			('00000000-0000-0000-0000-000000000001', 10, '2020-01-01Z', false, '2020-01-02Z'),
+			('00000000-0000-0000-0000-000000000002', 20, '2020-02-01Z', true, '2020-02-02Z')`); err != nil {
+		t.Fatalf("CREATE TABLE: %v", err)
+	}
+
+	step := AdoptCommon{Table: "test_adopted_orders", CreateTime: "created", DeleteTime: "CASE WHEN deleted THEN modified END"}
+	for i := 0; i < 2; i++ {
+		if err := step.Apply(ctx, p.db); err != nil {
+			t.Fatalf("Apply() %d: %v", i, err)
+		}
+	}
+
+	var orders []*adoptedOrder
+	if _, err := p.ListResources(ctx, &orders, ListOptions{ShowDeleted: true}, func(query *orm.Query) { query.Order("total") }); err != nil {
+		t.Fatalf("ListResources(): %v", err)
+	}
+
+	if len(orders) != 2 {
+		t.Fatalf("ListResources() = %d orders, want 2", len(orders))
+	}
+
+	live, deleted := orders[0], orders[1]
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if !live.CreateTime.Equal(created) || !live.UpdateTime.Equal(created) || !live.DeleteTime.IsZero() || live.Version != 1 {
+		t.Errorf("live order = %+v, want backfilled from created", live.Common)
+	}
+
+	if !deleted.DeleteTime.Equal(time.Date(2020, 2, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("deleted order deleted at %v, want when modified", deleted.DeleteTime)
+	}
+
+	// Legacy inserts without the columns get their defaults.
+	if _, err := p.db.ExecContext(ctx, "INSERT INTO test_adopted_orders (id, total, deleted) VALUES (gen_random_uuid(), 30, false)"); err != nil {
+		t.Errorf("legacy INSERT: %v", err)
+	}
+
+	var indexes int
+	if _, err := p.db.QueryOneContext(ctx, pg.Scan(&indexes),
+		"SELECT count(*) FROM pg_indexes WHERE tablename = 'test_adopted_orders' AND indexname LIKE 'test_adopted_orders_%_time_idx'"); err != nil || indexes != 2 {
+		t.Errorf("%d indexes, %v, want 2", indexes, err)
+	}
+}
@@ -0,0 +1,58 @@
+package persistsql
+
+import (
+	"context"
+	"reflect"
+)
+
+// AfterLoadHook transforms a resource loaded from the database, e.g. to decrypt fields or compute derived ones.
+type AfterLoadHook func(ctx context.Context, resource Resource) error
+
+// AfterLoad registers hook to be called on each resource of the type of model returned from the database:
+// by GetResource, ListResources, UpdateResource, DeleteResource and UndeleteResource.
+// Hooks run in registration order, an error fails the read.
+func (p *SQL) AfterLoad(model interface{}, hook AfterLoadHook) {
+	info := p.model(model)
+
+	p.registry.mu.Lock()
+	defer p.registry.mu.Unlock()
+
+	info.afterLoad = append(info.afterLoad, hook)
+}
+
+// afterLoad decodes the coded fields, see RegisterCodec, and runs the AfterLoad hooks on loaded, a pointer to a model or to a slice of models.
+func (p *SQL) afterLoad(ctx context.Context, loaded interface{}) error {
+	info := p.model(loaded)
+	if info.err != nil {
+		return info.err
+	}
+
+	p.registry.mu.RLock()
+	hooks := info.afterLoad
+	p.registry.mu.RUnlock()
+
+	if len(hooks) == 0 && len(info.coded) == 0 {
+		return nil
+	}
+
+	return eachStruct(loaded, func(strct reflect.Value) error {
+		for _, f := range info.coded {
+			if err := f.decode(strct); err != nil {
+				return err
+			}
+		}
+
+		if len(hooks) == 0 {
+			return nil
+		}
+
+		res := strct.Addr().Interface()
+		for _, hook := range hooks {
+			if err := hook(ctx, res); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
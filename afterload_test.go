@@ -0,0 +1,120 @@
+package persistsql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type decoratedProfile struct {
+	tableName struct{} `pg:"test_decorated_profiles"`
+
+	model.Common
+	First    string
+	Last     string
+	Settings json.RawMessage `codec:"json,Prefs"`
+
+	Prefs       codedSettings `pg:"-"`
+	DisplayName string        `pg:"-"`
+}
+
+func TestAfterLoadHooks(t *testing.T) {
+	ctx := context.Background()
+	p := &SQL{registry: &registry{models: map[reflect.Type]*modelInfo{}}}
+
+	var calls []string
+	p.AfterLoad((*decoratedProfile)(nil), func(ctx context.Context, res Resource) error {
+		profile := res.(*decoratedProfile)
+		profile.DisplayName = profile.First + " " + profile.Last + " (" + profile.Prefs.Theme + ")"
+		calls = append(calls, "display")
+		return nil
+	})
+	p.AfterLoad((*decoratedProfile)(nil), func(ctx context.Context, res Resource) error {
+		res.(*decoratedProfile).DisplayName += "!"
+		calls = append(calls, "shout")
+		return nil
+	})
+
+	profiles := []*decoratedProfile{
+		{First: "Ada", Last: "Lovelace", Settings: json.RawMessage(`{"Theme":"dark"}`)},
+		{First: "Alan", Last: "Turing", Settings: json.RawMessage(`{"Theme":"light"}`)},
+	}
+	if err := p.afterLoad(ctx, &profiles); err != nil {
+		t.Fatalf("afterLoad(): %v", err)
+	}
+
+	// The coded fields are decoded before the hooks run, which run in registration order.
+	if profiles[0].DisplayName != "Ada Lovelace (dark)!" || profiles[1].DisplayName != "Alan Turing (light)!" {
+		t.Errorf("display names = %q, %q", profiles[0].DisplayName, profiles[1].DisplayName)
+	}
+
+	if strings.Join(calls, ",") != "display,shout,display,shout" {
+		t.Errorf("hooks called %v", calls)
+	}
+
+	errHook := errors.New("can't decorate")
+	p.AfterLoad((*decoratedProfile)(nil), func(ctx context.Context, res Resource) error {
+		return errHook
+	})
+	if err := p.afterLoad(ctx, &decoratedProfile{}); !errors.Is(err, errHook) {
+		t.Errorf("afterLoad() = %v, want the hook error", err)
+	}
+
+	// Models without hooks nor coded fields are left alone.
+	if err := p.afterLoad(ctx, &labelledOrder{}); err != nil {
+		t.Errorf("afterLoad() without hooks = %v", err)
+	}
+}
+
+func TestAfterLoadReads(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*decoratedProfile)(nil))
+
+	loaded := 0
+	p.AfterLoad((*decoratedProfile)(nil), func(ctx context.Context, res Resource) error {
+		profile := res.(*decoratedProfile)
+		profile.DisplayName = profile.First + " " + profile.Last
+		loaded++
+		return nil
+	})
+
+	created, err := p.CreateResource(ctx, &decoratedProfile{First: "Ada", Last: "Lovelace", Prefs: codedSettings{Theme: "dark"}})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	id := created.(*decoratedProfile).ID
+
+	byID := func(query *orm.Query) {
+		query.Where("id = ?", id)
+	}
+
+	got, err := p.GetResource(ctx, &decoratedProfile{}, false, byID)
+	if err != nil {
+		t.Fatalf("GetResource(): %v", err)
+	}
+	if profile, _ := got.(*decoratedProfile); profile == nil || profile.DisplayName != "Ada Lovelace" || profile.Prefs.Theme != "dark" {
+		t.Errorf("GetResource() = %+v, want it decorated", profile)
+	}
+
+	var profiles []*decoratedProfile
+	if _, err := p.ListResources(ctx, &profiles, ListOptions{}, byID); err != nil || len(profiles) != 1 || profiles[0].DisplayName != "Ada Lovelace" {
+		t.Errorf("ListResources() = %v, %v, want the profile decorated", profiles, err)
+	}
+
+	deleted, err := p.DeleteResource(ctx, &decoratedProfile{Common: model.Common{ID: id}}, nil)
+	if profile, _ := deleted.(*decoratedProfile); err != nil || profile == nil || profile.DisplayName != "Ada Lovelace" {
+		t.Errorf("DeleteResource() = %v, %v, want the profile decorated", deleted, err)
+	}
+
+	if loaded < 3 {
+		t.Errorf("hook called %d times, want once per read", loaded)
+	}
+}
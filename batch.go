@@ -0,0 +1,235 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// BatchOptions controls CreateResources and UpsertResources.
+type BatchOptions struct {
+	// ContinueOnError skips the resources failing on a constraint, or a DuplicateError, and writes the others,
+	// the failures are reported in a BatchError. Other errors abort the batch.
+	ContinueOnError bool
+}
+
+// RowError is the failure of a resource of a batch.
+type RowError struct {
+	// Index of the resource in the batch
+	Index int
+	// Key is the primary key of the resource
+	Key string
+	// Err is the failure
+	Err error
+}
+
+// BatchError reports the resources of a batch which failed while the others were written, see BatchOptions.ContinueOnError.
+type BatchError struct {
+	// Errors in the order of the batch
+	Errors []RowError
+}
+
+// Error implements error.
+func (e *BatchError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, row := range e.Errors {
+		msgs[i] = fmt.Sprintf("resource %d (%s): %v", row.Index, row.Key, row.Err)
+	}
+
+	return fmt.Sprintf("%d resources failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// CreateResources inserts resources as CreateResource does, in a single transaction.
+// It returns the resources written: all of them, or with BatchOptions.ContinueOnError those which didn't fail along with a BatchError.
+func (p *SQL) CreateResources(ctx context.Context, resources []Resource, opts BatchOptions) ([]Resource, error) {
+	return p.batch(ctx, resources, opts, func(p *SQL, res Resource) error {
+		_, err := p.CreateResource(ctx, res)
+		return err
+	})
+}
+
+// UpsertResources inserts resources, or updates them if they exist, matching them by primary key, in a single transaction.
+// Inserts are checked as by CreateResource, see DetectDuplicates, and publish a ChangeCreate event.
+// Updates set all the columns but the immutable ones and the creation time, undeleting soft-deleted resources; they're checked
+// as by UpdateResource listing those columns, see DeclareStateMachine, and publish a ChangeUpdate event. An immutable column set
+// to a value other than the stored one fails the update with ErrImmutableField, zero values are ignored.
+// It returns the resources written: all of them, or with BatchOptions.ContinueOnError those which didn't fail along with a BatchError.
+func (p *SQL) UpsertResources(ctx context.Context, resources []Resource, opts BatchOptions) ([]Resource, error) {
+	return p.batch(ctx, resources, opts, func(p *SQL, res Resource) error {
+		return p.upsert(ctx, res)
+	})
+}
+
+// upsert inserts or updates res, in the transaction p is bound to.
+func (p *SQL) upsert(ctx context.Context, res Resource) error {
+	p.assignID(ctx, res)
+	if err := p.encodeFields(res); err != nil {
+		return err
+	}
+
+	for {
+		existing := cloneResource(res)
+		err := p.conn().ModelContext(ctx, existing).WherePK().AllWithDeleted().For("UPDATE").Select()
+		if err == pg.ErrNoRows {
+			inserted, err := p.upsertInsert(ctx, res)
+			if err != nil || inserted {
+				return err
+			}
+
+			// Inserted concurrently since, it's updated instead.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		return p.upsertUpdate(ctx, res, existing)
+	}
+}
+
+// upsertInsert inserts res as CreateResource does unless a row with its primary key exists, and reports whether it did.
+func (p *SQL) upsertInsert(ctx context.Context, res Resource) (bool, error) {
+	now := p.now(ctx)
+	stamp(res, createTimeColumns, now, true)
+	stamp(res, updateTimeColumns, now, false)
+
+	if err := p.checkDuplicates(ctx, p.tx, res); err != nil {
+		return false, err
+	}
+
+	result, err := p.conn().ModelContext(ctx, res).OnConflict("DO NOTHING").Returning("*").Insert()
+	if err != nil || result.RowsAffected() == 0 {
+		return false, err
+	}
+
+	if err := p.afterLoad(ctx, res); err != nil {
+		return false, err
+	}
+
+	return true, p.notify(ctx, p.tx, ChangeCreate, res)
+}
+
+// upsertUpdate updates the columns of existing, locked, but the immutable ones and the creation time, to those of res.
+func (p *SQL) upsertUpdate(ctx context.Context, res, existing Resource) error {
+	table := tableOf(res)
+	immutable := immutableColumns(res)
+	createTime := timeField(table, createTimeColumns)
+
+	v, stored := reflect.Indirect(reflect.ValueOf(res)), reflect.Indirect(reflect.ValueOf(existing))
+	var columns []string
+	for _, field := range table.DataFields {
+		switch {
+		case field == createTime:
+		case !immutable[field.SQLName]:
+			columns = append(columns, field.SQLName)
+		case !field.HasZeroValue(v) && !equalValues(field.Value(v), field.Value(stored)):
+			return fmt.Errorf("%w: %s", ErrImmutableField, field.SQLName)
+		}
+	}
+
+	stamp(res, updateTimeColumns, p.now(ctx), false)
+
+	changes, err := p.checkTransitions(ctx, p.tx, res, columns, func(query *orm.Query) {
+		query.WherePK().AllWithDeleted()
+	})
+	if err != nil {
+		return err
+	}
+
+	query := p.conn().ModelContext(ctx, res).Column(columns...).WherePK().AllWithDeleted().Returning("*")
+	for _, col := range columns {
+		// As inserted, zero values of columns with a default are set to it.
+		if field := table.FieldsMap[col]; field.Default != "" && field.HasZeroValue(v) {
+			query.Value(col, "DEFAULT")
+		}
+	}
+
+	if _, err := query.Update(); err != nil {
+		return err
+	}
+
+	if err := p.afterLoad(ctx, res); err != nil {
+		return err
+	}
+
+	if err := p.notify(ctx, p.tx, ChangeUpdate, res); err != nil {
+		return err
+	}
+
+	return p.notifyTransitions(ctx, p.tx, res, changes)
+}
+
+// batch calls write with each resource in a transaction, each in a savepoint with opts.ContinueOnError.
+func (p *SQL) batch(ctx context.Context, resources []Resource, opts BatchOptions,
+	write func(p *SQL, res Resource) error) ([]Resource, error) {
+	if err := p.checkWrite(); err != nil {
+		return nil, err
+	}
+
+	var written []Resource
+	var failed []RowError
+
+	err := p.RunInTransaction(ctx, func(p *SQL) error {
+		written, failed = nil, nil
+
+		for i, res := range resources {
+			if !opts.ContinueOnError {
+				if err := write(p, res); err != nil {
+					return fmt.Errorf("resource %d (%s): %w", i, primaryKey(res), err)
+				}
+
+				written = append(written, res)
+				continue
+			}
+
+			if _, err := p.tx.ExecContext(ctx, "SAVEPOINT persistsql_batch"); err != nil {
+				return err
+			}
+
+			if err := write(p, res); err != nil {
+				if !rowError(err) {
+					return err
+				}
+
+				if _, err := p.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT persistsql_batch"); err != nil {
+					return err
+				}
+
+				failed = append(failed, RowError{Index: i, Key: primaryKey(res), Err: err})
+				continue
+			}
+
+			if _, err := p.tx.ExecContext(ctx, "RELEASE SAVEPOINT persistsql_batch"); err != nil {
+				return err
+			}
+
+			written = append(written, res)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(failed) > 0 {
+		return written, &BatchError{Errors: failed}
+	}
+
+	return written, nil
+}
+
+// rowError reports whether err is the failure of a single row of a batch, which doesn't abort it.
+func rowError(err error) bool {
+	var pgErr pg.Error
+	if errors.As(err, &pgErr) && pgErr.IntegrityViolation() {
+		return true
+	}
+
+	return errors.Is(err, ErrPossibleDuplicate) || errors.Is(err, ErrImmutableField)
+}
@@ -0,0 +1,76 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type upsertedTicket struct {
+	tableName struct{} `pg:"test_upserted_tickets"`
+
+	model.Common
+	Tenant string `immutable:"true"`
+	Status string
+}
+
+func TestUpsertResources(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*upsertedTicket)(nil))
+
+	p.DeclareStateMachine((*upsertedTicket)(nil), StateMachine{
+		Column:      "status",
+		Transitions: map[string][]string{"open": {"closed"}},
+	})
+
+	changesCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changes, err := p.Changes(changesCtx)
+	if err != nil {
+		t.Fatalf("Changes(): %v", err)
+	}
+
+	ticket := &upsertedTicket{Tenant: "a", Status: "open"}
+	if _, err := p.UpsertResources(ctx, []Resource{ticket}, BatchOptions{}); err != nil {
+		t.Fatalf("UpsertResources() inserting: %v", err)
+	}
+
+	closed := &upsertedTicket{Common: model.Common{ID: ticket.ID}, Status: "closed"}
+	if _, err := p.UpsertResources(ctx, []Resource{closed}, BatchOptions{}); err != nil {
+		t.Fatalf("UpsertResources() closing: %v", err)
+	}
+
+	if closed.Tenant != "a" || !closed.CreateTime.Equal(ticket.CreateTime) {
+		t.Errorf("upserted = %+v, want the tenant and creation time kept", closed)
+	}
+
+	var ops []ChangeOp
+	timeout := time.After(5 * time.Second)
+	for len(ops) < 3 {
+		select {
+		case event := <-changes:
+			ops = append(ops, event.Op)
+		case <-timeout:
+			t.Fatalf("events = %v, want create, update and transition", ops)
+		}
+	}
+
+	if ops[0] != ChangeCreate || ops[1] != ChangeUpdate || ops[2] != ChangeTransition {
+		t.Errorf("events = %v, want create, update and transition", ops)
+	}
+
+	reopened := &upsertedTicket{Common: model.Common{ID: ticket.ID}, Status: "open"}
+	if _, err := p.UpsertResources(ctx, []Resource{reopened}, BatchOptions{}); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("UpsertResources() reopening = %v, want ErrInvalidTransition", err)
+	}
+
+	moved := &upsertedTicket{Common: model.Common{ID: ticket.ID}, Tenant: "b", Status: "closed"}
+	if _, err := p.UpsertResources(ctx, []Resource{moved}, BatchOptions{}); !errors.Is(err, ErrImmutableField) {
+		t.Errorf("UpsertResources() changing the tenant = %v, want ErrImmutableField", err)
+	}
+}
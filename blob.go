@@ -0,0 +1,147 @@
+package persistsql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// BlobRef references a blob of the blob store by the SHA-256 of its content, hex encoded, empty for none.
+// Models store large payloads shared between resources in BlobRef fields rather than inline, see PutBlob.
+type BlobRef string
+
+// blob is a blob of the blob store, stored once per content.
+type blob struct {
+	tableName struct{} `pg:"blobs"`
+
+	Hash       BlobRef   `pg:",pk"`
+	Data       []byte    `pg:",notnull"`
+	Size       int64     `pg:",notnull,use_zero"`
+	Refs       int64     `pg:",notnull,use_zero"`
+	CreateTime time.Time `pg:",notnull"`
+}
+
+// PutBlob stores data in the blob store, unless an identical blob is already stored, and takes a reference on it.
+// Each PutBlob must be balanced by a ReleaseBlob, DeleteResource releases the BlobRef fields of the resources it hard-deletes.
+func (p *SQL) PutBlob(ctx context.Context, data []byte) (BlobRef, error) {
+	if err := p.checkWrite(); err != nil {
+		return "", err
+	}
+
+	if err := p.ensureTable(ctx, (*blob)(nil)); err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(data)
+	b := &blob{
+		Hash:       BlobRef(hex.EncodeToString(hash[:])),
+		Data:       data,
+		Size:       int64(len(data)),
+		Refs:       1,
+		CreateTime: p.now(ctx),
+	}
+
+	if _, err := p.conn().ModelContext(ctx, b).
+		OnConflict("(hash) DO UPDATE").Set("refs = blob.refs + 1").
+		Insert(); err != nil {
+		return "", err
+	}
+
+	return b.Hash, nil
+}
+
+// GetBlob returns the content of the blob ref, nil if there's none.
+func (p *SQL) GetBlob(ctx context.Context, ref BlobRef) ([]byte, error) {
+	if err := p.ensureTable(ctx, (*blob)(nil)); err != nil {
+		return nil, err
+	}
+
+	b := &blob{Hash: ref}
+	if err := p.reader(ctx).ModelContext(ctx, b).Column("data").WherePK().Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return b.Data, nil
+}
+
+// ReleaseBlob drops a reference on the blob ref, taken by PutBlob, and deletes the blob once unreferenced.
+// Releasing an empty or unknown ref does nothing.
+func (p *SQL) ReleaseBlob(ctx context.Context, ref BlobRef) error {
+	if err := p.checkWrite(); err != nil {
+		return err
+	}
+
+	if ref == "" {
+		return nil
+	}
+
+	if err := p.ensureTable(ctx, (*blob)(nil)); err != nil {
+		return err
+	}
+
+	return p.runInTransaction(ctx, func(tx *pg.Tx) error {
+		return releaseBlob(ctx, tx, ref)
+	})
+}
+
+// releaseBlob drops a reference on the blob ref in tx, deleting it once unreferenced.
+// The update locks the blob, so a concurrent PutBlob either sees it deleted or references it before the deletion check.
+func releaseBlob(ctx context.Context, tx *pg.Tx, ref BlobRef) error {
+	b := &blob{Hash: ref}
+	res, err := tx.ModelContext(ctx, b).Set("refs = refs - 1").WherePK().Where("refs > 0").Update()
+	if err != nil || res.RowsAffected() == 0 {
+		return err
+	}
+
+	_, err = tx.ModelContext(ctx, b).WherePK().Where("refs <= 0").Delete()
+
+	return err
+}
+
+// blobRefFields returns the BlobRef fields of table.
+func blobRefFields(table *orm.Table) []*orm.Field {
+	var fields []*orm.Field
+	for _, field := range table.Fields {
+		if field.Field.Type == reflect.TypeOf(BlobRef("")) {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// releaseBlobRefs releases the non-empty BlobRef fields of model in tx.
+func (p *SQL) releaseBlobRefs(ctx context.Context, tx *pg.Tx, model interface{}) error {
+	var refs []BlobRef
+	for _, field := range blobRefFields(tableOf(model)) {
+		ref := field.Value(reflect.Indirect(reflect.ValueOf(model))).Interface().(BlobRef)
+		if ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+
+	if len(refs) == 0 {
+		return nil
+	}
+
+	if err := p.ensureTable(ctx, (*blob)(nil)); err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		if err := releaseBlob(ctx, tx, ref); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
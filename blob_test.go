@@ -0,0 +1,71 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+)
+
+type attachedFile struct {
+	tableName struct{} `pg:"test_attached_files"`
+
+	ID        int64
+	Name      string
+	Content   BlobRef
+	Thumbnail BlobRef
+}
+
+func TestBlobRefFields(t *testing.T) {
+	fields := blobRefFields(tableOf((*attachedFile)(nil)))
+	if len(fields) != 2 || fields[0].SQLName != "content" || fields[1].SQLName != "thumbnail" {
+		t.Errorf("blobRefFields() = %v, want content and thumbnail", fields)
+	}
+
+	if err := (&SQL{maintenance: &maintenance{}}).ReleaseBlob(context.Background(), ""); err != nil {
+		t.Errorf("ReleaseBlob(\"\") = %v", err)
+	}
+}
+
+func TestBlobRefCounts(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*blob)(nil), (*attachedFile)(nil))
+
+	data := []byte("%PDF-1.7 invoice")
+	ref, err := p.PutBlob(ctx, data)
+	if err != nil {
+		t.Fatalf("PutBlob(): %v", err)
+	}
+
+	if again, err := p.PutBlob(ctx, data); err != nil || again != ref {
+		t.Fatalf("PutBlob() of the same data = %s, %v, want %s", again, err, ref)
+	}
+
+	if count, err := p.db.Model((*blob)(nil)).Count(); err != nil || count != 1 {
+		t.Errorf("%d blobs stored, %v, want the data stored once", count, err)
+	}
+
+	if err := p.ReleaseBlob(ctx, ref); err != nil {
+		t.Fatalf("ReleaseBlob(): %v", err)
+	}
+
+	if got, err := p.GetBlob(ctx, ref); err != nil || string(got) != string(data) {
+		t.Errorf("GetBlob() while referenced = %q, %v", got, err)
+	}
+
+	// The second reference is held by a resource, released when it's deleted.
+	if _, err := p.CreateResource(ctx, &attachedFile{ID: 1, Name: "invoice.pdf", Content: ref}); err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+
+	if _, err := p.DeleteResource(ctx, &attachedFile{ID: 1}, nil); err != nil {
+		t.Fatalf("DeleteResource(): %v", err)
+	}
+
+	if got, err := p.GetBlob(ctx, ref); err != nil || got != nil {
+		t.Errorf("GetBlob() once unreferenced = %q, %v, want none", got, err)
+	}
+
+	if err := p.ReleaseBlob(ctx, ref); err != nil {
+		t.Errorf("ReleaseBlob() of a deleted blob = %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/vmihailenco/msgpack/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache stores encoded resources by key for GetResourceByPK, e.g. in Redis, see package persistsqlredis.
+type Cache interface {
+	// Get returns the value of key, false if it's missing
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores the value of key for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the keys, missing ones are ignored
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// cacheLoadTimeout bounds the loading of a resource missing from the cache, shared by the concurrent callers missing it.
+const cacheLoadTimeout = 30 * time.Second
+
+// errNoCache is returned by RunCacheInvalidator without a cache.
+var errNoCache = errors.New("no cache configured, see WithCache")
+
+// resourceCache caches the resources read by primary key.
+type resourceCache struct {
+	cache  Cache
+	ttl    time.Duration
+	flight singleflight.Group
+}
+
+// WithCache makes GetResourceByPK read through cache, storing resources for ttl.
+// Cached resources are invalidated by RunCacheInvalidator, ttl bounds their staleness when change events are lost.
+func WithCache(cache Cache, ttl time.Duration) Option {
+	return func(p *SQL) {
+		p.cache = &resourceCache{cache: cache, ttl: ttl}
+	}
+}
+
+// CacheKey returns the cache key of the resource of table with the primary key key, see PrimaryKey: the table and key joined by a colon.
+func CacheKey(table, key string) string {
+	return table + ":" + key
+}
+
+// GetResourceByPK retrieves the resource of a collection with the primary key of resource, nil if there's none or it's soft-deleted.
+// Resources are read through the cache if one is configured, see WithCache, concurrent misses of a key being loaded once.
+// The rows are cached as stored, the AfterLoad hooks run on each read, see AddAfterLoad.
+// The cache is bypassed in transactions, when reading as of a past time, see AsOf, and for the models disabling it,
+// see WithModelFeatures, and when ctx says so, see SkipCache.
+func (p *SQL) GetResourceByPK(ctx context.Context, resource Resource) (Resource, error) {
+	if p.cache == nil || p.tx != nil || ctx.Value(asOfKey{}) != nil || !p.features(resource).Cache || callOptionsOf(ctx).skipCache {
+		return p.GetResource(ctx, resource, false, WherePK)
+	}
+
+	key := CacheKey(unqualifiedName(tableOf(resource)), primaryKey(resource))
+
+	value, ok, err := p.cache.cache.Get(ctx, key)
+	if err != nil {
+		log.Printf("persistsql: cache get %s failed: %v", key, err)
+	}
+
+	if !ok {
+		// The load isn't canceled with the context of the caller starting it, the others waiting for it.
+		loading := p.cache.flight.DoChan(key, func() (loaded interface{}, err error) {
+			detach(ctx, cacheLoadTimeout, func(ctx context.Context) {
+				loaded, err = p.loadCached(ctx, key, cloneResource(resource))
+			})
+
+			return loaded, err
+		})
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case res := <-loading:
+			if res.Err != nil {
+				return nil, res.Err
+			}
+
+			value = res.Val.([]byte)
+		}
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+
+	if err := msgpack.Unmarshal(value, resource); err != nil {
+		return nil, err
+	}
+
+	if err := p.afterLoad(ctx, resource); err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// loadCached loads the row of key by the primary key of res and caches it, returning its encoding, nil if there's none.
+// It's loaded from the primary, a replica could still hold the version whose change invalidated the key.
+func (p *SQL) loadCached(ctx context.Context, key string, res Resource) ([]byte, error) {
+	ctx = WithCallOptions(ctx, PrimaryRead())
+	if err := p.reader(ctx).ModelContext(ctx, res).WherePK().Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	value, err := msgpack.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.cache.cache.Set(ctx, key, value, p.cache.ttl); err != nil {
+		log.Printf("persistsql: cache set %s failed: %v", key, err)
+	}
+
+	return value, nil
+}
+
+// RunCacheInvalidator removes changed resources from the cache, following the change feed, until ctx is done, see WithCache.
+// A nil alert logs errors with the standard logger. An error is returned at once without a cache.
+func (p *SQL) RunCacheInvalidator(ctx context.Context, alert func(error)) error {
+	if p.cache == nil {
+		return errNoCache
+	}
+
+	if alert == nil {
+		alert = func(err error) {
+			log.Printf("persistsql: invalidating the cache failed: %v", err)
+		}
+	}
+
+	for ctx.Err() == nil {
+		changes, err := p.Changes(ctx)
+		if err != nil {
+			alert(err)
+		} else {
+			for change := range changes {
+				if err := p.cache.cache.Delete(ctx, CacheKey(change.Table, change.Key)); err != nil && ctx.Err() == nil {
+					alert(err)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(changesRetry):
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package persistsql
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chi07/persistsql/model"
+)
+
+// memoryCache is a Cache in memory, ignoring ttls.
+type memoryCache struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (c *memoryCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok := c.values[key]
+
+	return value, ok, nil
+}
+
+func (c *memoryCache) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[key] = value
+
+	return nil
+}
+
+func (c *memoryCache) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.values, key)
+	}
+
+	return nil
+}
+
+func TestRunCacheInvalidatorWithoutCache(t *testing.T) {
+	p := testSQL(t)
+	if err := p.RunCacheInvalidator(context.Background(), nil); err != errNoCache {
+		t.Errorf("RunCacheInvalidator() = %v, want %v", err, errNoCache)
+	}
+}
+
+type cachedNote struct {
+	tableName struct{} `pg:"test_cached_notes"`
+
+	model.Common
+	Text    string
+	Excerpt string `pg:"-"`
+}
+
+func TestGetResourceByPKCached(t *testing.T) {
+	ctx := context.Background()
+	cache := &memoryCache{values: map[string][]byte{}}
+	p := testSQL(t, WithCache(cache, time.Minute))
+	testTables(t, p.db, (*cachedNote)(nil))
+
+	loads := 0
+	p.AfterLoad((*cachedNote)(nil), func(_ context.Context, res Resource) error {
+		loads++
+		note := res.(*cachedNote)
+		note.Excerpt = "> " + note.Text
+
+		return nil
+	})
+
+	created, err := p.CreateResource(ctx, &cachedNote{Text: "hello"})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	id := created.(*cachedNote).ID
+
+	loads = 0
+	for i := 0; i < 2; i++ {
+		got, err := p.GetResourceByPK(ctx, &cachedNote{Common: model.Common{ID: id}})
+		if err != nil || got == nil {
+			t.Fatalf("GetResourceByPK() = %v, %v", got, err)
+		}
+
+		if excerpt := got.(*cachedNote).Excerpt; excerpt != "> hello" {
+			t.Errorf("read %d: Excerpt = %q, want the AfterLoad hook's", i, excerpt)
+		}
+	}
+
+	if loads != 2 {
+		t.Errorf("AfterLoad hook ran %d times, want on the miss and the hit", loads)
+	}
+
+	if len(cache.values) != 1 {
+		t.Errorf("%d cached values, want 1", len(cache.values))
+	}
+}
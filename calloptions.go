@@ -0,0 +1,57 @@
+package persistsql
+
+import "context"
+
+type callOptionsKey struct{}
+
+// callOptions are the per-call options carried by a context, see WithCallOptions.
+type callOptions struct {
+	skipCache   bool
+	primaryRead bool
+	maxRows     int
+}
+
+// CallOption adjusts the behavior of the calls made with a context, see WithCallOptions.
+type CallOption func(*callOptions)
+
+// WithCallOptions returns a copy of ctx applying opts to the calls made with it, after the call options ctx already carries,
+// so that per-call behavior can be adjusted without changing method signatures:
+//
+//	p.GetResourceByPK(persistsql.WithCallOptions(ctx, persistsql.SkipCache(), persistsql.PrimaryRead()), order)
+func WithCallOptions(ctx context.Context, opts ...CallOption) context.Context {
+	o := callOptionsOf(ctx)
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return context.WithValue(ctx, callOptionsKey{}, o)
+}
+
+// SkipCache makes GetResourceByPK read from the database rather than through the cache, see WithCache, e.g. to read your writes.
+// The cache isn't filled by such reads.
+func SkipCache() CallOption {
+	return func(o *callOptions) {
+		o.skipCache = true
+	}
+}
+
+// PrimaryRead makes reads use the primary rather than a replica, see WithReplicas, e.g. to avoid replication lag.
+func PrimaryRead() CallOption {
+	return func(o *callOptions) {
+		o.primaryRead = true
+	}
+}
+
+// MaxRows caps the number of resources returned by ListResources at n, as ListOptions.MaxRows does when it's zero.
+func MaxRows(n int) CallOption {
+	return func(o *callOptions) {
+		o.maxRows = n
+	}
+}
+
+// callOptionsOf returns the call options of ctx, the zero value if there's none.
+func callOptionsOf(ctx context.Context) callOptions {
+	o, _ := ctx.Value(callOptionsKey{}).(callOptions)
+
+	return o
+}
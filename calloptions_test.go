@@ -0,0 +1,67 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql/model"
+)
+
+func TestWithCallOptions(t *testing.T) {
+	ctx := context.Background()
+	if o := callOptionsOf(ctx); o != (callOptions{}) {
+		t.Errorf("callOptionsOf() without options = %+v", o)
+	}
+
+	skipping := WithCallOptions(ctx, SkipCache(), MaxRows(10))
+	primary := WithCallOptions(skipping, PrimaryRead(), MaxRows(5))
+
+	if o := callOptionsOf(primary); o != (callOptions{skipCache: true, primaryRead: true, maxRows: 5}) {
+		t.Errorf("callOptionsOf() of layered options = %+v, want the later ones over the earlier", o)
+	}
+
+	if o := callOptionsOf(skipping); o != (callOptions{skipCache: true, maxRows: 10}) {
+		t.Errorf("callOptionsOf() of the parent = %+v, want it unchanged", o)
+	}
+}
+
+func TestCallOptions(t *testing.T) {
+	ctx := context.Background()
+	cache := &memoryCache{values: map[string][]byte{}}
+	p := testSQL(t, WithCache(cache, time.Minute))
+	testTables(t, p.db, (*cachedNote)(nil))
+
+	created, err := p.CreateResource(ctx, &cachedNote{Text: "hello"})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	byPK := &cachedNote{Common: model.Common{ID: created.(*cachedNote).ID}}
+
+	// Reads skipping the cache don't fill it.
+	if got, err := p.GetResourceByPK(WithCallOptions(ctx, SkipCache()), byPK); err != nil || got == nil || len(cache.values) != 0 {
+		t.Errorf("GetResourceByPK(SkipCache) = %v, %v, %d cached values, want none", got, err, len(cache.values))
+	}
+
+	if _, err := p.GetResourceByPK(ctx, byPK); err != nil || len(cache.values) != 1 {
+		t.Errorf("GetResourceByPK() = %v, %d cached values, want 1", err, len(cache.values))
+	}
+
+	if _, err := p.CreateResource(ctx, &cachedNote{Text: "world"}); err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+
+	var notes []*cachedNote
+	all := func(*orm.Query) {}
+	if _, err := p.ListResources(WithCallOptions(ctx, MaxRows(1)), &notes, ListOptions{}, all); !errors.Is(err, ErrTooManyRows) {
+		t.Errorf("ListResources(MaxRows(1)) = %v, want ErrTooManyRows", err)
+	}
+
+	// ListOptions.MaxRows takes precedence.
+	if _, err := p.ListResources(WithCallOptions(ctx, MaxRows(1)), &notes, ListOptions{MaxRows: 2}, all); err != nil || len(notes) != 2 {
+		t.Errorf("ListResources(MaxRows: 2) = %d notes, %v, want 2", len(notes), err)
+	}
+}
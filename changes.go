@@ -0,0 +1,70 @@
+package persistsql
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// versionColumn is the column of the version managed by the server, see model.Common.
+const versionColumn = "version"
+
+// ComputeChangedFields returns the columns whose values differ between before and after, two resources of the same model,
+// in the order of the model fields, to be used as the fields of UpdateResource.
+// Coded columns, see RegisterCodec, are compared by their decoded values. Primary keys, immutable columns, the soft delete column,
+// the update time and version columns and the output only fields, see OutputOnlyFielder, are never listed, being managed by the server.
+func ComputeChangedFields(before, after Resource) ([]string, error) {
+	if reflect.TypeOf(before) != reflect.TypeOf(after) {
+		return nil, fmt.Errorf("can't compare %T with %T", before, after)
+	}
+
+	table := tableOf(before)
+	immutable := immutableColumns(before)
+	beforeV := reflect.Indirect(reflect.ValueOf(before))
+	afterV := reflect.Indirect(reflect.ValueOf(after))
+
+	updateTime := timeField(table, updateTimeColumns)
+
+	codedList, err := codedFields(table)
+	if err != nil {
+		return nil, err
+	}
+
+	coded := map[*orm.Field][]int{}
+	for _, f := range codedList {
+		coded[f.column] = f.value.Index
+	}
+
+	var fields []string
+	for _, field := range table.DataFields {
+		if immutable[field.SQLName] || field == table.SoftDeleteField || field == updateTime ||
+			field.SQLName == versionColumn || SnakeCase(field.GoName) == versionColumn || IsFieldOutputOnly(before, field.SQLName) {
+			continue
+		}
+
+		if index, ok := coded[field]; ok {
+			if !reflect.DeepEqual(beforeV.FieldByIndex(index).Interface(), afterV.FieldByIndex(index).Interface()) {
+				fields = append(fields, field.SQLName)
+			}
+
+			continue
+		}
+
+		if !equalValues(field.Value(beforeV), field.Value(afterV)) {
+			fields = append(fields, field.SQLName)
+		}
+	}
+
+	return fields, nil
+}
+
+// equalValues reports whether a and b are deeply equal, comparing times as instants.
+func equalValues(a, b reflect.Value) bool {
+	if at, ok := a.Interface().(time.Time); ok {
+		return at.Equal(b.Interface().(time.Time))
+	}
+
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
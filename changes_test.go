@@ -0,0 +1,93 @@
+package persistsql
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type changedInvoice struct {
+	tableName struct{} `pg:"test_changed_invoices"`
+
+	model.Common
+	TenantID string `pg:",notnull" immutable:"true"`
+	Customer string
+	Total    int `pg:",use_zero"`
+	DueTime  time.Time
+	Status   string
+	Settings json.RawMessage `codec:"json,Prefs"`
+
+	Prefs codedSettings `pg:"-"`
+}
+
+func (*changedInvoice) IsFieldOutputOnly(field string) bool {
+	return field == "status"
+}
+
+func TestComputeChangedFields(t *testing.T) {
+	due := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	before := &changedInvoice{TenantID: "t1", Customer: "c1", Total: 10, DueTime: due, Settings: json.RawMessage(`{"Theme":"dark"}`),
+		Prefs: codedSettings{Theme: "dark"}}
+
+	same := *before
+	// The same instant in another location, and server managed columns.
+	same.DueTime = due.In(time.FixedZone("CET", 3600))
+	same.UpdateTime, same.Version, same.Status = time.Now(), 7, "paid"
+	same.TenantID = "t2"
+	// Coded columns are compared by their decoded values.
+	same.Settings = json.RawMessage(`{ "Theme": "dark" }`)
+
+	if fields, err := ComputeChangedFields(before, &same); err != nil || len(fields) != 0 {
+		t.Errorf("ComputeChangedFields() = %v, %v, want none", fields, err)
+	}
+
+	after := *before
+	after.Total, after.Customer = 0, "c2"
+	after.Prefs = codedSettings{Theme: "light"}
+
+	fields, err := ComputeChangedFields(before, &after)
+	if err != nil {
+		t.Fatalf("ComputeChangedFields(): %v", err)
+	}
+
+	if got := strings.Join(fields, ","); got != "customer,total,settings" {
+		t.Errorf("ComputeChangedFields() = %s, want customer,total,settings", got)
+	}
+
+	if _, err := ComputeChangedFields(before, &labelledOrder{}); err == nil {
+		t.Error("ComputeChangedFields() of different models succeeded")
+	}
+}
+
+func TestComputeChangedFieldsUpdate(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*changedInvoice)(nil))
+
+	created, err := p.CreateResource(ctx, &changedInvoice{TenantID: "t1", Customer: "c1", Total: 10})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	before := created.(*changedInvoice)
+
+	after := *before
+	after.Total = 0
+
+	fields, err := ComputeChangedFields(before, &after)
+	if err != nil {
+		t.Fatalf("ComputeChangedFields(): %v", err)
+	}
+
+	updated, err := p.UpdateResource(ctx, &after, fields, nil)
+	if err != nil {
+		t.Fatalf("UpdateResource(%v): %v", fields, err)
+	}
+
+	if invoice, _ := updated.(*changedInvoice); invoice == nil || invoice.Total != 0 || invoice.Customer != "c1" {
+		t.Errorf("UpdateResource() = %+v, want the total zeroed", updated)
+	}
+}
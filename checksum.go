@@ -0,0 +1,43 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// Checksum is an order-independent digest of rows, see TableChecksum.
+type Checksum struct {
+	// Rows is the number of rows
+	Rows int64 `json:"rows"`
+	// Sum is the sum, as a decimal number, of the first 64 bits of the MD5 of the row_to_json text of each row, read as signed integers
+	Sum string `json:"sum"`
+}
+
+// String returns the checksum as rows:sum.
+func (c Checksum) String() string {
+	return fmt.Sprintf("%d:%s", c.Rows, c.Sum)
+}
+
+// TableChecksum computes the checksum of rows of the table of model, from a replica if any is configured, so that other systems,
+// e.g. caches, search indexes or a data warehouse, can cheaply verify they're in sync, by computing it the same way on their copy.
+// The query is built without a WHERE clause and SELECT all fields of the model, soft-deleted rows excluded.
+// QueryHook, if non-nil, is called before executing the query, to be used for adding a WHERE clause or selecting the columns checked.
+func (p *SQL) TableChecksum(ctx context.Context, model interface{}, queryHook QueryHook) (Checksum, error) {
+	db := p.reader(ctx)
+	query := db.ModelContext(ctx, model)
+	if queryHook != nil {
+		queryHook(query)
+	}
+
+	var sum Checksum
+	if _, err := db.QueryOneContext(ctx, &sum, `
+		SELECT count(*) AS rows,
+			coalesce(sum(('x' || substr(md5(row_to_json(_rows)::text), 1, 16))::bit(64)::bigint::numeric), 0)::text AS sum
+		FROM (?) AS _rows`, orm.NewSelectQuery(query), query.TableModel()); err != nil {
+		return Checksum{}, err
+	}
+
+	return sum, nil
+}
@@ -0,0 +1,70 @@
+package persistsql
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"strconv"
+	"testing"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+func TestChecksumString(t *testing.T) {
+	if s := (Checksum{Rows: 2, Sum: "-42"}).String(); s != "2:-42" {
+		t.Errorf("String() = %s", s)
+	}
+}
+
+func TestTableChecksum(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*labelledOrder)(nil), (*trashedNote)(nil))
+
+	if sum, err := p.TableChecksum(ctx, (*labelledOrder)(nil), nil); err != nil || sum != (Checksum{Sum: "0"}) {
+		t.Errorf("TableChecksum() of an empty table = %v, %v", sum, err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, "INSERT INTO labelled_orders (id, total) VALUES (1, 10), (2, 20)"); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+
+	// Other systems compute it the same way.
+	var want int64
+	for _, row := range []string{`{"id":1,"total":10}`, `{"id":2,"total":20}`} {
+		digest := md5.Sum([]byte(row))
+		want += int64(binary.BigEndian.Uint64(digest[:8]))
+	}
+
+	sum, err := p.TableChecksum(ctx, (*labelledOrder)(nil), nil)
+	if err != nil || sum.Rows != 2 || sum.Sum != strconv.FormatInt(want, 10) {
+		t.Fatalf("TableChecksum() = %v, %v, want 2:%d", sum, err, want)
+	}
+
+	// The order of the rows doesn't matter.
+	if _, err := p.db.ExecContext(ctx, "TRUNCATE labelled_orders; INSERT INTO labelled_orders (id, total) VALUES (2, 20), (1, 10)"); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+
+	if again, err := p.TableChecksum(ctx, (*labelledOrder)(nil), nil); err != nil || again != sum {
+		t.Errorf("TableChecksum() of the rows inserted in another order = %v, %v, want %v", again, err, sum)
+	}
+
+	if first, err := p.TableChecksum(ctx, (*labelledOrder)(nil), func(query *orm.Query) { query.Where("id = 1") }); err != nil || first.Rows != 1 || first == sum {
+		t.Errorf("TableChecksum() of the first order = %v, %v", first, err)
+	}
+
+	// Soft-deleted rows are excluded.
+	created, err := p.CreateResource(ctx, &trashedNote{Folder: "inbox"})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+
+	if _, err := p.DeleteResource(ctx, created, nil); err != nil {
+		t.Fatalf("DeleteResource(): %v", err)
+	}
+
+	if deleted, err := p.TableChecksum(ctx, (*trashedNote)(nil), nil); err != nil || deleted.Rows != 0 {
+		t.Errorf("TableChecksum() of soft-deleted notes = %v, %v, want none", deleted, err)
+	}
+}
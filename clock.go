@@ -0,0 +1,114 @@
+package persistsql
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// Clock tells the time. All the timestamps written by a persistence layer come from its clock, see WithClock.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the Clock of the system.
+type systemClock struct{}
+
+// Now implements Clock.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithClock makes a persistence layer use clock instead of the system clock, e.g. a fake clock in tests.
+func WithClock(clock Clock) Option {
+	return func(p *SQL) {
+		p.clock = clock
+	}
+}
+
+type clockKey struct{}
+
+// ContextWithClock returns a copy of ctx making the persistence layers use clock instead of their own for the calls made with it.
+func ContextWithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+// Columns stamped with the time of creation and of the last update, the first present in a model is used.
+var (
+	createTimeColumns = []string{"create_time", "created_at"}
+	updateTimeColumns = []string{"update_time", "updated_at"}
+)
+
+// Now returns the current time of the clock of ctx, or else of p, as stamped by the writes, see WithClock and ContextWithClock.
+func (p *SQL) Now(ctx context.Context) time.Time {
+	return p.now(ctx)
+}
+
+// now returns the current time of the clock of ctx, or else of p, rounded to the microsecond precision of PostgreSQL.
+func (p *SQL) now(ctx context.Context) time.Time {
+	return nowOf(ctx, p.clock)
+}
+
+// nowOf returns the current time of the clock of ctx, or else of fallback, rounded as by SQL.now.
+// Migration steps, run without the persistence layer, get its clock from the context, see Migrator.
+func nowOf(ctx context.Context, fallback Clock) time.Time {
+	clock, ok := ctx.Value(clockKey{}).(Clock)
+	if !ok {
+		clock = fallback
+	}
+
+	return clock.Now().Round(time.Microsecond)
+}
+
+// timeField returns the first field of table among columns holding a time.Time, nil if there's none.
+// Fields are matched by column or, for columns renamed by a naming strategy, by the snake_case of their Go name.
+func timeField(table *orm.Table, columns []string) *orm.Field {
+	for _, col := range columns {
+		for _, field := range table.Fields {
+			if (field.SQLName == col || SnakeCase(field.GoName) == col) && field.Field.Type == reflect.TypeOf(time.Time{}) {
+				return field
+			}
+		}
+	}
+
+	return nil
+}
+
+// stamp sets the field of model among columns to now, unless it's set and keep is true.
+// It returns the SQL name of the field, empty if there's none.
+func stamp(model interface{}, columns []string, now time.Time, keep bool) string {
+	field := timeField(tableOf(model), columns)
+	if field == nil {
+		return ""
+	}
+
+	v := field.Value(reflect.Indirect(reflect.ValueOf(model)))
+	if !keep || v.Interface().(time.Time).IsZero() {
+		v.Set(reflect.ValueOf(now))
+	}
+
+	return field.SQLName
+}
+
+// markDeleted sets the soft delete field of model to now and returns its SQL name,
+// empty if model has no soft delete field or one of a type other than time.Time and int64.
+func markDeleted(model interface{}, now time.Time) string {
+	field := tableOf(model).SoftDeleteField
+	if field == nil {
+		return ""
+	}
+
+	v := field.Value(reflect.Indirect(reflect.ValueOf(model)))
+	switch {
+	case v.Type() == reflect.TypeOf(now):
+		v.Set(reflect.ValueOf(now))
+	case v.Kind() == reflect.Int64:
+		v.SetInt(now.UnixNano())
+	default:
+		return ""
+	}
+
+	return field.SQLName
+}
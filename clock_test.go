@@ -0,0 +1,96 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type stampedEvent struct {
+	ID        int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Deleted   int64 `pg:",soft_delete"`
+}
+
+type stampedOrder struct {
+	tableName struct{} `pg:"test_stamped_orders"`
+
+	model.Common
+	Total int
+}
+
+func TestSQLNow(t *testing.T) {
+	own := time.Date(2024, 1, 1, 12, 0, 0, 1500, time.UTC)
+	ctxTime := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if now := nowOf(context.Background(), fixedClock(own)); !now.Equal(own.Round(time.Microsecond)) || now.Nanosecond() != 2000 {
+		t.Errorf("nowOf() = %v, want %v rounded to the microsecond", now, own)
+	}
+
+	ctx := ContextWithClock(context.Background(), fixedClock(ctxTime))
+	if now := (&SQL{clock: fixedClock(own)}).Now(ctx); !now.Equal(ctxTime) {
+		t.Errorf("Now() = %v, want the clock of the context %v", now, ctxTime)
+	}
+}
+
+func TestStamp(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	created := now.Add(-time.Hour)
+
+	event := &stampedEvent{CreatedAt: created}
+	if col := stamp(event, createTimeColumns, now, true); col != "created_at" || !event.CreatedAt.Equal(created) {
+		t.Errorf("stamp(keep) = %s, CreatedAt %v, want it kept", col, event.CreatedAt)
+	}
+
+	if col := stamp(event, updateTimeColumns, now, false); col != "updated_at" || !event.UpdatedAt.Equal(now) {
+		t.Errorf("stamp() = %s, UpdatedAt %v, want %v", col, event.UpdatedAt, now)
+	}
+
+	if col := stamp(&labelledOrder{}, createTimeColumns, now, false); col != "" {
+		t.Errorf("stamp() without time column = %s", col)
+	}
+
+	if col := markDeleted(event, now); col != "deleted" || event.Deleted != now.UnixNano() {
+		t.Errorf("markDeleted() = %s, Deleted %d, want the nanoseconds of now", col, event.Deleted)
+	}
+
+	order := &stampedOrder{}
+	if col := markDeleted(order, now); col != "delete_time" || !order.DeleteTime.Equal(now) {
+		t.Errorf("markDeleted() = %s, DeleteTime %v", col, order.DeleteTime)
+	}
+
+	if col := markDeleted(&labelledOrder{}, now); col != "" {
+		t.Errorf("markDeleted() without soft delete = %s", col)
+	}
+}
+
+func TestClockStamps(t *testing.T) {
+	ctx := context.Background()
+	clockTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := testSQL(t, WithClock(fixedClock(clockTime)))
+	testTables(t, p.db, (*stampedOrder)(nil))
+
+	created, err := p.CreateResource(ctx, &stampedOrder{Total: 1})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	order := created.(*stampedOrder)
+
+	if !order.CreateTime.Equal(clockTime) || !order.UpdateTime.Equal(clockTime) {
+		t.Errorf("CreateResource() stamped %v, %v, want %v", order.CreateTime, order.UpdateTime, clockTime)
+	}
+
+	later := clockTime.Add(time.Hour)
+	order.Total = 2
+	updated, err := p.UpdateResource(ContextWithClock(ctx, fixedClock(later)), order, []string{"total"}, nil)
+	if err != nil {
+		t.Fatalf("UpdateResource(): %v", err)
+	}
+
+	if order := updated.(*stampedOrder); !order.CreateTime.Equal(clockTime) || !order.UpdateTime.Equal(later) {
+		t.Errorf("UpdateResource() stamped %v, %v, want %v, %v", order.CreateTime, order.UpdateTime, clockTime, later)
+	}
+}
@@ -0,0 +1,10 @@
+// Command persistsqlctl administers the database of a persistsql persistence layer. This generic build declares no models
+// nor migrations, so it's mostly useful for diagnostics and gen-models; applications build their own tool declaring theirs,
+// see package persistsqlctl.
+package main
+
+import "github.com/chi07/persistsql/persistsqlctl"
+
+func main() {
+	persistsqlctl.Main(persistsqlctl.App{})
+}
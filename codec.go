@@ -0,0 +1,204 @@
+package persistsql
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// Codec encodes struct field values into columns, see RegisterCodec.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// jsonCodec is the Codec of encoding/json.
+type jsonCodec struct{}
+
+// Marshal implements Codec.
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal implements Codec.
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+var codecs = struct {
+	sync.RWMutex
+	byName map[string]Codec
+}{byName: map[string]Codec{"json": jsonCodec{}, "raw": rawCodec{}}}
+
+// RegisterCodec makes codec available to fields under name, to be called before registering the models using it, see Register, e.g. "protojson" or "msgpack"; "json", encoding/json, is built in,
+// as is "raw", storing a []byte or a string field unchanged.
+//
+// A column field tagged `codec:"name,Field"` stores the value of the struct field Field, usually tagged `pg:"-"`, encoded with the codec:
+// a json.RawMessage or string for a jsonb or text column, a []byte for a bytea one. CreateResource and UpdateResource encode
+// the value, listing the column in the fields of UpdateResource updates it; reads decode it.
+//
+// A codec name suffixed with +gzip or +zstd, e.g. `codec:"json+zstd,Payload"` or `codec:"raw+zstd,Log"`, compresses the encoded values
+// into a bytea column, behind a format header. Values shorter than 1KiB are stored uncompressed; values without the header,
+// e.g. written before compression was enabled, are decoded as is.
+func RegisterCodec(name string, codec Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	codecs.byName[name] = codec
+}
+
+// codecByName returns the codec registered under name, compressed if name ends with +gzip or +zstd.
+func codecByName(name string) (Codec, error) {
+	if codec, ok, err := compressedCodecByName(name); ok {
+		return codec, err
+	}
+
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	codec, ok := codecs.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec %q", name)
+	}
+
+	return codec, nil
+}
+
+// codedField is a column storing the value of another field encoded by a codec.
+type codedField struct {
+	column *orm.Field
+	value  reflect.StructField
+	codec  string
+}
+
+// codedFields returns the coded fields of table, declared by `codec` tags, and an error if a tag names an unknown codec or field,
+// or is on a field other than a string or a []byte.
+func codedFields(table *orm.Table) ([]codedField, error) {
+	var coded []codedField
+	for _, field := range table.Fields {
+		tag, ok := field.Field.Tag.Lookup("codec")
+		if !ok {
+			continue
+		}
+
+		if t := field.Field.Type; t.Kind() != reflect.String && (t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uint8) {
+			return nil, fmt.Errorf("%s.%s: codec column of type %s, want a string or a []byte", table.TypeName, field.GoName, t)
+		}
+
+		name, goName := tag, ""
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			name, goName = tag[:i], tag[i+1:]
+		}
+
+		if _, err := codecByName(name); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", table.TypeName, field.GoName, err)
+		}
+
+		value, ok := table.Type.FieldByName(goName)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s: codec field %q not found", table.TypeName, field.GoName, goName)
+		}
+
+		coded = append(coded, codedField{column: field, value: value, codec: name})
+	}
+
+	return coded, nil
+}
+
+// encode sets the column of f in strct to its encoded value.
+func (f codedField) encode(strct reflect.Value) error {
+	codec, err := codecByName(f.codec)
+	if err != nil {
+		return err
+	}
+
+	data, err := codec.Marshal(strct.FieldByIndex(f.value.Index).Interface())
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.column.SQLName, err)
+	}
+
+	column := f.column.Value(strct)
+	if column.Kind() == reflect.String {
+		column.SetString(string(data))
+	} else {
+		column.SetBytes(data)
+	}
+
+	return nil
+}
+
+// decode sets the value of f in strct from its column, to the zero value if the column is empty.
+func (f codedField) decode(strct reflect.Value) error {
+	codec, err := codecByName(f.codec)
+	if err != nil {
+		return err
+	}
+
+	column := f.column.Value(strct)
+
+	var data []byte
+	if column.Kind() == reflect.String {
+		data = []byte(column.String())
+	} else {
+		data = column.Bytes()
+	}
+
+	value := strct.FieldByIndex(f.value.Index)
+	value.Set(reflect.Zero(value.Type()))
+	if len(data) == 0 {
+		return nil
+	}
+
+	if err := codec.Unmarshal(data, value.Addr().Interface()); err != nil {
+		return fmt.Errorf("%s: %w", f.column.SQLName, err)
+	}
+
+	return nil
+}
+
+// encodeFields encodes the coded fields of model, a pointer to a model or to a slice of models.
+func (p *SQL) encodeFields(model interface{}) error {
+	info := p.model(model)
+	if info.err != nil {
+		return info.err
+	}
+
+	coded := info.coded
+	if len(coded) == 0 {
+		return nil
+	}
+
+	return eachStruct(model, func(strct reflect.Value) error {
+		for _, f := range coded {
+			if err := f.encode(strct); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// eachStruct calls fn with each struct of model, a pointer to a model or to a slice of models or of pointers to models.
+func eachStruct(model interface{}, fn func(strct reflect.Value) error) error {
+	v := reflect.Indirect(reflect.ValueOf(model))
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Slice {
+		return fn(v)
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		if err := fn(reflect.Indirect(v.Index(i))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package persistsql
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type codedSettings struct {
+	Theme string
+}
+
+type codedAccount struct {
+	Settings     json.RawMessage `codec:"json,SettingsValue"`
+	SettingsText string          `codec:"json,TextValue"`
+
+	SettingsValue codedSettings `pg:"-"`
+	TextValue     codedSettings `pg:"-"`
+}
+
+type codedMap struct {
+	Settings map[string]string `codec:"json,Value"`
+	Value    codedSettings     `pg:"-"`
+}
+
+type codedUnknown struct {
+	Settings []byte `codec:"nope,Value"`
+	Value    string `pg:"-"`
+}
+
+func TestCodedFields(t *testing.T) {
+	coded, err := codedFields(tableOf((*codedAccount)(nil)))
+	if err != nil || len(coded) != 2 {
+		t.Fatalf("codedFields() = %v, %v, want 2 fields", coded, err)
+	}
+
+	account := &codedAccount{SettingsValue: codedSettings{Theme: "dark"}, TextValue: codedSettings{Theme: "light"}}
+	strct := reflect.ValueOf(account).Elem()
+	for _, f := range coded {
+		if err := f.encode(strct); err != nil {
+			t.Fatalf("encode(%s): %v", f.column.SQLName, err)
+		}
+	}
+
+	if string(account.Settings) != `{"Theme":"dark"}` || account.SettingsText != `{"Theme":"light"}` {
+		t.Errorf("encoded columns = %s, %s", account.Settings, account.SettingsText)
+	}
+
+	account.SettingsValue, account.TextValue = codedSettings{}, codedSettings{}
+	for _, f := range coded {
+		if err := f.decode(strct); err != nil {
+			t.Fatalf("decode(%s): %v", f.column.SQLName, err)
+		}
+	}
+
+	if account.SettingsValue.Theme != "dark" || account.TextValue.Theme != "light" {
+		t.Errorf("decoded values = %+v, %+v", account.SettingsValue, account.TextValue)
+	}
+
+	for _, model := range []interface{}{(*codedMap)(nil), (*codedUnknown)(nil)} {
+		if _, err := codedFields(tableOf(model)); err == nil {
+			t.Errorf("codedFields(%T) succeeded", model)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package persistsql
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression format header: compressedMagic, never the first byte of UTF-8 text, then the algorithm.
+const (
+	compressedMagic = 0xc0
+
+	compressNone = 0
+	compressGzip = 1
+	compressZstd = 2
+)
+
+// compressMinSize is the size under which encoded values are stored uncompressed, well below the TOAST threshold.
+const compressMinSize = 1024
+
+var compressions = map[string]byte{"gzip": compressGzip, "zstd": compressZstd}
+
+// Shared zstd encoder and decoder, safe for concurrent use with EncodeAll and DecodeAll.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// compressedCodec is a Codec compressing the encodings of codec behind a format header.
+type compressedCodec struct {
+	codec     Codec
+	algorithm byte
+}
+
+// Marshal implements Codec.
+func (c compressedCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < compressMinSize {
+		return append([]byte{compressedMagic, compressNone}, data...), nil
+	}
+
+	header := []byte{compressedMagic, c.algorithm}
+	if c.algorithm == compressZstd {
+		return zstdEncoder.EncodeAll(data, header), nil
+	}
+
+	buf := bytes.NewBuffer(header)
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal implements Codec.
+func (c compressedCodec) Unmarshal(data []byte, v interface{}) error {
+	data, err := decompress(data)
+	if err != nil {
+		return err
+	}
+
+	return c.codec.Unmarshal(data, v)
+}
+
+// decompress returns data without its format header, decompressed.
+func decompress(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != compressedMagic {
+		return data, nil
+	}
+
+	switch data[1] {
+	case compressNone:
+		return data[2:], nil
+	case compressGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data[2:]))
+		if err != nil {
+			return nil, err
+		}
+
+		return io.ReadAll(r)
+	case compressZstd:
+		return zstdDecoder.DecodeAll(data[2:], nil)
+	}
+
+	return nil, fmt.Errorf("unknown compression %d", data[1])
+}
+
+// compressedCodecByName returns the compressedCodec for a name of the form codec+algorithm, false if name has no algorithm.
+func compressedCodecByName(name string) (Codec, bool, error) {
+	i := strings.LastIndexByte(name, '+')
+	if i < 0 {
+		return nil, false, nil
+	}
+
+	algorithm, ok := compressions[name[i+1:]]
+	if !ok {
+		return nil, true, fmt.Errorf("unknown compression %q", name[i+1:])
+	}
+
+	codec, err := codecByName(name[:i])
+	if err != nil {
+		return nil, true, err
+	}
+
+	return compressedCodec{codec: codec, algorithm: algorithm}, true, nil
+}
+
+// rawCodec is the Codec storing []byte and string values unchanged.
+type rawCodec struct{}
+
+// Marshal implements Codec.
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.String:
+		return []byte(rv.String()), nil
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		return rv.Bytes(), nil
+	}
+
+	return nil, fmt.Errorf("raw codec can't encode %T", v)
+}
+
+// Unmarshal implements Codec.
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr {
+		return fmt.Errorf("raw codec can't decode into %T", v)
+	}
+
+	rv = rv.Elem()
+	switch {
+	case rv.Kind() == reflect.String:
+		rv.SetString(string(data))
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		rv.SetBytes(append([]byte(nil), data...))
+	default:
+		return fmt.Errorf("raw codec can't decode into %T", v)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package persistsql
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql/model"
+)
+
+func TestCompressedCodec(t *testing.T) {
+	large := strings.Repeat("GET /orders 200\n", 200)
+
+	for _, name := range []string{"raw+gzip", "raw+zstd", "json+zstd"} {
+		codec, err := codecByName(name)
+		if err != nil {
+			t.Fatalf("codecByName(%s): %v", name, err)
+		}
+
+		for _, value := range []string{"short", large} {
+			data, err := codec.Marshal(value)
+			if err != nil {
+				t.Fatalf("%s: Marshal(): %v", name, err)
+			}
+
+			if data[0] != compressedMagic || len(value) >= compressMinSize && (data[1] == compressNone || len(data) >= len(value)) {
+				t.Errorf("%s: Marshal() of %d bytes = %d bytes with header %x", name, len(value), len(data), data[:2])
+			}
+
+			var got string
+			if err := codec.Unmarshal(data, &got); err != nil || got != value {
+				t.Errorf("%s: Unmarshal() = %.20q, %v, want the value", name, got, err)
+			}
+		}
+	}
+
+	// Values stored before compression was enabled are read as is.
+	var got string
+	if err := (compressedCodec{codec: rawCodec{}, algorithm: compressZstd}).Unmarshal([]byte("legacy"), &got); err != nil || got != "legacy" {
+		t.Errorf("Unmarshal() of an uncompressed value = %q, %v", got, err)
+	}
+
+	if _, err := decompress([]byte{compressedMagic, 9, 1}); err == nil {
+		t.Error("decompress() of an unknown algorithm succeeded")
+	}
+
+	if _, err := codecByName("raw+lz4"); err == nil {
+		t.Error("codecByName(raw+lz4) succeeded")
+	}
+
+	if _, ok, err := compressedCodecByName("json"); ok || err != nil {
+		t.Errorf("compressedCodecByName(json) = %v, %v, want no compression", ok, err)
+	}
+}
+
+func TestRawCodec(t *testing.T) {
+	if data, err := (rawCodec{}).Marshal([]byte("abc")); err != nil || string(data) != "abc" {
+		t.Errorf("Marshal([]byte) = %s, %v", data, err)
+	}
+
+	if _, err := (rawCodec{}).Marshal(42); err == nil {
+		t.Error("Marshal(int) succeeded")
+	}
+
+	var b []byte
+	if err := (rawCodec{}).Unmarshal([]byte("abc"), &b); err != nil || string(b) != "abc" {
+		t.Errorf("Unmarshal([]byte) = %s, %v", b, err)
+	}
+
+	var n int
+	if err := (rawCodec{}).Unmarshal([]byte("abc"), &n); err == nil {
+		t.Error("Unmarshal(int) succeeded")
+	}
+}
+
+type compressedLog struct {
+	tableName struct{} `pg:"test_compressed_logs"`
+
+	model.Common
+	Payload []byte `codec:"raw+zstd,Log"`
+
+	Log string `pg:"-"`
+}
+
+func TestCompressedColumn(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*compressedLog)(nil))
+
+	log := strings.Repeat("GET /orders 200\n", 1000)
+	created, err := p.CreateResource(ctx, &compressedLog{Log: log})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	id := created.(*compressedLog).ID
+
+	var stored []byte
+	if _, err := p.db.QueryOneContext(ctx, pg.Scan(&stored), "SELECT payload FROM test_compressed_logs WHERE id = ?", id); err != nil {
+		t.Fatalf("SELECT: %v", err)
+	}
+
+	if !bytes.HasPrefix(stored, []byte{compressedMagic, compressZstd}) || len(stored) >= len(log) {
+		t.Errorf("stored %d bytes for %d, want them compressed with zstd", len(stored), len(log))
+	}
+
+	got, err := p.GetResource(ctx, &compressedLog{}, false, func(query *orm.Query) { query.Where("id = ?", id) })
+	if err != nil {
+		t.Fatalf("GetResource(): %v", err)
+	}
+
+	if read, _ := got.(*compressedLog); read == nil || read.Log != log {
+		t.Errorf("GetResource() didn't decompress the log")
+	}
+}
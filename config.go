@@ -0,0 +1,204 @@
+package persistsql
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// configEnvPrefix prefixes the environment variables overriding Config fields.
+const configEnvPrefix = "PERSISTSQL_"
+
+// Duration is a time.Duration read from configuration as a string such as "5s".
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string, or a number of nanoseconds.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var ns int64
+		if err := json.Unmarshal(b, &ns); err != nil {
+			return fmt.Errorf("invalid duration %s", b)
+		}
+		*d = Duration(ns)
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+
+	return nil
+}
+
+// MarshalJSON formats the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Config is the configuration of an SQL persistence layer and its databases for one environment.
+// Each field can be overridden by the environment variable named PERSISTSQL_ followed by its upper-cased JSON name,
+// e.g. PERSISTSQL_POOL_SIZE; lists are comma-separated.
+type Config struct {
+	// Addr of the primary database, host:port
+	Addr     string `json:"addr"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+	// Replicas are the addresses of read replicas, sharing the credentials of the primary.
+	Replicas []string `json:"replicas"`
+
+	// PoolSize is the maximum number of connections per database
+	PoolSize     int      `json:"pool_size"`
+	MinIdleConns int      `json:"min_idle_conns"`
+	DialTimeout  Duration `json:"dial_timeout"`
+	ReadTimeout  Duration `json:"read_timeout"`
+	WriteTimeout Duration `json:"write_timeout"`
+	PoolTimeout  Duration `json:"pool_timeout"`
+	IdleTimeout  Duration `json:"idle_timeout"`
+
+	// TLS enables TLS connections
+	TLS                   bool   `json:"tls"`
+	TLSServerName         string `json:"tls_server_name"`
+	TLSInsecureSkipVerify bool   `json:"tls_insecure_skip_verify"`
+
+	// Maintenance starts the persistence layer in maintenance mode
+	Maintenance bool `json:"maintenance"`
+	// MaintenanceBlockWrites also rejects writes in maintenance mode
+	MaintenanceBlockWrites bool `json:"maintenance_block_writes"`
+}
+
+// LoadConfig loads the configuration of the environment env.
+// The file at path, if not empty, is a JSON object mapping environment names to configurations:
+// the "default" one applies to all environments, the one named env overrides it field by field.
+// An empty env is read from PERSISTSQL_ENV. Environment variables override the file, see Config.
+func LoadConfig(path string, env string) (Config, error) {
+	var cfg Config
+	if env == "" {
+		env = os.Getenv(configEnvPrefix + "ENV")
+	}
+
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+
+		var profiles map[string]json.RawMessage
+		if err := json.Unmarshal(b, &profiles); err != nil {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+
+		for _, name := range []string{"default", env} {
+			if profile, ok := profiles[name]; ok && name != "" {
+				if err := json.Unmarshal(profile, &cfg); err != nil {
+					return cfg, fmt.Errorf("%s: profile %s: %w", path, name, err)
+				}
+			}
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// loadEnv overrides the fields of cfg with the environment variables set.
+func (cfg *Config) loadEnv() error {
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := configEnvPrefix + strings.ToUpper(field.Tag.Get("json"))
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		var err error
+		switch dst := v.Field(i).Addr().Interface().(type) {
+		case *string:
+			*dst = value
+		case *[]string:
+			*dst = strings.Split(value, ",")
+		case *int:
+			*dst, err = strconv.Atoi(value)
+		case *bool:
+			*dst, err = strconv.ParseBool(value)
+		case *Duration:
+			var d time.Duration
+			d, err = time.ParseDuration(value)
+			*dst = Duration(d)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Options returns the go-pg options to connect to the database at addr.
+func (cfg Config) Options(addr string) *pg.Options {
+	opt := &pg.Options{
+		Addr:         addr,
+		User:         cfg.User,
+		Password:     cfg.Password,
+		Database:     cfg.Database,
+		PoolSize:     cfg.PoolSize,
+		MinIdleConns: cfg.MinIdleConns,
+		DialTimeout:  time.Duration(cfg.DialTimeout),
+		ReadTimeout:  time.Duration(cfg.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.WriteTimeout),
+		PoolTimeout:  time.Duration(cfg.PoolTimeout),
+		IdleTimeout:  time.Duration(cfg.IdleTimeout),
+	}
+
+	if cfg.TLS {
+		opt.TLSConfig = &tls.Config{
+			ServerName:         cfg.TLSServerName,
+			InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+		}
+	}
+
+	return opt
+}
+
+// Open connects to the configured databases and returns the persistence layer using them.
+// Extra options are applied after the configured ones.
+func (cfg Config) Open(opts ...Option) (*SQL, error) {
+	db := pg.Connect(cfg.Options(cfg.Addr))
+
+	var replicas []*pg.DB
+	for _, addr := range cfg.Replicas {
+		replicas = append(replicas, pg.Connect(cfg.Options(addr)))
+	}
+
+	p, err := New(db, append([]Option{WithReplicas(replicas...)}, opts...)...)
+	if err != nil {
+		db.Close()
+		for _, replica := range replicas {
+			replica.Close()
+		}
+
+		return nil, err
+	}
+
+	if cfg.Maintenance {
+		p.StartMaintenance(cfg.MaintenanceBlockWrites)
+	}
+
+	return p, nil
+}
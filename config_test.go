@@ -0,0 +1,131 @@
+package persistsql
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+func TestDurationJSON(t *testing.T) {
+	var d Duration
+	if err := json.Unmarshal([]byte(`"1m30s"`), &d); err != nil || time.Duration(d) != 90*time.Second {
+		t.Errorf("Unmarshal(1m30s) = %v, %v", time.Duration(d), err)
+	}
+
+	if err := json.Unmarshal([]byte(`1000`), &d); err != nil || time.Duration(d) != time.Microsecond {
+		t.Errorf("Unmarshal(1000) = %v, %v", time.Duration(d), err)
+	}
+
+	for _, b := range []string{`"soon"`, `true`} {
+		if err := json.Unmarshal([]byte(b), &d); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded", b)
+		}
+	}
+
+	if b, err := json.Marshal(Duration(5 * time.Second)); err != nil || string(b) != `"5s"` {
+		t.Errorf("Marshal() = %s, %v", b, err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "persistsql.json")
+	profiles := `{
+		"default": {"addr": "db:5432", "user": "app", "database": "orders", "pool_size": 10, "read_timeout": "5s"},
+		"staging": {"addr": "staging-db:5432", "replicas": ["staging-replica:5432"], "tls": true}
+	}`
+	if err := os.WriteFile(path, []byte(profiles), 0o600); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+
+	t.Setenv("PERSISTSQL_ENV", "staging")
+	t.Setenv("PERSISTSQL_POOL_SIZE", "20")
+	t.Setenv("PERSISTSQL_REPLICAS", "replica-a:5432,replica-b:5432")
+	t.Setenv("PERSISTSQL_MAINTENANCE", "true")
+
+	cfg, err := LoadConfig(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfig(): %v", err)
+	}
+
+	if cfg.Addr != "staging-db:5432" || cfg.User != "app" || cfg.Database != "orders" || !cfg.TLS {
+		t.Errorf("LoadConfig() = %+v, want the staging profile over the default one", cfg)
+	}
+
+	if cfg.PoolSize != 20 || len(cfg.Replicas) != 2 || cfg.Replicas[1] != "replica-b:5432" || !cfg.Maintenance {
+		t.Errorf("LoadConfig() = %+v, want the environment over the file", cfg)
+	}
+
+	if time.Duration(cfg.ReadTimeout) != 5*time.Second {
+		t.Errorf("ReadTimeout = %v, want 5s", time.Duration(cfg.ReadTimeout))
+	}
+
+	t.Setenv("PERSISTSQL_POOL_SIZE", "many")
+	if _, err := LoadConfig(path, "staging"); err == nil {
+		t.Error("LoadConfig() with an invalid PERSISTSQL_POOL_SIZE succeeded")
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json"), "staging"); err == nil {
+		t.Error("LoadConfig() of a missing file succeeded")
+	}
+}
+
+func TestConfigOptions(t *testing.T) {
+	cfg := Config{User: "app", Database: "orders", PoolSize: 5, DialTimeout: Duration(time.Second)}
+
+	opt := cfg.Options("replica:5432")
+	if opt.Addr != "replica:5432" || opt.User != "app" || opt.PoolSize != 5 || opt.DialTimeout != time.Second || opt.TLSConfig != nil {
+		t.Errorf("Options() = %+v", opt)
+	}
+
+	cfg.TLS, cfg.TLSServerName = true, "db.internal"
+	if opt := cfg.Options("db:5432"); opt.TLSConfig == nil || opt.TLSConfig.ServerName != "db.internal" {
+		t.Errorf("Options() TLS = %+v, want server name db.internal", opt.TLSConfig)
+	}
+}
+
+func TestConfigOpen(t *testing.T) {
+	url := os.Getenv(testDatabaseEnv)
+	if url == "" {
+		t.Skipf("%s not set", testDatabaseEnv)
+	}
+
+	opt, err := pg.ParseURL(url)
+	if err != nil {
+		t.Fatalf("pg.ParseURL(): %v", err)
+	}
+
+	cfg := Config{
+		Addr:                   opt.Addr,
+		User:                   opt.User,
+		Password:               opt.Password,
+		Database:               opt.Database,
+		Replicas:               []string{opt.Addr},
+		TLS:                    opt.TLSConfig != nil,
+		TLSInsecureSkipVerify:  opt.TLSConfig != nil && opt.TLSConfig.InsecureSkipVerify,
+		Maintenance:            true,
+		MaintenanceBlockWrites: true,
+	}
+
+	p, err := cfg.Open()
+	if err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	t.Cleanup(func() {
+		_ = p.db.Close()
+		for _, replica := range p.replicas.dbs {
+			_ = replica.Close()
+		}
+	})
+
+	if active, blockWrites := p.Maintenance(); !active || !blockWrites {
+		t.Errorf("Maintenance() = %v, %v, want both", active, blockWrites)
+	}
+
+	if err := p.db.Ping(p.db.Context()); err != nil {
+		t.Errorf("Ping(): %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package persistsql
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// defaultBatchSize is the number of rows updated per batch when none is configured.
+const defaultBatchSize = 1000
+
+// migrationProgress records the last primary key processed by a DataMigration.
+type migrationProgress struct {
+	tableName struct{} `pg:"schema_migration_progress"`
+
+	Name      string    `pg:",pk"`
+	LastKey   string    `pg:",notnull,use_zero"`
+	UpdatedAt time.Time `pg:",notnull"`
+}
+
+// DataMigration is a migration step updating the rows of Table in primary key order, one batch per transaction.
+// The last key processed is saved with each batch so an interrupted run resumes after it.
+// It runs outside of the migration transaction.
+type DataMigration struct {
+	// Name identifies the saved progress, it must be unique across data migrations.
+	Name string
+	// Table to update
+	Table string
+	// PK is the primary key column of the table, defaults to "id".
+	PK string
+	// Set is the SET clause applied to each row, e.g. "total = price * quantity".
+	Set string
+	// Where optionally restricts the rows updated
+	Where string
+	// BatchSize is the number of rows updated per transaction, defaults to 1000.
+	BatchSize int
+	// Pause is waited between batches to throttle the load on the database
+	Pause time.Duration
+}
+
+// Apply updates the rows in batches, resuming after the last saved key.
+func (s DataMigration) Apply(ctx context.Context, db orm.DB) error {
+	if err := db.ModelContext(ctx, (*migrationProgress)(nil)).CreateTable(&orm.CreateTableOptions{IfNotExists: true}); err != nil {
+		return err
+	}
+
+	progress := &migrationProgress{Name: s.Name}
+	var last *string
+	if err := db.ModelContext(ctx, progress).WherePK().Select(); err == nil {
+		last = &progress.LastKey
+	} else if err != pg.ErrNoRows {
+		return err
+	}
+
+	for {
+		var done bool
+		if err := inTransaction(ctx, db, func(db orm.DB) error {
+			count, key, err := s.batch(ctx, db, last)
+			if err != nil {
+				return err
+			}
+
+			if count < s.batchSize() {
+				done = true
+				_, err = db.ModelContext(ctx, progress).WherePK().Delete()
+				return err
+			}
+
+			last = key
+			progress.LastKey = *key
+			progress.UpdatedAt = nowOf(ctx, systemClock{})
+			_, err = db.ModelContext(ctx, progress).OnConflict("(name) DO UPDATE").Insert()
+			return err
+		}); err != nil {
+			return err
+		}
+
+		if done {
+			return nil
+		}
+
+		if s.Pause > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.Pause):
+			}
+		}
+	}
+}
+
+// NonTransactional always returns true so each batch commits on its own.
+func (s DataMigration) NonTransactional() bool {
+	return true
+}
+
+// Statements returns the statement run for the first batch, the next ones select the rows after the last key updated.
+func (s DataMigration) Statements() []string {
+	return []string{s.query(pg.Safe("TRUE"))}
+}
+
+// batch updates the rows following the key last, or the first rows if nil.
+// It returns the number of rows updated and the greatest key among them.
+func (s DataMigration) batch(ctx context.Context, db orm.DB, last *string) (int, *string, error) {
+	after := pg.Safe("TRUE")
+	if last != nil {
+		after = pg.Safe(formatQuery("? > ?", pg.Ident(s.pk()), *last))
+	}
+
+	var batch struct {
+		Count int
+		Last  *string
+	}
+	// The query is formatted already, the ? of Set and Where, e.g. the jsonb operator, mustn't be taken for placeholders.
+	if _, err := db.QueryOneContext(ctx, &batch, s.query(after)); err != nil {
+		return 0, nil, err
+	}
+
+	return batch.Count, batch.Last, nil
+}
+
+// query returns the statement updating one batch of the rows matching after.
+// The last key is selected rather than aggregated, max isn't defined on all key types, e.g. uuid.
+func (s DataMigration) query(after pg.Safe) string {
+	where := pg.Safe("TRUE")
+	if s.Where != "" {
+		where = pg.Safe(s.Where)
+	}
+
+	return formatQuery(`WITH batch AS (SELECT ?0 FROM ?1 WHERE ?5 AND (?2) ORDER BY ?0 LIMIT ?3),
+updated AS (UPDATE ?1 AS t SET ?4 FROM batch WHERE t.?0 = batch.?0 RETURNING t.?0)
+SELECT (SELECT count(*) FROM updated) AS count, (SELECT ?0::text FROM updated ORDER BY ?0 DESC LIMIT 1) AS last`,
+		pg.Ident(s.pk()), pg.Ident(s.Table), where, s.batchSize(), pg.Safe(s.Set), after)
+}
+
+func (s DataMigration) pk() string {
+	if s.PK == "" {
+		return "id"
+	}
+
+	return s.PK
+}
+
+func (s DataMigration) batchSize() int {
+	if s.BatchSize <= 0 {
+		return defaultBatchSize
+	}
+
+	return s.BatchSize
+}
+
+// inTransaction runs fn in a transaction on db, or in the current one if db already is a transaction.
+func inTransaction(ctx context.Context, db orm.DB, fn func(db orm.DB) error) error {
+	switch db := db.(type) {
+	case *pg.DB:
+		return db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+			return fn(tx)
+		})
+	case *pg.Conn:
+		return db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+			return fn(tx)
+		})
+	default:
+		return fn(db)
+	}
+}
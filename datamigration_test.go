@@ -0,0 +1,75 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type migratedWidget struct {
+	tableName struct{} `pg:"test_migrated_widgets"`
+
+	model.Common
+	Name  string `pg:",notnull"`
+	Label string
+}
+
+func TestDataMigrationUUIDKey(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t)
+
+	if err := db.ModelContext(ctx, (*migratedWidget)(nil)).CreateTable(&orm.CreateTableOptions{IfNotExists: true}); err != nil {
+		t.Fatalf("CreateTable(): %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.ModelContext(ctx, (*migratedWidget)(nil)).DropTable(&orm.DropTableOptions{IfExists: true})
+	})
+
+	names := []string{"a", "b", "c", "d", "e"}
+	for _, name := range names {
+		if _, err := db.ExecContext(ctx, "INSERT INTO test_migrated_widgets (id, create_time, update_time, name) VALUES (gen_random_uuid(), now(), now(), ?)",
+			name); err != nil {
+			t.Fatalf("insert %s: %v", name, err)
+		}
+	}
+
+	step := DataMigration{Name: "test_migrated_widgets_label", Table: "test_migrated_widgets", Set: "label = upper(name)", BatchSize: 2}
+	if err := step.Apply(ctx, db); err != nil {
+		t.Fatalf("Apply(): %v", err)
+	}
+
+	var unlabelled int
+	if _, err := db.QueryOneContext(ctx, pg.Scan(&unlabelled), "SELECT count(*) FROM test_migrated_widgets WHERE label IS DISTINCT FROM upper(name)"); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+
+	if unlabelled != 0 {
+		t.Errorf("%d of %d rows not migrated", unlabelled, len(names))
+	}
+}
+
+func TestDataMigrationQuery(t *testing.T) {
+	step := DataMigration{Table: "widgets", Set: "flags = flags - 'legacy'", Where: "flags ? 'legacy'", BatchSize: 10}
+
+	got := step.query(pg.Safe(formatQuery("? > ?", pg.Ident("id"), "k?")))
+	want := `WITH batch AS (SELECT "id" FROM "widgets" WHERE "id" > 'k?' AND (flags ? 'legacy') ORDER BY "id" LIMIT 10),
+updated AS (UPDATE "widgets" AS t SET flags = flags - 'legacy' FROM batch WHERE t."id" = batch."id" RETURNING t."id")
+SELECT (SELECT count(*) FROM updated) AS count, (SELECT "id"::text FROM updated ORDER BY "id" DESC LIMIT 1) AS last`
+	if got != want {
+		t.Errorf("query() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNowOf(t *testing.T) {
+	stamped := time.Date(2000, 1, 2, 3, 4, 5, 6789, time.UTC)
+	ctx := ContextWithClock(context.Background(), fixedClock(stamped))
+
+	if got := nowOf(ctx, systemClock{}); !got.Equal(stamped.Round(time.Microsecond)) {
+		t.Errorf("nowOf() = %v, want the clock of the context", got)
+	}
+}
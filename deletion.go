@@ -0,0 +1,173 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// ErrNotPendingDeletion is returned when confirming or cancelling the deletion of a resource which isn't pending deletion,
+// or whose pending deletion expired.
+var ErrNotPendingDeletion = errors.New("resource is not pending deletion")
+
+// pendingDeletion records a resource marked for deletion.
+type pendingDeletion struct {
+	tableName struct{} `pg:"pending_deletions"`
+
+	Collection string    `pg:",pk"`
+	Key        string    `pg:",pk"`
+	ExpiresAt  time.Time `pg:",notnull"`
+}
+
+// newPendingDeletion returns the pending deletion record of resource.
+func newPendingDeletion(resource Resource) *pendingDeletion {
+	return &pendingDeletion{
+		Collection: unqualifiedName(tableOf(resource)),
+		Key:        primaryKey(resource),
+	}
+}
+
+// MarkForDeletion puts a resource, identified by its primary key, in the pending deletion state until ttl elapses.
+// The resource itself is left untouched: ConfirmDeletion then deletes it, while CancelDeletion or expiry revert it.
+// Marking a resource already pending deletion extends the expiry. The expiry is returned, the zero time if the resource doesn't exist.
+func (p *SQL) MarkForDeletion(ctx context.Context, resource Resource, ttl time.Duration) (time.Time, error) {
+	if err := p.checkWrite(); err != nil {
+		return time.Time{}, err
+	}
+
+	pending := newPendingDeletion(resource)
+	pending.ExpiresAt = p.now(ctx).Add(ttl)
+
+	if err := p.runInTransaction(ctx, func(tx *pg.Tx) error {
+		if err := p.ensureTable(ctx, pending); err != nil {
+			return err
+		}
+
+		exists, err := tx.Model(resource).WherePK().Exists()
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return pg.ErrNoRows
+		}
+
+		_, err = tx.Model(pending).OnConflict("(collection, key) DO UPDATE").Insert()
+		return err
+	}); err != nil {
+		if err == pg.ErrNoRows {
+			return time.Time{}, nil
+		}
+
+		return time.Time{}, err
+	}
+
+	return pending.ExpiresAt, nil
+}
+
+// PendingDeletion returns when the pending deletion of a resource, identified by its primary key, expires.
+// The zero time is returned if the resource isn't pending deletion.
+func (p *SQL) PendingDeletion(ctx context.Context, resource Resource) (time.Time, error) {
+	pending := newPendingDeletion(resource)
+	if err := p.ensureTable(ctx, pending); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := p.conn().ModelContext(ctx, pending).WherePK().Where("expires_at > ?", p.now(ctx)).Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return time.Time{}, nil
+		}
+
+		return time.Time{}, err
+	}
+
+	return pending.ExpiresAt, nil
+}
+
+// ConfirmDeletion deletes a resource pending deletion with DeleteResource, and ends its pending state: the resource is soft-deleted,
+// or hard-deleted if its model has no soft delete column. ErrNotPendingDeletion is returned if the resource isn't pending deletion.
+func (p *SQL) ConfirmDeletion(ctx context.Context, resource Resource, queryHook QueryHook) (Resource, error) {
+	if err := p.checkWrite(); err != nil {
+		return nil, err
+	}
+
+	var deleted Resource
+	if err := p.RunInTransaction(ctx, func(p *SQL) error {
+		if err := p.endPendingDeletion(ctx, p.tx, resource); err != nil {
+			return err
+		}
+
+		var err error
+		if deleted, err = p.DeleteResource(ctx, resource, queryHook); err != nil {
+			return err
+		}
+
+		if deleted == nil {
+			// Keep the pending deletion of a resource the query hook didn't match.
+			return pg.ErrNoRows
+		}
+
+		return nil
+	}); err != nil {
+		if err == pg.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// CancelDeletion reverts the pending deletion of a resource.
+// ErrNotPendingDeletion is returned if the resource isn't pending deletion.
+func (p *SQL) CancelDeletion(ctx context.Context, resource Resource) error {
+	if err := p.checkWrite(); err != nil {
+		return err
+	}
+
+	return p.runInTransaction(ctx, func(tx *pg.Tx) error {
+		return p.endPendingDeletion(ctx, tx, resource)
+	})
+}
+
+// ExpirePendingDeletions reverts the pending deletions which expired and returns how many were.
+// It's meant to be called periodically to keep the pending_deletions table small, expired deletions can't be confirmed anyway.
+// ErrMaintenance is returned while writes are blocked.
+func (p *SQL) ExpirePendingDeletions(ctx context.Context) (int, error) {
+	if err := p.checkWrite(); err != nil {
+		return 0, err
+	}
+
+	if err := p.ensureTable(ctx, (*pendingDeletion)(nil)); err != nil {
+		return 0, err
+	}
+
+	res, err := p.conn().ModelContext(ctx, (*pendingDeletion)(nil)).Where("expires_at <= ?", p.now(ctx)).Delete()
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+// endPendingDeletion removes the unexpired pending deletion of resource, ErrNotPendingDeletion if there's none.
+func (p *SQL) endPendingDeletion(ctx context.Context, tx *pg.Tx, resource Resource) error {
+	pending := newPendingDeletion(resource)
+	if err := p.ensureTable(ctx, pending); err != nil {
+		return err
+	}
+
+	res, err := tx.Model(pending).WherePK().Where("expires_at > ?", p.now(ctx)).Delete()
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return ErrNotPendingDeletion
+	}
+
+	return nil
+}
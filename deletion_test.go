@@ -0,0 +1,72 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type pendingReport struct {
+	tableName struct{} `pg:"test_pending_reports"`
+
+	model.Common
+}
+
+// hardReport has no soft delete column.
+type hardReport struct {
+	tableName struct{} `pg:"test_hard_reports"`
+
+	ID   int64 `pg:",pk"`
+	Name string
+}
+
+func TestConfirmDeletion(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*pendingReport)(nil), (*hardReport)(nil), (*pendingDeletion)(nil))
+
+	created, err := p.CreateResource(ctx, &pendingReport{})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	soft := created.(*pendingReport)
+
+	hard := &hardReport{ID: 1, Name: "q1"}
+	if _, err := p.db.ModelContext(ctx, hard).Insert(); err != nil {
+		t.Fatalf("Insert(): %v", err)
+	}
+
+	if _, err := p.ConfirmDeletion(ctx, soft, WherePK); err != ErrNotPendingDeletion {
+		t.Errorf("ConfirmDeletion() unmarked = %v, want ErrNotPendingDeletion", err)
+	}
+
+	for _, res := range []Resource{soft, hard} {
+		if _, err := p.MarkForDeletion(ctx, res, time.Hour); err != nil {
+			t.Fatalf("MarkForDeletion(%T): %v", res, err)
+		}
+
+		if deleted, err := p.ConfirmDeletion(ctx, res, WherePK); err != nil || deleted == nil {
+			t.Fatalf("ConfirmDeletion(%T) = %v, %v", res, deleted, err)
+		}
+	}
+
+	if n, err := p.db.ModelContext(ctx, (*pendingReport)(nil)).Deleted().Count(); err != nil || n != 1 {
+		t.Errorf("%d soft-deleted reports, %v, want 1", n, err)
+	}
+
+	if n, err := p.db.ModelContext(ctx, (*hardReport)(nil)).Count(); err != nil || n != 0 {
+		t.Errorf("%d reports without soft delete left, %v, want them hard-deleted", n, err)
+	}
+}
+
+func TestExpirePendingDeletionsMaintenance(t *testing.T) {
+	p := testSQL(t)
+	p.StartMaintenance(true)
+	defer p.StopMaintenance()
+
+	if _, err := p.ExpirePendingDeletions(context.Background()); err != ErrMaintenance {
+		t.Errorf("ExpirePendingDeletions() while writes are blocked = %v, want ErrMaintenance", err)
+	}
+}
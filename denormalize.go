@@ -0,0 +1,165 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// Denormalization declares a column copied from a referenced table, e.g. orders.customer_name copied from customers.name.
+type Denormalization struct {
+	// Source is the model copied from, e.g. (*Customer)(nil), with a single column primary key
+	Source interface{}
+	// SourceColumn is the column copied, e.g. "name"
+	SourceColumn string
+	// Target is the model holding the copy, e.g. (*Order)(nil), with a single column primary key
+	Target interface{}
+	// TargetColumn is the copy, e.g. "customer_name"
+	TargetColumn string
+	// ForeignKey is the column of Target referencing the primary key of Source, e.g. "customer_id"
+	ForeignKey string
+}
+
+// denormalization is a checked Denormalization.
+type denormalization struct {
+	source, target *orm.Table
+	sourceColumn   string
+	targetColumn   string
+	foreignKey     string
+}
+
+// Denormalize declares a denormalized column, kept in sync by RunDenormalizer, instead of hand-written triggers.
+// An error is returned if a model doesn't have a single column primary key or a column is unknown.
+func (p *SQL) Denormalize(d Denormalization) error {
+	dn := denormalization{
+		source:       tableOf(d.Source),
+		target:       tableOf(d.Target),
+		sourceColumn: d.SourceColumn,
+		targetColumn: d.TargetColumn,
+		foreignKey:   d.ForeignKey,
+	}
+
+	for _, table := range []*orm.Table{dn.source, dn.target} {
+		if len(table.PKs) != 1 {
+			return fmt.Errorf("denormalizing %s: %w", table.SQLName, errCompositeKey)
+		}
+	}
+
+	for _, col := range []struct {
+		table *orm.Table
+		name  string
+	}{{dn.source, dn.sourceColumn}, {dn.target, dn.targetColumn}, {dn.target, dn.foreignKey}} {
+		if _, ok := col.table.FieldsMap[col.name]; !ok {
+			return fmt.Errorf("denormalizing %s: unknown column %s", col.table.SQLName, col.name)
+		}
+	}
+
+	if err := p.Register(d.Source, d.Target); err != nil {
+		return err
+	}
+
+	p.registry.mu.Lock()
+	defer p.registry.mu.Unlock()
+
+	p.registry.denormalized = append(p.registry.denormalized, dn)
+
+	return nil
+}
+
+// RunDenormalizer keeps the denormalized columns in sync until ctx is done, see Denormalize.
+// It follows the change feed: creating, updating or undeleting a source row updates the copies in the rows referencing it,
+// and creating, updating or undeleting a target row updates its copy. Copies are updated in place: their update time isn't stamped
+// and no change event is published. As change events are best effort, all the copies are synced first, and again whenever the
+// change feed is lost. A nil alert logs errors with the standard logger.
+func (p *SQL) RunDenormalizer(ctx context.Context, alert func(error)) {
+	if alert == nil {
+		alert = func(err error) {
+			log.Printf("persistsql: syncing denormalized columns failed: %v", err)
+		}
+	}
+
+	for ctx.Err() == nil {
+		if err := p.followChanges(ctx, alert); err != nil && ctx.Err() == nil {
+			alert(err)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(changesRetry):
+		}
+	}
+}
+
+// SyncDenormalized updates all the denormalized columns which are out of sync, see Denormalize.
+func (p *SQL) SyncDenormalized(ctx context.Context) error {
+	for _, dn := range p.denormalizations() {
+		if err := p.syncDenormalized(ctx, dn, pg.Safe("TRUE")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// followChanges syncs all the denormalized columns, then those changed according to the change feed, until it's lost or ctx is done.
+func (p *SQL) followChanges(ctx context.Context, alert func(error)) error {
+	changes, err := p.Changes(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := p.SyncDenormalized(ctx); err != nil {
+		return err
+	}
+
+	for change := range changes {
+		if change.Op == ChangeDelete || change.Op == ChangeTransition {
+			continue
+		}
+
+		for _, dn := range p.denormalizations() {
+			var cond pg.Safe
+			switch change.Table {
+			case unqualifiedName(dn.source):
+				cond = pg.Safe(formatQuery("s.?::text = ?", dn.source.PKs[0].Column, change.Key))
+			case unqualifiedName(dn.target):
+				cond = pg.Safe(formatQuery("t.?::text = ?", dn.target.PKs[0].Column, change.Key))
+			default:
+				continue
+			}
+
+			if err := p.syncDenormalized(ctx, dn, cond); err != nil && ctx.Err() == nil {
+				alert(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// syncDenormalized updates the column denormalized by dn in the target rows matching cond, on the target as t and the source as s.
+func (p *SQL) syncDenormalized(ctx context.Context, dn denormalization, cond pg.Safe) error {
+	if err := p.checkWrite(); err != nil {
+		return err
+	}
+
+	_, err := p.conn().ExecContext(ctx, `
+		UPDATE ? AS t SET ? = s.? FROM ? AS s
+		WHERE t.? = s.? AND t.? IS DISTINCT FROM s.? AND ?`,
+		dn.target.SQLName, pg.Ident(dn.targetColumn), pg.Ident(dn.sourceColumn), dn.source.SQLName,
+		pg.Ident(dn.foreignKey), dn.source.PKs[0].Column, pg.Ident(dn.targetColumn), pg.Ident(dn.sourceColumn), cond)
+
+	return err
+}
+
+// denormalizations returns the declared denormalizations.
+func (p *SQL) denormalizations() []denormalization {
+	p.registry.mu.RLock()
+	defer p.registry.mu.RUnlock()
+
+	return append([]denormalization(nil), p.registry.denormalized...)
+}
@@ -0,0 +1,132 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/google/uuid"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type denormCustomer struct {
+	tableName struct{} `pg:"test_denorm_customers"`
+
+	model.Common
+	Name string
+}
+
+type denormOrder struct {
+	tableName struct{} `pg:"test_denorm_orders"`
+
+	model.Common
+	CustomerID   uuid.UUID `pg:"type:uuid"`
+	CustomerName string
+}
+
+var customerNames = Denormalization{
+	Source:       (*denormCustomer)(nil),
+	SourceColumn: "name",
+	Target:       (*denormOrder)(nil),
+	TargetColumn: "customer_name",
+	ForeignKey:   "customer_id",
+}
+
+func TestDenormalizeInvalid(t *testing.T) {
+	p := &SQL{maintenance: &maintenance{}, registry: &registry{models: map[reflect.Type]*modelInfo{}}}
+
+	composite := customerNames
+	composite.Source = (*versionedNode)(nil)
+	if err := p.Denormalize(composite); !errors.Is(err, errCompositeKey) {
+		t.Errorf("Denormalize() of a composite key = %v, want errCompositeKey", err)
+	}
+
+	for _, unknown := range []func(d *Denormalization){
+		func(d *Denormalization) { d.SourceColumn = "nope" },
+		func(d *Denormalization) { d.TargetColumn = "nope" },
+		func(d *Denormalization) { d.ForeignKey = "nope" },
+	} {
+		d := customerNames
+		unknown(&d)
+		if err := p.Denormalize(d); err == nil || !strings.Contains(err.Error(), "unknown column nope") {
+			t.Errorf("Denormalize() of an unknown column = %v", err)
+		}
+	}
+
+	if err := p.Denormalize(customerNames); err != nil || len(p.denormalizations()) != 1 {
+		t.Errorf("Denormalize() = %v, %d denormalizations", err, len(p.denormalizations()))
+	}
+}
+
+func TestRunDenormalizer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p := testSQL(t)
+	testTables(t, p.db, (*denormCustomer)(nil), (*denormOrder)(nil))
+	if err := p.Denormalize(customerNames); err != nil {
+		t.Fatalf("Denormalize(): %v", err)
+	}
+
+	created, err := p.CreateResource(ctx, &denormCustomer{Name: "Ada"})
+	if err != nil {
+		t.Fatalf("CreateResource(customer): %v", err)
+	}
+	customer := created.(*denormCustomer)
+
+	created, err = p.CreateResource(ctx, &denormOrder{CustomerID: customer.ID, CustomerName: "stale"})
+	if err != nil {
+		t.Fatalf("CreateResource(order): %v", err)
+	}
+	order := created.(*denormOrder)
+
+	copied := func(want string) {
+		t.Helper()
+
+		for {
+			got, err := p.GetResource(ctx, &denormOrder{}, false, func(query *orm.Query) { query.Where("id = ?", order.ID) })
+			if err != nil {
+				t.Fatalf("GetResource(): %v", err)
+			}
+			if got.(*denormOrder).CustomerName == want {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				t.Fatalf("customer name = %q, want %q", got.(*denormOrder).CustomerName, want)
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}
+
+	if err := p.SyncDenormalized(ctx); err != nil {
+		t.Fatalf("SyncDenormalized(): %v", err)
+	}
+	copied("Ada")
+
+	go p.RunDenormalizer(ctx, func(err error) {
+		t.Errorf("alert: %v", err)
+	})
+
+	// The copies of a renamed customer follow.
+	for _, name := range []string{"Ada Lovelace", "Countess of Lovelace"} {
+		customer.Name = name
+		if _, err := p.UpdateResource(ctx, customer, []string{"name"}, nil); err != nil {
+			t.Fatalf("UpdateResource(customer): %v", err)
+		}
+		copied(name)
+	}
+
+	// So do edited orders.
+	order.CustomerName = "typo"
+	if _, err := p.UpdateResource(ctx, order, []string{"customer_name"}, nil); err != nil {
+		t.Fatalf("UpdateResource(order): %v", err)
+	}
+	copied("Countess of Lovelace")
+}
@@ -0,0 +1,153 @@
+package persistsql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// Diagnostics is a support bundle describing the state of a persistence layer, see CollectDiagnostics.
+type Diagnostics struct {
+	CollectedAt time.Time `json:"collected_at"`
+	// Schema is the live schema of the registered models' tables
+	Schema *SchemaSnapshot `json:"schema"`
+	// Tables are the storage statistics of the registered models' tables
+	Tables []*ModelStats `json:"tables"`
+	// Pools are the connection pool statistics of the primary, then of the replicas
+	Pools []PoolDiagnostics `json:"pools"`
+	// SlowQueries are the most recent slow queries, oldest first, if the slow query log is enabled, see WithSlowQueryLog
+	SlowQueries []SlowQuery `json:"slow_queries"`
+	// Retries are the transaction statistics of the operations, see RetryStats
+	Retries []RetryStats `json:"retries"`
+	// Migrations are the applied migrations, see Migrator
+	Migrations []AppliedMigration `json:"migrations"`
+	// Maintenance is whether maintenance mode is active and BlockWrites whether it rejects writes
+	Maintenance bool `json:"maintenance"`
+	BlockWrites bool `json:"block_writes"`
+	// Listener is the status of the change feed, see Changes
+	Listener ListenerDiagnostics `json:"listener"`
+	// Errors are those met collecting the other sections, which are left empty
+	Errors []string `json:"errors,omitempty"`
+}
+
+// PoolDiagnostics are the statistics of a connection pool.
+type PoolDiagnostics struct {
+	// Database is "primary" or "replica N", N from 1
+	Database string        `json:"database"`
+	Addr     string        `json:"addr"`
+	Stats    *pg.PoolStats `json:"stats"`
+}
+
+// SlowQuery is a query which took longer than the slow query threshold.
+type SlowQuery struct {
+	Label    string        `json:"label,omitempty"`
+	Query    string        `json:"query"`
+	Duration time.Duration `json:"duration"`
+	Err      string        `json:"error,omitempty"`
+}
+
+// AppliedMigration is a migration recorded in the schema_migrations table.
+type AppliedMigration struct {
+	Version   int64     `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// ListenerDiagnostics is the status of the change feed.
+type ListenerDiagnostics struct {
+	// Listening is whether the listening connection is open
+	Listening bool `json:"listening"`
+	// Subscribers is the number of subscribers to the change feed
+	Subscribers int `json:"subscribers"`
+}
+
+// CollectDiagnostics writes to w a JSON support bundle, to attach to support tickets, see Diagnostics.
+// Sections which can't be collected are reported in Diagnostics.Errors rather than failing the bundle.
+func (p *SQL) CollectDiagnostics(ctx context.Context, w io.Writer) error {
+	diag := Diagnostics{CollectedAt: p.now(ctx)}
+
+	failed := func(section string, err error) {
+		diag.Errors = append(diag.Errors, fmt.Sprintf("%s: %v", section, err))
+	}
+
+	if schema, err := p.schemaSnapshot(ctx); err != nil {
+		failed("schema", err)
+	} else {
+		diag.Schema = schema
+	}
+
+	for _, info := range p.registered() {
+		stats, err := p.Stats(ctx, reflect.New(info.table.Type).Interface())
+		if err != nil {
+			failed("stats of "+info.name(), err)
+			continue
+		}
+		diag.Tables = append(diag.Tables, stats)
+	}
+
+	diag.Pools = append(diag.Pools, poolDiagnostics("primary", p.db))
+	for i, db := range p.replicas.dbs {
+		diag.Pools = append(diag.Pools, poolDiagnostics(fmt.Sprintf("replica %d", i+1), db))
+	}
+
+	for _, info := range p.observers.slowQueries() {
+		query := SlowQuery{Label: info.Label, Query: info.Query, Duration: info.Duration}
+		if info.Err != nil {
+			query.Err = info.Err.Error()
+		}
+		diag.SlowQueries = append(diag.SlowQueries, query)
+	}
+
+	diag.Retries = p.RetryStats()
+
+	if migrations, err := p.appliedMigrations(ctx); err != nil {
+		failed("migrations", err)
+	} else {
+		diag.Migrations = migrations
+	}
+
+	diag.Maintenance, diag.BlockWrites = p.Maintenance()
+
+	p.changes.mu.Lock()
+	diag.Listener = ListenerDiagnostics{Listening: p.changes.listener != nil, Subscribers: len(p.changes.subs)}
+	p.changes.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(diag)
+}
+
+// poolDiagnostics returns the connection pool statistics of db.
+func poolDiagnostics(name string, db *pg.DB) PoolDiagnostics {
+	return PoolDiagnostics{Database: name, Addr: db.Options().Addr, Stats: db.PoolStats()}
+}
+
+// appliedMigrations returns the migrations recorded in the schema_migrations table, oldest first, none if it doesn't exist.
+func (p *SQL) appliedMigrations(ctx context.Context) ([]AppliedMigration, error) {
+	var exists bool
+	if _, err := p.db.QueryOneContext(ctx, pg.Scan(&exists), "SELECT to_regclass('schema_migrations') IS NOT NULL"); err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	var records []schemaMigration
+	if err := p.db.ModelContext(ctx, &records).Order("version").Select(); err != nil {
+		return nil, err
+	}
+
+	migrations := make([]AppliedMigration, len(records))
+	for i, record := range records {
+		migrations[i] = AppliedMigration{Version: record.Version, Name: record.Name, AppliedAt: record.AppliedAt}
+	}
+
+	return migrations, nil
+}
@@ -0,0 +1,84 @@
+package persistsql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+func TestPoolDiagnostics(t *testing.T) {
+	db := pg.Connect(&pg.Options{Addr: "primary:5432"})
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	if diag := poolDiagnostics("primary", db); diag.Database != "primary" || diag.Addr != "primary:5432" || diag.Stats == nil {
+		t.Errorf("poolDiagnostics() = %+v", diag)
+	}
+}
+
+func TestCollectDiagnostics(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t, WithSlowQueryLog(time.Nanosecond, func(string, ...interface{}) {}))
+	testTables(t, p.db, (*labelledOrder)(nil))
+	if err := p.Register((*labelledOrder)(nil)); err != nil {
+		t.Fatalf("Register(): %v", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, "SELECT count(*) FROM labelled_orders WHERE total > 0 OR 'secret' = ''"); err != nil {
+		t.Fatalf("SELECT: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := p.CollectDiagnostics(ctx, &buf); err != nil {
+		t.Fatalf("CollectDiagnostics(): %v", err)
+	}
+
+	var diag Diagnostics
+	if err := json.Unmarshal(buf.Bytes(), &diag); err != nil {
+		t.Fatalf("json.Unmarshal(): %v\n%s", err, buf.String())
+	}
+
+	if len(diag.Errors) != 0 {
+		t.Errorf("Errors = %v", diag.Errors)
+	}
+	if diag.Schema == nil {
+		t.Error("no schema")
+	}
+	if len(diag.Tables) != 1 || diag.Tables[0].Table != "labelled_orders" {
+		t.Errorf("Tables = %+v, want labelled_orders", diag.Tables)
+	}
+	if len(diag.Pools) != 1 || diag.Pools[0].Database != "primary" {
+		t.Errorf("Pools = %+v, want the primary", diag.Pools)
+	}
+
+	var found bool
+	for _, query := range diag.SlowQueries {
+		if strings.Contains(query.Query, "labelled_orders") {
+			found = true
+		}
+		if strings.Contains(query.Query, "secret") {
+			t.Errorf("slow query %q has its literals", query.Query)
+		}
+	}
+	if !found {
+		t.Errorf("SlowQueries = %+v, want the count of labelled_orders", diag.SlowQueries)
+	}
+
+	// Sections which can't be collected are reported.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	buf.Reset()
+	if err := p.CollectDiagnostics(canceled, &buf); err != nil {
+		t.Fatalf("CollectDiagnostics() canceled: %v", err)
+	}
+	if diag = (Diagnostics{}); json.Unmarshal(buf.Bytes(), &diag) != nil || len(diag.Errors) == 0 {
+		t.Errorf("CollectDiagnostics() canceled = %s, want errors", buf.String())
+	}
+}
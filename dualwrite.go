@@ -0,0 +1,260 @@
+package persistsql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// Backend persists resources, as SQL does. It lets another implementation, e.g. on another driver, stand in for SQL, see DualWrite.
+type Backend interface {
+	CreateResource(ctx context.Context, resource Resource) (Resource, error)
+	GetResource(ctx context.Context, resource Resource, showDeleted bool, queryHook QueryHook) (Resource, error)
+	ListResources(ctx context.Context, resources interface{}, opts ListOptions, queryHook QueryHook) (ListMeta, error)
+	UpdateResource(ctx context.Context, resource Resource, fields []string, queryHook QueryHook) (Resource, error)
+	DeleteResource(ctx context.Context, resource Resource, queryHook QueryHook) (Resource, error)
+	UndeleteResource(ctx context.Context, resource Resource, queryHook QueryHook) (Resource, error)
+}
+
+var _ Backend = (*SQL)(nil)
+
+// Mismatch reports a secondary backend diverging from the primary, see DualWrite.
+type Mismatch struct {
+	// Op is the method called, e.g. "UpdateResource"
+	Op string
+	// Primary and Secondary are the results of the backends, resources or pointers to slices of resources,
+	// for reads Primary is the json.RawMessage encoding of the result, taken before it's returned to the caller
+	Primary   interface{}
+	Secondary interface{}
+	// Err is the error returned by the secondary backend, if any
+	Err error
+}
+
+// DualWrite is a Backend writing through to a primary and a secondary backend, to validate the secondary before switching to it.
+// Writes go to the primary, then, if they succeed, to the secondary, with a copy of the resource written by the primary, matched
+// by primary key, and a context whose clock tells the time the primary stamped, see ContextWithClock, so the timestamps match.
+// The secondary isn't part of the transactions of the primary: when the primary is an SQL in a transaction, see RunInTransaction,
+// the writes of the secondary persist even if the transaction rolls back. Reads are served by the primary, then repeated on the secondary in the background and their results compared.
+// The secondary never fails a call: its errors and diverging results are reported to OnMismatch.
+// The secondary gets the QueryHooks of the reads, it must apply them to an *orm.Query it builds.
+type DualWrite struct {
+	Primary   Backend
+	Secondary Backend
+	// OnMismatch is called with the mismatches, from other goroutines for reads
+	OnMismatch func(ctx context.Context, m Mismatch)
+	// Timeout bounds the reads repeated on the secondary, 10 seconds if zero
+	Timeout time.Duration
+}
+
+var _ Backend = (*DualWrite)(nil)
+
+// CreateResource implements Backend.
+func (d *DualWrite) CreateResource(ctx context.Context, resource Resource) (Resource, error) {
+	created, err := d.Primary.CreateResource(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	secondary, err := d.Secondary.CreateResource(ctx, cloneResource(created))
+	d.compare(ctx, "CreateResource", created, secondary, err)
+
+	return created, nil
+}
+
+// GetResource implements Backend.
+func (d *DualWrite) GetResource(ctx context.Context, resource Resource, showDeleted bool, queryHook QueryHook) (Resource, error) {
+	query := cloneResource(resource)
+
+	got, err := d.Primary.GetResource(ctx, resource, showDeleted, queryHook)
+	if err != nil {
+		return nil, err
+	}
+
+	primary := encodeJSON(got)
+	d.background(ctx, func(ctx context.Context) {
+		secondary, err := d.Secondary.GetResource(ctx, query, showDeleted, queryHook)
+		d.compare(ctx, "GetResource", primary, secondary, err)
+	})
+
+	return got, nil
+}
+
+// ListResources implements Backend.
+func (d *DualWrite) ListResources(ctx context.Context, resources interface{}, opts ListOptions, queryHook QueryHook) (ListMeta, error) {
+	meta, err := d.Primary.ListResources(ctx, resources, opts, queryHook)
+	if err != nil {
+		return meta, err
+	}
+
+	primary := encodeJSON(resources)
+	d.background(ctx, func(ctx context.Context) {
+		secondary := reflect.New(reflect.TypeOf(resources).Elem()).Interface()
+		_, err := d.Secondary.ListResources(ctx, secondary, opts, queryHook)
+		d.compare(ctx, "ListResources", primary, secondary, err)
+	})
+
+	return meta, nil
+}
+
+// UpdateResource implements Backend.
+func (d *DualWrite) UpdateResource(ctx context.Context, resource Resource, fields []string, queryHook QueryHook) (Resource, error) {
+	updated, err := d.Primary.UpdateResource(ctx, resource, fields, queryHook)
+	if err != nil || updated == nil {
+		return updated, err
+	}
+
+	stamped := pinClock(ctx, updated, timeField(tableOf(updated), updateTimeColumns))
+	secondary, err := d.Secondary.UpdateResource(stamped, cloneResource(updated), fields, WherePK)
+	d.compare(ctx, "UpdateResource", updated, secondary, err)
+
+	return updated, nil
+}
+
+// DeleteResource implements Backend.
+func (d *DualWrite) DeleteResource(ctx context.Context, resource Resource, queryHook QueryHook) (Resource, error) {
+	deleted, err := d.Primary.DeleteResource(ctx, resource, queryHook)
+	if err != nil || deleted == nil {
+		return deleted, err
+	}
+
+	stamped := pinClock(ctx, deleted, tableOf(deleted).SoftDeleteField)
+	secondary, err := d.Secondary.DeleteResource(stamped, cloneResource(deleted), nil)
+	d.compare(ctx, "DeleteResource", deleted, secondary, err)
+
+	return deleted, nil
+}
+
+// UndeleteResource implements Backend.
+func (d *DualWrite) UndeleteResource(ctx context.Context, resource Resource, queryHook QueryHook) (Resource, error) {
+	undeleted, err := d.Primary.UndeleteResource(ctx, resource, queryHook)
+	if err != nil || undeleted == nil {
+		return undeleted, err
+	}
+
+	stamped := pinClock(ctx, undeleted, timeField(tableOf(undeleted), updateTimeColumns))
+	secondary, err := d.Secondary.UndeleteResource(stamped, cloneResource(undeleted), nil)
+	d.compare(ctx, "UndeleteResource", undeleted, secondary, err)
+
+	return undeleted, nil
+}
+
+// pinClock returns a copy of ctx whose clock tells the time held by field of written, as stamped by the primary,
+// ctx if there's no such field or it's zero.
+func pinClock(ctx context.Context, written Resource, field *orm.Field) context.Context {
+	if field == nil {
+		return ctx
+	}
+
+	var t time.Time
+	switch v := field.Value(reflect.Indirect(reflect.ValueOf(written))).Interface().(type) {
+	case time.Time:
+		t = v
+	case int64:
+		if v != 0 {
+			t = time.Unix(0, v)
+		}
+	}
+
+	if t.IsZero() {
+		return ctx
+	}
+
+	return ContextWithClock(ctx, fixedClock(t))
+}
+
+// fixedClock is a Clock always telling the same time.
+type fixedClock time.Time
+
+// Now implements Clock.
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// background calls fn in another goroutine, bounded by the timeout, see detach.
+func (d *DualWrite) background(ctx context.Context, fn func(ctx context.Context)) {
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	go detach(ctx, timeout, fn)
+}
+
+// compare reports a mismatch if err is non-nil or the results of the backends differ, compared by their JSON encoding.
+func (d *DualWrite) compare(ctx context.Context, op string, primary, secondary interface{}, err error) {
+	if d.OnMismatch == nil {
+		return
+	}
+
+	if err == nil && sameJSON(primary, secondary) {
+		return
+	}
+
+	d.OnMismatch(ctx, Mismatch{Op: op, Primary: primary, Secondary: secondary, Err: err})
+}
+
+// encodeJSON returns the JSON encoding of v, nil if it can't be encoded.
+func encodeJSON(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	return b
+}
+
+// sameJSON returns whether a and b have the same JSON encoding.
+func sameJSON(a, b interface{}) bool {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(ja, jb)
+}
+
+// cloneResource returns a shallow copy of res, a pointer to a struct.
+func cloneResource(res Resource) Resource {
+	v := reflect.ValueOf(res).Elem()
+	clone := reflect.New(v.Type())
+	clone.Elem().Set(v)
+
+	return clone.Interface()
+}
+
+// detach calls fn with a context carrying the values of ctx but not its cancellation, bounded by timeout.
+func detach(ctx context.Context, timeout time.Duration, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithTimeout(detached{ctx}, timeout)
+	defer cancel()
+
+	fn(ctx)
+}
+
+// detached is a context carrying the values of its parent, without its deadline and cancellation.
+type detached struct {
+	context.Context
+}
+
+// Deadline implements context.Context.
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// Done implements context.Context.
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+// Err implements context.Context.
+func (detached) Err() error {
+	return nil
+}
@@ -0,0 +1,85 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type dualNote struct {
+	model.Common
+	Text string
+}
+
+// stampingBackend is a Backend stamping the writes with the clock of the context, or else the system clock.
+type stampingBackend struct {
+	Backend
+}
+
+func (stampingBackend) now(ctx context.Context) time.Time {
+	if clock, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return clock.Now()
+	}
+
+	return time.Now()
+}
+
+func (b stampingBackend) UpdateResource(ctx context.Context, resource Resource, _ []string, _ QueryHook) (Resource, error) {
+	resource.(*dualNote).UpdateTime = b.now(ctx)
+
+	return resource, nil
+}
+
+func (b stampingBackend) DeleteResource(ctx context.Context, resource Resource, _ QueryHook) (Resource, error) {
+	resource.(*dualNote).DeleteTime = b.now(ctx)
+
+	return resource, nil
+}
+
+func TestDualWriteStampedTimes(t *testing.T) {
+	ctx := context.Background()
+	stamped := time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var mismatches []Mismatch
+	d := &DualWrite{
+		Primary:   stampingBackend{},
+		Secondary: stampingBackend{},
+		OnMismatch: func(_ context.Context, m Mismatch) {
+			mismatches = append(mismatches, m)
+		},
+	}
+
+	clocked := ContextWithClock(ctx, fixedClock(stamped))
+	if _, err := d.UpdateResource(clocked, &dualNote{Text: "a"}, []string{"text"}, nil); err != nil {
+		t.Fatalf("UpdateResource(): %v", err)
+	}
+
+	if _, err := d.DeleteResource(clocked, &dualNote{Text: "a"}, nil); err != nil {
+		t.Fatalf("DeleteResource(): %v", err)
+	}
+
+	if _, err := d.UpdateResource(ctx, &dualNote{Text: "b"}, []string{"text"}, nil); err != nil {
+		t.Fatalf("UpdateResource(): %v", err)
+	}
+
+	if len(mismatches) != 0 {
+		t.Errorf("mismatches = %+v, want none, the secondary stamping the times of the primary", mismatches)
+	}
+}
+
+func TestPinClock(t *testing.T) {
+	ctx := context.Background()
+	stamped := time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)
+	note := &dualNote{Common: model.Common{UpdateTime: stamped}}
+
+	pinned := pinClock(ctx, note, timeField(tableOf(note), updateTimeColumns))
+	if clock, ok := pinned.Value(clockKey{}).(Clock); !ok || !clock.Now().Equal(stamped) {
+		t.Errorf("pinClock() clock = %v, want one telling %v", pinned.Value(clockKey{}), stamped)
+	}
+
+	if pinned := pinClock(ctx, note, tableOf(note).SoftDeleteField); pinned != ctx {
+		t.Error("pinClock() of a zero time changed the context")
+	}
+}
@@ -0,0 +1,74 @@
+package persistsql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// DumpTable returns the rows of the table of model as JSON objects, soft-deleted rows included, ordered by primary key.
+// It reads from the primary, to see the writes just made, and is meant for tests and debugging.
+func (p *SQL) DumpTable(ctx context.Context, model interface{}) ([]json.RawMessage, error) {
+	table := tableOf(model)
+
+	var rows []string
+	if _, err := p.conn().QueryContext(ctx, &rows, "SELECT row_to_json(?)::text FROM ? AS ? ORDER BY ?",
+		table.Alias, table.SQLName, table.Alias, pkColumns(table)); err != nil {
+		return nil, err
+	}
+
+	dump := make([]json.RawMessage, len(rows))
+	for i, row := range rows {
+		dump[i] = json.RawMessage(row)
+	}
+
+	return dump, nil
+}
+
+// DumpTableTo writes the rows of the table of model to w as JSON lines, in the order and format of DumpTable, and returns their number.
+// The rows are streamed with COPY instead of being buffered, for tables too large for DumpTable.
+func (p *SQL) DumpTableTo(ctx context.Context, model interface{}, w io.Writer) (int, error) {
+	table := tableOf(model)
+
+	// JSON text never holds raw control characters, so as CSV delimiter and quote they leave the lines as is.
+	query := "COPY (SELECT row_to_json(?)::text FROM ? AS ? ORDER BY ?) TO STDOUT WITH (FORMAT csv, DELIMITER E'\\x01', QUOTE E'\\x02')"
+	params := []interface{}{table.Alias, table.SQLName, table.Alias, pkColumns(table)}
+
+	var res pg.Result
+	var err error
+	if p.tx != nil {
+		res, err = p.tx.CopyTo(w, query, params...)
+	} else {
+		res, err = p.db.WithContext(ctx).CopyTo(w, query, params...)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+// RestoreTable inserts rows, JSON objects as returned by DumpTable, into the table of model, skipping those conflicting with existing rows.
+// Rows are inserted as is, without stamping, hooks nor change events. It returns the number of rows inserted.
+// ErrMaintenance is returned while writes are blocked.
+func (p *SQL) RestoreTable(ctx context.Context, model interface{}, rows []json.RawMessage) (int, error) {
+	if err := p.checkWrite(); err != nil {
+		return 0, err
+	}
+
+	b, err := json.Marshal(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	table := tableOf(model)
+	res, err := p.conn().ExecContext(ctx, "INSERT INTO ? SELECT * FROM json_populate_recordset(NULL::?, ?) ON CONFLICT DO NOTHING",
+		table.SQLName, table.SQLName, string(b))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
@@ -0,0 +1,49 @@
+package persistsql
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type dumpedComment struct {
+	tableName struct{} `pg:"test_dumped_comments"`
+
+	model.Common
+	Body string
+}
+
+func TestDumpTableTo(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*dumpedComment)(nil))
+
+	for _, body := range []string{`plain`, `"quoted", with a comma`, "back\\slash\nand\ttabs\x01"} {
+		if _, err := p.CreateResource(ctx, &dumpedComment{Body: body}); err != nil {
+			t.Fatalf("CreateResource(): %v", err)
+		}
+	}
+
+	rows, err := p.DumpTable(ctx, (*dumpedComment)(nil))
+	if err != nil {
+		t.Fatalf("DumpTable(): %v", err)
+	}
+
+	var want bytes.Buffer
+	for _, row := range rows {
+		want.Write(row)
+		want.WriteByte('\n')
+	}
+
+	var got bytes.Buffer
+	n, err := p.DumpTableTo(ctx, (*dumpedComment)(nil), &got)
+	if err != nil || n != len(rows) {
+		t.Fatalf("DumpTableTo() = %d, %v, want %d", n, err, len(rows))
+	}
+
+	if got.String() != want.String() {
+		t.Errorf("DumpTableTo() wrote\n%s\nwant, as DumpTable\n%s", got.String(), want.String())
+	}
+}
@@ -0,0 +1,139 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// ErrPossibleDuplicate is wrapped by DuplicateError.
+var ErrPossibleDuplicate = errors.New("possible duplicate")
+
+// DuplicateError is returned by CreateResource when existing resources are similar to the one created, see DetectDuplicates.
+type DuplicateError struct {
+	// Candidates are the similar resources, most similar first
+	Candidates []Resource
+}
+
+// Error implements error.
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("%s: %d similar resources", ErrPossibleDuplicate, len(e.Candidates))
+}
+
+// Unwrap returns ErrPossibleDuplicate.
+func (e *DuplicateError) Unwrap() error {
+	return ErrPossibleDuplicate
+}
+
+// DuplicateCheck configures the detection of duplicates of a model.
+type DuplicateCheck struct {
+	// Columns compared, text columns
+	Columns []string
+	// Threshold is the trigram similarity, between 0 and 1, from which a column makes a resource a candidate, 0.6 if zero
+	Threshold float64
+	// Limit is the maximum number of candidates returned, 5 if zero
+	Limit int
+}
+
+// DetectDuplicates makes CreateResource look for existing resources of the type of model similar to the one created,
+// by trigram similarity of the columns of check, returning them in a DuplicateError instead of creating the resource.
+// The pg_trgm extension must be installed, and a trigram index on each column, e.g. CREATE INDEX ON t USING gin (col gin_trgm_ops),
+// keeps the check from scanning the table. Soft-deleted resources aren't candidates, see SkipDuplicateCheck to create anyway.
+func (p *SQL) DetectDuplicates(model interface{}, check DuplicateCheck) {
+	if check.Threshold == 0 {
+		check.Threshold = 0.6
+	}
+	if check.Limit == 0 {
+		check.Limit = 5
+	}
+
+	info := p.model(model)
+
+	p.registry.mu.Lock()
+	defer p.registry.mu.Unlock()
+
+	info.duplicates = &check
+}
+
+type skipDuplicateCheckKey struct{}
+
+// SkipDuplicateCheck returns a copy of ctx making CreateResource skip the duplicate check, e.g. once the user confirmed the creation.
+func SkipDuplicateCheck(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipDuplicateCheckKey{}, true)
+}
+
+// checkDuplicates returns a DuplicateError if resources similar to created exist, in tx.
+func (p *SQL) checkDuplicates(ctx context.Context, tx *pg.Tx, created Resource) error {
+	if skip, _ := ctx.Value(skipDuplicateCheckKey{}).(bool); skip {
+		return nil
+	}
+
+	info := p.model(created)
+
+	p.registry.mu.RLock()
+	check := info.duplicates
+	p.registry.mu.RUnlock()
+
+	if check == nil {
+		return nil
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(created))
+
+	var matches, scores []string
+	var params []interface{}
+	for _, col := range check.Columns {
+		field, ok := info.table.FieldsMap[col]
+		if !ok {
+			return fmt.Errorf("duplicate check: unknown column %s", col)
+		}
+
+		value := fmt.Sprint(field.Value(v).Interface())
+		if value == "" {
+			continue
+		}
+
+		matches = append(matches, "?TableAlias.? % ?")
+		scores = append(scores, "coalesce(similarity(?TableAlias.?, ?), 0)")
+		params = append(params, field.Column, value)
+	}
+
+	if len(scores) == 0 {
+		return nil
+	}
+
+	// The % operator, unlike a comparison of similarity(), can use trigram indexes.
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('pg_trgm.similarity_threshold', ?, true)", fmt.Sprint(check.Threshold)); err != nil {
+		return err
+	}
+
+	score := "greatest(" + strings.Join(scores, ", ") + ", 0)"
+
+	candidates := reflect.New(reflect.SliceOf(reflect.PtrTo(info.table.Type)))
+	if err := tx.ModelContext(ctx, candidates.Interface()).
+		Where("("+strings.Join(matches, " OR ")+")", params...).
+		OrderExpr(score+" DESC", params...).
+		Limit(check.Limit).
+		Select(); err != nil {
+		return err
+	}
+
+	if candidates.Elem().Len() == 0 {
+		return nil
+	}
+
+	if err := p.afterLoad(ctx, candidates.Interface()); err != nil {
+		return err
+	}
+
+	dup := &DuplicateError{Candidates: make([]Resource, candidates.Elem().Len())}
+	for i := range dup.Candidates {
+		dup.Candidates[i] = candidates.Elem().Index(i).Interface()
+	}
+
+	return dup
+}
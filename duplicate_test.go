@@ -0,0 +1,52 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type duplicatedContact struct {
+	tableName struct{} `pg:"test_duplicated_contacts"`
+
+	model.Common
+	Name string
+}
+
+func TestDetectDuplicates(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*duplicatedContact)(nil))
+
+	if _, err := p.db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS pg_trgm"); err != nil {
+		t.Skipf("pg_trgm: %v", err)
+	}
+	if _, err := p.db.ExecContext(ctx, "CREATE INDEX ON test_duplicated_contacts USING gin (name gin_trgm_ops)"); err != nil {
+		t.Fatalf("create index: %v", err)
+	}
+
+	p.DetectDuplicates((*duplicatedContact)(nil), DuplicateCheck{Columns: []string{"name"}, Threshold: 0.5})
+
+	for _, name := range []string{"Jane Smith", "Jane Smithers", "Bob Jones"} {
+		if _, err := p.CreateResource(SkipDuplicateCheck(ctx), &duplicatedContact{Name: name}); err != nil {
+			t.Fatalf("CreateResource(%s): %v", name, err)
+		}
+	}
+
+	_, err := p.CreateResource(ctx, &duplicatedContact{Name: "Jane Smith"})
+
+	var dup *DuplicateError
+	if !errors.As(err, &dup) {
+		t.Fatalf("CreateResource() of a duplicate = %v, want a DuplicateError", err)
+	}
+
+	if len(dup.Candidates) != 2 || dup.Candidates[0].(*duplicatedContact).Name != "Jane Smith" {
+		t.Errorf("candidates = %v, want Jane Smith then Jane Smithers", dup.Candidates)
+	}
+
+	if _, err := p.CreateResource(ctx, &duplicatedContact{Name: "Alice Walker"}); err != nil {
+		t.Errorf("CreateResource() of a distinct contact: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package persistsql
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// eventsChannel is the notification channel of change events.
+const eventsChannel = "events"
+
+// changesRetry is the delay before the followers of the change feed, e.g. RunDenormalizer, subscribe again after losing it.
+const changesRetry = 5 * time.Second
+
+// changeBuffer is the number of change events buffered per subscriber before it's dropped as lagging.
+const changeBuffer = 256
+
+// ChangeOp is the operation of a change event.
+type ChangeOp string
+
+// Change operations.
+const (
+	ChangeCreate   ChangeOp = "create"
+	ChangeUpdate   ChangeOp = "update"
+	ChangeDelete   ChangeOp = "delete"
+	ChangeUndelete ChangeOp = "undelete"
+	// ChangeTransition follows the ChangeUpdate of a resource whose state changed, see DeclareStateMachine
+	ChangeTransition ChangeOp = "transition"
+)
+
+// ChangeEvent notifies a change of a resource, published when the transaction making it commits.
+type ChangeEvent struct {
+	// Op is the operation
+	Op ChangeOp `json:"op"`
+	// Table of the resource, unqualified
+	Table string `json:"table"`
+	// Key is the primary key of the resource, see ValidateReferences for the format of composite keys
+	Key string `json:"key"`
+	// Time of the change, from the clock
+	Time time.Time `json:"time"`
+	// Column, From and To describe the change of state of a ChangeTransition
+	Column string `json:"column,omitempty"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// notify publishes the change of resource by op in tx, delivered when tx commits, unless its model disabled events.
+func (p *SQL) notify(ctx context.Context, tx *pg.Tx, op ChangeOp, resource interface{}) error {
+	if !p.features(resource).Events {
+		return nil
+	}
+
+	return p.publish(ctx, tx, p.changeEvent(ctx, op, resource))
+}
+
+// notifyTransitions publishes the changes of state of resource in tx.
+func (p *SQL) notifyTransitions(ctx context.Context, tx *pg.Tx, resource interface{}, changes []stateChange) error {
+	if !p.features(resource).Events {
+		return nil
+	}
+
+	for _, change := range changes {
+		event := p.changeEvent(ctx, ChangeTransition, resource)
+		event.Column, event.From, event.To = change.column, change.from, change.to
+
+		if err := p.publish(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// changeEvent returns the change event of resource by op.
+func (p *SQL) changeEvent(ctx context.Context, op ChangeOp, resource interface{}) *ChangeEvent {
+	return &ChangeEvent{
+		Op:    op,
+		Table: unqualifiedName(tableOf(resource)),
+		Key:   primaryKey(resource),
+		Time:  p.now(ctx),
+	}
+}
+
+// publish publishes event in tx, delivered when tx commits.
+func (p *SQL) publish(ctx context.Context, tx *pg.Tx, event *ChangeEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Stmt(p.notifyStmt).ExecContext(ctx, string(payload))
+
+	return err
+}
+
+// changeFeed fans the change events out to subscribers, over a single listening connection open while there are subscribers.
+type changeFeed struct {
+	mu       sync.Mutex
+	subs     map[chan ChangeEvent]struct{}
+	listener *pg.Listener
+}
+
+// Changes returns the change events of all tables, published by CreateResource, UpdateResource, DeleteResource and UndeleteResource,
+// until ctx is done. Events are best effort: those published while the connection is re-established are lost, and the channel
+// is closed early if the events aren't consumed fast enough.
+func (p *SQL) Changes(ctx context.Context) (<-chan ChangeEvent, error) {
+	ch := make(chan ChangeEvent, changeBuffer)
+
+	p.changes.mu.Lock()
+	if p.changes.listener == nil {
+		ln := p.db.Listen(context.Background())
+		if err := ln.Listen(ctx, eventsChannel); err != nil {
+			p.changes.mu.Unlock()
+			_ = ln.Close()
+
+			return nil, err
+		}
+
+		p.changes.listener = ln
+		go p.changes.dispatch(ln)
+	}
+	p.changes.subs[ch] = struct{}{}
+	p.changes.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		p.changes.unsubscribe(ch)
+	}()
+
+	return ch, nil
+}
+
+// dispatch sends the notifications of ln to the subscribers until ln is closed.
+func (f *changeFeed) dispatch(ln *pg.Listener) {
+	for n := range ln.Channel() {
+		var event ChangeEvent
+		if err := json.Unmarshal([]byte(n.Payload), &event); err != nil {
+			log.Printf("persistsql: invalid change event %q: %v", n.Payload, err)
+			continue
+		}
+
+		f.mu.Lock()
+		for ch := range f.subs {
+			select {
+			case ch <- event:
+			default:
+				f.remove(ch)
+			}
+		}
+		f.mu.Unlock()
+	}
+}
+
+// unsubscribe removes the subscriber ch, if still subscribed.
+func (f *changeFeed) unsubscribe(ch chan ChangeEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.subs[ch]; ok {
+		f.remove(ch)
+	}
+}
+
+// remove closes and removes the subscriber ch, closing the listener after the last one, with f.mu held.
+func (f *changeFeed) remove(ch chan ChangeEvent) {
+	delete(f.subs, ch)
+	close(ch)
+
+	if len(f.subs) == 0 && f.listener != nil {
+		_ = f.listener.Close()
+		f.listener = nil
+	}
+}
@@ -0,0 +1,44 @@
+package persistsql
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+// FilterExisting returns the ids of the collection of model, which must have a single UUID primary key, that exist and aren't soft-deleted.
+// The ids are returned in the order given, in a single query.
+func (p *SQL) FilterExisting(ctx context.Context, model interface{}, ids []uuid.UUID) ([]uuid.UUID, error) {
+	table := tableOf(model)
+	if len(table.PKs) != 1 {
+		return nil, errCompositeKey
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var found []uuid.UUID
+	if err := p.reader(ctx).ModelContext(ctx, model).
+		ColumnExpr("?TableAlias.?", table.PKs[0].Column).
+		Where("?TableAlias.? IN (?)", table.PKs[0].Column, pg.In(ids)).
+		Select(&found); err != nil {
+		return nil, err
+	}
+
+	exists := make(map[uuid.UUID]bool, len(found))
+	for _, id := range found {
+		exists[id] = true
+	}
+
+	existing := make([]uuid.UUID, 0, len(found))
+	for _, id := range ids {
+		if exists[id] {
+			existing = append(existing, id)
+			delete(exists, id)
+		}
+	}
+
+	return existing, nil
+}
@@ -0,0 +1,53 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFilterExistingWithoutQuery(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := (&SQL{}).FilterExisting(ctx, (*versionedNode)(nil), []uuid.UUID{uuid.New()}); err != errCompositeKey {
+		t.Errorf("FilterExisting() of a composite key = %v, want errCompositeKey", err)
+	}
+
+	if ids, err := (&SQL{}).FilterExisting(ctx, (*trashedNote)(nil), nil); err != nil || ids != nil {
+		t.Errorf("FilterExisting() of no ids = %v, %v", ids, err)
+	}
+}
+
+func TestFilterExisting(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*trashedNote)(nil))
+
+	var ids []uuid.UUID
+	for i := 0; i < 3; i++ {
+		created, err := p.CreateResource(ctx, &trashedNote{Folder: "inbox"})
+		if err != nil {
+			t.Fatalf("CreateResource(): %v", err)
+		}
+		ids = append(ids, created.(*trashedNote).ID)
+	}
+
+	deleted := &trashedNote{}
+	deleted.ID = ids[1]
+	if _, err := p.DeleteResource(ctx, deleted, nil); err != nil {
+		t.Fatalf("DeleteResource(): %v", err)
+	}
+
+	unknown := uuid.New()
+	existing, err := p.FilterExisting(ctx, (*trashedNote)(nil), []uuid.UUID{ids[2], unknown, ids[1], ids[0], ids[2]})
+	if err != nil {
+		t.Fatalf("FilterExisting(): %v", err)
+	}
+
+	// In the order given, once each, without the soft-deleted one.
+	if want := fmt.Sprint([]uuid.UUID{ids[2], ids[0]}); fmt.Sprint(existing) != want {
+		t.Errorf("FilterExisting() = %v, want %v", existing, want)
+	}
+}
@@ -0,0 +1,34 @@
+package persistsql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// ExplainList returns the plan of the query ListResources would run with opts and queryHook, as printed by EXPLAIN.
+// With analyze, the query is executed and the plan includes actual times and buffer usage.
+func (p *SQL) ExplainList(ctx context.Context, resources interface{}, opts ListOptions, queryHook QueryHook, analyze bool) (string, error) {
+	db := p.reader(ctx)
+	query := db.ModelContext(ctx, resources)
+	ShowDeleted(query, opts.ShowDeleted)
+	queryHook(query)
+
+	if err := p.asOf(ctx, db, resources, query); err != nil {
+		return "", err
+	}
+
+	explain := "EXPLAIN"
+	if analyze {
+		explain = "EXPLAIN (ANALYZE, BUFFERS)"
+	}
+
+	var lines []string
+	if _, err := db.QueryContext(ctx, &lines, "? ?", pg.Safe(explain), orm.NewSelectQuery(query)); err != nil {
+		return "", err
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
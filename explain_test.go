@@ -0,0 +1,36 @@
+package persistsql
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+func TestExplainList(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*trashedNote)(nil))
+
+	var notes []*trashedNote
+	byFolder := func(query *orm.Query) { query.Where("folder = ?", "inbox") }
+
+	plan, err := p.ExplainList(ctx, &notes, ListOptions{}, byFolder, false)
+	if err != nil {
+		t.Fatalf("ExplainList(): %v", err)
+	}
+
+	// Soft-deleted resources are filtered out like ListResources does.
+	if !strings.Contains(plan, "test_trashed_notes") || !strings.Contains(plan, "delete_time IS NULL") || strings.Contains(plan, "actual time") {
+		t.Errorf("ExplainList() =\n%s\nwant the plan of the live notes of the folder", plan)
+	}
+
+	if plan, err = p.ExplainList(ctx, &notes, ListOptions{ShowDeleted: true}, byFolder, true); err != nil {
+		t.Fatalf("ExplainList(analyze): %v", err)
+	}
+
+	if strings.Contains(plan, "delete_time IS NULL") || !strings.Contains(plan, "actual time") {
+		t.Errorf("ExplainList(ShowDeleted, analyze) =\n%s\nwant the analyzed plan of all the notes", plan)
+	}
+}
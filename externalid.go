@@ -0,0 +1,131 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/google/uuid"
+)
+
+// ErrExternalIDConflict is returned when linking an external ID already linked to another resource,
+// or a resource already linked to another external ID of the same system.
+var ErrExternalIDConflict = errors.New("external ID conflict")
+
+// externalLink maps the ID of a resource in an external system to the resource.
+type externalLink struct {
+	tableName struct{} `pg:"external_ids"`
+
+	System     string    `pg:",pk,unique:resource"`
+	ExternalID string    `pg:",pk"`
+	Collection string    `pg:",notnull,unique:resource"`
+	ID         uuid.UUID `pg:",notnull,type:uuid,unique:resource"`
+	CreateTime time.Time `pg:",notnull"`
+}
+
+// LinkExternalID maps externalID, the ID of a resource in system, to a resource, identified by its UUID primary key.
+// An external ID maps to a single resource and a resource has a single external ID per system, ErrExternalIDConflict is returned otherwise.
+// Linking an existing mapping again does nothing.
+func (p *SQL) LinkExternalID(ctx context.Context, resource Resource, system, externalID string) error {
+	if err := p.checkWrite(); err != nil {
+		return err
+	}
+
+	id, err := uuidKey(resource)
+	if err != nil {
+		return err
+	}
+
+	link := newExternalLink(resource, system, externalID)
+	link.ID = id
+	link.CreateTime = p.now(ctx)
+
+	if err := p.ensureTable(ctx, link); err != nil {
+		return err
+	}
+
+	res, err := p.conn().ModelContext(ctx, link).OnConflict("DO NOTHING").Insert()
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() > 0 {
+		return nil
+	}
+
+	existing := newExternalLink(resource, system, externalID)
+	if err := p.conn().ModelContext(ctx, existing).WherePK().Select(); err != nil && err != pg.ErrNoRows {
+		return err
+	}
+
+	if existing.Collection != link.Collection || existing.ID != id {
+		return ErrExternalIDConflict
+	}
+
+	return nil
+}
+
+// UnlinkExternalID removes the mapping of externalID in system for the collection of resource, if any.
+func (p *SQL) UnlinkExternalID(ctx context.Context, resource Resource, system, externalID string) error {
+	if err := p.checkWrite(); err != nil {
+		return err
+	}
+
+	link := newExternalLink(resource, system, externalID)
+	if err := p.ensureTable(ctx, link); err != nil {
+		return err
+	}
+
+	_, err := p.conn().ModelContext(ctx, link).WherePK().Where("collection = ?", link.Collection).Delete()
+
+	return err
+}
+
+// GetByExternalID retrieves into resource the resource of its collection mapped to externalID in system, see LinkExternalID.
+// It returns nil if there's none or it's soft-deleted.
+func (p *SQL) GetByExternalID(ctx context.Context, resource Resource, system, externalID string) (Resource, error) {
+	if _, err := uuidKey(resource); err != nil {
+		return nil, err
+	}
+
+	link := newExternalLink(resource, system, externalID)
+	if err := p.ensureTable(ctx, link); err != nil {
+		return nil, err
+	}
+
+	if err := p.reader(ctx).ModelContext(ctx, link).WherePK().Where("collection = ?", link.Collection).Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	pk := tableOf(resource).PKs[0]
+
+	return p.GetResource(ctx, resource, false, func(query *orm.Query) {
+		query.Where("?TableAlias.? = ?", pk.Column, link.ID)
+	})
+}
+
+// newExternalLink returns the mapping of externalID in system for the collection of resource.
+func newExternalLink(resource Resource, system, externalID string) *externalLink {
+	return &externalLink{
+		System:     system,
+		ExternalID: externalID,
+		Collection: unqualifiedName(tableOf(resource)),
+	}
+}
+
+// uuidKey returns the primary key of model, which must be a single UUID.
+func uuidKey(model interface{}) (uuid.UUID, error) {
+	table := tableOf(model)
+	if len(table.PKs) != 1 || table.PKs[0].Field.Type != reflect.TypeOf(uuid.UUID{}) {
+		return uuid.Nil, errors.New("model must have a single UUID primary key")
+	}
+
+	return table.PKs[0].Value(reflect.Indirect(reflect.ValueOf(model))).Interface().(uuid.UUID), nil
+}
@@ -0,0 +1,78 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestUUIDKey(t *testing.T) {
+	note := &trashedNote{}
+	note.ID[15] = 1
+	if id, err := uuidKey(note); err != nil || id != note.ID {
+		t.Errorf("uuidKey() = %v, %v, want %v", id, err, note.ID)
+	}
+
+	for _, model := range []interface{}{&stampedEvent{}, &versionedNode{}} {
+		if _, err := uuidKey(model); err == nil {
+			t.Errorf("uuidKey(%T) succeeded", model)
+		}
+	}
+
+	if _, err := (&SQL{}).GetByExternalID(context.Background(), &stampedEvent{}, "stripe", "cus_1"); err == nil {
+		t.Error("GetByExternalID() of an int64 key succeeded")
+	}
+}
+
+func TestExternalIDs(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*externalLink)(nil), (*trashedNote)(nil))
+
+	var notes []*trashedNote
+	for i := 0; i < 2; i++ {
+		created, err := p.CreateResource(ctx, &trashedNote{Folder: "inbox"})
+		if err != nil {
+			t.Fatalf("CreateResource(): %v", err)
+		}
+		notes = append(notes, created.(*trashedNote))
+	}
+
+	if err := p.LinkExternalID(ctx, notes[0], "crm", "N-1"); err != nil {
+		t.Fatalf("LinkExternalID(): %v", err)
+	}
+
+	if err := p.LinkExternalID(ctx, notes[0], "crm", "N-1"); err != nil {
+		t.Errorf("LinkExternalID() again = %v, want nothing done", err)
+	}
+
+	// The external ID is taken, and the note has one in the system already.
+	if err := p.LinkExternalID(ctx, notes[1], "crm", "N-1"); !errors.Is(err, ErrExternalIDConflict) {
+		t.Errorf("LinkExternalID() of a linked external ID = %v, want ErrExternalIDConflict", err)
+	}
+	if err := p.LinkExternalID(ctx, notes[0], "crm", "N-2"); !errors.Is(err, ErrExternalIDConflict) {
+		t.Errorf("LinkExternalID() of a linked resource = %v, want ErrExternalIDConflict", err)
+	}
+
+	// Other systems are independent.
+	if err := p.LinkExternalID(ctx, notes[1], "erp", "N-1"); err != nil {
+		t.Errorf("LinkExternalID() in another system = %v", err)
+	}
+
+	got, err := p.GetByExternalID(ctx, &trashedNote{}, "crm", "N-1")
+	if note, _ := got.(*trashedNote); err != nil || note == nil || note.ID != notes[0].ID {
+		t.Errorf("GetByExternalID() = %v, %v, want the first note", got, err)
+	}
+
+	if got, err := p.GetByExternalID(ctx, &trashedNote{}, "crm", "N-3"); err != nil || got != nil {
+		t.Errorf("GetByExternalID() of an unknown ID = %v, %v, want nil", got, err)
+	}
+
+	if err := p.UnlinkExternalID(ctx, notes[0], "crm", "N-1"); err != nil {
+		t.Fatalf("UnlinkExternalID(): %v", err)
+	}
+
+	if got, err := p.GetByExternalID(ctx, &trashedNote{}, "crm", "N-1"); err != nil || got != nil {
+		t.Errorf("GetByExternalID() once unlinked = %v, %v, want nil", got, err)
+	}
+}
@@ -0,0 +1,162 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// errNotInTransaction is returned by the operations needing p to be bound to a transaction, see WithTx.
+var errNotInTransaction = errors.New("not bound to a transaction")
+
+// tenantSetting is the configuration parameter holding the tenant of the current transaction, see SetTenant.
+const tenantSetting = "persistsql.tenant"
+
+// Features are the subsystems enabled for a model, see WithModelFeatures.
+// Models without declared features publish events and are cached, history and row level security being off.
+type Features struct {
+	// Events publishes the changes of the resources, see Changes
+	Events bool
+	// History records the versions of the rows, see EnableHistory
+	History bool
+	// Cache reads the resources through the cache in GetResourceByPK, see WithCache
+	Cache bool
+	// RLS restricts the rows of the table to those of the tenant of the transaction, see SetTenant
+	RLS bool
+	// TenantColumn holds the tenant of the rows, required by RLS
+	TenantColumn string
+}
+
+// defaultFeatures are the features of the models without declared features.
+var defaultFeatures = Features{Events: true, Cache: true}
+
+// modelFeatures are the features declared for a model.
+type modelFeatures struct {
+	model    interface{}
+	features Features
+}
+
+// WithModelFeatures declares the subsystems enabled for model, a pointer to a model, so that heavyweight ones only apply
+// where needed. The features are validated by New, those needing DDL are set up by ApplyFeatures.
+func WithModelFeatures(model interface{}, features Features) Option {
+	return func(p *SQL) {
+		p.declaredFeatures = append(p.declaredFeatures, modelFeatures{model: model, features: features})
+	}
+}
+
+// registerFeatures registers the models with declared features and validates them.
+func (p *SQL) registerFeatures() error {
+	for _, declared := range p.declaredFeatures {
+		info := p.model(declared.model)
+		if info.err != nil {
+			return info.err
+		}
+
+		f := declared.features
+
+		var problem string
+		switch {
+		case f.Cache && p.cache == nil:
+			problem = "the cache is enabled without WithCache"
+		case f.Cache && !f.Events:
+			problem = "the cache is enabled without events, which invalidate it"
+		case f.History && strings.ContainsRune(string(info.table.SQLName), '.'):
+			problem = "history isn't supported on schema qualified tables"
+		case f.RLS && f.TenantColumn == "":
+			problem = "row level security is enabled without tenant column"
+		case f.RLS && info.table.FieldsMap[f.TenantColumn] == nil:
+			problem = fmt.Sprintf("the tenant column %s doesn't exist", f.TenantColumn)
+		}
+		if problem != "" {
+			return fmt.Errorf("features of %s: %s", info.name(), problem)
+		}
+
+		p.registry.mu.Lock()
+		info.features = &f
+		p.registry.mu.Unlock()
+	}
+
+	return nil
+}
+
+// features returns the features of the model of resource.
+func (p *SQL) features(resource interface{}) Features {
+	p.registry.mu.RLock()
+	defer p.registry.mu.RUnlock()
+
+	info, ok := p.registry.models[tableOf(resource).Type]
+	if !ok || info.features == nil {
+		return defaultFeatures
+	}
+
+	return *info.features
+}
+
+// ApplyFeatures sets up the declared features needing DDL, enabling the history and row level security of the models
+// declaring them. It's idempotent and meant to be called at startup, after CreateTables or the migrations.
+// Row level security is forced on the table owner too, rows being visible in the transactions whose tenant matches.
+func (p *SQL) ApplyFeatures(ctx context.Context) error {
+	for _, declared := range p.declaredFeatures {
+		f := declared.features
+
+		if f.History {
+			if err := p.EnableHistory(ctx, declared.model); err != nil {
+				return err
+			}
+		}
+
+		if f.RLS {
+			if err := p.enableRLS(ctx, declared.model, f.TenantColumn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// enableRLS enables the row level security of the table of model, with a policy matching the tenant column to the tenant
+// of the transaction.
+func (p *SQL) enableRLS(ctx context.Context, model interface{}, tenantColumn string) error {
+	if err := p.checkDDL(); err != nil {
+		return err
+	}
+
+	table := tableOf(model)
+	policy := pg.Ident(unqualifiedName(table) + "_tenant")
+
+	if err := p.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		for _, q := range []string{
+			formatQuery("ALTER TABLE ? ENABLE ROW LEVEL SECURITY", table.SQLName),
+			formatQuery("ALTER TABLE ? FORCE ROW LEVEL SECURITY", table.SQLName),
+			formatQuery("DROP POLICY IF EXISTS ? ON ?", policy, table.SQLName),
+			formatQuery("CREATE POLICY ? ON ? USING (?::text = current_setting(?, true))",
+				policy, table.SQLName, table.FieldsMap[tenantColumn].Column, tenantSetting),
+		} {
+			if _, err := tx.ExecContext(ctx, q); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("row level security of %s: %w", table.SQLName, err)
+	}
+
+	return nil
+}
+
+// SetTenant sets the tenant of the transaction p is bound to, restricting the rows of the tables with row level security
+// to those of tenant until it ends, see Features.RLS.
+func (p *SQL) SetTenant(ctx context.Context, tenant string) error {
+	if p.tx == nil {
+		return errNotInTransaction
+	}
+
+	_, err := p.tx.ExecContext(ctx, "SELECT set_config(?, ?, true)", tenantSetting, tenant)
+
+	return err
+}
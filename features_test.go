@@ -0,0 +1,111 @@
+package persistsql
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type tenantNote struct {
+	tableName struct{} `pg:"test_tenant_notes"`
+
+	model.Common
+	TenantID string
+	Text     string
+}
+
+func TestRegisterFeatures(t *testing.T) {
+	for _, tt := range []struct {
+		model    interface{}
+		features Features
+		problem  string
+	}{
+		{(*labelledOrder)(nil), Features{Events: true, Cache: true}, "the cache is enabled without WithCache"},
+		{(*qualifiedOrder)(nil), Features{History: true}, "history isn't supported on schema qualified tables"},
+		{(*tenantNote)(nil), Features{RLS: true}, "row level security is enabled without tenant column"},
+		{(*tenantNote)(nil), Features{RLS: true, TenantColumn: "tenant"}, "the tenant column tenant doesn't exist"},
+		{(*tenantNote)(nil), Features{RLS: true, TenantColumn: "tenant_id"}, ""},
+	} {
+		p := &SQL{registry: &registry{models: map[reflect.Type]*modelInfo{}}}
+		WithModelFeatures(tt.model, tt.features)(p)
+
+		err := p.registerFeatures()
+		if tt.problem == "" && err != nil || tt.problem != "" && (err == nil || !strings.HasSuffix(err.Error(), ": "+tt.problem)) {
+			t.Errorf("registerFeatures(%T, %+v) = %v, want %q", tt.model, tt.features, err, tt.problem)
+		}
+
+		if err == nil && p.features((*tenantNote)(nil)) != tt.features {
+			t.Errorf("features() = %+v, want the declared ones", p.features((*tenantNote)(nil)))
+		}
+	}
+
+	p := &SQL{registry: &registry{models: map[reflect.Type]*modelInfo{}}, cache: &resourceCache{}}
+	WithModelFeatures((*labelledOrder)(nil), Features{Cache: true})(p)
+	if err := p.registerFeatures(); err == nil || !strings.Contains(err.Error(), "without events") {
+		t.Errorf("registerFeatures() of the cache without events = %v", err)
+	}
+
+	if f := p.features((*trashedNote)(nil)); f != defaultFeatures {
+		t.Errorf("features() of an undeclared model = %+v, want the defaults", f)
+	}
+
+	if err := p.SetTenant(context.Background(), "t1"); err != errNotInTransaction {
+		t.Errorf("SetTenant() outside of a transaction = %v, want errNotInTransaction", err)
+	}
+}
+
+func TestApplyFeatures(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t, WithModelFeatures((*tenantNote)(nil), Features{Events: true, RLS: true, TenantColumn: "tenant_id"}))
+	testTables(t, p.db, (*tenantNote)(nil))
+
+	for i := 0; i < 2; i++ {
+		if err := p.ApplyFeatures(ctx); err != nil {
+			t.Fatalf("ApplyFeatures() %d: %v", i, err)
+		}
+	}
+
+	var bypass bool
+	if _, err := p.db.QueryOneContext(ctx, pg.Scan(&bypass), "SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user"); err != nil {
+		t.Fatalf("SELECT rolbypassrls: %v", err)
+	}
+	if bypass {
+		t.Skip("the test database user bypasses row level security")
+	}
+
+	for _, tenant := range []string{"t1", "t2"} {
+		if err := p.RunInTransaction(ctx, func(p *SQL) error {
+			if err := p.SetTenant(ctx, tenant); err != nil {
+				return err
+			}
+
+			_, err := p.CreateResource(ctx, &tenantNote{TenantID: tenant, Text: "of " + tenant})
+			return err
+		}); err != nil {
+			t.Fatalf("CreateResource(%s): %v", tenant, err)
+		}
+	}
+
+	var notes []*tenantNote
+	if err := p.RunInTransaction(ctx, func(p *SQL) error {
+		if err := p.SetTenant(ctx, "t1"); err != nil {
+			return err
+		}
+
+		_, err := p.ListResources(ctx, &notes, ListOptions{}, func(*orm.Query) {})
+		return err
+	}); err != nil || len(notes) != 1 || notes[0].TenantID != "t1" {
+		t.Errorf("ListResources() of t1 = %+v, %v, want only its note", notes, err)
+	}
+
+	// Without tenant, no row is visible.
+	if _, err := p.ListResources(ctx, &notes, ListOptions{}, func(*orm.Query) {}); err != nil || len(notes) != 0 {
+		t.Errorf("ListResources() without tenant = %+v, %v, want none", notes, err)
+	}
+}
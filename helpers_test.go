@@ -0,0 +1,71 @@
+package persistsql
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// testDatabaseEnv is the environment variable holding the URL of the database the tests needing one run against,
+// e.g. postgres://postgres@localhost/persistsql_test?sslmode=disable. They're skipped if it's empty.
+const testDatabaseEnv = "PERSISTSQL_TEST_DATABASE"
+
+// testDB returns a connection to the test database with its options changed by configure, closed when t ends,
+// skipping t if there's none.
+func testDB(t *testing.T, configure ...func(opt *pg.Options)) *pg.DB {
+	t.Helper()
+
+	url := os.Getenv(testDatabaseEnv)
+	if url == "" {
+		t.Skipf("%s not set", testDatabaseEnv)
+	}
+
+	opt, err := pg.ParseURL(url)
+	if err != nil {
+		t.Fatalf("pg.ParseURL(): %v", err)
+	}
+
+	for _, fn := range configure {
+		fn(opt)
+	}
+
+	db := pg.Connect(opt)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return db
+}
+
+// testSQL returns a persistence layer on the test database configured by opts, skipping t if there's none.
+func testSQL(t *testing.T, opts ...Option) *SQL {
+	t.Helper()
+
+	p, err := New(testDB(t), opts...)
+	if err != nil {
+		t.Fatalf("New(): %v", err)
+	}
+
+	return p
+}
+
+// testTables creates the tables of models, dropped when t ends.
+func testTables(t *testing.T, db orm.DB, models ...interface{}) {
+	t.Helper()
+
+	ctx := context.Background()
+	for _, model := range models {
+		model := model
+		_ = db.ModelContext(ctx, model).DropTable(&orm.DropTableOptions{IfExists: true, Cascade: true})
+		if err := db.ModelContext(ctx, model).CreateTable(&orm.CreateTableOptions{}); err != nil {
+			t.Fatalf("CreateTable(%T): %v", model, err)
+		}
+
+		t.Cleanup(func() {
+			_ = db.ModelContext(ctx, model).DropTable(&orm.DropTableOptions{IfExists: true, Cascade: true})
+		})
+	}
+}
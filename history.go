@@ -0,0 +1,165 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// ErrNoHistory is returned when reading a model as of a past time without its history enabled, see EnableHistory.
+var ErrNoHistory = errors.New("history not enabled")
+
+type asOfKey struct{}
+
+// AsOf returns a copy of ctx making GetResource and ListResources read resources as they were at t, from their history, see EnableHistory.
+func AsOf(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, asOfKey{}, t)
+}
+
+// EnableHistory records the versions of the rows of the tables of models, so they can be read as of a past time, see AsOf.
+// Each table gets a history table, named after it with a _history suffix, holding its columns and the validity period of each version,
+// maintained by a trigger. Rows existing beforehand are recorded as valid since ever. Periods are measured by the database clock.
+// It's idempotent and meant to be called at startup, like CreateTables, with models of unqualified tables.
+// Columns added to a table later are added to its history table, nullable, and recorded by the trigger once it's called again;
+// until then the trigger keeps recording the columns it was created with.
+func (p *SQL) EnableHistory(ctx context.Context, models ...interface{}) error {
+	if err := p.checkDDL(); err != nil {
+		return err
+	}
+
+	for _, model := range models {
+		info := p.model(model)
+		if strings.ContainsRune(string(info.table.SQLName), '.') {
+			return fmt.Errorf("history of %s: schema qualified tables are not supported", info.table.SQLName)
+		}
+
+		if err := p.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+			var columns []historyColumn
+			if _, err := tx.QueryContext(ctx, &columns, `SELECT attname AS name, format_type(atttypid, atttypmod) AS type
+				FROM pg_attribute WHERE attrelid = ?::regclass AND attnum > 0 AND NOT attisdropped ORDER BY attnum`,
+				string(info.table.SQLName)); err != nil {
+				return err
+			}
+
+			for _, q := range historyDDL(info.table, columns) {
+				if _, err := tx.ExecContext(ctx, q); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("history of %s: %w", info.table.SQLName, err)
+		}
+
+		p.registry.mu.Lock()
+		info.history = true
+		p.registry.mu.Unlock()
+	}
+
+	return nil
+}
+
+// historyColumn is a column of a table with history.
+type historyColumn struct {
+	Name string
+	Type string
+}
+
+// historyDDL returns the statements creating the history table of table, with its columns, and the trigger maintaining it.
+// Columns are listed explicitly, since the ones added later come after the validity period in the history table.
+func historyDDL(table *orm.Table, columns []historyColumn) []string {
+	name := unqualifiedName(table)
+	history := pg.Ident(name + "_history")
+
+	pks := make([]string, len(table.PKs))
+	for i, pk := range table.PKs {
+		pks[i] = string(pk.Column)
+	}
+
+	names := make([]string, len(columns))
+	values := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = quoteIdent(col.Name)
+		values[i] = "NEW." + names[i]
+	}
+	list := pg.Safe(strings.Join(names, ", "))
+
+	stmts := []string{
+		formatQuery(`CREATE TABLE IF NOT EXISTS ? (LIKE ?, valid_from timestamptz NOT NULL, valid_to timestamptz NOT NULL DEFAULT 'infinity')`,
+			history, table.SQLName),
+	}
+	for _, col := range columns {
+		stmts = append(stmts, formatQuery("ALTER TABLE ? ADD COLUMN IF NOT EXISTS ? ?", history, pg.Ident(col.Name), pg.Safe(col.Type)))
+	}
+
+	return append(stmts,
+		formatQuery("CREATE INDEX IF NOT EXISTS ? ON ? (?, valid_from)",
+			pg.Ident(name+"_history_period_idx"), history, pg.Safe(strings.Join(pks, ", "))),
+		formatQuery(`INSERT INTO ? (?, valid_from, valid_to) SELECT ?, '-infinity', 'infinity' FROM ? AS t
+			WHERE NOT EXISTS (SELECT 1 FROM ? AS h WHERE ? AND h.valid_to = 'infinity')`,
+			history, list, list, table.SQLName, history, rowsMatch(pks, "h.", "t.")),
+		formatQuery(`CREATE OR REPLACE FUNCTION ? () RETURNS trigger LANGUAGE plpgsql AS $$
+			BEGIN
+				IF TG_OP IN ('UPDATE', 'DELETE') THEN
+					UPDATE ? SET valid_to = now() WHERE ? AND valid_to = 'infinity';
+				END IF;
+				IF TG_OP IN ('INSERT', 'UPDATE') THEN
+					INSERT INTO ? (?, valid_from, valid_to) SELECT ?, now(), 'infinity';
+				END IF;
+				RETURN NULL;
+			END $$`, history, history, rowsMatch(pks, "", "OLD."), history, list, pg.Safe(strings.Join(values, ", "))),
+		formatQuery("DROP TRIGGER IF EXISTS ? ON ?", history, table.SQLName),
+		formatQuery("CREATE TRIGGER ? AFTER INSERT OR UPDATE OR DELETE ON ? FOR EACH ROW EXECUTE FUNCTION ? ()",
+			history, table.SQLName, history),
+	)
+}
+
+// rowsMatch returns the condition matching the columns of two rows, prefixed by left and right.
+func rowsMatch(columns []string, left, right string) pg.Safe {
+	l := make([]string, len(columns))
+	r := make([]string, len(columns))
+	for i, col := range columns {
+		l[i] = left + col
+		r[i] = right + col
+	}
+
+	return pg.Safe("(" + strings.Join(l, ", ") + ") = (" + strings.Join(r, ", ") + ")")
+}
+
+// asOf makes query, on the table of model, read from its history if ctx reads as of a past time, see AsOf.
+// The table is shadowed by a common table expression of the same name, holding its rows as they were.
+func (p *SQL) asOf(ctx context.Context, db orm.DB, model interface{}, query *orm.Query) error {
+	t, ok := ctx.Value(asOfKey{}).(time.Time)
+	if !ok {
+		return nil
+	}
+
+	info := p.model(model)
+
+	p.registry.mu.RLock()
+	history := info.history
+	p.registry.mu.RUnlock()
+
+	if !history {
+		return fmt.Errorf("%w: %s", ErrNoHistory, info.table.SQLName)
+	}
+
+	columns := make([]string, len(info.table.Fields))
+	for i, field := range info.table.Fields {
+		columns[i] = field.SQLName
+	}
+
+	query.With(info.name(), db.ModelContext(ctx).
+		TableExpr("?", pg.Ident(info.name()+"_history")).
+		Column(columns...).
+		Where("valid_from <= ?", t).
+		Where("valid_to > ?", t))
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+type qualifiedOrder struct {
+	tableName struct{} `pg:"billing.orders"`
+
+	ID int64
+}
+
+func TestHistoryDDL(t *testing.T) {
+	if cond := rowsMatch([]string{`"id"`, `"rev"`}, "h.", "OLD."); cond != `(h."id", h."rev") = (OLD."id", OLD."rev")` {
+		t.Errorf("rowsMatch() = %s", cond)
+	}
+
+	stmts := historyDDL(tableOf((*labelledOrder)(nil)), []historyColumn{{Name: "id", Type: "bigint"}, {Name: "total", Type: "bigint"}})
+	if len(stmts) != 8 {
+		t.Fatalf("historyDDL() = %d statements, want 8:\n%s", len(stmts), strings.Join(stmts, "\n"))
+	}
+
+	for i, prefix := range []string{
+		`CREATE TABLE IF NOT EXISTS "labelled_orders_history" (LIKE "labelled_orders"`,
+		`ALTER TABLE "labelled_orders_history" ADD COLUMN IF NOT EXISTS "id" bigint`,
+		`ALTER TABLE "labelled_orders_history" ADD COLUMN IF NOT EXISTS "total" bigint`,
+		`CREATE INDEX IF NOT EXISTS "labelled_orders_history_period_idx" ON "labelled_orders_history" ("id", valid_from)`,
+		`INSERT INTO "labelled_orders_history" ("id", "total", valid_from, valid_to)`,
+		`CREATE OR REPLACE FUNCTION "labelled_orders_history" ()`,
+		`DROP TRIGGER IF EXISTS "labelled_orders_history" ON "labelled_orders"`,
+		`CREATE TRIGGER "labelled_orders_history" AFTER INSERT OR UPDATE OR DELETE ON "labelled_orders"`,
+	} {
+		if !strings.HasPrefix(stmts[i], prefix) {
+			t.Errorf("statement %d = %s, want prefix %s", i, stmts[i], prefix)
+		}
+	}
+}
+
+func TestAsOfWithoutHistory(t *testing.T) {
+	ctx := context.Background()
+	p := &SQL{maintenance: &maintenance{}, registry: &registry{models: map[reflect.Type]*modelInfo{}}}
+
+	if err := p.asOf(ctx, nil, (*labelledOrder)(nil), nil); err != nil {
+		t.Errorf("asOf() of the present = %v", err)
+	}
+
+	if err := p.asOf(AsOf(ctx, time.Now()), nil, (*labelledOrder)(nil), nil); !errors.Is(err, ErrNoHistory) {
+		t.Errorf("asOf() without history = %v, want ErrNoHistory", err)
+	}
+
+	if err := p.EnableHistory(ctx, (*qualifiedOrder)(nil)); err == nil {
+		t.Error("EnableHistory() of a schema qualified table succeeded")
+	}
+}
+
+func TestAsOf(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*labelledOrder)(nil))
+	t.Cleanup(func() {
+		_, _ = p.db.ExecContext(ctx, "DROP TABLE IF EXISTS labelled_orders_history")
+		_, _ = p.db.ExecContext(ctx, "DROP FUNCTION IF EXISTS labelled_orders_history CASCADE")
+	})
+
+	// Rows existing beforehand are valid since ever.
+	if _, err := p.db.ExecContext(ctx, "INSERT INTO labelled_orders (id, total) VALUES (1, 10)"); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+
+	if err := p.EnableHistory(ctx, (*labelledOrder)(nil)); err != nil {
+		t.Fatalf("EnableHistory(): %v", err)
+	}
+	if err := p.EnableHistory(ctx, (*labelledOrder)(nil)); err != nil {
+		t.Fatalf("EnableHistory() again: %v", err)
+	}
+
+	dbNow := func() time.Time {
+		t.Helper()
+
+		var now time.Time
+		if _, err := p.db.QueryOneContext(ctx, pg.Scan(&now), "SELECT clock_timestamp()"); err != nil {
+			t.Fatalf("clock_timestamp(): %v", err)
+		}
+
+		return now
+	}
+
+	before := dbNow()
+	for _, q := range []string{
+		"UPDATE labelled_orders SET total = 20 WHERE id = 1",
+		"INSERT INTO labelled_orders (id, total) VALUES (2, 5)",
+	} {
+		if _, err := p.db.ExecContext(ctx, q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	updated := dbNow()
+
+	if _, err := p.db.ExecContext(ctx, "DELETE FROM labelled_orders WHERE id = 1"); err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+
+	byID := func(query *orm.Query) { query.Where("id = ?", 1) }
+	for _, tt := range []struct {
+		at    time.Time
+		total int
+	}{
+		{time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), 10},
+		{before, 10},
+		{updated, 20},
+	} {
+		got, err := p.GetResource(AsOf(ctx, tt.at), &labelledOrder{}, false, byID)
+		if order, _ := got.(*labelledOrder); err != nil || order == nil || order.Total != tt.total {
+			t.Errorf("GetResource() as of %v = %+v, %v, want total %d", tt.at, got, err, tt.total)
+		}
+	}
+
+	if got, err := p.GetResource(ctx, &labelledOrder{}, false, byID); err != nil || got != nil {
+		t.Errorf("GetResource() of the deleted order = %+v, %v, want nil", got, err)
+	}
+
+	var orders []*labelledOrder
+	if _, err := p.ListResources(AsOf(ctx, before), &orders, ListOptions{}, func(query *orm.Query) { query.Order("id") }); err != nil || len(orders) != 1 {
+		t.Errorf("ListResources() as of before = %v, %v, want only the first order", orders, err)
+	}
+}
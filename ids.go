@@ -0,0 +1,55 @@
+package persistsql
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator generates the primary keys of the resources created with a zero uuid.UUID primary key, see CreateResource.
+type IDGenerator interface {
+	NewID() uuid.UUID
+}
+
+// randomIDs is the IDGenerator of random, version 4, UUIDs.
+type randomIDs struct{}
+
+// NewID implements IDGenerator.
+func (randomIDs) NewID() uuid.UUID {
+	return uuid.New()
+}
+
+// WithIDGenerator makes a persistence layer generate primary keys with gen instead of random UUIDs.
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(p *SQL) {
+		p.ids = gen
+	}
+}
+
+type idGeneratorKey struct{}
+
+// ContextWithIDGenerator returns a copy of ctx making the persistence layers generate primary keys with gen for the calls made with it.
+func ContextWithIDGenerator(ctx context.Context, gen IDGenerator) context.Context {
+	return context.WithValue(ctx, idGeneratorKey{}, gen)
+}
+
+// assignID sets the primary key of model to a generated ID if it's a zero uuid.UUID.
+func (p *SQL) assignID(ctx context.Context, model interface{}) {
+	table := tableOf(model)
+	if len(table.PKs) != 1 || table.PKs[0].Field.Type != reflect.TypeOf(uuid.UUID{}) {
+		return
+	}
+
+	v := table.PKs[0].Value(reflect.Indirect(reflect.ValueOf(model)))
+	if v.Interface().(uuid.UUID) != uuid.Nil {
+		return
+	}
+
+	gen, ok := ctx.Value(idGeneratorKey{}).(IDGenerator)
+	if !ok {
+		gen = p.ids
+	}
+
+	v.Set(reflect.ValueOf(gen.NewID()))
+}
@@ -0,0 +1,50 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// countingIDs is an IDGenerator of the UUIDs ending with 1, 2, ...
+type countingIDs struct {
+	n byte
+}
+
+func (g *countingIDs) NewID() uuid.UUID {
+	g.n++
+	return uuid.UUID{15: g.n}
+}
+
+func TestAssignID(t *testing.T) {
+	ctx := context.Background()
+	p := &SQL{ids: randomIDs{}}
+	WithIDGenerator(&countingIDs{})(p)
+
+	order := &stampedOrder{}
+	p.assignID(ctx, order)
+	if order.ID != (uuid.UUID{15: 1}) {
+		t.Errorf("assignID() = %v, want the first ID of the generator", order.ID)
+	}
+
+	// Set keys are kept.
+	p.assignID(ctx, order)
+	if order.ID != (uuid.UUID{15: 1}) {
+		t.Errorf("assignID() of a set key = %v", order.ID)
+	}
+
+	ctx = ContextWithIDGenerator(ctx, &countingIDs{n: 41})
+	order = &stampedOrder{}
+	p.assignID(ctx, order)
+	if order.ID != (uuid.UUID{15: 42}) {
+		t.Errorf("assignID() = %v, want the generator of the context", order.ID)
+	}
+
+	// Keys other than a single UUID aren't generated.
+	event := &stampedEvent{}
+	p.assignID(ctx, event)
+	if event.ID != 0 {
+		t.Errorf("assignID() of an int64 key = %d", event.ID)
+	}
+}
@@ -0,0 +1,33 @@
+package persistsql
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrImmutableField is returned when an update lists a column whose struct field is tagged `immutable:"true"`.
+var ErrImmutableField = errors.New("immutable field")
+
+// immutableColumns returns the SQL names of the columns of model tagged `immutable:"true"`.
+func immutableColumns(model interface{}) map[string]bool {
+	columns := map[string]bool{}
+	for _, field := range tableOf(model).Fields {
+		if field.Field.Tag.Get("immutable") == "true" {
+			columns[field.SQLName] = true
+		}
+	}
+
+	return columns
+}
+
+// checkMutable returns an error wrapping ErrImmutableField if any of the columns is immutable in model.
+func checkMutable(model interface{}, columns []string) error {
+	immutable := immutableColumns(model)
+	for _, col := range columns {
+		if immutable[col] {
+			return fmt.Errorf("%w: %s", ErrImmutableField, col)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type immutableAccount struct {
+	tableName struct{} `pg:"test_immutable_accounts"`
+
+	model.Common
+	TenantID string `pg:",notnull" immutable:"true"`
+	Name     string
+}
+
+func TestCheckMutable(t *testing.T) {
+	account := (*immutableAccount)(nil)
+
+	if err := checkMutable(account, []string{"name"}); err != nil {
+		t.Errorf("checkMutable(name) = %v", err)
+	}
+
+	if err := checkMutable(account, nil); err != nil {
+		t.Errorf("checkMutable() = %v", err)
+	}
+
+	// The embedded identity columns and the tenant column are immutable.
+	for _, col := range []string{"id", "create_time", "tenant_id"} {
+		if err := checkMutable(account, []string{"name", col}); !errors.Is(err, ErrImmutableField) {
+			t.Errorf("checkMutable(%s) = %v, want ErrImmutableField", col, err)
+		}
+	}
+
+	want := map[string]bool{"id": true, "create_time": true, "tenant_id": true}
+	if got := immutableColumns(account); len(got) != len(want) {
+		t.Errorf("immutableColumns() = %v, want %v", got, want)
+	}
+}
+
+func TestUpdateImmutableField(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*immutableAccount)(nil))
+
+	res, err := p.CreateResource(ctx, &immutableAccount{TenantID: "t1", Name: "a"})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	created := res.(*immutableAccount)
+
+	update := *created
+	update.TenantID = "t2"
+	update.CreateTime = time.Now().Add(time.Hour)
+	if _, err := p.UpdateResource(ctx, &update, []string{"tenant_id", "create_time"}, WherePK); !errors.Is(err, ErrImmutableField) {
+		t.Fatalf("UpdateResource(tenant_id) = %v, want ErrImmutableField", err)
+	}
+
+	got, err := p.GetResourceByPK(ctx, &immutableAccount{Common: model.Common{ID: created.ID}})
+	if err != nil || got == nil {
+		t.Fatalf("GetResourceByPK() = %v, %v", got, err)
+	}
+
+	if stored := got.(*immutableAccount); stored.TenantID != "t1" || !stored.CreateTime.Equal(created.CreateTime) {
+		t.Errorf("stored %+v, want the immutable fields unchanged", stored)
+	}
+}
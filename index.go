@@ -0,0 +1,155 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// errConcurrentInTx is returned when a concurrent index step is applied inside a transaction.
+var errConcurrentInTx = errors.New("concurrent index operations can't run in a transaction")
+
+// CreateIndexConcurrently is a migration step building an index with CREATE INDEX CONCURRENTLY,
+// which doesn't block writes to the table. It runs outside of the migration transaction.
+// A failed build leaves an invalid index behind, it's dropped before retrying.
+type CreateIndexConcurrently struct {
+	// Name of the index
+	Name string
+	// Table to index, optionally qualified by its schema, e.g. billing.orders; the index is created in the schema of the table.
+	Table string
+	// Columns or expressions indexed, used as is
+	Columns []string
+	// Unique makes a unique index
+	Unique bool
+	// Using is the index method, e.g. gin, defaults to btree.
+	Using string
+	// Where is an optional predicate making a partial index
+	Where string
+	// Retries is the number of times a failed build is retried, defaults to 2.
+	Retries int
+}
+
+// Apply builds the index, dropping any invalid leftover of a previous attempt first.
+func (s CreateIndexConcurrently) Apply(ctx context.Context, db orm.DB) error {
+	if _, ok := db.(*pg.Tx); ok {
+		return errConcurrentInTx
+	}
+
+	retries := s.Retries
+	if retries == 0 {
+		retries = 2
+	}
+
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if err = dropInvalidIndex(ctx, db, s.qualifiedName()); err != nil {
+			return err
+		}
+
+		if _, err = db.ExecContext(ctx, s.query()); err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+
+	if dropErr := dropInvalidIndex(ctx, db, s.qualifiedName()); dropErr != nil {
+		return fmt.Errorf("%v, cleanup failed: %w", err, dropErr)
+	}
+
+	return err
+}
+
+// NonTransactional always returns true: indexes can't be built concurrently inside a transaction.
+func (s CreateIndexConcurrently) NonTransactional() bool {
+	return true
+}
+
+// Statements returns the CREATE INDEX statement.
+func (s CreateIndexConcurrently) Statements() []string {
+	return []string{s.query()}
+}
+
+// qualifiedName returns the name of the index, qualified by the schema of the table if it is.
+func (s CreateIndexConcurrently) qualifiedName() string {
+	if i := strings.LastIndexByte(s.Table, '.'); i >= 0 {
+		return s.Table[:i+1] + s.Name
+	}
+
+	return s.Name
+}
+
+// query returns the CREATE INDEX statement.
+func (s CreateIndexConcurrently) query() string {
+	var b strings.Builder
+	b.WriteString("CREATE ")
+	if s.Unique {
+		b.WriteString("UNIQUE ")
+	}
+	b.WriteString("INDEX CONCURRENTLY IF NOT EXISTS ")
+	b.WriteString(quoteIdent(s.Name))
+	b.WriteString(" ON ")
+	b.WriteString(quoteIdent(s.Table))
+	if s.Using != "" {
+		b.WriteString(" USING ")
+		b.WriteString(s.Using)
+	}
+	b.WriteString(" (")
+	b.WriteString(strings.Join(s.Columns, ", "))
+	b.WriteString(")")
+	if s.Where != "" {
+		b.WriteString(" WHERE ")
+		b.WriteString(s.Where)
+	}
+
+	return b.String()
+}
+
+// DropIndexConcurrently is a migration step dropping an index with DROP INDEX CONCURRENTLY.
+// It runs outside of the migration transaction.
+type DropIndexConcurrently struct {
+	// Name of the index
+	Name string
+}
+
+// Apply drops the index if it exists.
+func (s DropIndexConcurrently) Apply(ctx context.Context, db orm.DB) error {
+	if _, ok := db.(*pg.Tx); ok {
+		return errConcurrentInTx
+	}
+
+	_, err := db.ExecContext(ctx, s.Statements()[0])
+	return err
+}
+
+// Statements returns the DROP INDEX statement.
+func (s DropIndexConcurrently) Statements() []string {
+	return []string{formatQuery("DROP INDEX CONCURRENTLY IF EXISTS ?", pg.Ident(s.Name))}
+}
+
+// NonTransactional always returns true: indexes can't be dropped concurrently inside a transaction.
+func (s DropIndexConcurrently) NonTransactional() bool {
+	return true
+}
+
+// dropInvalidIndex drops the index name if it exists and is marked invalid, as left by a failed concurrent build.
+func dropInvalidIndex(ctx context.Context, db orm.DB, name string) error {
+	var invalid bool
+	if _, err := db.QueryContext(ctx, pg.Scan(&invalid),
+		"SELECT NOT indisvalid FROM pg_index WHERE indexrelid = to_regclass(?)", quoteIdent(name)); err != nil {
+		return err
+	}
+
+	if !invalid {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, "DROP INDEX CONCURRENTLY IF EXISTS ?", pg.Ident(name))
+	return err
+}
@@ -0,0 +1,86 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+)
+
+func TestCreateIndexConcurrentlyQuery(t *testing.T) {
+	for _, tc := range []struct {
+		index     CreateIndexConcurrently
+		query     string
+		qualified string
+	}{
+		{
+			index:     CreateIndexConcurrently{Name: "orders_total_idx", Table: "orders", Columns: []string{"total"}},
+			query:     `CREATE INDEX CONCURRENTLY IF NOT EXISTS "orders_total_idx" ON "orders" (total)`,
+			qualified: "orders_total_idx",
+		},
+		{
+			index: CreateIndexConcurrently{Name: "orders_total_idx", Table: "billing.orders", Columns: []string{"total"},
+				Unique: true, Using: "btree", Where: "total > 0"},
+			query:     `CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS "orders_total_idx" ON "billing"."orders" USING btree (total) WHERE total > 0`,
+			qualified: "billing.orders_total_idx",
+		},
+	} {
+		if query := tc.index.query(); query != tc.query {
+			t.Errorf("query() = %s, want %s", query, tc.query)
+		}
+
+		if name := tc.index.qualifiedName(); name != tc.qualified {
+			t.Errorf("qualifiedName() = %s, want %s", name, tc.qualified)
+		}
+	}
+}
+
+func TestCreateIndexConcurrently(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+
+	if _, err := p.db.ExecContext(ctx, "DROP TABLE IF EXISTS test_indexed"); err != nil {
+		t.Fatalf("DROP TABLE: %v", err)
+	}
+	if _, err := p.db.ExecContext(ctx, "CREATE TABLE test_indexed (id bigserial PRIMARY KEY, code text); INSERT INTO test_indexed (code) VALUES ('a'), ('a')"); err != nil {
+		t.Fatalf("CREATE TABLE: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = p.db.ExecContext(ctx, "DROP TABLE IF EXISTS test_indexed")
+	})
+
+	index := CreateIndexConcurrently{Name: "test_indexed_code_idx", Table: "test_indexed", Columns: []string{"code"}, Unique: true, Retries: 1}
+	exists := func() bool {
+		var exists bool
+		if _, err := p.db.QueryOneContext(ctx, pg.Scan(&exists), "SELECT to_regclass('test_indexed_code_idx') IS NOT NULL"); err != nil {
+			t.Fatalf("to_regclass(): %v", err)
+		}
+
+		return exists
+	}
+
+	// The duplicates fail the build, the invalid index it leaves is dropped.
+	if err := index.Apply(ctx, p.db); err == nil {
+		t.Fatal("Apply() over duplicates succeeded")
+	}
+	if exists() {
+		t.Error("the invalid index wasn't dropped")
+	}
+
+	if _, err := p.db.ExecContext(ctx, "DELETE FROM test_indexed WHERE id = (SELECT max(id) FROM test_indexed)"); err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	if err := index.Apply(ctx, p.db); err != nil || !exists() {
+		t.Fatalf("Apply() = %v, index built: %t", err, exists())
+	}
+
+	if err := p.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		return DropIndexConcurrently{Name: index.Name}.Apply(ctx, tx)
+	}); err != errConcurrentInTx {
+		t.Errorf("Apply() in a transaction = %v, want errConcurrentInTx", err)
+	}
+
+	if err := (DropIndexConcurrently{Name: index.Name}).Apply(ctx, p.db); err != nil || exists() {
+		t.Errorf("DropIndexConcurrently.Apply() = %v, index left: %t", err, exists())
+	}
+}
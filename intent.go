@@ -0,0 +1,126 @@
+package persistsql
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+// Intent records a step of a workflow spanning database writes and external calls, e.g. charging a card once an order is created.
+// Recorded in the transaction of the triggering write and completed once the step is done, the incomplete intents left by a crash
+// are found by RecoverIntents.
+type Intent struct {
+	tableName struct{} `pg:"intents"`
+
+	ID           uuid.UUID       `pg:",pk,type:uuid"`
+	Kind         string          `pg:",notnull"`
+	Payload      json.RawMessage `pg:"type:jsonb"`
+	CreateTime   time.Time       `pg:",notnull"`
+	CompleteTime time.Time
+	Attempts     int `pg:",notnull,use_zero"`
+	LastError    string
+}
+
+// RecordIntent records an intent of kind with payload, marshalled to JSON. To be atomic with the triggering write,
+// it must be called on the persistence layer bound to the transaction of the write, see RunInTransaction.
+func (p *SQL) RecordIntent(ctx context.Context, kind string, payload interface{}) (*Intent, error) {
+	if err := p.checkWrite(); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	intent := &Intent{Kind: kind, Payload: data, CreateTime: p.now(ctx)}
+	p.assignID(ctx, intent)
+
+	if err := p.ensureTable(ctx, intent); err != nil {
+		return nil, err
+	}
+
+	if _, err := p.conn().ModelContext(ctx, intent).Insert(); err != nil {
+		return nil, err
+	}
+
+	return intent, nil
+}
+
+// CompleteIntent marks the intent id complete. Completing an intent again does nothing.
+func (p *SQL) CompleteIntent(ctx context.Context, id uuid.UUID) error {
+	if err := p.checkWrite(); err != nil {
+		return err
+	}
+
+	intent := &Intent{ID: id}
+	if err := p.ensureTable(ctx, intent); err != nil {
+		return err
+	}
+
+	_, err := p.conn().ModelContext(ctx, intent).Set("complete_time = ?", p.now(ctx)).
+		WherePK().Where("complete_time IS NULL").Update()
+
+	return err
+}
+
+// RecoverIntents calls handle with each intent left incomplete for olderThan, oldest first, completing those handled without error
+// and recording the error of the others, retried by the next call. Each intent is locked while handled, so concurrent recoveries
+// handle different intents. It returns the number of intents completed.
+func (p *SQL) RecoverIntents(ctx context.Context, olderThan time.Duration, handle func(ctx context.Context, intent *Intent) error) (int, error) {
+	if err := p.checkWrite(); err != nil {
+		return 0, err
+	}
+
+	if err := p.ensureTable(ctx, (*Intent)(nil)); err != nil {
+		return 0, err
+	}
+
+	cutoff := p.now(ctx).Add(-olderThan)
+	last := &Intent{}
+	completed := 0
+
+	for {
+		found, done := false, false
+
+		// handle can have effects outside of the transaction, which isn't retried on conflicts.
+		err := p.runOnce(ctx, func(tx *pg.Tx) error {
+			intent := &Intent{}
+			if err := tx.ModelContext(ctx, intent).
+				Where("complete_time IS NULL AND create_time <= ?", cutoff).
+				Where("(create_time, id) > (?, ?)", last.CreateTime, last.ID).
+				Order("create_time", "id").Limit(1).For("UPDATE SKIP LOCKED").Select(); err != nil {
+				if err == pg.ErrNoRows {
+					return nil
+				}
+
+				return err
+			}
+
+			found = true
+			last = intent
+
+			query := tx.ModelContext(ctx, intent).WherePK()
+			if err := handle(ctx, intent); err != nil {
+				query.Set("attempts = attempts + 1, last_error = ?", err.Error())
+			} else {
+				query.Set("attempts = attempts + 1, complete_time = ?", p.now(ctx))
+				done = true
+			}
+
+			_, err := query.Update()
+
+			return err
+		})
+		if err != nil || !found {
+			return completed, err
+		}
+
+		if done {
+			completed++
+		}
+	}
+}
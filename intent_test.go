@@ -0,0 +1,84 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecoverIntents(t *testing.T) {
+	recorded := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ctx := ContextWithClock(context.Background(), fixedClock(recorded))
+	p := testSQL(t)
+	testTables(t, p.db, (*Intent)(nil), (*trashedNote)(nil))
+
+	var kept, completed *Intent
+	for _, kind := range []string{"charge", "email", "ship"} {
+		kind := kind
+		if err := p.RunInTransaction(ctx, func(p *SQL) error {
+			if _, err := p.CreateResource(ctx, &trashedNote{Folder: kind}); err != nil {
+				return err
+			}
+
+			intent, err := p.RecordIntent(ctx, kind, map[string]string{"folder": kind})
+			if err != nil {
+				return err
+			}
+
+			switch kind {
+			case "charge":
+				kept = intent
+			case "email":
+				completed = intent
+			case "ship":
+				// Rolled back with the write.
+				return errors.New("out of stock")
+			}
+
+			return nil
+		}); err != nil && kind != "ship" {
+			t.Fatalf("RunInTransaction(%s): %v", kind, err)
+		}
+	}
+
+	if err := p.CompleteIntent(ctx, completed.ID); err != nil {
+		t.Fatalf("CompleteIntent(): %v", err)
+	}
+	if err := p.CompleteIntent(ctx, completed.ID); err != nil {
+		t.Errorf("CompleteIntent() again = %v", err)
+	}
+
+	handle := func(err error) func(ctx context.Context, intent *Intent) error {
+		return func(ctx context.Context, intent *Intent) error {
+			if intent.ID != kept.ID || string(intent.Payload) != `{"folder":"charge"}` {
+				t.Errorf("handled %+v, want the charge intent", intent)
+			}
+
+			return err
+		}
+	}
+
+	// Too recent to be recovered.
+	if n, err := p.RecoverIntents(ctx, time.Hour, handle(nil)); err != nil || n != 0 {
+		t.Errorf("RecoverIntents() of recent intents = %d, %v, want none", n, err)
+	}
+
+	later := ContextWithClock(context.Background(), fixedClock(recorded.Add(2*time.Hour)))
+	if n, err := p.RecoverIntents(later, time.Hour, handle(errors.New("card declined"))); err != nil || n != 0 {
+		t.Errorf("RecoverIntents() failing = %d, %v, want none completed", n, err)
+	}
+
+	failed := &Intent{ID: kept.ID}
+	if err := p.db.Model(failed).WherePK().Select(); err != nil || failed.Attempts != 1 || failed.LastError != "card declined" {
+		t.Errorf("failed intent = %+v, %v, want the attempt recorded", failed, err)
+	}
+
+	if n, err := p.RecoverIntents(later, time.Hour, handle(nil)); err != nil || n != 1 {
+		t.Errorf("RecoverIntents() = %d, %v, want the charge intent completed", n, err)
+	}
+
+	if n, err := p.RecoverIntents(later, time.Hour, handle(nil)); err != nil || n != 0 {
+		t.Errorf("RecoverIntents() once recovered = %d, %v, want none", n, err)
+	}
+}
@@ -0,0 +1,153 @@
+// Package resourceapi holds the request handling shared by the persistsqlhttp and persistsqlgrpc servers:
+// page tokens, ordering and filtering of lists, and decoding of keys.
+package resourceapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql"
+)
+
+// ErrInvalidPageToken is returned when decoding a page token which wasn't returned by EncodePageToken.
+var ErrInvalidPageToken = errors.New("invalid page_token")
+
+// EncodePageToken returns the page token of the page starting at offset.
+func EncodePageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// DecodePageToken returns the offset of the page of token, 0 if it's empty.
+func DecodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidPageToken
+	}
+
+	offset, err := strconv.Atoi(string(b))
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidPageToken
+	}
+
+	return offset, nil
+}
+
+// Order orders a list by a column.
+type Order struct {
+	Field *orm.Field
+	Desc  bool
+}
+
+// ParseOrderBy parses an order_by parameter of a list of table, "column [asc|desc]", none if s is empty.
+func ParseOrderBy(table *orm.Table, s string) (Order, error) {
+	if s == "" {
+		return Order{}, nil
+	}
+
+	col, dir, _ := strings.Cut(s, " ")
+	field, ok := table.FieldsMap[col]
+	if !ok || dir != "" && !strings.EqualFold(dir, "asc") && !strings.EqualFold(dir, "desc") {
+		return Order{}, fmt.Errorf("invalid order_by %q", s)
+	}
+
+	return Order{Field: field, Desc: strings.EqualFold(dir, "desc")}, nil
+}
+
+// Filter selects the resources whose column has a value.
+type Filter struct {
+	Field *orm.Field
+	Value string
+}
+
+// ParseFilter returns the filter of a list of table on col, which mustn't be tagged `filter:"-"`.
+func ParseFilter(table *orm.Table, col, value string) (Filter, error) {
+	field, ok := table.FieldsMap[col]
+	if !ok || field.Field.Tag.Get("filter") == "-" {
+		return Filter{}, fmt.Errorf("can't filter on %q", col)
+	}
+
+	return Filter{Field: field, Value: value}, nil
+}
+
+// Page is a page of a list of the resources of Table, ordered by Order then by primary key.
+type Page struct {
+	Table   *orm.Table
+	Filters []Filter
+	Order   Order
+	Offset  int
+	Size    int
+}
+
+// Hook is the QueryHook selecting the resources of the page, and the first one of the next page to tell whether there's one, see Next.
+func (p Page) Hook(query *orm.Query) {
+	for _, f := range p.Filters {
+		query.Where("?TableAlias.? = ?", f.Field.Column, f.Value)
+	}
+
+	if p.Order.Field != nil && p.Order.Desc {
+		query.OrderExpr("?TableAlias.? DESC", p.Order.Field.Column)
+	} else if p.Order.Field != nil {
+		query.OrderExpr("?TableAlias.?", p.Order.Field.Column)
+	}
+
+	query.OrderExpr("?TableAlias.?", p.Table.PKs[0].Column).Offset(p.Offset).Limit(p.Size + 1)
+}
+
+// Next truncates rows, the slice of resources selected by Hook, to the page and returns the token of the next page,
+// empty if there's none.
+func (p Page) Next(rows reflect.Value) string {
+	if rows.Len() <= p.Size {
+		return ""
+	}
+
+	rows.SetLen(p.Size)
+
+	return EncodePageToken(p.Offset + p.Size)
+}
+
+// SetKey sets the primary key of res, a resource of table, to id, decoded as a JSON string, or else a JSON value, e.g. a number.
+func SetKey(table *orm.Table, res persistsql.Resource, id string) error {
+	key := table.PKs[0].Value(reflect.ValueOf(res).Elem()).Addr().Interface()
+	if err := json.Unmarshal([]byte(strconv.Quote(id)), key); err != nil {
+		if err := json.Unmarshal([]byte(id), key); err != nil {
+			return fmt.Errorf("invalid id %q", id)
+		}
+	}
+
+	return nil
+}
+
+// CheckCreate returns an error if res, a resource of table to create, sets an output only field, see persistsql.IsFieldOutputOnly.
+func CheckCreate(table *orm.Table, res persistsql.Resource) error {
+	v := reflect.ValueOf(res).Elem()
+	for _, field := range table.Fields {
+		if persistsql.IsFieldOutputOnly(res, field.SQLName) && !field.Value(v).IsZero() {
+			return fmt.Errorf("can't set %q", field.SQLName)
+		}
+	}
+
+	return nil
+}
+
+// CheckUpdate returns an error if fields, the columns to update of res, a resource of table, aren't columns of table
+// or are output only, see persistsql.IsFieldOutputOnly.
+func CheckUpdate(table *orm.Table, res persistsql.Resource, fields []string) error {
+	for _, field := range fields {
+		if _, ok := table.FieldsMap[field]; !ok || persistsql.IsFieldOutputOnly(res, field) {
+			return fmt.Errorf("can't update %q", field)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package resourceapi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+type apiOrder struct {
+	ID       int64
+	Customer string
+	Secret   string `filter:"-"`
+	Total    int
+	Status   string
+}
+
+func (*apiOrder) IsFieldOutputOnly(field string) bool {
+	return field == "status"
+}
+
+var apiOrders = orm.GetTable(reflect.TypeOf(apiOrder{}))
+
+func TestPageToken(t *testing.T) {
+	for _, offset := range []int{0, 1, 50, 1000} {
+		if got, err := DecodePageToken(EncodePageToken(offset)); err != nil || got != offset {
+			t.Errorf("DecodePageToken(EncodePageToken(%d)) = %d, %v", offset, got, err)
+		}
+	}
+
+	if offset, err := DecodePageToken(""); err != nil || offset != 0 {
+		t.Errorf("DecodePageToken(\"\") = %d, %v, want 0", offset, err)
+	}
+
+	for _, token := range []string{"!", EncodePageToken(-1), "YQ"} {
+		if _, err := DecodePageToken(token); err != ErrInvalidPageToken {
+			t.Errorf("DecodePageToken(%q) = %v, want ErrInvalidPageToken", token, err)
+		}
+	}
+}
+
+func TestParseOrderBy(t *testing.T) {
+	for s, want := range map[string]Order{
+		"":           {},
+		"total":      {Field: apiOrders.FieldsMap["total"]},
+		"total asc":  {Field: apiOrders.FieldsMap["total"]},
+		"total DESC": {Field: apiOrders.FieldsMap["total"], Desc: true},
+	} {
+		if got, err := ParseOrderBy(apiOrders, s); err != nil || got != want {
+			t.Errorf("ParseOrderBy(%q) = %+v, %v, want %+v", s, got, err, want)
+		}
+	}
+
+	for _, s := range []string{"nope", "total sideways"} {
+		if _, err := ParseOrderBy(apiOrders, s); err == nil {
+			t.Errorf("ParseOrderBy(%q) succeeded", s)
+		}
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	if f, err := ParseFilter(apiOrders, "customer", "c1"); err != nil || f.Field.SQLName != "customer" || f.Value != "c1" {
+		t.Errorf("ParseFilter(customer) = %+v, %v", f, err)
+	}
+
+	for _, col := range []string{"secret", "nope"} {
+		if _, err := ParseFilter(apiOrders, col, "x"); err == nil {
+			t.Errorf("ParseFilter(%s) succeeded", col)
+		}
+	}
+}
+
+func TestPage(t *testing.T) {
+	page := Page{
+		Table:   apiOrders,
+		Filters: []Filter{{Field: apiOrders.FieldsMap["customer"], Value: "c1"}},
+		Order:   Order{Field: apiOrders.FieldsMap["total"], Desc: true},
+		Offset:  4,
+		Size:    2,
+	}
+
+	query := orm.NewQuery(nil, (*apiOrder)(nil))
+	page.Hook(query)
+	sel := orm.NewSelectQuery(query)
+	b, err := sel.AppendQuery(orm.NewFormatter().WithModel(sel), nil)
+	if err != nil {
+		t.Fatalf("AppendQuery(): %v", err)
+	}
+
+	want := `WHERE ("api_order"."customer" = 'c1') ORDER BY "api_order"."total" DESC, "api_order"."id" LIMIT 3 OFFSET 4`
+	if !strings.HasSuffix(string(b), want) {
+		t.Errorf("query = %s, want suffix %s", b, want)
+	}
+
+	rows := reflect.ValueOf(&[]*apiOrder{{ID: 1}, {ID: 2}, {ID: 3}}).Elem()
+	if token := page.Next(rows); token != EncodePageToken(6) || rows.Len() != 2 {
+		t.Errorf("Next() = %q with %d rows, want the token of offset 6 with 2 rows", token, rows.Len())
+	}
+
+	if token := page.Next(rows); token != "" {
+		t.Errorf("Next() of the last page = %q, want empty", token)
+	}
+}
+
+func TestSetKey(t *testing.T) {
+	res := &apiOrder{}
+	if err := SetKey(apiOrders, res, "42"); err != nil || res.ID != 42 {
+		t.Errorf("SetKey(42) = %v, ID %d", err, res.ID)
+	}
+
+	if err := SetKey(apiOrders, res, "x"); err == nil {
+		t.Error("SetKey(x) succeeded")
+	}
+}
+
+func TestCheckCreateUpdate(t *testing.T) {
+	if err := CheckCreate(apiOrders, &apiOrder{Customer: "c1"}); err != nil {
+		t.Errorf("CheckCreate() = %v", err)
+	}
+
+	if err := CheckCreate(apiOrders, &apiOrder{Status: "paid"}); err == nil {
+		t.Error("CheckCreate() of an output only field succeeded")
+	}
+
+	if err := CheckUpdate(apiOrders, &apiOrder{}, []string{"total"}); err != nil {
+		t.Errorf("CheckUpdate(total) = %v", err)
+	}
+
+	for _, field := range []string{"status", "nope"} {
+		if err := CheckUpdate(apiOrders, &apiOrder{}, []string{field}); err == nil {
+			t.Errorf("CheckUpdate(%s) succeeded", field)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package persistsql
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// TableInfo describes a live table, see IntrospectTables.
+type TableInfo struct {
+	Schema  string       `json:"schema"`
+	Name    string       `json:"name"`
+	Columns []ColumnInfo `json:"columns"`
+}
+
+// ColumnInfo describes a live column.
+type ColumnInfo struct {
+	Name string `json:"name"`
+	// Type is the udt_name of the type, e.g. int8 or _text for text[]
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+	// Default is the default expression, empty if there's none
+	Default    string `json:"default,omitempty"`
+	PrimaryKey bool   `json:"primary_key,omitempty"`
+}
+
+// IntrospectTables returns the base tables of schema, "public" if empty, ordered by name with their columns in ordinal order.
+// Only the tables named are returned if any. It reads the live schema, registered models or not, e.g. to generate models.
+func (p *SQL) IntrospectTables(ctx context.Context, schema string, tables ...string) ([]TableInfo, error) {
+	if schema == "" {
+		schema = "public"
+	}
+
+	only := pg.Safe("")
+	if len(tables) > 0 {
+		only = pg.Safe(formatQuery("AND c.table_name IN (?)", pg.In(tables)))
+	}
+
+	var columns []struct {
+		Table string
+		ColumnInfo
+	}
+	if _, err := p.db.QueryContext(ctx, &columns, `
+		SELECT c.table_name AS "table", c.column_name AS name, c.udt_name AS type, c.is_nullable = 'YES' AS nullable,
+			coalesce(c.column_default, '') AS "default",
+			EXISTS (
+				SELECT 1 FROM information_schema.table_constraints tc
+				JOIN information_schema.key_column_usage k USING (constraint_schema, constraint_name)
+				WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = c.table_schema AND tc.table_name = c.table_name
+					AND k.column_name = c.column_name
+			) AS primary_key
+		FROM information_schema.columns c
+		JOIN information_schema.tables t USING (table_schema, table_name)
+		WHERE c.table_schema = ? AND t.table_type = 'BASE TABLE' ?
+		ORDER BY c.table_name, c.ordinal_position`, schema, only); err != nil {
+		return nil, err
+	}
+
+	var infos []TableInfo
+	for _, col := range columns {
+		if len(infos) == 0 || infos[len(infos)-1].Name != col.Table {
+			infos = append(infos, TableInfo{Schema: schema, Name: col.Table})
+		}
+		info := &infos[len(infos)-1]
+		info.Columns = append(info.Columns, col.ColumnInfo)
+	}
+
+	return infos, nil
+}
@@ -0,0 +1,40 @@
+package persistsql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestIntrospectTables(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+
+	t.Cleanup(func() {
+		_, _ = p.db.ExecContext(ctx, "DROP TABLE IF EXISTS test_introspected; DROP VIEW IF EXISTS test_introspected_view")
+	})
+	if _, err := p.db.ExecContext(ctx, `
+		DROP TABLE IF EXISTS test_introspected CASCADE;
+		CREATE TABLE test_introspected (id bigserial PRIMARY KEY, name text NOT NULL DEFAULT 'x', tags text[]);
+		CREATE OR REPLACE VIEW test_introspected_view AS SELECT id FROM test_introspected`); err != nil {
+		t.Fatalf("CREATE TABLE: %v", err)
+	}
+
+	tables, err := p.IntrospectTables(ctx, "", "test_introspected", "test_introspected_view", "test_missing")
+	if err != nil {
+		t.Fatalf("IntrospectTables(): %v", err)
+	}
+
+	want := []TableInfo{{Schema: "public", Name: "test_introspected", Columns: []ColumnInfo{
+		{Name: "id", Type: "int8", Default: "nextval('test_introspected_id_seq'::regclass)", PrimaryKey: true},
+		{Name: "name", Type: "text", Default: "'x'::text"},
+		{Name: "tags", Type: "_text", Nullable: true},
+	}}}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("IntrospectTables() = %+v, want %+v", tables, want)
+	}
+
+	if tables, err := p.IntrospectTables(ctx, "test_no_such_schema"); err != nil || len(tables) != 0 {
+		t.Errorf("IntrospectTables() of a missing schema = %+v, %v", tables, err)
+	}
+}
@@ -0,0 +1,186 @@
+package persistsql
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// Defaults of Loader.
+const (
+	defaultLoaderWait  = 2 * time.Millisecond
+	defaultLoaderBatch = 100
+)
+
+// Loader loads resources of a collection by primary key in batches, for dataloader-style GraphQL resolvers: the keys requested
+// concurrently during a short wait are loaded with a single WHERE IN query, and the results are cached for the lifetime of the loader.
+// A loader is meant to be created per request, it isn't invalidated by writes, see Clear.
+type Loader struct {
+	// Wait is how long keys are collected before a batch is loaded, 2ms by default
+	Wait time.Duration
+	// MaxBatch is the maximum number of keys loaded by a query, a full batch is loaded without waiting, 100 by default
+	MaxBatch int
+
+	p     *SQL
+	ctx   context.Context
+	table *orm.Table
+
+	mu      sync.Mutex
+	results map[string]*loadResult
+	batch   []*loadResult
+	timer   *time.Timer
+}
+
+// loadResult is the result of loading a key, available once done is closed.
+type loadResult struct {
+	key  string
+	done chan struct{}
+	res  Resource
+	err  error
+}
+
+// Loader creates a Loader of the resources of the collection of model, a pointer to a model with a single column primary key.
+// The resources are loaded with ctx, soft-deleted ones excluded.
+func (p *SQL) Loader(ctx context.Context, model interface{}) (*Loader, error) {
+	table := tableOf(model)
+	if len(table.PKs) != 1 {
+		return nil, errCompositeKey
+	}
+
+	return &Loader{
+		Wait:     defaultLoaderWait,
+		MaxBatch: defaultLoaderBatch,
+		p:        p,
+		ctx:      ctx,
+		table:    table,
+		results:  map[string]*loadResult{},
+	}, nil
+}
+
+// Load returns the resource with the primary key key, formatted as by PrimaryKey, nil if there's none.
+// ctx only bounds the wait for the result.
+func (l *Loader) Load(ctx context.Context, key string) (Resource, error) {
+	result := l.enqueue(key)
+
+	select {
+	case <-result.done:
+		return result.res, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// LoadMany returns the resources with the primary keys keys, in the same order, nil for those which don't exist.
+// The first error met is returned.
+func (l *Loader) LoadMany(ctx context.Context, keys []string) ([]Resource, error) {
+	results := make([]*loadResult, len(keys))
+	for i, key := range keys {
+		results[i] = l.enqueue(key)
+	}
+
+	resources := make([]Resource, len(keys))
+	for i, result := range results {
+		select {
+		case <-result.done:
+			if result.err != nil {
+				return nil, result.err
+			}
+			resources[i] = result.res
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return resources, nil
+}
+
+// Prime caches res, e.g. just created, so that loading it doesn't query the database.
+func (l *Loader) Prime(res Resource) {
+	done := make(chan struct{})
+	close(done)
+
+	l.mu.Lock()
+	l.results[primaryKey(res)] = &loadResult{done: done, res: res}
+	l.mu.Unlock()
+}
+
+// Clear removes the cached result of key, e.g. after updating its resource or failing to load it, so that it's loaded again.
+func (l *Loader) Clear(key string) {
+	l.mu.Lock()
+	delete(l.results, key)
+	l.mu.Unlock()
+}
+
+// enqueue returns the result of key, cached or pending, adding key to the batch if it isn't.
+func (l *Loader) enqueue(key string) *loadResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if result, ok := l.results[key]; ok {
+		return result
+	}
+
+	result := &loadResult{key: key, done: make(chan struct{})}
+	l.results[key] = result
+	l.batch = append(l.batch, result)
+
+	switch {
+	case len(l.batch) >= l.MaxBatch:
+		if l.timer != nil {
+			l.timer.Stop()
+		}
+		go l.load(l.take())
+	case len(l.batch) == 1:
+		l.timer = time.AfterFunc(l.Wait, func() {
+			l.mu.Lock()
+			batch := l.take()
+			l.mu.Unlock()
+
+			l.load(batch)
+		})
+	}
+
+	return result
+}
+
+// take returns the batch and starts a new one. l.mu must be held.
+func (l *Loader) take() []*loadResult {
+	batch := l.batch
+	l.batch = nil
+
+	return batch
+}
+
+// load loads the resources of batch with a single query and completes its results.
+func (l *Loader) load(batch []*loadResult) {
+	if len(batch) == 0 {
+		return
+	}
+
+	keys := make([]string, len(batch))
+	for i, result := range batch {
+		keys[i] = result.key
+	}
+
+	list := reflect.New(reflect.SliceOf(reflect.PtrTo(l.table.Type)))
+	_, err := l.p.ListResources(l.ctx, list.Interface(), ListOptions{}, func(query *orm.Query) {
+		query.Where("?TableAlias.? IN (?)", l.table.PKs[0].Column, pg.In(keys))
+	})
+
+	found := map[string]Resource{}
+	if err == nil {
+		for i := 0; i < list.Elem().Len(); i++ {
+			res := list.Elem().Index(i).Interface()
+			found[primaryKey(res)] = res
+		}
+	}
+
+	for _, result := range batch {
+		result.res, result.err = found[result.key], err
+		close(result.done)
+	}
+}
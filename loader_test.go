@@ -0,0 +1,103 @@
+package persistsql
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoaderCache(t *testing.T) {
+	p := &SQL{}
+	if _, err := p.Loader(context.Background(), (*versionedNode)(nil)); err != errCompositeKey {
+		t.Errorf("Loader() of a composite key = %v, want errCompositeKey", err)
+	}
+
+	l, err := p.Loader(context.Background(), (*labelledOrder)(nil))
+	if err != nil {
+		t.Fatalf("Loader(): %v", err)
+	}
+
+	primed := &labelledOrder{ID: 1, Total: 10}
+	l.Prime(primed)
+
+	// Primed resources are loaded without querying.
+	if res, err := l.Load(context.Background(), "1"); err != nil || res != primed {
+		t.Errorf("Load() of a primed resource = %+v, %v", res, err)
+	}
+
+	if res, err := l.LoadMany(context.Background(), []string{"1", "1"}); err != nil || len(res) != 2 || res[1] != primed {
+		t.Errorf("LoadMany() of a primed resource = %+v, %v", res, err)
+	}
+
+	// Keys not loaded yet wait for their batch, until ctx is done.
+	l.Clear("1")
+	l.Wait = time.Hour
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if res, err := l.Load(canceled, "1"); err != context.Canceled || res != nil {
+		t.Errorf("Load() canceled = %+v, %v, want context.Canceled", res, err)
+	}
+
+	l.mu.Lock()
+	l.timer.Stop()
+	if batch := l.take(); len(batch) != 1 || batch[0].key != "1" {
+		t.Errorf("batch = %+v, want the cleared key", batch)
+	}
+	l.mu.Unlock()
+}
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+
+	var queries int32
+	p := testSQL(t, WithQueryObserver(func(ctx context.Context, info QueryInfo) {
+		atomic.AddInt32(&queries, 1)
+	}))
+	testTables(t, p.db, (*labelledOrder)(nil))
+
+	if _, err := p.db.ExecContext(ctx, "INSERT INTO labelled_orders (id, total) SELECT i, i * 10 FROM generate_series(1, 3) i"); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+	atomic.StoreInt32(&queries, 0)
+
+	l, err := p.Loader(ctx, (*labelledOrder)(nil))
+	if err != nil {
+		t.Fatalf("Loader(): %v", err)
+	}
+
+	// Concurrent loads are batched.
+	var wg sync.WaitGroup
+	for _, key := range []string{"1", "2", "4"} {
+		key := key
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			res, err := l.Load(ctx, key)
+			if err != nil {
+				t.Errorf("Load(%s): %v", key, err)
+			} else if order, _ := res.(*labelledOrder); (key == "4") != (order == nil) || order != nil && primaryKey(order) != key {
+				t.Errorf("Load(%s) = %+v", key, res)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&queries); n != 1 {
+		t.Errorf("%d queries, want a single batch", n)
+	}
+
+	// Loaded keys are cached, full batches are loaded without waiting.
+	l.MaxBatch, l.Wait = 2, time.Hour
+	resources, err := l.LoadMany(ctx, []string{"3", "1", "2", "5"})
+	if err != nil || len(resources) != 4 || resources[0].(*labelledOrder).Total != 30 || resources[1].(*labelledOrder).Total != 10 || resources[3] != nil {
+		t.Errorf("LoadMany() = %+v, %v", resources, err)
+	}
+
+	if n := atomic.LoadInt32(&queries); n != 2 {
+		t.Errorf("%d queries, want another for the new keys", n)
+	}
+}
@@ -0,0 +1,61 @@
+package persistsql
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrMaintenance is returned for operations rejected while in maintenance mode.
+var ErrMaintenance = errors.New("maintenance mode")
+
+// maintenance holds the maintenance mode switch of an SQL persistence layer.
+type maintenance struct {
+	mu          sync.RWMutex
+	active      bool
+	blockWrites bool
+}
+
+// StartMaintenance puts the persistence layer in maintenance mode: DDL is rejected with ErrMaintenance,
+// and so are writes if blockWrites is true. Reads are never affected.
+func (p *SQL) StartMaintenance(blockWrites bool) {
+	p.maintenance.mu.Lock()
+	defer p.maintenance.mu.Unlock()
+
+	p.maintenance.active = true
+	p.maintenance.blockWrites = blockWrites
+}
+
+// StopMaintenance leaves maintenance mode.
+func (p *SQL) StopMaintenance() {
+	p.maintenance.mu.Lock()
+	defer p.maintenance.mu.Unlock()
+
+	p.maintenance.active = false
+	p.maintenance.blockWrites = false
+}
+
+// Maintenance reports whether maintenance mode is active and whether writes are blocked.
+func (p *SQL) Maintenance() (active bool, blockWrites bool) {
+	p.maintenance.mu.RLock()
+	defer p.maintenance.mu.RUnlock()
+
+	return p.maintenance.active, p.maintenance.blockWrites
+}
+
+// checkDDL returns ErrMaintenance if DDL is currently rejected.
+func (p *SQL) checkDDL() error {
+	if active, _ := p.Maintenance(); active {
+		return ErrMaintenance
+	}
+
+	return nil
+}
+
+// checkWrite returns ErrMaintenance if writes are currently rejected.
+func (p *SQL) checkWrite() error {
+	if _, blockWrites := p.Maintenance(); blockWrites {
+		return ErrMaintenance
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type maintainedNote struct {
+	tableName struct{} `pg:"test_maintained_notes"`
+
+	model.Common
+	Text string
+}
+
+func TestMaintenanceSwitch(t *testing.T) {
+	p := &SQL{maintenance: &maintenance{}}
+
+	if err := p.checkDDL(); err != nil {
+		t.Errorf("checkDDL() = %v", err)
+	}
+
+	p.StartMaintenance(false)
+	if active, blockWrites := p.Maintenance(); !active || blockWrites {
+		t.Errorf("Maintenance() = %t, %t, want true, false", active, blockWrites)
+	}
+	if err := p.checkDDL(); err != ErrMaintenance {
+		t.Errorf("checkDDL() = %v, want ErrMaintenance", err)
+	}
+	if err := p.checkWrite(); err != nil {
+		t.Errorf("checkWrite() = %v", err)
+	}
+
+	p.StartMaintenance(true)
+	if err := p.checkWrite(); err != ErrMaintenance {
+		t.Errorf("checkWrite() = %v, want ErrMaintenance", err)
+	}
+
+	p.StopMaintenance()
+	if active, blockWrites := p.Maintenance(); active || blockWrites {
+		t.Errorf("Maintenance() = %t, %t after StopMaintenance", active, blockWrites)
+	}
+	if err := p.checkDDL(); err != nil {
+		t.Errorf("checkDDL() = %v", err)
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*maintainedNote)(nil))
+
+	res, err := p.CreateResource(ctx, &maintainedNote{Text: "a"})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	note := res.(*maintainedNote)
+
+	p.StartMaintenance(true)
+	defer p.StopMaintenance()
+
+	if err := p.CreateTables(ctx, []interface{}{(*maintainedNote)(nil)}, nil); err != ErrMaintenance {
+		t.Errorf("CreateTables() = %v, want ErrMaintenance", err)
+	}
+
+	if _, err := p.CreateResource(ctx, &maintainedNote{Text: "b"}); err != ErrMaintenance {
+		t.Errorf("CreateResource() = %v, want ErrMaintenance", err)
+	}
+
+	if _, err := p.DeleteResource(ctx, note, nil); err != ErrMaintenance {
+		t.Errorf("DeleteResource() = %v, want ErrMaintenance", err)
+	}
+
+	if got, err := p.GetResourceByPK(ctx, &maintainedNote{Common: model.Common{ID: note.ID}}); err != nil || got == nil {
+		t.Errorf("GetResourceByPK() = %v, %v, reads must still work", got, err)
+	}
+}
@@ -0,0 +1,72 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// ErrTooManyRows is wrapped by TooManyRowsError.
+var ErrTooManyRows = errors.New("too many rows")
+
+// TooManyRowsError is returned by ListResources when the query matches more rows than ListOptions.MaxRows.
+type TooManyRowsError struct {
+	// Count is the number of rows the query matches
+	Count int
+	// Max is the number of rows allowed
+	Max int
+}
+
+// Error implements error.
+func (e *TooManyRowsError) Error() string {
+	return fmt.Sprintf("%s: %d rows, at most %d allowed", ErrTooManyRows, e.Count, e.Max)
+}
+
+// Unwrap returns ErrTooManyRows.
+func (e *TooManyRowsError) Unwrap() error {
+	return ErrTooManyRows
+}
+
+// checkMaxRows returns a TooManyRowsError if query, built by a QueryHook, matches more than max rows on db.
+// No more than max+1 rows are scanned: the query is wrapped, so a LIMIT set by the hook still applies, and the matching rows
+// are only counted, exactly, if there are too many.
+func checkMaxRows(ctx context.Context, db orm.DB, query *orm.Query, max int) error {
+	sel := orm.NewSelectQuery(query)
+
+	var count int
+	if _, err := db.QueryOneContext(ctx, pg.Scan(&count), "SELECT count(*) FROM (SELECT FROM (?) AS _rows LIMIT ?) AS _capped",
+		sel, max+1, query.TableModel()); err != nil {
+		return err
+	}
+
+	if count <= max {
+		return nil
+	}
+
+	if _, err := db.QueryOneContext(ctx, pg.Scan(&count), "SELECT count(*) FROM (?) AS _rows", sel, query.TableModel()); err != nil {
+		return err
+	}
+
+	return &TooManyRowsError{Count: count, Max: max}
+}
+
+// fitBytes returns how many of the first rows of query, built by a QueryHook, fit in maxBytes, by their pg_column_size,
+// and whether rows were left out. The rows are sized on the server, without being transferred.
+func fitBytes(ctx context.Context, db orm.DB, query *orm.Query, maxBytes int64) (int, bool, error) {
+	var fit struct {
+		Rows      int
+		Truncated bool
+	}
+
+	if _, err := db.QueryOneContext(ctx, &fit, `
+		SELECT count(*) FILTER (WHERE _bytes <= ?0) AS rows, coalesce(bool_or(_bytes > ?0), false) AS truncated
+		FROM (SELECT sum(pg_column_size(_rows.*)) OVER (ROWS UNBOUNDED PRECEDING) AS _bytes FROM (?1) AS _rows) AS _sizes`,
+		maxBytes, orm.NewSelectQuery(query), query.TableModel()); err != nil {
+		return 0, false, err
+	}
+
+	return fit.Rows, fit.Truncated, nil
+}
@@ -0,0 +1,78 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/google/uuid"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type listedAuthor struct {
+	tableName struct{} `pg:"test_listed_authors"`
+
+	model.Common
+	Name string
+}
+
+type listedBook struct {
+	tableName struct{} `pg:"test_listed_books"`
+
+	model.Common
+	Title    string
+	AuthorID uuid.UUID     `pg:",type:uuid"`
+	Author   *listedAuthor `pg:"rel:has-one"`
+}
+
+func TestListResourcesLimits(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*listedAuthor)(nil), (*listedBook)(nil))
+
+	created, err := p.CreateResource(ctx, &listedAuthor{Name: "Ursula"})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	author := created.(*listedAuthor)
+
+	for _, title := range []string{"a", "b", "c"} {
+		if _, err := p.CreateResource(ctx, &listedBook{Title: title, AuthorID: author.ID}); err != nil {
+			t.Fatalf("CreateResource(): %v", err)
+		}
+	}
+
+	withAuthor := func(query *orm.Query) {
+		query.Relation("Author").Order("title")
+	}
+
+	var books []*listedBook
+	if _, err := p.ListResources(ctx, &books, ListOptions{MaxRows: 3}, withAuthor); err != nil || len(books) != 3 {
+		t.Fatalf("ListResources() = %d books, %v, want 3", len(books), err)
+	}
+
+	for _, book := range books {
+		if book.Author == nil || book.Author.Name != "Ursula" {
+			t.Errorf("book %s: Author = %+v, want the relation loaded", book.Title, book.Author)
+		}
+	}
+
+	var tooMany *TooManyRowsError
+	if _, err := p.ListResources(ctx, &books, ListOptions{MaxRows: 2}, withAuthor); !errors.As(err, &tooMany) || tooMany.Count != 3 {
+		t.Errorf("ListResources() with MaxRows 2 = %v, want a TooManyRowsError counting 3 rows", err)
+	}
+
+	books = nil
+	meta, err := p.ListResources(ctx, &books, ListOptions{MaxBytes: 1}, withAuthor)
+	if err != nil || !meta.Truncated || len(books) != 0 {
+		t.Errorf("ListResources() with MaxBytes 1 = %d books, %+v, %v, want none, truncated", len(books), meta, err)
+	}
+
+	books = nil
+	meta, err = p.ListResources(ctx, &books, ListOptions{MaxBytes: 1 << 20}, withAuthor)
+	if err != nil || meta.Truncated || len(books) != 3 || books[0].Author == nil {
+		t.Errorf("ListResources() with MaxBytes 1MiB = %d books, %+v, %v, want 3 with their author", len(books), meta, err)
+	}
+}
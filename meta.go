@@ -0,0 +1,73 @@
+package persistsql
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/go-pg/pg/v10/types"
+)
+
+// Resource is a resource persisted by the persistence layer, a pointer to a go-pg model struct.
+// Models need not implement anything, see OutputOnlyFielder and PrimaryKeyer for the optional interfaces.
+type Resource interface{}
+
+// OutputOnlyFielder is implemented by resources with fields set by the server only, which clients can't update,
+// as does github.com/chi07/resource.Resource.
+type OutputOnlyFielder interface {
+	// IsFieldOutputOnly reports whether the field, named by its column, is output only
+	IsFieldOutputOnly(field string) bool
+}
+
+// PrimaryKeyer is implemented by resources formatting their own primary key, sparing the reflection of PrimaryKey.
+// The key must be formatted as PrimaryKey would, to match the keys of change events.
+type PrimaryKeyer interface {
+	PrimaryKey() string
+}
+
+// IsFieldOutputOnly reports whether the field of res, named by its column, is output only, false if res isn't an OutputOnlyFielder.
+func IsFieldOutputOnly(res Resource, field string) bool {
+	f, ok := res.(OutputOnlyFielder)
+
+	return ok && f.IsFieldOutputOnly(field)
+}
+
+// tableOf returns the go-pg table metadata of model, which must be a struct or a pointer to a struct.
+func tableOf(model interface{}) *orm.Table {
+	typ := reflect.TypeOf(model)
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+
+	return orm.GetTable(typ)
+}
+
+// quoteIdent returns name quoted as an SQL identifier.
+func quoteIdent(name string) string {
+	return string(types.AppendIdent(nil, name, 1))
+}
+
+// PrimaryKey returns the primary key of model formatted as text, as in ChangeEvent.Key, the values of composite keys being comma-separated.
+// Models implementing PrimaryKeyer format their own.
+func PrimaryKey(model interface{}) string {
+	return primaryKey(model)
+}
+
+// primaryKey returns the primary key of model formatted as text, the values of composite keys being comma-separated.
+func primaryKey(model interface{}) string {
+	if pk, ok := model.(PrimaryKeyer); ok {
+		return pk.PrimaryKey()
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(model))
+
+	var key string
+	for i, field := range tableOf(model).PKs {
+		if i > 0 {
+			key += ","
+		}
+		key += fmt.Sprint(field.Value(v).Interface())
+	}
+
+	return key
+}
@@ -0,0 +1,47 @@
+package persistsql
+
+import (
+	"fmt"
+	"testing"
+)
+
+// keyedNote formats its own primary key.
+type keyedNote struct {
+	ID int64
+}
+
+func (n *keyedNote) PrimaryKey() string {
+	return fmt.Sprintf("note-%d", n.ID)
+}
+
+func TestPrimaryKey(t *testing.T) {
+	for _, tt := range []struct {
+		model interface{}
+		want  string
+	}{
+		{&labelledOrder{ID: 42}, "42"},
+		{labelledOrder{ID: 42}, "42"},
+		{&versionedNode{ID: 1, Revision: 3}, "1,3"},
+		{&keyedNote{ID: 7}, "note-7"},
+	} {
+		if got := PrimaryKey(tt.model); got != tt.want {
+			t.Errorf("PrimaryKey(%+v) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestMeta(t *testing.T) {
+	if !IsFieldOutputOnly(&changedInvoice{}, "status") || IsFieldOutputOnly(&labelledOrder{}, "total") {
+		t.Error("IsFieldOutputOnly() doesn't follow OutputOnlyFielder")
+	}
+
+	for _, model := range []interface{}{labelledOrder{}, (*labelledOrder)(nil), &[]*labelledOrder{}, []labelledOrder{}} {
+		if table := tableOf(model); table.SQLName != `"labelled_orders"` {
+			t.Errorf("tableOf(%T) = %s", model, table.SQLName)
+		}
+	}
+
+	if id := quoteIdent(`odd"name`); id != `"odd""name"` {
+		t.Errorf("quoteIdent() = %s", id)
+	}
+}
@@ -0,0 +1,387 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// Step is a single unit of work of a Migration.
+type Step interface {
+	// Apply runs the step against db, which is the migration transaction unless the migration runs outside of one.
+	Apply(ctx context.Context, db orm.DB) error
+}
+
+// NonTransactionalStep is implemented by steps which must run outside of a transaction.
+// A migration containing such a step runs all its steps directly against the database, so they should be idempotent.
+type NonTransactionalStep interface {
+	Step
+	NonTransactional() bool
+}
+
+// Describer is implemented by steps able to list the statements they execute, used by Migrator.Plan.
+type Describer interface {
+	Statements() []string
+}
+
+// Apply executes the raw query as a migration step.
+func (q RawQuery) Apply(ctx context.Context, db orm.DB) error {
+	if _, err := db.ExecContext(ctx, q.Q); err != nil && !q.ErrOk {
+		return err
+	}
+
+	return nil
+}
+
+// Statements returns the raw query.
+func (q RawQuery) Statements() []string {
+	return []string{q.Q}
+}
+
+// Statements is a migration step executing SQL statements in order.
+type Statements []string
+
+// Apply executes the statements.
+func (s Statements) Apply(ctx context.Context, db orm.DB) error {
+	for _, q := range s {
+		if _, err := db.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Statements returns the statements.
+func (s Statements) Statements() []string {
+	return s
+}
+
+// formatQuery formats query with params the way go-pg does before sending it.
+func formatQuery(query string, params ...interface{}) string {
+	return string(orm.NewFormatter().FormatQuery(nil, query, params...))
+}
+
+// Migration is a versioned schema or data change.
+type Migration struct {
+	// Version orders migrations, it must be unique.
+	Version int64
+	// Name describes the migration
+	Name string
+	// Up are the steps applying the migration, in order.
+	Up []Step
+	// Down are the steps reverting the migration, in order. A migration without them can't be reverted.
+	Down []Step
+	// Destructive marks migrations whose Down steps lose data, e.g. by dropping a column.
+	Destructive bool
+}
+
+// transactional reports whether all the steps can run in a single transaction.
+func transactional(steps []Step) bool {
+	for _, step := range steps {
+		if nt, ok := step.(NonTransactionalStep); ok && nt.NonTransactional() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// schemaMigration records an applied migration.
+type schemaMigration struct {
+	tableName struct{} `pg:"schema_migrations"`
+
+	Version   int64     `pg:",pk,use_zero"`
+	Name      string    `pg:",notnull,use_zero"`
+	AppliedAt time.Time `pg:",notnull"`
+}
+
+// DefaultMigrationLockKey is the advisory lock key used by Migrator when none is set.
+const DefaultMigrationLockKey int64 = 0x7065727369737473
+
+// migrationLockPoll is the interval between attempts to take the migration lock.
+const migrationLockPoll = 500 * time.Millisecond
+
+// ErrIrreversibleMigration is returned by MigrateDown for migrations without Down steps.
+var ErrIrreversibleMigration = errors.New("irreversible migration")
+
+// ErrDestructiveMigration is returned by MigrateDown for destructive migrations, unless Migrator.AllowDestructive is set.
+var ErrDestructiveMigration = errors.New("destructive down migration")
+
+// ErrMigrationLockTimeout is returned when the migration lock couldn't be taken within Migrator.LockTimeout.
+var ErrMigrationLockTimeout = errors.New("timed out waiting for the migration lock")
+
+// Migrator applies migrations, recording the applied versions in the schema_migrations table.
+// Instances sharing a database serialize through an advisory lock: while one applies migrations,
+// the others wait, then verify the migrations were applied instead of failing.
+type Migrator struct {
+	// LockKey is the advisory lock key, defaults to DefaultMigrationLockKey.
+	LockKey int64
+	// LockTimeout bounds the wait for the lock, zero waits as long as the context allows.
+	LockTimeout time.Duration
+	// AllowDestructive allows MigrateDown to revert migrations marked Destructive.
+	AllowDestructive bool
+
+	sql        *SQL
+	migrations []Migration
+}
+
+// NewMigrator creates a Migrator for the migrations, which may be given in any order.
+func (p *SQL) NewMigrator(migrations ...Migration) *Migrator {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	return &Migrator{
+		LockKey:    DefaultMigrationLockKey,
+		sql:        p,
+		migrations: sorted,
+	}
+}
+
+// Migrate applies all the migrations which haven't been applied yet, in version order.
+// Each migration runs in its own transaction, unless it contains a NonTransactionalStep.
+// ErrMaintenance is returned while in maintenance mode.
+func (m *Migrator) Migrate(ctx context.Context) error {
+	if err := m.sql.checkDDL(); err != nil {
+		return err
+	}
+
+	return m.withLock(ctx, func(conn *pg.Conn) error {
+		if err := m.migrate(ctx, conn); err != nil {
+			return err
+		}
+
+		return m.verify(ctx, conn)
+	})
+}
+
+// migrate applies the pending migrations on conn, holding the migration lock.
+func (m *Migrator) migrate(ctx context.Context, conn *pg.Conn) error {
+	if err := conn.ModelContext(ctx, (*schemaMigration)(nil)).CreateTable(&orm.CreateTableOptions{IfNotExists: true}); err != nil {
+		return err
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if applied[migration.Version] {
+			continue
+		}
+
+		if err := m.apply(ctx, conn, migration); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// verify checks all the migrations are recorded as applied, in db.
+func (m *Migrator) verify(ctx context.Context, db orm.DB) error {
+	applied, err := m.applied(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if !applied[migration.Version] {
+			return fmt.Errorf("migration %d (%s) not applied", migration.Version, migration.Name)
+		}
+	}
+
+	return nil
+}
+
+// withLock runs fn holding the migration advisory lock, waiting for it up to LockTimeout.
+// Advisory locks belong to the session, so the lock is taken and released on a dedicated connection, passed to fn to run the
+// migrations: running them on other connections of the pool would wait forever for a free one with a small pool.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pg.Conn) error) error {
+	if m.LockTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.LockTimeout)
+		defer cancel()
+	}
+
+	conn := m.sql.db.Conn()
+	defer conn.Close()
+
+	for {
+		var locked bool
+		if _, err := conn.QueryOneContext(ctx, pg.Scan(&locked), "SELECT pg_try_advisory_lock(?)", m.LockKey); err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return ErrMigrationLockTimeout
+			}
+
+			return fmt.Errorf("pg_try_advisory_lock(): %w", err)
+		}
+
+		if locked {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return ErrMigrationLockTimeout
+			}
+
+			return ctx.Err()
+		case <-time.After(migrationLockPoll):
+		}
+	}
+
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(?)", m.LockKey)
+	}()
+
+	return fn(conn)
+}
+
+// applied returns the versions applied according to db, none if the schema_migrations table doesn't exist yet.
+func (m *Migrator) applied(ctx context.Context, db orm.DB) (map[int64]bool, error) {
+	for i := 1; i < len(m.migrations); i++ {
+		if m.migrations[i].Version == m.migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", m.migrations[i].Version)
+		}
+	}
+
+	var exists bool
+	if _, err := db.QueryOneContext(ctx, pg.Scan(&exists), "SELECT to_regclass('schema_migrations') IS NOT NULL"); err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return map[int64]bool{}, nil
+	}
+
+	var records []schemaMigration
+	if err := db.ModelContext(ctx, &records).Select(); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]bool, len(records))
+	for _, record := range records {
+		applied[record.Version] = true
+	}
+
+	return applied, nil
+}
+
+// MigrateDown reverts the applied migrations with a version greater than target, newest first.
+// Nothing is reverted if one of them has no Down steps, or is destructive and AllowDestructive isn't set,
+// or if versions above target are recorded as applied but aren't among the migrations of m, e.g. applied by a newer release.
+// ErrMaintenance is returned while in maintenance mode.
+func (m *Migrator) MigrateDown(ctx context.Context, target int64) error {
+	if err := m.sql.checkDDL(); err != nil {
+		return err
+	}
+
+	return m.withLock(ctx, func(conn *pg.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		known := make(map[int64]bool, len(m.migrations))
+		for _, migration := range m.migrations {
+			known[migration.Version] = true
+		}
+
+		var unknown []int64
+		for version := range applied {
+			if version > target && !known[version] {
+				unknown = append(unknown, version)
+			}
+		}
+
+		if len(unknown) > 0 {
+			sort.Slice(unknown, func(i, j int) bool { return unknown[i] < unknown[j] })
+			return fmt.Errorf("unknown applied migrations %v", unknown)
+		}
+
+		var revert []Migration
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version <= target || !applied[migration.Version] {
+				continue
+			}
+
+			if len(migration.Down) == 0 {
+				return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, ErrIrreversibleMigration)
+			}
+
+			if migration.Destructive && !m.AllowDestructive {
+				return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, ErrDestructiveMigration)
+			}
+
+			revert = append(revert, migration)
+		}
+
+		for _, migration := range revert {
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// apply runs the Up steps of migration on conn and records it as applied.
+func (m *Migrator) apply(ctx context.Context, conn *pg.Conn, migration Migration) error {
+	record := &schemaMigration{
+		Version:   migration.Version,
+		Name:      migration.Name,
+		AppliedAt: m.sql.now(ctx),
+	}
+
+	return m.run(ctx, conn, migration.Up, func(db orm.DB) error {
+		_, err := db.Model(record).Insert()
+		return err
+	})
+}
+
+// revert runs the Down steps of migration on conn and removes its record.
+func (m *Migrator) revert(ctx context.Context, conn *pg.Conn, migration Migration) error {
+	return m.run(ctx, conn, migration.Down, func(db orm.DB) error {
+		_, err := db.Model((*schemaMigration)(nil)).Where("version = ?", migration.Version).Delete()
+		return err
+	})
+}
+
+// run runs the steps then record, in a single transaction unless one of the steps is non-transactional.
+// The steps get the clock of the persistence layer unless ctx has one, see ContextWithClock.
+func (m *Migrator) run(ctx context.Context, conn *pg.Conn, steps []Step, record func(db orm.DB) error) error {
+	if _, ok := ctx.Value(clockKey{}).(Clock); !ok {
+		ctx = ContextWithClock(ctx, m.sql.clock)
+	}
+
+	if !transactional(steps) {
+		db := conn.WithContext(ctx)
+		for _, step := range steps {
+			if err := step.Apply(ctx, db); err != nil {
+				return err
+			}
+		}
+
+		return record(db)
+	}
+
+	return conn.WithContext(ctx).RunInTransaction(ctx, func(tx *pg.Tx) error {
+		for _, step := range steps {
+			if err := step.Apply(ctx, tx); err != nil {
+				return err
+			}
+		}
+
+		return record(tx)
+	})
+}
@@ -0,0 +1,92 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+func TestMigrateDownUnknownVersions(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*schemaMigration)(nil))
+
+	one := Migration{Version: 1, Name: "one", Up: []Step{Statements{"CREATE TABLE test_migrated_one (id int)"}},
+		Down: []Step{Statements{"DROP TABLE test_migrated_one"}}}
+	two := Migration{Version: 2, Name: "two", Up: []Step{Statements{"CREATE TABLE test_migrated_two (id int)"}},
+		Down: []Step{Statements{"DROP TABLE test_migrated_two"}}}
+	t.Cleanup(func() {
+		_, _ = p.db.Exec("DROP TABLE IF EXISTS test_migrated_one, test_migrated_two")
+	})
+
+	if err := p.NewMigrator(one, two).Migrate(ctx); err != nil {
+		t.Fatalf("Migrate(): %v", err)
+	}
+
+	if err := p.NewMigrator(one).MigrateDown(ctx, 0); err == nil {
+		t.Fatal("MigrateDown() past an unknown applied migration succeeded")
+	}
+
+	applied, err := p.NewMigrator(one, two).applied(ctx, p.db)
+	if err != nil {
+		t.Fatalf("applied(): %v", err)
+	}
+
+	if !applied[1] || !applied[2] {
+		t.Errorf("applied = %v after a failed MigrateDown, want both migrations", applied)
+	}
+
+	if err := p.NewMigrator(one, two).MigrateDown(ctx, 0); err != nil {
+		t.Errorf("MigrateDown(): %v", err)
+	}
+}
+
+func TestMigrateSmallPool(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// The prepared statement publishing the change events holds one of the 2 connections.
+	p, err := New(testDB(t, func(opt *pg.Options) { opt.PoolSize = 2 }))
+	if err != nil {
+		t.Fatalf("New(): %v", err)
+	}
+	testTables(t, p.db, (*schemaMigration)(nil), (*migrationProgress)(nil))
+	t.Cleanup(func() {
+		_, _ = p.db.Exec("DROP TABLE IF EXISTS test_pooled_rows")
+	})
+
+	create := Migration{Version: 1, Name: "create", Up: []Step{Statements{
+		"CREATE TABLE test_pooled_rows (id int PRIMARY KEY, n int)",
+		"INSERT INTO test_pooled_rows SELECT i, 0 FROM generate_series(1, 5) AS i",
+	}}}
+	fill := Migration{Version: 2, Name: "fill", Up: []Step{
+		DataMigration{Name: "test_pooled_rows_n", Table: "test_pooled_rows", Set: "n = id", BatchSize: 2},
+	}}
+
+	if err := p.NewMigrator(create, fill).Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() with a pool of 2 connections: %v", err)
+	}
+}
+
+func TestMigrateLockTimeout(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+
+	holder := p.db.Conn()
+	defer holder.Close()
+
+	if _, err := holder.ExecContext(ctx, "SELECT pg_advisory_lock(?)", DefaultMigrationLockKey); err != nil {
+		t.Fatalf("pg_advisory_lock(): %v", err)
+	}
+	defer func() {
+		_, _ = holder.ExecContext(ctx, "SELECT pg_advisory_unlock(?)", DefaultMigrationLockKey)
+	}()
+
+	m := p.NewMigrator()
+	m.LockTimeout = 100 * time.Millisecond
+	if err := m.Migrate(ctx); err != ErrMigrationLockTimeout {
+		t.Errorf("Migrate() while the lock is held = %v, want ErrMigrationLockTimeout", err)
+	}
+}
@@ -6,11 +6,13 @@ import (
 	"github.com/google/uuid"
 )
 
+// Common holds the columns shared by all resources.
+// Identity columns are tagged `immutable:"true"` so updates can't rewrite them; models should tag their tenant column the same way.
 type Common struct {
 	tableName struct{} `pg:",discard_unknown_columns"`
 
-	ID         uuid.UUID `pg:",pk,type:uuid" filter:"-"`
-	CreateTime time.Time `pg:",notnull"`
+	ID         uuid.UUID `pg:",pk,type:uuid" filter:"-" immutable:"true"`
+	CreateTime time.Time `pg:",notnull" immutable:"true"`
 	UpdateTime time.Time `pg:",notnull"`
 	DeleteTime time.Time `pg:",soft_delete" filter:"-"`
 	Version    uint64    `pg:",notnull,default:1" filter:"-"`
@@ -0,0 +1,225 @@
+package persistsql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/go-pg/pg/v10/types"
+	"github.com/jinzhu/inflection"
+)
+
+// NamingStrategy names the tables and columns of models which don't name them in their pg tags, for pre-existing schemas
+// which don't follow the go-pg defaults, see WithNamingStrategy. Names given in pg tags always win.
+type NamingStrategy struct {
+	// Table names the table of a model from its Go type name, the plural of its snake_case by default
+	Table func(typeName string) string
+	// Singular keeps the default table names in the singular, e.g. order rather than orders
+	Singular bool
+	// Column names a column from its Go field name, snake_case by default, see SnakeCase
+	Column func(fieldName string) string
+	// Overrides name the tables and columns of specific models, before Table and Column
+	Overrides []ModelNaming
+}
+
+// ModelNaming overrides the names of the table and columns of a model.
+type ModelNaming struct {
+	// Model is a pointer to the model
+	Model interface{}
+	// Table is the name of the table, possibly schema-qualified, unchanged if empty
+	Table string
+	// Columns are the names of columns by Go field name
+	Columns map[string]string
+}
+
+// WithNamingStrategy names the tables and columns of models with ns, in CreateTables, queries and the metadata used by the
+// subsystems alike. go-pg caches the metadata of models process-wide, so the strategy applies to all persistence layers,
+// and models must be registered, see Register, before being used. Registering a model already registered by another persistence
+// layer fails if the strategies name it differently, layers without a strategy naming models as go-pg does.
+func WithNamingStrategy(ns NamingStrategy) Option {
+	return func(p *SQL) {
+		p.naming = &ns
+	}
+}
+
+// modelNames are the names given to the tables and columns of models, by Go type, shared by all persistence layers since
+// go-pg caches the metadata of models process-wide.
+var modelNames = struct {
+	sync.Mutex
+	byType map[reflect.Type]*namedModel
+}{byType: map[reflect.Type]*namedModel{}}
+
+// namedModel holds the default names of a model, and the names it was given.
+type namedModel struct {
+	defaults tableNames
+	given    tableNames
+}
+
+// tableNames are the names of a table and of its columns, by Go field name.
+type tableNames struct {
+	table   string
+	columns map[string]string
+}
+
+// equal reports whether n and o are the same names.
+func (n tableNames) equal(o tableNames) bool {
+	if n.table != o.table || len(n.columns) != len(o.columns) {
+		return false
+	}
+
+	for goName, name := range n.columns {
+		if o.columns[goName] != name {
+			return false
+		}
+	}
+
+	return true
+}
+
+// namesOf returns the current names of table.
+func namesOf(table *orm.Table) tableNames {
+	names := tableNames{table: strings.ReplaceAll(string(table.SQLName), `"`, ""), columns: map[string]string{}}
+	for _, field := range table.Fields {
+		names.columns[field.GoName] = field.SQLName
+	}
+
+	return names
+}
+
+// apply renames the table and columns of table as ns names them, the go-pg defaults if ns is nil.
+// It fails if another persistence layer gave the model different names.
+func (ns *NamingStrategy) apply(table *orm.Table) error {
+	modelNames.Lock()
+	defer modelNames.Unlock()
+
+	named, ok := modelNames.byType[table.Type]
+	if !ok {
+		named = &namedModel{defaults: namesOf(table)}
+	}
+
+	want := ns.names(table, named.defaults)
+	if ok {
+		if !named.given.equal(want) {
+			return fmt.Errorf("%s: naming conflicts with the names given by another persistence layer, table %s", table.TypeName, named.given.table)
+		}
+
+		return nil
+	}
+
+	if want.table != named.defaults.table {
+		setTableName(table, want.table)
+	}
+
+	for _, field := range table.Fields {
+		name := want.columns[field.GoName]
+		if name == field.SQLName {
+			continue
+		}
+
+		delete(table.FieldsMap, field.SQLName)
+		field.SQLName = name
+		field.Column = types.Safe(quoteIdent(name))
+		table.FieldsMap[name] = field
+	}
+
+	named.given = want
+	modelNames.byType[table.Type] = named
+
+	return nil
+}
+
+// names returns the names of table as ns names them, given its default names.
+func (ns *NamingStrategy) names(table *orm.Table, defaults tableNames) tableNames {
+	if ns == nil {
+		return defaults
+	}
+
+	var override ModelNaming
+	for _, o := range ns.Overrides {
+		if tableOf(o.Model) == table {
+			override = o
+		}
+	}
+
+	names := tableNames{table: defaults.table, columns: make(map[string]string, len(defaults.columns))}
+	switch {
+	case override.Table != "":
+		names.table = override.Table
+	case !namedTable(table) && (ns.Table != nil || ns.Singular):
+		names.table = ns.tableName(table.Type.Name())
+	}
+
+	for _, field := range table.Fields {
+		name, ok := override.Columns[field.GoName]
+		if !ok && ns.Column != nil && tagName(field.Field.Tag.Get("pg")) == "" {
+			name = ns.Column(field.GoName)
+		}
+
+		if name == "" {
+			name = defaults.columns[field.GoName]
+		}
+		names.columns[field.GoName] = name
+	}
+
+	return names
+}
+
+// tableName returns the name of the table of the model of Go type name typeName.
+func (ns *NamingStrategy) tableName(typeName string) string {
+	if ns.Table != nil {
+		return ns.Table(typeName)
+	}
+
+	name := SnakeCase(typeName)
+	if !ns.Singular {
+		name = inflection.Plural(name)
+	}
+
+	return name
+}
+
+// namedTable reports whether the model of table names its table in the pg tag of its tableName field.
+func namedTable(table *orm.Table) bool {
+	field, ok := table.Type.FieldByName("tableName")
+
+	return ok && len(field.Index) == 1 && tagName(field.Tag.Get("pg")) != ""
+}
+
+// setTableName sets the name of table, possibly schema-qualified.
+func setTableName(table *orm.Table, name string) {
+	table.SQLName = types.Safe(quoteIdent(name))
+	table.SQLNameForSelects = table.SQLName
+}
+
+// tagName returns the name of a pg tag, before the options.
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+
+	return name
+}
+
+// SnakeCase returns the Go name s in snake_case, as go-pg names columns and tables by default, e.g. customer_id for CustomerID.
+func SnakeCase(s string) string {
+	b := make([]byte, 0, len(s)+5)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 'A' || c > 'Z' {
+			b = append(b, c)
+			continue
+		}
+
+		if i > 0 && i+1 < len(s) && (isLower(s[i-1]) || isLower(s[i+1])) {
+			b = append(b, '_')
+		}
+		b = append(b, c+'a'-'A')
+	}
+
+	return string(b)
+}
+
+// isLower reports whether c is a lower case ASCII letter.
+func isLower(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
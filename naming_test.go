@@ -0,0 +1,101 @@
+package persistsql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSnakeCase(t *testing.T) {
+	for s, want := range map[string]string{
+		"ID":             "id",
+		"CustomerID":     "customer_id",
+		"HTTPServer":     "http_server",
+		"OrderLine":      "order_line",
+		"createTime":     "create_time",
+		"Version2":       "version2",
+		"already_snaked": "already_snaked",
+	} {
+		if got := SnakeCase(s); got != want {
+			t.Errorf("SnakeCase(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestNamingStrategyTableName(t *testing.T) {
+	for _, tc := range []struct {
+		ns   NamingStrategy
+		want string
+	}{
+		{ns: NamingStrategy{}, want: "order_lines"},
+		{ns: NamingStrategy{Singular: true}, want: "order_line"},
+		{ns: NamingStrategy{Table: func(typeName string) string { return "tbl" + typeName }}, want: "tblOrderLine"},
+	} {
+		if got := tc.ns.tableName("OrderLine"); got != tc.want {
+			t.Errorf("tableName(OrderLine) = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+type namedInvoice struct {
+	ID         int64
+	CustomerID string
+	Total      int `pg:"amount"`
+}
+
+type namedReceipt struct {
+	tableName struct{} `pg:"receipts_v2"`
+
+	ID   int64
+	Note string
+}
+
+func TestNamingStrategyApply(t *testing.T) {
+	legacy := &NamingStrategy{
+		Singular: true,
+		Column:   func(fieldName string) string { return "c_" + SnakeCase(fieldName) },
+		Overrides: []ModelNaming{
+			{Model: (*namedInvoice)(nil), Columns: map[string]string{"ID": "invoice_no"}},
+		},
+	}
+
+	invoices := tableOf((*namedInvoice)(nil))
+	if err := legacy.apply(invoices); err != nil {
+		t.Fatalf("apply(): %v", err)
+	}
+
+	if invoices.SQLName != `"named_invoice"` {
+		t.Errorf("table = %s, want named_invoice", invoices.SQLName)
+	}
+
+	for goName, want := range map[string]string{"ID": "invoice_no", "CustomerID": "c_customer_id", "Total": "amount"} {
+		field, ok := invoices.FieldsMap[want]
+		if !ok || field.GoName != goName || string(field.Column) != `"`+want+`"` {
+			t.Errorf("column %s of %s = %+v", want, goName, field)
+		}
+	}
+
+	if _, ok := invoices.FieldsMap["customer_id"]; ok {
+		t.Error("the default column name customer_id is still mapped")
+	}
+
+	// The same names can be applied again, e.g. by another persistence layer with the same strategy.
+	if err := legacy.apply(invoices); err != nil {
+		t.Errorf("apply() again = %v", err)
+	}
+
+	for _, ns := range []*NamingStrategy{nil, {Singular: true}} {
+		if err := ns.apply(invoices); err == nil || !strings.Contains(err.Error(), "conflicts") {
+			t.Errorf("apply() of another strategy = %v, want a conflict", err)
+		}
+	}
+
+	// The table named by the model keeps its name.
+	receipts := tableOf((*namedReceipt)(nil))
+	if err := (&NamingStrategy{Singular: true}).apply(receipts); err != nil {
+		t.Fatalf("apply(): %v", err)
+	}
+
+	if receipts.SQLName != `"receipts_v2"` {
+		t.Errorf("table = %s, want receipts_v2", receipts.SQLName)
+	}
+}
@@ -0,0 +1,287 @@
+// Package persistsqlbench runs standardized load profiles against a persistsql persistence layer and reports latency percentiles per operation,
+// to evaluate pool, index or schema changes consistently.
+package persistsqlbench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql"
+)
+
+// Operations of a load profile.
+const (
+	OpCreate = "create"
+	OpGet    = "get"
+	OpList   = "list"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Profile is a load profile: the relative weights of the operations.
+type Profile struct {
+	Name                              string
+	Create, Get, List, Update, Delete int
+}
+
+// Standard load profiles.
+var (
+	ReadHeavy  = Profile{Name: "read-heavy", Create: 5, Get: 70, List: 20, Update: 4, Delete: 1}
+	WriteHeavy = Profile{Name: "write-heavy", Create: 40, Get: 10, List: 5, Update: 35, Delete: 10}
+	Mixed      = Profile{Name: "mixed", Create: 20, Get: 40, List: 15, Update: 20, Delete: 5}
+)
+
+// Config configures a run.
+type Config struct {
+	// Profile to run
+	Profile Profile
+	// Duration of the run, 10s if zero
+	Duration time.Duration
+	// Concurrency is the number of workers, 8 if zero
+	Concurrency int
+	// Seed of the choice of operations, for reproducible runs
+	Seed int64
+	// New returns a new resource to create, its primary key is generated by the persistence layer if zero
+	New func() persistsql.Resource
+	// Update modifies a resource and returns the changed columns, the update time is bumped only if nil
+	Update func(res persistsql.Resource) []string
+	// ListLimit is the number of resources listed at once, 50 if zero
+	ListLimit int
+}
+
+// OpStats are the statistics of an operation.
+type OpStats struct {
+	Op     string        `json:"op"`
+	Count  int           `json:"count"`
+	Errors int           `json:"errors"`
+	P50    time.Duration `json:"p50"`
+	P90    time.Duration `json:"p90"`
+	P99    time.Duration `json:"p99"`
+	Max    time.Duration `json:"max"`
+}
+
+// Report is the result of a run.
+type Report struct {
+	Profile  string        `json:"profile"`
+	Duration time.Duration `json:"duration"`
+	Ops      []OpStats     `json:"ops"`
+}
+
+// String returns the report as a table.
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "profile %s, %s\n", r.Profile, r.Duration)
+	fmt.Fprintf(&b, "%-8s %8s %8s %12s %12s %12s %12s\n", "op", "count", "errors", "p50", "p90", "p99", "max")
+	for _, op := range r.Ops {
+		fmt.Fprintf(&b, "%-8s %8d %8d %12s %12s %12s %12s\n", op.Op, op.Count, op.Errors, op.P50, op.P90, op.P99, op.Max)
+	}
+
+	return b.String()
+}
+
+// Run runs cfg.Profile against p until cfg.Duration elapses or ctx is done.
+// Each worker reads, updates and deletes the resources it created.
+func Run(ctx context.Context, p *persistsql.SQL, cfg Config) (*Report, error) {
+	if cfg.New == nil {
+		return nil, errors.New("persistsqlbench: Config.New is required")
+	}
+
+	weights := []struct {
+		op     string
+		weight int
+	}{
+		{OpCreate, cfg.Profile.Create},
+		{OpGet, cfg.Profile.Get},
+		{OpList, cfg.Profile.List},
+		{OpUpdate, cfg.Profile.Update},
+		{OpDelete, cfg.Profile.Delete},
+	}
+
+	var ops []string
+	for _, w := range weights {
+		for i := 0; i < w.weight; i++ {
+			ops = append(ops, w.op)
+		}
+	}
+
+	if len(ops) == 0 {
+		return nil, errors.New("persistsqlbench: empty profile")
+	}
+
+	duration, concurrency := cfg.Duration, cfg.Concurrency
+	if duration == 0 {
+		duration = 10 * time.Second
+	}
+	if concurrency == 0 {
+		concurrency = 8
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	var (
+		mu        sync.Mutex
+		latencies = map[string][]time.Duration{}
+		errs      = map[string]int{}
+		wg        sync.WaitGroup
+	)
+
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		w := &worker{p: p, cfg: cfg, ops: ops, rand: rand.New(rand.NewSource(cfg.Seed + int64(i))), latencies: map[string][]time.Duration{}, errs: map[string]int{}}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			w.run(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			for op, l := range w.latencies {
+				latencies[op] = append(latencies[op], l...)
+			}
+			for op, n := range w.errs {
+				errs[op] += n
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	report := &Report{Profile: cfg.Profile.Name, Duration: time.Since(start)}
+	for _, w := range weights {
+		l := latencies[w.op]
+		if len(l) == 0 && errs[w.op] == 0 {
+			continue
+		}
+
+		sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+		report.Ops = append(report.Ops, OpStats{
+			Op:     w.op,
+			Count:  len(l),
+			Errors: errs[w.op],
+			P50:    percentile(l, 50),
+			P90:    percentile(l, 90),
+			P99:    percentile(l, 99),
+			Max:    percentile(l, 100),
+		})
+	}
+
+	return report, nil
+}
+
+// percentile returns the nth percentile of sorted, zero if it's empty.
+func percentile(sorted []time.Duration, n int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := (len(sorted)*n + 99) / 100
+	if i > 0 {
+		i--
+	}
+
+	return sorted[i]
+}
+
+// worker runs operations and records their latencies.
+type worker struct {
+	p         *persistsql.SQL
+	cfg       Config
+	ops       []string
+	rand      *rand.Rand
+	created   []persistsql.Resource
+	latencies map[string][]time.Duration
+	errs      map[string]int
+}
+
+// run runs operations until ctx is done. Operations on resources fall back to creating one if the worker has none.
+func (w *worker) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		op := w.ops[w.rand.Intn(len(w.ops))]
+		if op != OpCreate && op != OpList && len(w.created) == 0 {
+			op = OpCreate
+		}
+
+		start := time.Now()
+		err := w.do(ctx, op)
+		elapsed := time.Since(start)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			w.errs[op]++
+			continue
+		}
+
+		w.latencies[op] = append(w.latencies[op], elapsed)
+	}
+}
+
+// do runs op once.
+func (w *worker) do(ctx context.Context, op string) error {
+	pk := func(query *orm.Query) { query.WherePK() }
+
+	switch op {
+	case OpCreate:
+		res, err := w.p.CreateResource(ctx, w.cfg.New())
+		if err != nil {
+			return err
+		}
+		w.created = append(w.created, res)
+
+		return nil
+	case OpGet:
+		_, err := w.p.GetResource(ctx, w.pick(), false, pk)
+		return err
+	case OpList:
+		limit := w.cfg.ListLimit
+		if limit == 0 {
+			limit = 50
+		}
+
+		list := reflect.New(reflect.SliceOf(reflect.TypeOf(w.cfg.New()))).Interface()
+		_, err := w.p.ListResources(ctx, list, persistsql.ListOptions{}, func(query *orm.Query) { query.Limit(limit) })
+
+		return err
+	case OpUpdate:
+		res := w.pick()
+
+		var fields []string
+		if w.cfg.Update != nil {
+			fields = w.cfg.Update(res)
+		}
+
+		_, err := w.p.UpdateResource(ctx, res, fields, pk)
+
+		return err
+	case OpDelete:
+		i := w.rand.Intn(len(w.created))
+		res := w.created[i]
+		w.created = append(w.created[:i], w.created[i+1:]...)
+
+		_, err := w.p.DeleteResource(ctx, res, nil)
+
+		return err
+	}
+
+	return fmt.Errorf("persistsqlbench: unknown operation %q", op)
+}
+
+// pick returns one of the resources created by the worker.
+func (w *worker) pick() persistsql.Resource {
+	return w.created[w.rand.Intn(len(w.created))]
+}
@@ -0,0 +1,89 @@
+package persistsqlbench
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chi07/persistsql"
+	"github.com/chi07/persistsql/model"
+	"github.com/chi07/persistsql/persistsqltest"
+)
+
+func TestPercentile(t *testing.T) {
+	if p := percentile(nil, 50); p != 0 {
+		t.Errorf("percentile(nil) = %v, want 0", p)
+	}
+
+	var sorted []time.Duration
+	for i := 1; i <= 10; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+
+	for n, want := range map[int]time.Duration{0: time.Millisecond, 50: 5 * time.Millisecond, 90: 9 * time.Millisecond,
+		99: 10 * time.Millisecond, 100: 10 * time.Millisecond} {
+		if p := percentile(sorted, n); p != want {
+			t.Errorf("percentile(%d) = %v, want %v", n, p, want)
+		}
+	}
+}
+
+func TestReportString(t *testing.T) {
+	report := &Report{Profile: "mixed", Duration: time.Second, Ops: []OpStats{{Op: OpGet, Count: 3, Errors: 1, P50: time.Millisecond}}}
+
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	if len(lines) != 3 || lines[0] != "profile mixed, 1s" || !strings.HasPrefix(lines[2], "get") || !strings.Contains(lines[2], "1ms") {
+		t.Errorf("String() =\n%s", report)
+	}
+}
+
+func TestRunInvalidConfig(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := Run(ctx, nil, Config{Profile: Mixed}); err == nil {
+		t.Error("Run() without Config.New succeeded")
+	}
+
+	newNote := func() persistsql.Resource { return &benchNote{} }
+	if _, err := Run(ctx, nil, Config{Profile: Profile{Name: "idle"}, New: newNote}); err == nil {
+		t.Error("Run() of an empty profile succeeded")
+	}
+}
+
+type benchNote struct {
+	tableName struct{} `pg:"test_bench_notes"`
+
+	model.Common
+	Text string
+}
+
+func TestRun(t *testing.T) {
+	p, db := persistsqltest.Open(t)
+	persistsqltest.Tables(t, db, (*benchNote)(nil))
+
+	report, err := Run(context.Background(), p, Config{
+		Profile:     Mixed,
+		Duration:    500 * time.Millisecond,
+		Concurrency: 2,
+		Seed:        1,
+		New:         func() persistsql.Resource { return &benchNote{Text: "note"} },
+		Update: func(res persistsql.Resource) []string {
+			res.(*benchNote).Text += "!"
+			return []string{"text"}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Run(): %v", err)
+	}
+
+	if report.Profile != "mixed" || len(report.Ops) == 0 || report.Ops[0].Op != OpCreate || report.Ops[0].Count == 0 {
+		t.Fatalf("Run() = %s, want creations first", report)
+	}
+
+	for _, op := range report.Ops {
+		if op.Errors != 0 || op.P50 > op.P99 || op.P99 > op.Max {
+			t.Errorf("%s: %+v, want no errors and ordered percentiles", op.Op, op)
+		}
+	}
+}
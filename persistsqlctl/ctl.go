@@ -0,0 +1,472 @@
+// Package persistsqlctl implements persistsqlctl, the administration tool of persistsql persistence layers, for day-2 operations
+// against a configured database, see persistsql.LoadConfig. Models and migrations are declared in code, so applications build
+// their own tool declaring them:
+//
+//	func main() {
+//		persistsqlctl.Main(persistsqlctl.App{
+//			Models:     []interface{}{(*Order)(nil), (*Customer)(nil)},
+//			Migrations: migrations,
+//		})
+//	}
+//
+// The generic tool, cmd/persistsqlctl, declares none and only runs the commands which don't need them.
+package persistsqlctl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql"
+	"github.com/chi07/persistsql/persistsqlgen"
+)
+
+// importBatch is the number of rows imported per statement.
+const importBatch = 1000
+
+// App declares what the commands operate on.
+type App struct {
+	// Models are the models of the application, pointers to structs
+	Models []interface{}
+	// RawQueries are run by create-tables after creating the tables of the models
+	RawQueries []persistsql.RawQuery
+	// Migrations are applied by migrate
+	Migrations []persistsql.Migration
+	// Seed inserts the initial or fixture data, run by seed
+	Seed func(ctx context.Context, p *persistsql.SQL) error
+	// Options are applied when opening the persistence layer
+	Options []persistsql.Option
+}
+
+// command is a subcommand of the tool.
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, c *cli, args []string) error
+}
+
+// Usages of the commands.
+const (
+	migrateUsage      = "[-plan] [-down version [-allow-destructive]]  apply the pending migrations, print their plan or revert down to version"
+	createTablesUsage = "  create the missing tables of the models and run the raw queries"
+	seedUsage         = "  insert the seed data"
+	purgeDeletedUsage = "[-older-than duration] [table...]  hard delete the rows soft-deleted for longer than 30 days by default"
+	exportUsage       = "[-o file] table  write the rows of table as JSON lines, to stdout by default"
+	importRowsUsage   = "[-i file] table  insert rows read as JSON lines, from stdin by default, skipping conflicting ones"
+	explainUsage      = "[-analyze] [-show-deleted] [-where condition] table  print the plan of listing the resources of table"
+	diagnosticsUsage  = "[-o file]  write a JSON support bundle, to stdout by default"
+	genModelsUsage    = "[-schema name] [-package name] [-common] [-o file] [table...]  generate Go models from the live schema"
+)
+
+// commands are the subcommands of the tool.
+var commands = []command{
+	{"migrate", migrateUsage, migrate},
+	{"create-tables", createTablesUsage, createTables},
+	{"seed", seedUsage, seed},
+	{"purge-deleted", purgeDeletedUsage, purgeDeleted},
+	{"export", exportUsage, export},
+	{"import", importRowsUsage, importRows},
+	{"explain", explainUsage, explain},
+	{"diagnostics", diagnosticsUsage, diagnostics},
+	{"gen-models", genModelsUsage, genModels},
+}
+
+// cli is the state of a run of the tool.
+type cli struct {
+	app    App
+	p      *persistsql.SQL
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// Main runs the tool with the command line arguments and exits, with status 2 on usage errors and 1 on failures.
+// SIGINT cancels the command.
+func Main(app App) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := Run(ctx, app, os.Args[1:], os.Stdin, os.Stdout)
+	stop()
+
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "persistsqlctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Run runs the tool with args, the command line arguments without the program name.
+// flag.ErrHelp is returned on usage errors, after printing the usage to stderr.
+func Run(ctx context.Context, app App, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("persistsqlctl", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv("PERSISTSQL_CONFIG"), "configuration `file`, see persistsql.LoadConfig")
+	env := flags.String("env", "", "configuration environment, PERSISTSQL_ENV by default")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: persistsqlctl [-config file] [-env name] command [arguments]\n\ncommands:\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(flags.Output(), "  %s %s\n", cmd.name, cmd.usage)
+		}
+		fmt.Fprintf(flags.Output(), "\nflags:\n")
+		flags.PrintDefaults()
+	}
+
+	// The flag package prints the error and the usage.
+	if err := flags.Parse(args); err != nil {
+		return flag.ErrHelp
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == flags.Arg(0) {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(flags.Output(), "unknown command %q\n", flags.Arg(0))
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	cfg, err := persistsql.LoadConfig(*configPath, *env)
+	if err != nil {
+		return err
+	}
+
+	p, err := cfg.Open(app.Options...)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Register(app.Models...); err != nil {
+		return err
+	}
+
+	return cmd.run(ctx, &cli{app: app, p: p, stdin: stdin, stdout: stdout}, flags.Args()[1:])
+}
+
+// parse parses the flags of a command, returning flag.ErrHelp on usage errors.
+func parse(flags *flag.FlagSet, usage string, args []string) error {
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: persistsqlctl %s %s\n", flags.Name(), usage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return flag.ErrHelp
+	}
+
+	return nil
+}
+
+// model returns the model of the application stored in table.
+func (c *cli) model(table string) (interface{}, error) {
+	for _, model := range c.app.Models {
+		if tableName(model) == table {
+			return model, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown table %q", table)
+}
+
+// migrate runs the migrate command.
+func migrate(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	plan := flags.Bool("plan", false, "print the statements of the pending migrations without applying them")
+	down := flags.Int64("down", -1, "revert the migrations above `version`")
+	allowDestructive := flags.Bool("allow-destructive", false, "let -down revert the migrations marked destructive, losing data")
+	if err := parse(flags, migrateUsage, args); err != nil {
+		return err
+	}
+
+	m := c.p.NewMigrator(c.app.Migrations...)
+	m.AllowDestructive = *allowDestructive
+	switch {
+	case *plan:
+		pl, err := m.Plan(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(c.stdout, pl)
+		return err
+	case *down >= 0:
+		return m.MigrateDown(ctx, *down)
+	default:
+		return m.Migrate(ctx)
+	}
+}
+
+// createTables runs the create-tables command.
+func createTables(ctx context.Context, c *cli, args []string) error {
+	if err := parse(flag.NewFlagSet("create-tables", flag.ContinueOnError), createTablesUsage, args); err != nil {
+		return err
+	}
+
+	return c.p.CreateTables(ctx, c.app.Models, c.app.RawQueries)
+}
+
+// seed runs the seed command.
+func seed(ctx context.Context, c *cli, args []string) error {
+	if err := parse(flag.NewFlagSet("seed", flag.ContinueOnError), seedUsage, args); err != nil {
+		return err
+	}
+
+	if c.app.Seed == nil {
+		return errors.New("no seed data")
+	}
+
+	return c.app.Seed(ctx, c.p)
+}
+
+// purgeDeleted runs the purge-deleted command.
+func purgeDeleted(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("purge-deleted", flag.ContinueOnError)
+	olderThan := flags.Duration("older-than", 30*24*time.Hour, "minimum time since the soft deletion")
+	if err := parse(flags, purgeDeletedUsage, args); err != nil {
+		return err
+	}
+
+	var models []interface{}
+	for _, table := range flags.Args() {
+		model, err := c.model(table)
+		if err != nil {
+			return err
+		}
+		models = append(models, model)
+	}
+
+	if flags.NArg() == 0 {
+		for _, model := range c.app.Models {
+			if orm.GetTable(tableType(model)).SoftDeleteField != nil {
+				models = append(models, model)
+			}
+		}
+	}
+
+	before := c.p.Now(ctx).Add(-*olderThan)
+	for _, model := range models {
+		n, err := c.p.PurgeDeleted(ctx, model, before)
+		if err != nil {
+			return fmt.Errorf("%s: %w", tableName(model), err)
+		}
+		fmt.Fprintf(c.stdout, "%s: %d rows purged\n", tableName(model), n)
+	}
+
+	return nil
+}
+
+// export runs the export command.
+func export(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := flags.String("o", "", "output `file`")
+	if err := parse(flags, exportUsage, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	model, err := c.model(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return c.write(*out, func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		if _, err := c.p.DumpTableTo(ctx, model, bw); err != nil {
+			return err
+		}
+
+		return bw.Flush()
+	})
+}
+
+// importRows runs the import command.
+func importRows(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	in := flags.String("i", "", "input `file`")
+	if err := parse(flags, importRowsUsage, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	model, err := c.model(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	r := c.stdin
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	read, inserted := 0, 0
+	flush := func(rows []json.RawMessage) error {
+		n, err := c.p.RestoreTable(ctx, model, rows)
+		inserted += n
+		return err
+	}
+
+	dec := json.NewDecoder(r)
+	var rows []json.RawMessage
+	for {
+		var row json.RawMessage
+		if err := dec.Decode(&row); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("row %d: %w", read+1, err)
+		}
+		read++
+
+		if rows = append(rows, row); len(rows) == importBatch {
+			if err := flush(rows); err != nil {
+				return err
+			}
+			rows = rows[:0]
+		}
+	}
+
+	if len(rows) > 0 {
+		if err := flush(rows); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(c.stdout, "%s: %d rows read, %d inserted\n", tableName(model), read, inserted)
+	return err
+}
+
+// explain runs the explain command.
+func explain(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	analyze := flags.Bool("analyze", false, "execute the query and print actual times")
+	showDeleted := flags.Bool("show-deleted", false, "list soft-deleted resources too")
+	where := flags.String("where", "", "SQL `condition` filtering the resources")
+	if err := parse(flags, explainUsage, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	model, err := c.model(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	list := newSlice(model)
+	plan, err := c.p.ExplainList(ctx, list, persistsql.ListOptions{ShowDeleted: *showDeleted}, func(query *orm.Query) {
+		if *where != "" {
+			query.Where(*where)
+		}
+	}, *analyze)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(c.stdout, plan)
+	return err
+}
+
+// diagnostics runs the diagnostics command.
+func diagnostics(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("diagnostics", flag.ContinueOnError)
+	out := flags.String("o", "", "output `file`")
+	if err := parse(flags, diagnosticsUsage, args); err != nil {
+		return err
+	}
+
+	return c.write(*out, func(w io.Writer) error {
+		return c.p.CollectDiagnostics(ctx, w)
+	})
+}
+
+// genModels runs the gen-models command.
+func genModels(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("gen-models", flag.ContinueOnError)
+	schema := flags.String("schema", "public", "database schema")
+	pkg := flags.String("package", "models", "package `name` of the models")
+	common := flags.Bool("common", true, "embed model.Common in the models of the tables having its columns")
+	out := flags.String("o", "", "output `file`")
+	if err := parse(flags, genModelsUsage, args); err != nil {
+		return err
+	}
+
+	tables, err := c.p.IntrospectTables(ctx, *schema, flags.Args()...)
+	if err != nil {
+		return err
+	}
+
+	src, err := persistsqlgen.Generate(tables, persistsqlgen.Options{Package: *pkg, Common: *common})
+	if err != nil {
+		return err
+	}
+
+	return c.write(*out, func(w io.Writer) error {
+		_, err := w.Write(src)
+		return err
+	})
+}
+
+// write calls fn with the file at path, created or truncated, or stdout if path is empty.
+func (c *cli) write(path string, fn func(w io.Writer) error) error {
+	if path == "" {
+		return fn(c.stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// tableName returns the unquoted name of the table of model.
+func tableName(model interface{}) string {
+	return strings.ReplaceAll(string(orm.GetTable(tableType(model)).SQLName), `"`, "")
+}
+
+// tableType returns the struct type of model, a pointer to a struct.
+func tableType(model interface{}) reflect.Type {
+	return reflect.TypeOf(model).Elem()
+}
+
+// newSlice returns a pointer to a new slice of pointers to the type of model.
+func newSlice(model interface{}) interface{} {
+	return reflect.New(reflect.SliceOf(reflect.TypeOf(model))).Interface()
+}
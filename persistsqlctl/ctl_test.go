@@ -0,0 +1,139 @@
+package persistsqlctl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+
+	"github.com/chi07/persistsql/model"
+)
+
+// testDatabaseEnv is the environment variable holding the URL of the database the tests needing one run against,
+// see persistsql. They're skipped if it's empty.
+const testDatabaseEnv = "PERSISTSQL_TEST_DATABASE"
+
+type ctlNote struct {
+	tableName struct{} `pg:"test_ctl_notes"`
+
+	model.Common
+	Text string
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"nope"}, {"-nope"}} {
+		if err := Run(context.Background(), App{}, args, nil, nil); !errors.Is(err, flag.ErrHelp) {
+			t.Errorf("Run(%q) = %v, want flag.ErrHelp", args, err)
+		}
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	ctx := context.Background()
+	c := &cli{app: App{Models: []interface{}{(*ctlNote)(nil)}}, stdin: strings.NewReader(`{"text": "a"} {`)}
+
+	if tableName((*ctlNote)(nil)) != "test_ctl_notes" {
+		t.Errorf("tableName() = %s", tableName((*ctlNote)(nil)))
+	}
+
+	if _, ok := newSlice((*ctlNote)(nil)).(*[]*ctlNote); !ok {
+		t.Errorf("newSlice() = %T", newSlice((*ctlNote)(nil)))
+	}
+
+	if _, err := c.model("nope"); err == nil || err.Error() != `unknown table "nope"` {
+		t.Errorf("model(nope) = %v", err)
+	}
+
+	for _, fn := range []func(context.Context, *cli, []string) error{export, importRows, explain} {
+		if err := fn(ctx, c, nil); !errors.Is(err, flag.ErrHelp) {
+			t.Errorf("command without a table = %v, want flag.ErrHelp", err)
+		}
+	}
+
+	if err := purgeDeleted(ctx, c, []string{"-older-than", "soon"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("purge-deleted with an invalid duration = %v, want flag.ErrHelp", err)
+	}
+
+	if err := seed(ctx, c, nil); err == nil {
+		t.Error("seed without seed data succeeded")
+	}
+
+	// Rows are decoded before any is inserted.
+	if err := importRows(ctx, c, []string{"test_ctl_notes"}); err == nil || !strings.HasPrefix(err.Error(), "row 2: ") {
+		t.Errorf("import of invalid JSON = %v, want an error on row 2", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	url := os.Getenv(testDatabaseEnv)
+	if url == "" {
+		t.Skipf("%s not set", testDatabaseEnv)
+	}
+
+	opt, err := pg.ParseURL(url)
+	if err != nil {
+		t.Fatalf("pg.ParseURL(): %v", err)
+	}
+
+	t.Setenv("PERSISTSQL_CONFIG", "")
+	t.Setenv("PERSISTSQL_ADDR", opt.Addr)
+	t.Setenv("PERSISTSQL_USER", opt.User)
+	t.Setenv("PERSISTSQL_PASSWORD", opt.Password)
+	t.Setenv("PERSISTSQL_DATABASE", opt.Database)
+	if opt.TLSConfig == nil {
+		t.Setenv("PERSISTSQL_TLS", "false")
+	}
+
+	ctx := context.Background()
+	app := App{Models: []interface{}{(*ctlNote)(nil)}}
+	run := func(stdin string, args ...string) string {
+		t.Helper()
+
+		var stdout bytes.Buffer
+		if err := Run(ctx, app, args, strings.NewReader(stdin), &stdout); err != nil {
+			t.Fatalf("Run(%q): %v", args, err)
+		}
+
+		return stdout.String()
+	}
+
+	db := pg.Connect(opt)
+	t.Cleanup(func() {
+		_, _ = db.ExecContext(ctx, "DROP TABLE IF EXISTS test_ctl_notes")
+		_ = db.Close()
+	})
+	if _, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS test_ctl_notes"); err != nil {
+		t.Fatalf("DROP TABLE: %v", err)
+	}
+
+	run("", "create-tables")
+
+	rows := `{"id": "00000000-0000-0000-0000-000000000001", "create_time": "2024-01-01T00:00:00Z", "update_time": "2024-01-01T00:00:00Z", "version": 1, "text": "kept"}
+{"id": "00000000-0000-0000-0000-000000000002", "create_time": "2024-01-01T00:00:00Z", "update_time": "2024-01-01T00:00:00Z", "delete_time": "2024-01-01T00:00:00Z", "version": 1, "text": "purged"}
+`
+	if out := run(rows, "import", "test_ctl_notes"); out != "test_ctl_notes: 2 rows read, 2 inserted\n" {
+		t.Errorf("import = %q", out)
+	}
+
+	// Conflicting rows are skipped.
+	if out := run(rows, "import", "test_ctl_notes"); out != "test_ctl_notes: 2 rows read, 0 inserted\n" {
+		t.Errorf("import again = %q", out)
+	}
+
+	if out := run("", "purge-deleted"); out != "test_ctl_notes: 1 rows purged\n" {
+		t.Errorf("purge-deleted = %q", out)
+	}
+
+	if out := run("", "export", "test_ctl_notes"); strings.Count(out, "\n") != 1 || !strings.Contains(out, `"text":"kept"`) {
+		t.Errorf("export = %q, want the kept note", out)
+	}
+
+	if out := run("", "explain", "-where", "text = 'kept'", "test_ctl_notes"); !strings.Contains(out, "test_ctl_notes") {
+		t.Errorf("explain = %q", out)
+	}
+}
@@ -0,0 +1,246 @@
+// Package persistsqlexport exports the rows of the tables of a persistsql persistence layer incrementally, as CSV batches,
+// to feed analytics pipelines without direct database access.
+package persistsqlexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql"
+)
+
+// updateTimeColumns are the columns holding the time of the last update, the first present in a model is used.
+var updateTimeColumns = []string{"update_time", "updated_at"}
+
+// defaultWindow is the default of Exporter.Window.
+const defaultWindow = time.Minute
+
+// Exporter exports the rows of models created or updated since its last export, ordered by update time, to a Sink,
+// once they're older than its Window.
+// Soft-deleted rows are exported with their deletion time, hard deletions aren't exported.
+type Exporter struct {
+	// Sink stores the batches and cursors
+	Sink Sink
+	// BatchSize is the maximum number of rows per batch, 10000 if zero
+	BatchSize int
+	// Window holds back the rows updated within it, 1 minute if zero. Update times are stamped before their transaction commits,
+	// a row committed after rows stamped later were exported would be skipped for good: Window must exceed the longest write
+	// transactions, and the clock skew between the application instances.
+	Window time.Duration
+
+	p      *persistsql.SQL
+	models []interface{}
+}
+
+// New creates an Exporter of the rows of models, pointers to models with a single column primary key and an update time column,
+// update_time or updated_at, stamped by the persistence layer p.
+func New(p *persistsql.SQL, sink Sink, models ...interface{}) *Exporter {
+	return &Exporter{Sink: sink, p: p, models: models}
+}
+
+// Run exports every interval until ctx is done. A nil alert logs errors with the standard logger.
+func (e *Exporter) Run(ctx context.Context, interval time.Duration, alert func(error)) {
+	if alert == nil {
+		alert = func(err error) {
+			log.Printf("persistsqlexport: %v", err)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := e.Export(ctx); err != nil && ctx.Err() == nil {
+			alert(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Export exports the rows of each model changed since the cursor of its table, in batches, saving the cursor after each.
+// It returns the number of rows exported.
+func (e *Exporter) Export(ctx context.Context) (int, error) {
+	total := 0
+	for _, model := range e.models {
+		n, err := e.export(ctx, model)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// export exports the changed rows of model.
+func (e *Exporter) export(ctx context.Context, model interface{}) (int, error) {
+	table := orm.GetTable(reflect.TypeOf(model).Elem())
+	name := tableName(table)
+
+	if len(table.PKs) != 1 {
+		return 0, fmt.Errorf("exporting %s: the primary key must be a single column", name)
+	}
+
+	updateTime := timeColumn(table)
+	if updateTime == nil {
+		return 0, fmt.Errorf("exporting %s: no update time column", name)
+	}
+
+	cursor, err := e.Sink.LoadCursor(ctx, name)
+	if err != nil {
+		return 0, fmt.Errorf("exporting %s: %w", name, err)
+	}
+
+	batchSize := e.BatchSize
+	if batchSize == 0 {
+		batchSize = 10000
+	}
+
+	window := e.Window
+	if window == 0 {
+		window = defaultWindow
+	}
+	until := e.p.Now(ctx).Add(-window)
+
+	total := 0
+	for {
+		list := reflect.New(reflect.SliceOf(reflect.TypeOf(model)))
+		opts := persistsql.ListOptions{ShowDeleted: true}
+		if _, err := e.p.ListResources(ctx, list.Interface(), opts, func(query *orm.Query) {
+			query.Where("?TableAlias.? < ?", updateTime.Column, until)
+			if !cursor.Time.IsZero() {
+				query.Where("(?TableAlias.?, ?TableAlias.?) > (?, ?)", updateTime.Column, table.PKs[0].Column, cursor.Time, cursor.Key)
+			}
+			query.OrderExpr("?TableAlias.?, ?TableAlias.?", updateTime.Column, table.PKs[0].Column).Limit(batchSize)
+		}); err != nil {
+			return total, fmt.Errorf("exporting %s: %w", name, err)
+		}
+
+		rows := list.Elem()
+		if rows.Len() == 0 {
+			return total, nil
+		}
+
+		data, err := encodeCSV(table, rows)
+		if err != nil {
+			return total, fmt.Errorf("exporting %s: %w", name, err)
+		}
+
+		first := reflect.Indirect(rows.Index(0))
+		batch := fmt.Sprintf("%s/%d-%s.csv", name, updateTime.Value(first).Interface().(time.Time).UnixMicro(),
+			url.PathEscape(fmt.Sprint(table.PKs[0].Value(first).Interface())))
+		if err := e.Sink.Put(ctx, batch, data); err != nil {
+			return total, fmt.Errorf("exporting %s: %w", name, err)
+		}
+
+		last := reflect.Indirect(rows.Index(rows.Len() - 1))
+		cursor = Cursor{
+			Time: updateTime.Value(last).Interface().(time.Time),
+			Key:  fmt.Sprint(table.PKs[0].Value(last).Interface()),
+		}
+		if err := e.Sink.SaveCursor(ctx, name, cursor); err != nil {
+			return total, fmt.Errorf("exporting %s: %w", name, err)
+		}
+
+		total += rows.Len()
+		if rows.Len() < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// encodeCSV encodes rows, a slice of pointers to models, as CSV with a header of the column names.
+// Times are formatted in RFC 3339, bytes in base64, composite values in JSON and zero pointers as empty fields.
+func encodeCSV(table *orm.Table, rows reflect.Value) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	record := make([]string, len(table.Fields))
+	for i, field := range table.Fields {
+		record[i] = field.SQLName
+	}
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < rows.Len(); i++ {
+		row := reflect.Indirect(rows.Index(i))
+		for j, field := range table.Fields {
+			value, err := formatValue(field.Value(row))
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", field.SQLName, err)
+			}
+			record[j] = value
+		}
+
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
+
+// formatValue formats a column value as a CSV field.
+func formatValue(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Time:
+		if value.IsZero() {
+			return "", nil
+		}
+		return value.UTC().Format(time.RFC3339Nano), nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(value), nil
+	case fmt.Stringer:
+		return value.String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		b, err := json.Marshal(v.Interface())
+		return string(b), err
+	default:
+		return fmt.Sprint(v.Interface()), nil
+	}
+}
+
+// timeColumn returns the update time field of table, nil if there's none.
+func timeColumn(table *orm.Table) *orm.Field {
+	for _, col := range updateTimeColumns {
+		if field, ok := table.FieldsMap[col]; ok && field.Field.Type == reflect.TypeOf(time.Time{}) {
+			return field
+		}
+	}
+
+	return nil
+}
+
+// tableName returns the unquoted name of table.
+func tableName(table *orm.Table) string {
+	return strings.ReplaceAll(string(table.SQLName), `"`, "")
+}
@@ -0,0 +1,74 @@
+package persistsqlexport
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chi07/persistsql"
+	"github.com/chi07/persistsql/model"
+	"github.com/chi07/persistsql/persistsqltest"
+)
+
+func TestFormatValue(t *testing.T) {
+	name := "a"
+	for _, tc := range []struct {
+		value interface{}
+		want  string
+	}{
+		{value: 42, want: "42"},
+		{value: &name, want: "a"},
+		{value: (*string)(nil), want: ""},
+		{value: time.Time{}, want: ""},
+		{value: time.Date(2000, 1, 2, 3, 4, 5, 6000, time.FixedZone("", 3600)), want: "2000-01-02T02:04:05.000006Z"},
+		{value: []byte("hi"), want: "aGk="},
+		{value: map[string]int{"n": 1}, want: `{"n":1}`},
+	} {
+		got, err := formatValue(reflect.ValueOf(tc.value))
+		if err != nil || got != tc.want {
+			t.Errorf("formatValue(%#v) = %q, %v, want %q", tc.value, got, err, tc.want)
+		}
+	}
+}
+
+type exportedOrder struct {
+	tableName struct{} `pg:"test_exported_orders"`
+
+	model.Common
+	Total int `pg:",use_zero"`
+}
+
+func TestExportWindow(t *testing.T) {
+	clock := persistsqltest.NewClock(persistsqltest.Epoch, 0)
+	p, db := persistsqltest.Open(t, persistsql.WithClock(clock))
+	persistsqltest.Tables(t, db, (*exportedOrder)(nil))
+
+	ctx := context.Background()
+	e := New(p, Dir(t.TempDir()), (*exportedOrder)(nil))
+	e.Window = time.Minute
+
+	stamped := func(offset time.Duration) context.Context {
+		return persistsql.ContextWithClock(ctx, persistsqltest.NewClock(persistsqltest.Epoch.Add(offset), 0))
+	}
+
+	// A fast transaction commits a row stamped at 80s, while a slow one stamped its row at 70s.
+	if _, err := p.CreateResource(stamped(80*time.Second), &exportedOrder{Total: 1}); err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+
+	clock.Advance(85 * time.Second)
+	if n, err := e.Export(ctx); err != nil || n != 0 {
+		t.Fatalf("Export() within the window = %d, %v, want 0", n, err)
+	}
+
+	// The slow transaction commits.
+	if _, err := p.CreateResource(stamped(70*time.Second), &exportedOrder{Total: 2}); err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if n, err := e.Export(ctx); err != nil || n != 2 {
+		t.Errorf("Export() after the window = %d, %v, want 2", n, err)
+	}
+}
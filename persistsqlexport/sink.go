@@ -0,0 +1,77 @@
+package persistsqlexport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cursor is the position of an export in a table: the update time and primary key of the last row exported.
+type Cursor struct {
+	Time time.Time `json:"time"`
+	Key  string    `json:"key"`
+}
+
+// Sink stores the exported batches and the cursors of the exports, e.g. in a directory or an S3-compatible bucket.
+type Sink interface {
+	// Put stores a batch, name is a slash-separated path, e.g. orders/1760529600000000-1.csv
+	Put(ctx context.Context, name string, data []byte) error
+	// LoadCursor returns the cursor of table, the zero Cursor if there's none yet
+	LoadCursor(ctx context.Context, table string) (Cursor, error)
+	// SaveCursor stores the cursor of table, once its batches are put
+	SaveCursor(ctx context.Context, table string, cursor Cursor) error
+}
+
+// Dir is a Sink storing the batches as files under a local directory, and the cursors in a _cursors subdirectory.
+type Dir string
+
+// Put implements Sink.
+func (d Dir) Put(_ context.Context, name string, data []byte) error {
+	return d.write(filepath.FromSlash(name), data)
+}
+
+// LoadCursor implements Sink.
+func (d Dir) LoadCursor(_ context.Context, table string) (Cursor, error) {
+	var cursor Cursor
+
+	b, err := os.ReadFile(filepath.Join(string(d), "_cursors", table+".json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cursor, nil
+		}
+
+		return cursor, err
+	}
+
+	err = json.Unmarshal(b, &cursor)
+
+	return cursor, err
+}
+
+// SaveCursor implements Sink.
+func (d Dir) SaveCursor(_ context.Context, table string, cursor Cursor) error {
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+
+	return d.write(filepath.Join("_cursors", table+".json"), b)
+}
+
+// write writes the file at the relative path name atomically, creating its directory if needed.
+func (d Dir) write(name string, data []byte) error {
+	path := filepath.Join(string(d), name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
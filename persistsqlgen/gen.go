@@ -0,0 +1,257 @@
+// Package persistsqlgen generates Go models from the live schema of a database, see persistsql.SQL.IntrospectTables,
+// to adopt persistsql on legacy databases. The models are a starting point, meant to be reviewed and edited:
+//
+//	tables, err := p.IntrospectTables(ctx, "public")
+//	src, err := persistsqlgen.Generate(tables, persistsqlgen.Options{Package: "models", Common: true})
+//
+// persistsqlctl exposes it as the gen-models command.
+package persistsqlgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/inflection"
+
+	"github.com/chi07/persistsql"
+)
+
+// Options configures the generation of models.
+type Options struct {
+	// Package is the name of the package of the models, "models" if empty
+	Package string
+	// Common embeds model.Common in the models of the tables having all its columns, rather than declaring them
+	Common bool
+}
+
+// goType is the Go type of a column type.
+type goType struct {
+	name string
+	// pkg is the import path of the package of the type, empty for builtin types
+	pkg string
+	// sqlType is set if go-pg wouldn't infer the column type from the Go type
+	sqlType string
+	// scalar types are made pointers when nullable, to tell NULL from zero
+	scalar bool
+}
+
+// goTypes are the Go types of the udt_name of column types, array types excluded.
+var goTypes = map[string]goType{
+	"bool":        {name: "bool", scalar: true},
+	"int2":        {name: "int16", scalar: true},
+	"int4":        {name: "int32", scalar: true},
+	"int8":        {name: "int64", scalar: true},
+	"float4":      {name: "float32", scalar: true},
+	"float8":      {name: "float64", scalar: true},
+	"numeric":     {name: "string", sqlType: "numeric", scalar: true},
+	"text":        {name: "string", scalar: true},
+	"varchar":     {name: "string", scalar: true},
+	"bpchar":      {name: "string", scalar: true},
+	"citext":      {name: "string", sqlType: "citext", scalar: true},
+	"uuid":        {name: "uuid.UUID", pkg: "github.com/google/uuid", sqlType: "uuid"},
+	"timestamptz": {name: "time.Time", pkg: "time"},
+	"timestamp":   {name: "time.Time", pkg: "time", sqlType: "timestamp"},
+	"date":        {name: "time.Time", pkg: "time", sqlType: "date"},
+	"bytea":       {name: "[]byte"},
+	"json":        {name: "json.RawMessage", pkg: "encoding/json", sqlType: "json"},
+	"jsonb":       {name: "json.RawMessage", pkg: "encoding/json", sqlType: "jsonb"},
+}
+
+// commonColumns are the columns of model.Common and their types.
+var commonColumns = map[string]string{
+	"id":          "uuid",
+	"create_time": "timestamptz",
+	"update_time": "timestamptz",
+	"delete_time": "timestamptz",
+	"version":     "int8",
+}
+
+// softDeleteColumns are the columns generated as soft delete fields, when holding a time.
+var softDeleteColumns = []string{"delete_time", "deleted_at"}
+
+// initialisms are the words written in upper case in Go names.
+var initialisms = map[string]bool{
+	"api": true, "csv": true, "dns": true, "html": true, "http": true, "https": true, "id": true, "ip": true, "json": true,
+	"sql": true, "ssh": true, "tls": true, "ttl": true, "ui": true, "uid": true, "uri": true, "url": true, "uuid": true, "xml": true,
+}
+
+// Generate returns the formatted Go source declaring a model per table.
+func Generate(tables []persistsql.TableInfo, opts Options) ([]byte, error) {
+	pkg := opts.Package
+	if pkg == "" {
+		pkg = "models"
+	}
+
+	imports := map[string]bool{}
+	var body bytes.Buffer
+	for _, table := range tables {
+		writeModel(&body, table, opts, imports)
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Generated by persistsqlgen from the live schema, to be reviewed and edited.\n\npackage %s\n\n", pkg)
+	if len(imports) > 0 {
+		// Standard library packages first, then the others.
+		var std, other []string
+		for path := range imports {
+			if first, _, _ := strings.Cut(path, "/"); strings.Contains(first, ".") {
+				other = append(other, path)
+			} else {
+				std = append(std, path)
+			}
+		}
+		sort.Strings(std)
+		sort.Strings(other)
+
+		fmt.Fprintf(&src, "import (\n")
+		for i, group := range [][]string{std, other} {
+			if i > 0 && len(std) > 0 && len(other) > 0 {
+				fmt.Fprintf(&src, "\n")
+			}
+			for _, path := range group {
+				fmt.Fprintf(&src, "\t%q\n", path)
+			}
+		}
+		fmt.Fprintf(&src, ")\n\n")
+	}
+	src.Write(body.Bytes())
+
+	return format.Source(src.Bytes())
+}
+
+// writeModel writes the model of table to w, adding the packages it uses to imports.
+func writeModel(w *bytes.Buffer, table persistsql.TableInfo, opts Options, imports map[string]bool) {
+	name := table.Name
+	if table.Schema != "" && table.Schema != "public" {
+		name = table.Schema + "." + name
+	}
+
+	fmt.Fprintf(w, "// %s is a row of the %s table.\n", goName(inflection.Singular(table.Name)), name)
+	fmt.Fprintf(w, "type %s struct {\n", goName(inflection.Singular(table.Name)))
+	fmt.Fprintf(w, "\ttableName struct{} `pg:%q`\n\n", name)
+
+	common := opts.Common && hasCommon(table)
+	if common {
+		imports["github.com/chi07/persistsql/model"] = true
+		fmt.Fprintf(w, "\tmodel.Common\n")
+	}
+
+	for _, col := range table.Columns {
+		if common && commonColumns[col.Name] != "" {
+			continue
+		}
+
+		typ, tag := fieldType(col)
+		if typ.pkg != "" {
+			imports[typ.pkg] = true
+		}
+
+		field := goName(col.Name)
+		if persistsql.SnakeCase(field) != col.Name {
+			tag = append([]string{col.Name}, tag...)
+		} else {
+			tag = append([]string{""}, tag...)
+		}
+
+		fmt.Fprintf(w, "\t%s %s `pg:%q`\n", field, typ.name, strings.Join(tag, ","))
+	}
+
+	fmt.Fprintf(w, "}\n\n")
+}
+
+// fieldType returns the Go type of col and the options of its pg tag.
+func fieldType(col persistsql.ColumnInfo) (goType, []string) {
+	var tag []string
+	if col.PrimaryKey {
+		tag = append(tag, "pk")
+	}
+
+	typ, ok := goTypes[col.Type]
+	switch {
+	case ok:
+	case strings.HasPrefix(col.Type, "_"):
+		elem, ok := goTypes[strings.TrimPrefix(col.Type, "_")]
+		if !ok || elem.name == "[]byte" {
+			elem = goType{name: "string"}
+		}
+		typ = goType{name: "[]" + elem.name, pkg: elem.pkg}
+		tag = append(tag, "array")
+	default:
+		typ = goType{name: "string", sqlType: col.Type}
+	}
+
+	if typ.sqlType != "" {
+		tag = append(tag, "type:"+typ.sqlType)
+	}
+
+	// Defaults which can't be written in tags are left out, zero values being inserted instead of them.
+	withDefault := col.Default != "" && !strings.HasPrefix(col.Default, "nextval(") && !strings.ContainsAny(col.Default, ",`\"'")
+
+	switch {
+	case typ.name == "time.Time" && contains(softDeleteColumns, col.Name):
+		tag = append(tag, "soft_delete")
+	case col.Nullable && typ.scalar && !col.PrimaryKey:
+		typ.name = "*" + typ.name
+	case !col.Nullable && !col.PrimaryKey:
+		tag = append(tag, "notnull")
+		if typ.scalar && !withDefault {
+			tag = append(tag, "use_zero")
+		}
+	}
+
+	if withDefault {
+		tag = append(tag, "default:"+col.Default)
+	}
+
+	return typ, tag
+}
+
+// hasCommon reports whether table has all the columns of model.Common, with its types and primary key.
+func hasCommon(table persistsql.TableInfo) bool {
+	found := 0
+	for _, col := range table.Columns {
+		typ, ok := commonColumns[col.Name]
+		if !ok {
+			continue
+		}
+		if typ != col.Type || col.PrimaryKey != (col.Name == "id") {
+			return false
+		}
+		found++
+	}
+
+	return found == len(commonColumns)
+}
+
+// goName returns the exported Go name of the snake_case SQL name s, e.g. CustomerID for customer_id.
+func goName(s string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' || r == ' ' || r == '.' }) {
+		if initialisms[strings.ToLower(word)] {
+			b.WriteString(strings.ToUpper(word))
+		} else {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+
+	name := b.String()
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "X" + name
+	}
+
+	return name
+}
+
+// contains reports whether ss contains s.
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
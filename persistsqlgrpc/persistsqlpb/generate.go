@@ -0,0 +1,4 @@
+// Package persistsqlpb holds the protocol buffers of the Persistence gRPC service, see package persistsqlgrpc.
+package persistsqlpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative persistence.proto
@@ -0,0 +1,295 @@
+// Package persistsqlgrpc implements the Persistence gRPC service, see package persistsqlpb, on a persistsql persistence layer,
+// for sidecar or admin access to resources without bespoke services:
+//
+//	persistsqlpb.RegisterPersistenceServer(server, persistsqlgrpc.New(p, (*Order)(nil), (*Customer)(nil)))
+//
+// Resources are exchanged as their JSON encoding in a google.protobuf.Struct, collections are named after the tables of the models.
+package persistsqlgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/chi07/persistsql"
+	"github.com/chi07/persistsql/internal/resourceapi"
+	"github.com/chi07/persistsql/persistsqlgrpc/persistsqlpb"
+)
+
+// Defaults of ListResources.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// Server implements persistsqlpb.PersistenceServer on the collections of its models.
+type Server struct {
+	persistsqlpb.UnimplementedPersistenceServer
+
+	p      *persistsql.SQL
+	tables map[string]*orm.Table
+}
+
+var _ persistsqlpb.PersistenceServer = (*Server)(nil)
+
+// New creates a Server of the collections of models, pointers to models with a single column primary key.
+func New(p *persistsql.SQL, models ...persistsql.Resource) *Server {
+	s := &Server{p: p, tables: map[string]*orm.Table{}}
+	for _, model := range models {
+		table := orm.GetTable(reflect.TypeOf(model).Elem())
+		if len(table.PKs) != 1 {
+			panic(fmt.Sprintf("persistsqlgrpc: %s must have a single column primary key", table.SQLName))
+		}
+		s.tables[tableName(table)] = table
+	}
+
+	return s
+}
+
+// CreateResource implements persistsqlpb.PersistenceServer.
+func (s *Server) CreateResource(ctx context.Context, req *persistsqlpb.CreateResourceRequest) (*persistsqlpb.Resource, error) {
+	table, err := s.table(req.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := decode(table, req.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := resourceapi.CheckCreate(table, res); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	res, err = s.p.CreateResource(ctx, res)
+
+	return respond(table, res, err)
+}
+
+// GetResource implements persistsqlpb.PersistenceServer.
+func (s *Server) GetResource(ctx context.Context, req *persistsqlpb.GetResourceRequest) (*persistsqlpb.Resource, error) {
+	table, err := s.table(req.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := keyed(table, nil, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ShowDeleted {
+		res, err = s.p.GetResource(ctx, res, true, persistsql.WherePK)
+	} else {
+		res, err = s.p.GetResourceByPK(ctx, res)
+	}
+
+	return respond(table, res, err)
+}
+
+// ListResources implements persistsqlpb.PersistenceServer.
+func (s *Server) ListResources(ctx context.Context, req *persistsqlpb.ListResourcesRequest) (*persistsqlpb.ListResourcesResponse, error) {
+	table, err := s.table(req.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := int(req.PageSize)
+	switch {
+	case pageSize < 0:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid page_size %d", pageSize)
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	offset, err := resourceapi.DecodePageToken(req.PageToken)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	order, err := resourceapi.ParseOrderBy(table, req.OrderBy)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	page := resourceapi.Page{Table: table, Order: order, Offset: offset, Size: pageSize}
+	for col, value := range req.Filter {
+		filter, err := resourceapi.ParseFilter(table, col, value)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		page.Filters = append(page.Filters, filter)
+	}
+
+	list := reflect.New(reflect.SliceOf(reflect.PtrTo(table.Type)))
+	opts := persistsql.ListOptions{ShowDeleted: req.ShowDeleted}
+	if _, err := s.p.ListResources(ctx, list.Interface(), opts, page.Hook); err != nil {
+		return nil, statusOf(err)
+	}
+
+	rows := list.Elem()
+	resp := &persistsqlpb.ListResourcesResponse{NextPageToken: page.Next(rows)}
+	for i := 0; i < rows.Len(); i++ {
+		r, err := encode(table, rows.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		resp.Resources = append(resp.Resources, r)
+	}
+
+	return resp, nil
+}
+
+// UpdateResource implements persistsqlpb.PersistenceServer.
+func (s *Server) UpdateResource(ctx context.Context, req *persistsqlpb.UpdateResourceRequest) (*persistsqlpb.Resource, error) {
+	table, err := s.table(req.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := req.UpdateMask.GetPaths()
+	if len(fields) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing update_mask")
+	}
+
+	res, err := decode(table, req.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := resourceapi.CheckUpdate(table, res, fields); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if res, err = keyed(table, res, req.Id); err != nil {
+		return nil, err
+	}
+
+	res, err = s.p.UpdateResource(ctx, res, fields, persistsql.WherePK)
+
+	return respond(table, res, err)
+}
+
+// DeleteResource implements persistsqlpb.PersistenceServer.
+func (s *Server) DeleteResource(ctx context.Context, req *persistsqlpb.DeleteResourceRequest) (*persistsqlpb.Resource, error) {
+	table, err := s.table(req.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := keyed(table, nil, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err = s.p.DeleteResource(ctx, res, nil)
+
+	return respond(table, res, err)
+}
+
+// table returns the table of collection.
+func (s *Server) table(collection string) (*orm.Table, error) {
+	table, ok := s.tables[collection]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown collection %q", collection)
+	}
+
+	return table, nil
+}
+
+// respond returns res encoded, or the status of err, NotFound if res is nil.
+func respond(table *orm.Table, res persistsql.Resource, err error) (*persistsqlpb.Resource, error) {
+	switch {
+	case err != nil:
+		return nil, statusOf(err)
+	case res == nil:
+		return nil, status.Error(codes.NotFound, "not found")
+	default:
+		return encode(table, res)
+	}
+}
+
+// encode encodes res, a resource of table.
+func encode(table *orm.Table, res persistsql.Resource) (*persistsqlpb.Resource, error) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	data, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &persistsqlpb.Resource{Collection: tableName(table), Id: persistsql.PrimaryKey(res), Data: data}, nil
+}
+
+// decode decodes data into a new resource of table.
+func decode(table *orm.Table, data *structpb.Struct) (persistsql.Resource, error) {
+	res := reflect.New(table.Type).Interface()
+
+	b, err := json.Marshal(data.AsMap())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := json.Unmarshal(b, res); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return res, nil
+}
+
+// keyed sets the primary key of res, a new resource of table if nil, to id, see resourceapi.SetKey.
+func keyed(table *orm.Table, res persistsql.Resource, id string) (persistsql.Resource, error) {
+	if res == nil {
+		res = reflect.New(table.Type).Interface()
+	}
+
+	if err := resourceapi.SetKey(table, res, id); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return res, nil
+}
+
+// statusOf returns the gRPC status of err, returned by the persistence layer. Internal errors aren't exposed.
+func statusOf(err error) error {
+	var pgErr pg.Error
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, persistsql.ErrImmutableField), errors.Is(err, persistsql.ErrInvalidTransition):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, persistsql.ErrPossibleDuplicate), errors.Is(err, persistsql.ErrExternalIDConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.As(err, &pgErr) && pgErr.IntegrityViolation():
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, persistsql.ErrMaintenance):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// tableName returns the unquoted name of table.
+func tableName(table *orm.Table) string {
+	return strings.ReplaceAll(string(table.SQLName), `"`, "")
+}
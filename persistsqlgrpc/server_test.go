@@ -0,0 +1,59 @@
+package persistsqlgrpc
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/chi07/persistsql/persistsqlgrpc/persistsqlpb"
+)
+
+type servedOrder struct {
+	ID     int64
+	Total  int
+	Status string
+}
+
+func (*servedOrder) IsFieldOutputOnly(field string) bool {
+	return field == "status"
+}
+
+func TestServerRejects(t *testing.T) {
+	// The requests are rejected before reaching the persistence layer.
+	s := New(nil, (*servedOrder)(nil))
+	ctx := context.Background()
+
+	data, err := structpb.NewStruct(map[string]interface{}{"Status": "paid"})
+	if err != nil {
+		t.Fatalf("structpb.NewStruct(): %v", err)
+	}
+
+	_, err = s.CreateResource(ctx, &persistsqlpb.CreateResourceRequest{Collection: "served_orders", Data: data})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreateResource() with an output only field = %v, want InvalidArgument", err)
+	}
+
+	_, err = s.UpdateResource(ctx, &persistsqlpb.UpdateResourceRequest{
+		Collection: "served_orders",
+		Id:         "1",
+		Data:       data,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"status"}},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("UpdateResource() of an output only field = %v, want InvalidArgument", err)
+	}
+
+	for _, req := range []*persistsqlpb.ListResourcesRequest{
+		{Collection: "served_orders", OrderBy: "total sideways"},
+		{Collection: "served_orders", PageToken: "!"},
+		{Collection: "served_orders", Filter: map[string]string{"nope": "1"}},
+	} {
+		if _, err := s.ListResources(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListResources(%v) = %v, want InvalidArgument", req, err)
+		}
+	}
+}
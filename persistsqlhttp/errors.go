@@ -0,0 +1,50 @@
+package persistsqlhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-pg/pg/v10"
+
+	"github.com/chi07/persistsql"
+)
+
+// errorResponse is the body of an error response.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// statusOf returns the HTTP status of err, returned by the persistence layer.
+func statusOf(err error) int {
+	var pgErr pg.Error
+	switch {
+	case errors.Is(err, persistsql.ErrImmutableField), errors.Is(err, persistsql.ErrInvalidTransition):
+		return http.StatusBadRequest
+	case errors.Is(err, persistsql.ErrPossibleDuplicate), errors.Is(err, persistsql.ErrExternalIDConflict):
+		return http.StatusConflict
+	case errors.As(err, &pgErr) && pgErr.IntegrityViolation():
+		return http.StatusConflict
+	case errors.Is(err, persistsql.ErrMaintenance):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeJSON writes v encoded in JSON with status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err with status. Internal errors aren't exposed.
+func writeError(w http.ResponseWriter, status int, err error) {
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		msg = http.StatusText(status)
+	}
+
+	writeJSON(w, status, errorResponse{Error: msg})
+}
@@ -0,0 +1,275 @@
+// Package persistsqlhttp exposes the resources of a persistsql persistence layer over HTTP, with standard CRUD endpoints,
+// for quick internal admin services. Mount a Handler per model, stripping its prefix:
+//
+//	mux.Handle("/orders/", http.StripPrefix("/orders", persistsqlhttp.New(p, (*Order)(nil))))
+package persistsqlhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql"
+	"github.com/chi07/persistsql/internal/resourceapi"
+)
+
+// Defaults of the list endpoint.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// Query parameters of the list endpoint, the others filter on the column they name.
+const (
+	paramPageSize    = "page_size"
+	paramPageToken   = "page_token"
+	paramOrderBy     = "order_by"
+	paramShowDeleted = "show_deleted"
+	paramUpdateMask  = "update_mask"
+)
+
+// Handler serves the resources of a model:
+//
+//	GET    /                list, filtered by ?column=value on the filter fields, paginated by page_size and page_token,
+//	                        ordered by ?order_by=column [desc], soft-deleted resources included with show_deleted=true
+//	POST   /                create, output only fields can't be set
+//	GET    /{id}            get, soft-deleted resources included with show_deleted=true
+//	PATCH  /{id}            update the columns listed in ?update_mask=a,b
+//	DELETE /{id}            delete
+//	POST   /{id}:undelete   undelete
+//
+// Resources are encoded in JSON, errors as {"error": "..."}.
+type Handler struct {
+	p     *persistsql.SQL
+	table *orm.Table
+}
+
+// New creates a Handler of the resources of the collection of model, a pointer to a model with a single column primary key.
+func New(p *persistsql.SQL, model persistsql.Resource) *Handler {
+	table := orm.GetTable(reflect.TypeOf(model).Elem())
+	if len(table.PKs) != 1 {
+		panic(fmt.Sprintf("persistsqlhttp: %s must have a single column primary key", table.SQLName))
+	}
+
+	return &Handler{p: p, table: table}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+
+	if path == "" {
+		switch r.Method {
+		case http.MethodGet:
+			h.list(w, r)
+		case http.MethodPost:
+			h.create(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		}
+
+		return
+	}
+
+	if id := strings.TrimSuffix(path, ":undelete"); id != path {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+
+		h.undelete(w, r, id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, r, path)
+	case http.MethodPatch:
+		h.update(w, r, path)
+	case http.MethodDelete:
+		h.delete(w, r, path)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// listResponse is the response of the list endpoint.
+type listResponse struct {
+	Resources     interface{} `json:"resources"`
+	NextPageToken string      `json:"next_page_token,omitempty"`
+}
+
+// list serves the list endpoint.
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	pageSize := defaultPageSize
+	if s := params.Get(paramPageSize); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid %s %q", paramPageSize, s))
+			return
+		}
+		if n < maxPageSize {
+			pageSize = n
+		} else {
+			pageSize = maxPageSize
+		}
+	}
+
+	offset, err := resourceapi.DecodePageToken(params.Get(paramPageToken))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	order, err := resourceapi.ParseOrderBy(h.table, params.Get(paramOrderBy))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	page := resourceapi.Page{Table: h.table, Order: order, Offset: offset, Size: pageSize}
+	for name, vals := range params {
+		switch name {
+		case paramPageSize, paramPageToken, paramOrderBy, paramShowDeleted:
+			continue
+		}
+
+		filter, err := resourceapi.ParseFilter(h.table, name, vals[0])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		page.Filters = append(page.Filters, filter)
+	}
+
+	list := reflect.New(reflect.SliceOf(reflect.PtrTo(h.table.Type)))
+	opts := persistsql.ListOptions{ShowDeleted: params.Get(paramShowDeleted) == "true"}
+	if _, err := h.p.ListResources(r.Context(), list.Interface(), opts, page.Hook); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	resp := listResponse{Resources: list.Interface(), NextPageToken: page.Next(list.Elem())}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// get serves the get endpoint.
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, id string) {
+	res, err := h.keyed(id)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if r.URL.Query().Get(paramShowDeleted) == "true" {
+		res, err = h.p.GetResource(r.Context(), res, true, persistsql.WherePK)
+	} else {
+		res, err = h.p.GetResourceByPK(r.Context(), res)
+	}
+
+	h.respond(w, http.StatusOK, res, err)
+}
+
+// create serves the create endpoint.
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	res := h.newResource()
+	if err := json.NewDecoder(r.Body).Decode(res); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := resourceapi.CheckCreate(h.table, res); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := h.p.CreateResource(r.Context(), res)
+	h.respond(w, http.StatusCreated, res, err)
+}
+
+// update serves the update endpoint.
+func (h *Handler) update(w http.ResponseWriter, r *http.Request, id string) {
+	var fields []string
+	if mask := r.URL.Query().Get(paramUpdateMask); mask != "" {
+		fields = strings.Split(mask, ",")
+	}
+	if len(fields) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing %s", paramUpdateMask))
+		return
+	}
+
+	res := h.newResource()
+	if err := json.NewDecoder(r.Body).Decode(res); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := resourceapi.CheckUpdate(h.table, res, fields); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := resourceapi.SetKey(h.table, res, id); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := h.p.UpdateResource(r.Context(), res, fields, persistsql.WherePK)
+	h.respond(w, http.StatusOK, res, err)
+}
+
+// delete serves the delete endpoint.
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, id string) {
+	res, err := h.keyed(id)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res, err = h.p.DeleteResource(r.Context(), res, nil)
+	h.respond(w, http.StatusOK, res, err)
+}
+
+// undelete serves the undelete endpoint.
+func (h *Handler) undelete(w http.ResponseWriter, r *http.Request, id string) {
+	res, err := h.keyed(id)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res, err = h.p.UndeleteResource(r.Context(), res, nil)
+	h.respond(w, http.StatusOK, res, err)
+}
+
+// respond writes res with status, or the error, 404 if res is nil.
+func (h *Handler) respond(w http.ResponseWriter, status int, res persistsql.Resource, err error) {
+	switch {
+	case err != nil:
+		writeError(w, statusOf(err), err)
+	case res == nil:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	default:
+		writeJSON(w, status, res)
+	}
+}
+
+// newResource returns a new zero resource of the model.
+func (h *Handler) newResource() persistsql.Resource {
+	return reflect.New(h.table.Type).Interface()
+}
+
+// keyed returns a new resource of the model with the primary key id.
+func (h *Handler) keyed(id string) (persistsql.Resource, error) {
+	res := h.newResource()
+
+	return res, resourceapi.SetKey(h.table, res, id)
+}
@@ -0,0 +1,41 @@
+package persistsqlhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type handledOrder struct {
+	ID     int64
+	Total  int
+	Status string
+}
+
+func (*handledOrder) IsFieldOutputOnly(field string) bool {
+	return field == "status"
+}
+
+func TestHandlerRejects(t *testing.T) {
+	// The requests are rejected before reaching the persistence layer.
+	h := New(nil, (*handledOrder)(nil))
+
+	for _, tc := range []struct {
+		method, target, body string
+		want                 string
+	}{
+		{method: http.MethodPost, target: "/", body: `{"Status": "paid"}`, want: `can't set \"status\"`},
+		{method: http.MethodPatch, target: "/1?update_mask=status", body: `{}`, want: `can't update \"status\"`},
+		{method: http.MethodGet, target: "/?order_by=total+sideways", want: `invalid order_by`},
+		{method: http.MethodGet, target: "/?page_token=!", want: `invalid page_token`},
+		{method: http.MethodGet, target: "/?nope=1", want: `can't filter on \"nope\"`},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tc.want) {
+			t.Errorf("%s %s = %d %s, want 400 %s", tc.method, tc.target, w.Code, w.Body, tc.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Package persistsqlredis implements the persistsql.Cache interface on Redis, for cache-aside reads of resources by primary key:
+//
+//	cache := persistsqlredis.New(client, "")
+//	p, err := persistsql.New(db, persistsql.WithCache(cache, 10*time.Minute))
+//	go p.RunCacheInvalidator(ctx, nil)
+//	res, err := p.GetResourceByPK(ctx, &Order{ID: id})
+package persistsqlredis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/chi07/persistsql"
+)
+
+// DefaultPrefix prefixes the keys when no prefix is given to New.
+const DefaultPrefix = "persistsql:"
+
+// Cache is a persistsql.Cache storing resources in Redis under prefixed keys, e.g. persistsql:orders:<id>, see persistsql.CacheKey.
+type Cache struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+var _ persistsql.Cache = (*Cache)(nil)
+
+// New creates a Cache on client, a single node, sentinel or cluster client, prefixing the keys with prefix, DefaultPrefix if empty,
+// so that several applications can share a Redis.
+func New(client redis.UniversalClient, prefix string) *Cache {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+
+	return &Cache{client: client, prefix: prefix}
+}
+
+// Get implements persistsql.Cache.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+// Set implements persistsql.Cache.
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, c.prefix+key, value, ttl).Err()
+}
+
+// Delete implements persistsql.Cache. In a cluster, the keys are deleted one by one as they may live on different nodes.
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	if _, ok := c.client.(*redis.ClusterClient); ok {
+		for _, key := range keys {
+			if err := c.client.Del(ctx, c.prefix+key).Err(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.prefix + key
+	}
+
+	return c.client.Del(ctx, prefixed...).Err()
+}
@@ -0,0 +1,78 @@
+package persistsqlredis
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// testRedisEnv is the environment variable holding the address of the Redis the tests needing one run against,
+// e.g. localhost:6379. They're skipped if it's empty.
+const testRedisEnv = "PERSISTSQL_TEST_REDIS"
+
+func TestNew(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	if c := New(client, ""); c.prefix != DefaultPrefix {
+		t.Errorf("New() prefix = %q, want %q", c.prefix, DefaultPrefix)
+	}
+
+	if c := New(client, "app:"); c.prefix != "app:" {
+		t.Errorf("New(app:) prefix = %q", c.prefix)
+	}
+}
+
+func TestCache(t *testing.T) {
+	addr := os.Getenv(testRedisEnv)
+	if addr == "" {
+		t.Skipf("%s not set", testRedisEnv)
+	}
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	prefix := "persistsqlredis_test:" + time.Now().Format(time.RFC3339Nano) + ":"
+	c := New(client, prefix)
+
+	if value, ok, err := c.Get(ctx, "orders:1"); err != nil || ok || value != nil {
+		t.Errorf("Get() of a missing key = %q, %v, %v", value, ok, err)
+	}
+
+	for _, key := range []string{"orders:1", "orders:2", "orders:3"} {
+		if err := c.Set(ctx, key, []byte(key), time.Minute); err != nil {
+			t.Fatalf("Set(%s): %v", key, err)
+		}
+	}
+
+	if value, ok, err := c.Get(ctx, "orders:1"); err != nil || !ok || string(value) != "orders:1" {
+		t.Errorf("Get() = %q, %v, %v", value, ok, err)
+	}
+
+	// The keys are prefixed and expire.
+	if ttl, err := client.TTL(ctx, prefix+"orders:1").Result(); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Errorf("TTL() = %v, %v, want at most a minute", ttl, err)
+	}
+
+	if err := c.Delete(ctx, "orders:1", "orders:2"); err != nil {
+		t.Fatalf("Delete(): %v", err)
+	}
+
+	for key, want := range map[string]bool{"orders:1": false, "orders:2": false, "orders:3": true} {
+		if _, ok, err := c.Get(ctx, key); err != nil || ok != want {
+			t.Errorf("Get(%s) after Delete() = %v, %v, want %v", key, ok, err, want)
+		}
+	}
+
+	if err := c.Delete(ctx, "orders:3"); err != nil {
+		t.Errorf("Delete(): %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package persistsqlsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// action is a bulk indexing action: a document indexed, or deleted if doc is nil.
+type action struct {
+	index string
+	id    string
+	doc   json.RawMessage
+}
+
+// bulkResponse is the response of the _bulk API.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID     string          `json:"_id"`
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// bulk sends actions to the _bulk API of the cluster at url.
+// Deleting a missing document isn't an error, the first failed action is reported otherwise.
+func bulk(ctx context.Context, client *http.Client, url string, actions []action) error {
+	if len(actions) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, a := range actions {
+		meta := map[string]interface{}{"_index": a.index, "_id": a.id}
+		if a.doc == nil {
+			if err := enc.Encode(map[string]interface{}{"delete": meta}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := enc.Encode(map[string]interface{}{"index": meta}); err != nil {
+			return err
+		}
+		body.Write(a.doc)
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(url, "/")+"/_bulk", &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("bulk request: %s: %s", resp.Status, msg)
+	}
+
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("bulk response: %w", err)
+	}
+
+	if !result.Errors {
+		return nil
+	}
+
+	for _, item := range result.Items {
+		for op, status := range item {
+			if status.Status < 300 || op == "delete" && status.Status == http.StatusNotFound {
+				continue
+			}
+
+			return fmt.Errorf("bulk %s of %s: status %d: %s", op, status.ID, status.Status, status.Error)
+		}
+	}
+
+	return nil
+}
+
+// scrollTTL is how long the cluster keeps the search context of a scroll between two pages.
+const scrollTTL = "1m"
+
+// scrollResponse is the response of the _search API when scrolling.
+type scrollResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Hits []struct {
+			ID string `json:"_id"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// scrollIDs calls fn with the IDs of the documents of index, by pages of at most size IDs, until fn fails.
+// A missing index has no documents.
+func scrollIDs(ctx context.Context, client *http.Client, url, index string, size int, fn func(ids []string) error) error {
+	url = strings.TrimSuffix(url, "/")
+
+	var page scrollResponse
+	status, err := call(ctx, client, http.MethodPost, url+"/"+index+"/_search?scroll="+scrollTTL,
+		map[string]interface{}{"size": size, "_source": false, "sort": []string{"_doc"}}, &page)
+	if status == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("scrolling %s: %w", index, err)
+	}
+
+	defer func() {
+		if page.ScrollID != "" {
+			_, _ = call(context.Background(), client, http.MethodDelete, url+"/_search/scroll", map[string]interface{}{"scroll_id": page.ScrollID}, nil)
+		}
+	}()
+
+	for len(page.Hits.Hits) > 0 {
+		ids := make([]string, len(page.Hits.Hits))
+		for i, hit := range page.Hits.Hits {
+			ids[i] = hit.ID
+		}
+
+		if err := fn(ids); err != nil {
+			return err
+		}
+
+		scrollID := page.ScrollID
+		page = scrollResponse{}
+		if _, err := call(ctx, client, http.MethodPost, url+"/_search/scroll", map[string]interface{}{"scroll": scrollTTL, "scroll_id": scrollID}, &page); err != nil {
+			page.ScrollID = scrollID
+			return fmt.Errorf("scrolling %s: %w", index, err)
+		}
+	}
+
+	return nil
+}
+
+// call sends body encoded in JSON to url and decodes the response into out, if non-nil. It returns the status of the response,
+// an error if it isn't 200 OK.
+func call(ctx context.Context, client *http.Client, method, url string, body, out interface{}) (int, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+
+	if out == nil {
+		return resp.StatusCode, nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,327 @@
+// Package persistsqlsearch keeps Elasticsearch or OpenSearch indexes in sync with the tables of a persistsql persistence layer,
+// following its change feed.
+package persistsqlsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql"
+)
+
+// retryDelay is the delay before a mapping is followed again after its subscription ended.
+const retryDelay = 5 * time.Second
+
+// Mapper maps a resource to the search document indexing it.
+type Mapper func(res persistsql.Resource) (interface{}, error)
+
+// Mapping projects the resources of a model to an index, with their primary key as document ID, see persistsql.PrimaryKey.
+type Mapping struct {
+	// Model is a pointer to a model, e.g. (*Order)(nil), with a single column primary key
+	Model interface{}
+	// Index is the name of the index
+	Index string
+	// QueryHook selects the resources indexed, all if nil, the others are removed from the index
+	QueryHook persistsql.QueryHook
+	// Mapper maps the resources to documents, the documents are the resources themselves if nil
+	Mapper Mapper
+}
+
+// action returns the bulk action indexing res.
+func (m Mapping) action(res persistsql.Resource) (action, error) {
+	var doc interface{} = res
+	if m.Mapper != nil {
+		var err error
+		if doc, err = m.Mapper(res); err != nil {
+			return action{}, fmt.Errorf("mapping %s of %s: %w", persistsql.PrimaryKey(res), m.Index, err)
+		}
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return action{}, fmt.Errorf("encoding %s of %s: %w", persistsql.PrimaryKey(res), m.Index, err)
+	}
+
+	return action{index: m.Index, id: persistsql.PrimaryKey(res), doc: b}, nil
+}
+
+// Projector indexes resources in a search cluster, as they change.
+type Projector struct {
+	// URL of the cluster, e.g. http://localhost:9200
+	URL string
+	// Client makes the requests, http.DefaultClient if nil
+	Client *http.Client
+	// BatchSize is the maximum number of documents per bulk request, 500 if zero
+	BatchSize int
+	// FlushInterval is the maximum delay before a change is indexed, 1 second if zero
+	FlushInterval time.Duration
+
+	p        *persistsql.SQL
+	mappings []Mapping
+}
+
+// New creates a Projector of the resources of p to the cluster at url, according to mappings.
+func New(p *persistsql.SQL, url string, mappings ...Mapping) *Projector {
+	return &Projector{URL: url, p: p, mappings: mappings}
+}
+
+// Run keeps the indexes in sync until ctx is done. Each mapping is resynced from the snapshot of its subscription, documents
+// of resources deleted meanwhile being removed, then its changes are indexed in bulk. When the change feed is lost, the mapping is resynced again. A nil alert logs errors with the standard logger.
+func (pr *Projector) Run(ctx context.Context, alert func(error)) {
+	if alert == nil {
+		alert = func(err error) {
+			log.Printf("persistsqlsearch: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, m := range pr.mappings {
+		wg.Add(1)
+		go func(m Mapping) {
+			defer wg.Done()
+
+			for ctx.Err() == nil {
+				if err := pr.follow(ctx, m, alert); err != nil && ctx.Err() == nil {
+					alert(fmt.Errorf("following %s: %w", m.Index, err))
+				}
+
+				select {
+				case <-ctx.Done():
+				case <-time.After(retryDelay):
+				}
+			}
+		}(m)
+	}
+
+	wg.Wait()
+}
+
+// Resync indexes all the resources of the mapping of model, by pages of BatchSize resources in primary key order,
+// then removes the documents of the resources no longer selected, e.g. deleted while the projector wasn't running, see sweep.
+func (pr *Projector) Resync(ctx context.Context, model interface{}) error {
+	m, ok := pr.mapping(model)
+	if !ok {
+		return fmt.Errorf("no mapping for %T", model)
+	}
+
+	table := orm.GetTable(reflect.TypeOf(m.Model).Elem())
+	if len(table.PKs) != 1 {
+		return fmt.Errorf("resyncing %s: the primary key must be a single column", m.Index)
+	}
+	pk := table.PKs[0]
+
+	var last interface{}
+	for {
+		list := reflect.New(reflect.SliceOf(reflect.TypeOf(m.Model)))
+		if _, err := pr.p.ListResources(ctx, list.Interface(), persistsql.ListOptions{}, func(query *orm.Query) {
+			m.hook()(query)
+			if last != nil {
+				query.Where("?TableAlias.? > ?", pk.Column, last)
+			}
+			query.OrderExpr("?TableAlias.?", pk.Column).Limit(pr.batchSize())
+		}); err != nil {
+			return err
+		}
+
+		rows := list.Elem()
+		actions := make([]action, 0, rows.Len())
+		for i := 0; i < rows.Len(); i++ {
+			a, err := m.action(rows.Index(i).Interface())
+			if err != nil {
+				return err
+			}
+			actions = append(actions, a)
+		}
+
+		if err := pr.send(ctx, actions); err != nil {
+			return err
+		}
+
+		if rows.Len() < pr.batchSize() {
+			return pr.sweep(ctx, m)
+		}
+		last = pk.Value(reflect.Indirect(rows.Index(rows.Len() - 1))).Interface()
+	}
+}
+
+// sweep removes the documents of the index of m whose resource isn't selected anymore, checking the documents by pages of BatchSize.
+// A resource created while the page of its document is checked may have the document removed, the next resync restores it.
+func (pr *Projector) sweep(ctx context.Context, m Mapping) error {
+	table := orm.GetTable(reflect.TypeOf(m.Model).Elem())
+	if len(table.PKs) != 1 {
+		return fmt.Errorf("sweeping %s: the primary key must be a single column", m.Index)
+	}
+	pk := table.PKs[0]
+
+	return scrollIDs(ctx, pr.client(), pr.URL, m.Index, pr.batchSize(), func(ids []string) error {
+		list := reflect.New(reflect.SliceOf(reflect.TypeOf(m.Model)))
+		if _, err := pr.p.ListResources(ctx, list.Interface(), persistsql.ListOptions{}, func(query *orm.Query) {
+			m.hook()(query)
+			query.Where("?TableAlias.?::text IN (?)", pk.Column, pg.In(ids))
+		}); err != nil {
+			return err
+		}
+
+		live := make(map[string]bool, list.Elem().Len())
+		for i := 0; i < list.Elem().Len(); i++ {
+			live[persistsql.PrimaryKey(list.Elem().Index(i).Interface())] = true
+		}
+
+		var stale []action
+		for _, id := range ids {
+			if !live[id] {
+				stale = append(stale, action{index: m.Index, id: id})
+			}
+		}
+
+		return pr.send(ctx, stale)
+	})
+}
+
+// follow indexes the snapshot and then the changes of the resources of m, until the subscription ends.
+// Once the snapshot is indexed, the documents of the resources not in it are removed, see sweep.
+// Resources which can't be mapped are reported to alert and skipped. The changes a bulk request failed to index are kept
+// and sent again with the next ones, unless BatchSize of them are pending: the subscription then ends, to resync on the next one.
+func (pr *Projector) follow(ctx context.Context, m Mapping, alert func(error)) error {
+	events, err := pr.p.Subscribe(ctx, m.Model, m.hook())
+	if err != nil {
+		return err
+	}
+
+	interval := pr.FlushInterval
+	if interval == 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pending []action
+	add := func(res persistsql.Resource, key string) {
+		a := action{index: m.Index, id: key}
+		if res != nil {
+			var err error
+			if a, err = m.action(res); err != nil {
+				alert(err)
+				return
+			}
+		}
+
+		pending = append(pending, a)
+	}
+	flush := func() error {
+		if err := pr.send(ctx, pending); err != nil {
+			return err
+		}
+
+		pending = pending[:0]
+		return nil
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return flush()
+			}
+
+			if event.Err != nil {
+				if err := flush(); err != nil {
+					alert(err)
+				}
+				return event.Err
+			}
+
+			if event.Snapshot != nil {
+				for _, res := range event.Snapshot {
+					add(res, "")
+				}
+
+				if err := flush(); err != nil {
+					return err
+				}
+
+				if err := pr.sweep(ctx, m); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			add(event.Resource, event.Key)
+			if len(pending) >= pr.batchSize() {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				alert(fmt.Errorf("indexing %s: %w", m.Index, err))
+			}
+		}
+	}
+}
+
+// send sends actions in bulk requests of at most BatchSize actions.
+func (pr *Projector) send(ctx context.Context, actions []action) error {
+	client := pr.client()
+	for len(actions) > 0 {
+		n := pr.batchSize()
+		if n > len(actions) {
+			n = len(actions)
+		}
+
+		if err := bulk(ctx, client, pr.URL, actions[:n]); err != nil {
+			return err
+		}
+		actions = actions[n:]
+	}
+
+	return nil
+}
+
+// client returns the client making the requests.
+func (pr *Projector) client() *http.Client {
+	if pr.Client == nil {
+		return http.DefaultClient
+	}
+
+	return pr.Client
+}
+
+// batchSize returns the maximum number of actions per bulk request.
+func (pr *Projector) batchSize() int {
+	if pr.BatchSize == 0 {
+		return 500
+	}
+
+	return pr.BatchSize
+}
+
+// mapping returns the mapping of the type of model.
+func (pr *Projector) mapping(model interface{}) (Mapping, bool) {
+	for _, m := range pr.mappings {
+		if reflect.TypeOf(m.Model) == reflect.TypeOf(model) {
+			return m, true
+		}
+	}
+
+	return Mapping{}, false
+}
+
+// hook returns the QueryHook of m, selecting all resources if it's nil.
+func (m Mapping) hook() persistsql.QueryHook {
+	if m.QueryHook == nil {
+		return func(*orm.Query) {}
+	}
+
+	return m.QueryHook
+}
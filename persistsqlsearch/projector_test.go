@@ -0,0 +1,246 @@
+package persistsqlsearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chi07/persistsql/model"
+	"github.com/chi07/persistsql/persistsqltest"
+)
+
+// fakeCluster serves the _bulk and scroll APIs over in-memory indexes.
+type fakeCluster struct {
+	mu      sync.Mutex
+	docs    map[string]map[string]json.RawMessage
+	scrolls map[string][]string
+	fail    bool
+	bulks   int
+}
+
+func newFakeCluster(t *testing.T) (*fakeCluster, *httptest.Server) {
+	c := &fakeCluster{docs: map[string]map[string]json.RawMessage{}, scrolls: map[string][]string{}}
+	srv := httptest.NewServer(c)
+	t.Cleanup(srv.Close)
+
+	return c, srv
+}
+
+func (c *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/_bulk":
+		c.bulk(w, r)
+	case r.URL.Path == "/_search/scroll" && r.Method == http.MethodDelete:
+		var body struct {
+			ScrollID string `json:"scroll_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		delete(c.scrolls, body.ScrollID)
+	case r.URL.Path == "/_search/scroll":
+		var body struct {
+			ScrollID string `json:"scroll_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		c.page(w, body.ScrollID, 0)
+	case strings.HasSuffix(r.URL.Path, "/_search"):
+		docs, ok := c.docs[strings.Trim(strings.TrimSuffix(r.URL.Path, "/_search"), "/")]
+		if !ok {
+			http.Error(w, "no such index", http.StatusNotFound)
+			return
+		}
+
+		var body struct {
+			Size int `json:"size"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		ids := make([]string, 0, len(docs))
+		for id := range docs {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		scrollID := fmt.Sprint("scroll-", len(c.scrolls))
+		c.scrolls[scrollID] = ids
+		c.page(w, scrollID, body.Size)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// page writes the next page of the scroll, of size IDs, 1 if size is zero.
+func (c *fakeCluster) page(w http.ResponseWriter, scrollID string, size int) {
+	if size == 0 {
+		size = 1
+	}
+
+	ids := c.scrolls[scrollID]
+	if size > len(ids) {
+		size = len(ids)
+	}
+	c.scrolls[scrollID] = ids[size:]
+
+	var resp scrollResponse
+	resp.ScrollID = scrollID
+	for _, id := range ids[:size] {
+		resp.Hits.Hits = append(resp.Hits.Hits, struct {
+			ID string `json:"_id"`
+		}{ID: id})
+	}
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (c *fakeCluster) bulk(w http.ResponseWriter, r *http.Request) {
+	c.bulks++
+	if c.fail {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var meta map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &meta); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for op, m := range meta {
+			if c.docs[m.Index] == nil {
+				c.docs[m.Index] = map[string]json.RawMessage{}
+			}
+
+			if op == "delete" {
+				delete(c.docs[m.Index], m.ID)
+				continue
+			}
+
+			scanner.Scan()
+			c.docs[m.Index][m.ID] = append(json.RawMessage(nil), scanner.Bytes()...)
+		}
+	}
+
+	_ = json.NewEncoder(w).Encode(bulkResponse{})
+}
+
+// ids returns the sorted IDs of the documents of index.
+func (c *fakeCluster) ids(index string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ids := make([]string, 0, len(c.docs[index]))
+	for id := range c.docs[index] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestScrollIDs(t *testing.T) {
+	c, srv := newFakeCluster(t)
+	ctx := context.Background()
+
+	var pages [][]string
+	collect := func(ids []string) error {
+		pages = append(pages, ids)
+		return nil
+	}
+
+	if err := scrollIDs(ctx, srv.Client(), srv.URL, "orders", 2, collect); err != nil || len(pages) != 0 {
+		t.Fatalf("scrollIDs() of a missing index = %v, %v, want no pages", pages, err)
+	}
+
+	actions := []action{
+		{index: "orders", id: "a", doc: json.RawMessage(`{}`)},
+		{index: "orders", id: "b", doc: json.RawMessage(`{}`)},
+		{index: "orders", id: "c", doc: json.RawMessage(`{}`)},
+	}
+	if err := bulk(ctx, srv.Client(), srv.URL, actions); err != nil {
+		t.Fatalf("bulk(): %v", err)
+	}
+
+	if err := scrollIDs(ctx, srv.Client(), srv.URL, "orders", 2, collect); err != nil {
+		t.Fatalf("scrollIDs(): %v", err)
+	}
+
+	if fmt.Sprint(pages) != "[[a b] [c]]" {
+		t.Errorf("scrollIDs() pages = %v, want [[a b] [c]]", pages)
+	}
+
+	if len(c.scrolls) != 0 {
+		t.Errorf("scrolls left open: %v", c.scrolls)
+	}
+}
+
+func TestBulkFailure(t *testing.T) {
+	c, srv := newFakeCluster(t)
+	c.fail = true
+
+	err := bulk(context.Background(), srv.Client(), srv.URL, []action{{index: "orders", id: "a"}})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("bulk() = %v, want the status", err)
+	}
+}
+
+type searchedOrder struct {
+	tableName struct{} `pg:"test_searched_orders"`
+
+	model.Common
+	Total int `pg:",use_zero"`
+}
+
+func TestResync(t *testing.T) {
+	p, db := persistsqltest.Open(t)
+	persistsqltest.Tables(t, db, (*searchedOrder)(nil))
+
+	c, srv := newFakeCluster(t)
+	ctx := context.Background()
+
+	var want []string
+	for i := 0; i < 5; i++ {
+		res, err := p.CreateResource(ctx, &searchedOrder{Total: i})
+		if err != nil {
+			t.Fatalf("CreateResource(): %v", err)
+		}
+		want = append(want, res.(*searchedOrder).ID.String())
+	}
+	sort.Strings(want)
+
+	// The document of an order deleted while the projector wasn't running.
+	if err := bulk(ctx, srv.Client(), srv.URL, []action{{index: "orders", id: "deleted", doc: json.RawMessage(`{}`)}}); err != nil {
+		t.Fatalf("bulk(): %v", err)
+	}
+	c.bulks = 0
+
+	pr := New(p, srv.URL, Mapping{Model: (*searchedOrder)(nil), Index: "orders"})
+	pr.Client = srv.Client()
+	pr.BatchSize = 2
+
+	if err := pr.Resync(ctx, (*searchedOrder)(nil)); err != nil {
+		t.Fatalf("Resync(): %v", err)
+	}
+
+	if got := c.ids("orders"); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("indexed %v, want %v", got, want)
+	}
+
+	// 3 pages of orders, then 3 pages of documents checked, one of them holding the stale document.
+	if c.bulks != 4 {
+		t.Errorf("%d bulk requests, want 4", c.bulks)
+	}
+}
@@ -0,0 +1,62 @@
+package persistsqltest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql"
+)
+
+// DatabaseEnv is the environment variable holding the URL of the database the tests needing one run against,
+// e.g. postgres://postgres@localhost/persistsql_test?sslmode=disable. Open skips the tests if it's empty.
+const DatabaseEnv = "PERSISTSQL_TEST_DATABASE"
+
+// Open returns a persistence layer on the database of DatabaseEnv configured by opts, closed when t ends,
+// skipping t if there's none.
+func Open(t testing.TB, opts ...persistsql.Option) (*persistsql.SQL, *pg.DB) {
+	t.Helper()
+
+	url := os.Getenv(DatabaseEnv)
+	if url == "" {
+		t.Skipf("%s not set", DatabaseEnv)
+	}
+
+	opt, err := pg.ParseURL(url)
+	if err != nil {
+		t.Fatalf("pg.ParseURL(): %v", err)
+	}
+
+	db := pg.Connect(opt)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	p, err := persistsql.New(db, opts...)
+	if err != nil {
+		t.Fatalf("persistsql.New(): %v", err)
+	}
+
+	return p, db
+}
+
+// Tables creates the tables of models in db, replacing existing ones, and drops them when t ends.
+func Tables(t testing.TB, db *pg.DB, models ...interface{}) {
+	t.Helper()
+
+	ctx := context.Background()
+	for _, model := range models {
+		model := model
+		_ = db.ModelContext(ctx, model).DropTable(&orm.DropTableOptions{IfExists: true, Cascade: true})
+		if err := db.ModelContext(ctx, model).CreateTable(&orm.CreateTableOptions{}); err != nil {
+			t.Fatalf("CreateTable(%T): %v", model, err)
+		}
+
+		t.Cleanup(func() {
+			_ = db.ModelContext(ctx, model).DropTable(&orm.DropTableOptions{IfExists: true, Cascade: true})
+		})
+	}
+}
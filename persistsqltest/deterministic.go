@@ -0,0 +1,77 @@
+// Package persistsqltest provides helpers to test code built on persistsql.
+package persistsqltest
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/chi07/persistsql"
+)
+
+// Epoch is the time the clocks of Deterministic start at.
+var Epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Clock is a fake persistsql.Clock, each call to Now returns the time of the previous one plus a step.
+type Clock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+// NewClock returns a Clock starting at start, moving by step on each call to Now.
+func NewClock(start time.Time, step time.Duration) *Clock {
+	return &Clock{now: start, step: step}
+}
+
+// Now implements persistsql.Clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now
+	c.now = c.now.Add(c.step)
+
+	return now
+}
+
+// Advance moves the clock by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// IDs is a persistsql.IDGenerator of sequential version 4 UUIDs: 00000000-0000-4000-8000-000000000001, ...
+type IDs struct {
+	mu sync.Mutex
+	n  uint64
+}
+
+// NewID implements persistsql.IDGenerator.
+func (g *IDs) NewID() uuid.UUID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.n++
+
+	var id uuid.UUID
+	binary.BigEndian.PutUint64(id[8:], g.n)
+	id[6] = 0x40
+	id[8] |= 0x80
+
+	return id
+}
+
+// Deterministic returns a copy of ctx fixing the clock and the ID sequence of the persistence layers it's used with:
+// times start at Epoch and move by a second on each use, IDs are sequential, see IDs.
+// A test run making the same calls in the same order gets the same timestamps and IDs, e.g. for golden files.
+func Deterministic(ctx context.Context) context.Context {
+	ctx = persistsql.ContextWithClock(ctx, NewClock(Epoch, time.Second))
+
+	return persistsql.ContextWithIDGenerator(ctx, &IDs{})
+}
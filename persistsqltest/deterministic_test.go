@@ -0,0 +1,82 @@
+package persistsqltest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chi07/persistsql/model"
+)
+
+func TestClock(t *testing.T) {
+	clock := NewClock(Epoch, time.Second)
+
+	if now := clock.Now(); !now.Equal(Epoch) {
+		t.Errorf("Now() = %v, want %v", now, Epoch)
+	}
+
+	clock.Advance(time.Minute)
+	if now := clock.Now(); !now.Equal(Epoch.Add(time.Minute + time.Second)) {
+		t.Errorf("Now() after Advance() = %v, want a minute and a step later", now)
+	}
+}
+
+func TestIDs(t *testing.T) {
+	var ids IDs
+	for _, want := range []string{"00000000-0000-4000-8000-000000000001", "00000000-0000-4000-8000-000000000002"} {
+		id := ids.NewID()
+		if id.String() != want || id.Version() != 4 {
+			t.Errorf("NewID() = %v, want %s", id, want)
+		}
+	}
+}
+
+type deterministicNote struct {
+	tableName struct{} `pg:"test_deterministic_notes"`
+
+	model.Common
+	Text string
+}
+
+func TestDeterministic(t *testing.T) {
+	p, db := Open(t)
+	Tables(t, db, (*deterministicNote)(nil))
+
+	// Two runs making the same calls get the same keys and timestamps.
+	var runs [2][]*deterministicNote
+	for i := range runs {
+		// The second run inserts the same keys.
+		if _, err := db.Exec("TRUNCATE test_deterministic_notes"); err != nil {
+			t.Fatalf("TRUNCATE: %v", err)
+		}
+
+		ctx := Deterministic(context.Background())
+		for _, text := range []string{"a", "b"} {
+			created, err := p.CreateResource(ctx, &deterministicNote{Text: text + string(rune('0'+i))})
+			if err != nil {
+				t.Fatalf("CreateResource(): %v", err)
+			}
+			runs[i] = append(runs[i], created.(*deterministicNote))
+		}
+	}
+
+	for j := range runs[0] {
+		a, b := runs[0][j], runs[1][j]
+		if a.ID != b.ID || !a.CreateTime.Equal(b.CreateTime) {
+			t.Errorf("note %d: %v at %v, then %v at %v, want the same", j, a.ID, a.CreateTime, b.ID, b.CreateTime)
+		}
+	}
+
+	if first := runs[0][0]; first.ID != (&IDs{}).NewID() || !first.CreateTime.Equal(Epoch) {
+		t.Errorf("first note = %v at %v, want the first ID at Epoch", first.ID, first.CreateTime)
+	}
+
+	// Other contexts keep the persistence layer's own generators.
+	created, err := p.CreateResource(context.Background(), &deterministicNote{Text: "c"})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	if note := created.(*deterministicNote); note.CreateTime.Equal(Epoch) || note.ID == runs[0][0].ID {
+		t.Errorf("CreateResource() without Deterministic = %v at %v", note.ID, note.CreateTime)
+	}
+}
@@ -0,0 +1,27 @@
+package persistsqltest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chi07/persistsql"
+)
+
+// WithRollback returns a copy of p bound to a transaction rolled back when t ends, see persistsql.SQL.WithTx,
+// so tests against a shared database don't see each other's writes and leave nothing behind.
+func WithRollback(t testing.TB, p *persistsql.SQL) *persistsql.SQL {
+	t.Helper()
+
+	tx, err := p.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Begin(): %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := tx.Rollback(); err != nil {
+			t.Errorf("Rollback(): %v", err)
+		}
+	})
+
+	return p.WithTx(tx)
+}
@@ -0,0 +1,39 @@
+package persistsqltest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql"
+)
+
+func TestWithRollback(t *testing.T) {
+	p, db := Open(t)
+	Tables(t, db, (*deterministicNote)(nil))
+	ctx := context.Background()
+
+	t.Run("writes", func(t *testing.T) {
+		tx := WithRollback(t, p)
+
+		created, err := tx.CreateResource(ctx, &deterministicNote{Text: "a"})
+		if err != nil {
+			t.Fatalf("CreateResource(): %v", err)
+		}
+
+		var notes []*deterministicNote
+		if _, err := tx.ListResources(ctx, &notes, persistsql.ListOptions{}, func(*orm.Query) {}); err != nil || len(notes) != 1 {
+			t.Errorf("ListResources() in the transaction = %d notes, %v, want 1", len(notes), err)
+		}
+
+		// Not committed.
+		if count, err := db.Model((*deterministicNote)(nil)).Where("id = ?", created.(*deterministicNote).ID).Count(); err != nil || count != 0 {
+			t.Errorf("%d notes outside the transaction, %v, want none", count, err)
+		}
+	})
+
+	if count, err := db.Model((*deterministicNote)(nil)).Count(); err != nil || count != 0 {
+		t.Errorf("%d notes after the test, %v, want none", count, err)
+	}
+}
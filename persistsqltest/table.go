@@ -0,0 +1,97 @@
+package persistsqltest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/chi07/persistsql"
+)
+
+// UpdateGoldenEnv is the environment variable which, set to a non-empty value, makes AssertTable write the golden files instead of comparing to them.
+const UpdateGoldenEnv = "PERSISTSQL_UPDATE_GOLDEN"
+
+// Masked replaces the values of the masked columns in the dumps of AssertTable.
+const Masked = "<masked>"
+
+// VolatileColumns are the columns masked by AssertTable in addition to the ones given.
+var VolatileColumns = []string{"create_time", "created_at", "update_time", "updated_at", "delete_time", "deleted_at"}
+
+// AssertTable dumps the table of model, see persistsql.SQL.DumpTable, and compares it to goldenFile, failing t if they differ.
+// The non-null values of VolatileColumns and of the mask columns are replaced by Masked, and the rows are sorted,
+// so the dump doesn't depend on the clock nor on the primary keys; mask "id" unless IDs are deterministic, see Deterministic.
+func AssertTable(t testing.TB, ctx context.Context, p *persistsql.SQL, model interface{}, goldenFile string, mask ...string) {
+	t.Helper()
+
+	rows, err := p.DumpTable(ctx, model)
+	if err != nil {
+		t.Fatalf("DumpTable(): %v", err)
+	}
+
+	got, err := normalize(rows, append(append([]string{}, VolatileColumns...), mask...))
+	if err != nil {
+		t.Fatalf("normalize(): %v", err)
+	}
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(goldenFile), 0o755); err != nil {
+			t.Fatalf("os.MkdirAll(): %v", err)
+		}
+
+		if err := os.WriteFile(goldenFile, got, 0o644); err != nil {
+			t.Fatalf("os.WriteFile(): %v", err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("os.ReadFile(): %v, set %s=1 to create it", err, UpdateGoldenEnv)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("table of %T differs from %s, set %s=1 to update it\ngot:\n%s\nwant:\n%s", model, goldenFile, UpdateGoldenEnv, got, want)
+	}
+}
+
+// normalize masks the columns of rows, sorts them and returns them indented, one JSON array.
+func normalize(rows []json.RawMessage, mask []string) ([]byte, error) {
+	normalized := make([]json.RawMessage, len(rows))
+	for i, row := range rows {
+		var values map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(row))
+		decoder.UseNumber()
+		if err := decoder.Decode(&values); err != nil {
+			return nil, err
+		}
+
+		for _, col := range mask {
+			if v, ok := values[col]; ok && v != nil {
+				values[col] = Masked
+			}
+		}
+
+		b, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+
+		normalized[i] = b
+	}
+
+	sort.Slice(normalized, func(i, j int) bool {
+		return bytes.Compare(normalized[i], normalized[j]) < 0
+	})
+
+	b, err := json.MarshalIndent(normalized, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,84 @@
+package persistsqltest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	rows := []json.RawMessage{
+		json.RawMessage(`{"id": 2, "text": "b", "update_time": "2024-01-01T00:00:00Z", "delete_time": null}`),
+		json.RawMessage(`{"id": 1, "text": "a", "update_time": "2024-01-02T00:00:00Z", "total": 12345678901234567890}`),
+	}
+
+	got, err := normalize(rows, []string{"update_time", "delete_time"})
+	if err != nil {
+		t.Fatalf("normalize(): %v", err)
+	}
+
+	// Sorted by their JSON, masked unless null, and numbers kept exact.
+	want := `[
+  {
+    "delete_time": null,
+    "id": 2,
+    "text": "b",
+    "update_time": "\u003cmasked\u003e"
+  },
+  {
+    "id": 1,
+    "text": "a",
+    "total": 12345678901234567890,
+    "update_time": "\u003cmasked\u003e"
+  }
+]
+`
+	if string(got) != want {
+		t.Errorf("normalize() =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := normalize([]json.RawMessage{json.RawMessage(`[]`)}, nil); err == nil {
+		t.Error("normalize() of a non object succeeded")
+	}
+}
+
+// recorder records the failures of AssertTable instead of failing the test.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertTable(t *testing.T) {
+	p, db := Open(t)
+	Tables(t, db, (*deterministicNote)(nil))
+
+	ctx := Deterministic(context.Background())
+	for _, text := range []string{"b", "a"} {
+		if _, err := p.CreateResource(ctx, &deterministicNote{Text: text}); err != nil {
+			t.Fatalf("CreateResource(): %v", err)
+		}
+	}
+
+	golden := filepath.Join(t.TempDir(), "testdata", "notes.json")
+	t.Setenv(UpdateGoldenEnv, "1")
+	AssertTable(t, ctx, p, (*deterministicNote)(nil), golden)
+
+	t.Setenv(UpdateGoldenEnv, "")
+	AssertTable(t, ctx, p, (*deterministicNote)(nil), golden)
+
+	if _, err := p.CreateResource(ctx, &deterministicNote{Text: "c"}); err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+
+	r := &recorder{TB: t}
+	AssertTable(r, ctx, p, (*deterministicNote)(nil), golden)
+	if len(r.failures) != 1 {
+		t.Errorf("AssertTable() of a changed table failed %d times, want once", len(r.failures))
+	}
+}
@@ -0,0 +1,133 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// PlannedStatement is a statement a pending migration would execute.
+type PlannedStatement struct {
+	// Version of the migration
+	Version int64
+	// Migration name
+	Migration string
+	// SQL statement, or a comment if the step doesn't implement Describer
+	SQL string
+	// Lock is the strongest table-level lock the statement is expected to take, empty if unknown.
+	Lock string
+	// Transactional is false if the migration runs outside of a transaction.
+	Transactional bool
+}
+
+// Plan is the ordered list of statements pending migrations would execute.
+type Plan []PlannedStatement
+
+// String formats the plan as an SQL script annotated with migrations and locks.
+func (p Plan) String() string {
+	var b strings.Builder
+	version := int64(-1)
+	for i, stmt := range p {
+		if i == 0 || stmt.Version != version {
+			mode := "transaction"
+			if !stmt.Transactional {
+				mode = "no transaction"
+			}
+			fmt.Fprintf(&b, "-- migration %d: %s (%s)\n", stmt.Version, stmt.Migration, mode)
+			version = stmt.Version
+		}
+
+		if stmt.Lock != "" {
+			fmt.Fprintf(&b, "-- lock: %s\n", stmt.Lock)
+		}
+		fmt.Fprintf(&b, "%s;\n", strings.TrimSuffix(strings.TrimSpace(stmt.SQL), ";"))
+	}
+
+	return b.String()
+}
+
+// Plan returns the statements Migrate would execute, without applying anything.
+func (m *Migrator) Plan(ctx context.Context) (Plan, error) {
+	applied, err := m.applied(ctx, m.sql.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan Plan
+	for _, migration := range m.migrations {
+		if applied[migration.Version] {
+			continue
+		}
+
+		for _, step := range migration.Up {
+			describer, ok := step.(Describer)
+			if !ok {
+				plan = append(plan, PlannedStatement{
+					Version:       migration.Version,
+					Migration:     migration.Name,
+					SQL:           fmt.Sprintf("-- %T: statements unknown", step),
+					Transactional: transactional(migration.Up),
+				})
+				continue
+			}
+
+			for _, q := range describer.Statements() {
+				plan = append(plan, PlannedStatement{
+					Version:       migration.Version,
+					Migration:     migration.Name,
+					SQL:           q,
+					Lock:          estimateLock(q),
+					Transactional: transactional(migration.Up),
+				})
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// lockPrefixes maps statement prefixes to the table lock they take, longest prefixes first.
+var lockPrefixes = []struct {
+	prefix string
+	lock   string
+}{
+	{"CREATE UNIQUE INDEX CONCURRENTLY", "SHARE UPDATE EXCLUSIVE"},
+	{"CREATE INDEX CONCURRENTLY", "SHARE UPDATE EXCLUSIVE"},
+	{"DROP INDEX CONCURRENTLY", "SHARE UPDATE EXCLUSIVE"},
+	{"CREATE UNIQUE INDEX", "SHARE"},
+	{"CREATE INDEX", "SHARE"},
+	{"CREATE TRIGGER", "SHARE ROW EXCLUSIVE"},
+	{"ALTER TABLE", "ACCESS EXCLUSIVE"},
+	{"DROP TABLE", "ACCESS EXCLUSIVE"},
+	{"DROP INDEX", "ACCESS EXCLUSIVE"},
+	{"DROP TRIGGER", "ACCESS EXCLUSIVE"},
+	{"TRUNCATE", "ACCESS EXCLUSIVE"},
+	{"VACUUM FULL", "ACCESS EXCLUSIVE"},
+	{"CREATE TABLE", "none"},
+	{"CREATE OR REPLACE FUNCTION", "none"},
+	{"DROP FUNCTION", "none"},
+	{"INSERT", "ROW EXCLUSIVE"},
+	{"UPDATE", "ROW EXCLUSIVE"},
+	{"DELETE", "ROW EXCLUSIVE"},
+	{"SELECT", "ACCESS SHARE"},
+}
+
+// estimateLock returns the table lock q is expected to take, from its leading keywords.
+func estimateLock(q string) string {
+	q = strings.ToUpper(strings.Join(strings.Fields(q), " "))
+	if strings.HasPrefix(q, "WITH ") {
+		for _, dml := range []string{"UPDATE ", "DELETE ", "INSERT "} {
+			if strings.Contains(q, dml) {
+				return "ROW EXCLUSIVE"
+			}
+		}
+	}
+
+	for _, lp := range lockPrefixes {
+		if strings.HasPrefix(q, lp.prefix) {
+			return lp.lock
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,106 @@
+package persistsql
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+func TestEstimateLock(t *testing.T) {
+	for q, want := range map[string]string{
+		"CREATE INDEX CONCURRENTLY orders_total ON orders (total)":                                             "SHARE UPDATE EXCLUSIVE",
+		"create unique index orders_ref on orders (ref)":                                                       "SHARE",
+		"alter  table\n orders ADD COLUMN note text":                                                           "ACCESS EXCLUSIVE",
+		"CREATE TABLE orders (id bigint)":                                                                      "none",
+		"WITH b AS (SELECT id FROM orders LIMIT 10) UPDATE orders SET total = 0 FROM b WHERE orders.id = b.id": "ROW EXCLUSIVE",
+		"WITH b AS (SELECT 1) SELECT * FROM b":                                                                 "",
+		"SELECT count(*) FROM orders":                                                                          "ACCESS SHARE",
+		"COMMENT ON TABLE orders IS 'x'":                                                                       "",
+	} {
+		if got := estimateLock(q); got != want {
+			t.Errorf("estimateLock(%q) = %q, want %q", q, got, want)
+		}
+	}
+}
+
+func TestPlanString(t *testing.T) {
+	plan := Plan{
+		{Version: 1, Migration: "create", SQL: "CREATE TABLE orders (id bigint);", Lock: "none", Transactional: true},
+		{Version: 1, Migration: "create", SQL: "CREATE INDEX orders_id ON orders (id)", Lock: "SHARE", Transactional: true},
+		{Version: 2, Migration: "index", SQL: " CREATE INDEX CONCURRENTLY orders_total ON orders (total) ; ", Lock: "SHARE UPDATE EXCLUSIVE"},
+		{Version: 3, Migration: "custom", SQL: "-- persistsql.opaqueStep: statements unknown", Transactional: true},
+	}
+
+	want := `-- migration 1: create (transaction)
+-- lock: none
+CREATE TABLE orders (id bigint);
+-- lock: SHARE
+CREATE INDEX orders_id ON orders (id);
+-- migration 2: index (no transaction)
+-- lock: SHARE UPDATE EXCLUSIVE
+CREATE INDEX CONCURRENTLY orders_total ON orders (total) ;
+-- migration 3: custom (transaction)
+-- persistsql.opaqueStep: statements unknown;
+`
+	if got := plan.String(); got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+}
+
+// opaqueStep is a step which doesn't describe its statements.
+type opaqueStep struct{}
+
+func (opaqueStep) Apply(ctx context.Context, db orm.DB) error {
+	_, err := db.ExecContext(ctx, "SELECT 1")
+	return err
+}
+
+func TestMigratorPlan(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*schemaMigration)(nil))
+	t.Cleanup(func() {
+		_, _ = p.db.Exec("DROP TABLE IF EXISTS test_planned_one, test_planned_two")
+	})
+
+	one := Migration{Version: 1, Name: "one", Up: []Step{Statements{"CREATE TABLE test_planned_one (id int)"}}}
+	two := Migration{Version: 2, Name: "two", Up: []Step{
+		Statements{"CREATE TABLE test_planned_two (id int)", "ALTER TABLE test_planned_two ADD COLUMN total int"},
+		opaqueStep{},
+	}}
+
+	if err := p.NewMigrator(one).Migrate(ctx); err != nil {
+		t.Fatalf("Migrate(): %v", err)
+	}
+
+	plan, err := p.NewMigrator(one, two).Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan(): %v", err)
+	}
+
+	if len(plan) != 3 {
+		t.Fatalf("Plan() = %v, want the 3 statements of the pending migration", plan)
+	}
+
+	for _, stmt := range plan {
+		if stmt.Version != 2 || !stmt.Transactional {
+			t.Errorf("planned %+v, want a transactional statement of migration 2", stmt)
+		}
+	}
+
+	if plan[1].Lock != "ACCESS EXCLUSIVE" || !strings.Contains(plan[2].SQL, "opaqueStep: statements unknown") {
+		t.Errorf("Plan() = %+v", plan)
+	}
+
+	// Planning applies nothing.
+	var exists bool
+	if _, err := p.db.QueryOne(pg.Scan(&exists), "SELECT to_regclass('test_planned_two') IS NOT NULL"); err != nil {
+		t.Fatalf("QueryOne(): %v", err)
+	}
+	if exists {
+		t.Error("Plan() created test_planned_two")
+	}
+}
@@ -0,0 +1,232 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// PurgeTarget lists the rows of a table a purge removes.
+type PurgeTarget struct {
+	// Table holding the rows
+	Table string `json:"table"`
+	// Keys are the primary keys of the rows
+	Keys []string `json:"keys"`
+	// Via lists the references leading to the rows, as table.column, empty for the purged resource.
+	Via []string `json:"via,omitempty"`
+
+	pk    string
+	table *orm.Table
+	depth int
+}
+
+// PurgePlan is the set of rows a hard purge of a resource removes: the resource and, recursively,
+// the rows of registered models referencing it through `references` tags, see ValidateReferences.
+type PurgePlan struct {
+	// Targets in deletion order, referencing rows first
+	Targets []PurgeTarget `json:"targets"`
+
+	p *SQL
+}
+
+// Rows returns the total number of rows the plan removes.
+func (pl *PurgePlan) Rows() int {
+	rows := 0
+	for _, target := range pl.Targets {
+		rows += len(target.Keys)
+	}
+
+	return rows
+}
+
+// PlanPurge returns the rows a hard purge of a resource, identified by its primary key, would remove, soft-deleted rows included.
+// Nothing is removed until the plan is executed. The models involved must have a single column primary key.
+func (p *SQL) PlanPurge(ctx context.Context, resource Resource) (*PurgePlan, error) {
+	table := tableOf(resource)
+	if len(table.PKs) != 1 {
+		return nil, errCompositeKey
+	}
+
+	root := &PurgeTarget{
+		Table: unqualifiedName(table),
+		Keys:  []string{primaryKey(resource)},
+		pk:    table.PKs[0].SQLName,
+		table: table,
+	}
+	targets := map[string]*PurgeTarget{root.Table: root}
+	seen := map[string]bool{root.Table + ":" + root.Keys[0]: true}
+
+	queue := []*PurgeTarget{{Table: root.Table, Keys: root.Keys, pk: root.pk, table: table}}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		for _, info := range p.registered() {
+			for _, ref := range references(info.table.Type) {
+				if ref.table != parent.Table {
+					continue
+				}
+
+				if len(info.table.PKs) != 1 {
+					return nil, fmt.Errorf("%s: %w", info.name(), errCompositeKey)
+				}
+
+				keys, err := p.referencingKeys(ctx, info.name(), info.table.PKs[0].SQLName, ref, parent)
+				if err != nil {
+					return nil, err
+				}
+
+				var fresh []string
+				for _, key := range keys {
+					if !seen[info.name()+":"+key] {
+						seen[info.name()+":"+key] = true
+						fresh = append(fresh, key)
+					}
+				}
+
+				if len(fresh) == 0 {
+					continue
+				}
+
+				target, ok := targets[info.name()]
+				if !ok {
+					target = &PurgeTarget{Table: info.name(), pk: info.table.PKs[0].SQLName, table: info.table}
+					targets[info.name()] = target
+				}
+
+				target.Keys = append(target.Keys, fresh...)
+				target.Via = appendUnique(target.Via, info.name()+"."+ref.column)
+				if target.depth < parent.depth+1 {
+					target.depth = parent.depth + 1
+				}
+
+				queue = append(queue, &PurgeTarget{Table: target.Table, Keys: fresh, pk: target.pk, table: target.table, depth: parent.depth + 1})
+			}
+		}
+	}
+
+	plan := &PurgePlan{p: p}
+	for _, target := range targets {
+		plan.Targets = append(plan.Targets, *target)
+	}
+
+	sort.Slice(plan.Targets, func(i, j int) bool {
+		if plan.Targets[i].depth != plan.Targets[j].depth {
+			return plan.Targets[i].depth > plan.Targets[j].depth
+		}
+
+		return plan.Targets[i].Table < plan.Targets[j].Table
+	})
+
+	return plan, nil
+}
+
+// referencingKeys returns the primary keys of the rows of table whose ref column references the rows of parent.
+func (p *SQL) referencingKeys(ctx context.Context, table, pk string, ref reference, parent *PurgeTarget) ([]string, error) {
+	referenced := pg.Safe(formatQuery("SELECT ? FROM ? WHERE ? IN (?)",
+		pg.Ident(ref.target), pg.Ident(parent.Table), pg.Ident(parent.pk), pg.In(parent.Keys)))
+
+	var keys []string
+	if _, err := p.conn().QueryContext(ctx, &keys, "SELECT ?::text FROM ? WHERE ? IN (?) ORDER BY 1",
+		pg.Ident(pk), pg.Ident(table), pg.Ident(ref.column), referenced); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Execute hard-deletes the planned rows in a single transaction, referencing rows first.
+// Rows added since the plan was made aren't removed, foreign key constraints make the purge fail in that case.
+// As with PurgeDeleted, the BlobRef fields of the deleted rows are released, and a ChangeDelete event is published for each.
+func (pl *PurgePlan) Execute(ctx context.Context) error {
+	if err := pl.p.checkWrite(); err != nil {
+		return err
+	}
+
+	return pl.p.runInTransaction(ctx, func(tx *pg.Tx) error {
+		for _, target := range pl.Targets {
+			rows := reflect.New(reflect.SliceOf(reflect.PtrTo(target.table.Type)))
+			if _, err := tx.QueryContext(ctx, rows.Interface(), "DELETE FROM ? WHERE ? IN (?) RETURNING *",
+				pg.Ident(target.Table), pg.Ident(target.pk), pg.In(target.Keys)); err != nil {
+				return fmt.Errorf("%s: %w", target.Table, err)
+			}
+
+			for i := 0; i < rows.Elem().Len(); i++ {
+				row := rows.Elem().Index(i).Interface()
+				if err := pl.p.releaseBlobRefs(ctx, tx, row); err != nil {
+					return fmt.Errorf("%s: %w", target.Table, err)
+				}
+
+				if err := pl.p.notify(ctx, tx, ChangeDelete, row); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// appendUnique appends s to ss unless it's already there.
+func appendUnique(ss []string, s string) []string {
+	for _, e := range ss {
+		if e == s {
+			return ss
+		}
+	}
+
+	return append(ss, s)
+}
+
+// PurgeDeleted hard deletes the rows of the table of model soft-deleted before before, returning their number.
+// Rows referenced by foreign keys make it fail, see PlanPurge. ErrMaintenance is returned while writes are blocked.
+// The BlobRef fields of the purged rows are released in the same transaction, see PutBlob.
+func (p *SQL) PurgeDeleted(ctx context.Context, model interface{}, before time.Time) (int, error) {
+	if err := p.checkWrite(); err != nil {
+		return 0, err
+	}
+
+	table := tableOf(model)
+	if table.SoftDeleteField == nil {
+		return 0, fmt.Errorf("%s has no soft delete column", unqualifiedName(table))
+	}
+
+	if len(blobRefFields(table)) == 0 {
+		res, err := p.conn().ExecContext(ctx, "DELETE FROM ? WHERE ? < ?", table.SQLName, table.SoftDeleteField.Column, before)
+		if err != nil {
+			return 0, err
+		}
+
+		return res.RowsAffected(), nil
+	}
+
+	var purged int
+	err := p.runInTransaction(ctx, func(tx *pg.Tx) error {
+		rows := reflect.New(reflect.SliceOf(reflect.PtrTo(table.Type)))
+		res, err := tx.QueryContext(ctx, rows.Interface(), "DELETE FROM ? WHERE ? < ? RETURNING *",
+			table.SQLName, table.SoftDeleteField.Column, before)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < rows.Elem().Len(); i++ {
+			if err := p.releaseBlobRefs(ctx, tx, rows.Elem().Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+
+		purged = res.RowsAffected()
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,84 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type purgedAccount struct {
+	tableName struct{} `pg:"test_purged_accounts"`
+
+	model.Common
+}
+
+type purgedInvoice struct {
+	tableName struct{} `pg:"test_purged_invoices"`
+
+	model.Common
+	AccountID uuid.UUID `pg:",type:uuid" references:"test_purged_accounts"`
+}
+
+func TestPurgePlanExecute(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*purgedAccount)(nil), (*purgedInvoice)(nil))
+
+	if err := p.Register((*purgedAccount)(nil), (*purgedInvoice)(nil)); err != nil {
+		t.Fatalf("Register(): %v", err)
+	}
+
+	created, err := p.CreateResource(ctx, &purgedAccount{})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	account := created.(*purgedAccount)
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.CreateResource(ctx, &purgedInvoice{AccountID: account.ID}); err != nil {
+			t.Fatalf("CreateResource(): %v", err)
+		}
+	}
+
+	plan, err := p.PlanPurge(ctx, account)
+	if err != nil {
+		t.Fatalf("PlanPurge(): %v", err)
+	}
+
+	if plan.Rows() != 3 || plan.Targets[0].Table != "test_purged_invoices" {
+		t.Fatalf("plan = %+v, want the 2 invoices then the account", plan.Targets)
+	}
+
+	changesCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changes, err := p.Changes(changesCtx)
+	if err != nil {
+		t.Fatalf("Changes(): %v", err)
+	}
+
+	if err := plan.Execute(ctx); err != nil {
+		t.Fatalf("Execute(): %v", err)
+	}
+
+	deleted := map[string]int{}
+	timeout := time.After(5 * time.Second)
+	for deleted["test_purged_invoices"]+deleted["test_purged_accounts"] < 3 {
+		select {
+		case event := <-changes:
+			if event.Op == ChangeDelete {
+				deleted[event.Table]++
+			}
+		case <-timeout:
+			t.Fatalf("delete events = %v, want 2 invoices and 1 account", deleted)
+		}
+	}
+
+	if n, err := p.db.ModelContext(ctx, (*purgedInvoice)(nil)).AllWithDeleted().Count(); err != nil || n != 0 {
+		t.Errorf("%d invoices left, %v", n, err)
+	}
+}
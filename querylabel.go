@@ -0,0 +1,336 @@
+package persistsql
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+type queryLabelKey struct{}
+
+// WithQueryLabel returns a copy of ctx labelling the queries run with it, e.g. "checkout.list_orders",
+// so the same tables accessed by different features can be told apart.
+// The label is reported to the query observers and in the slow query log, see WithQueryObserver and WithSlowQueryLog,
+// and appended to the queries the persistence layer runs outside of its internal transactions as an sqlcommenter comment, see SQLComment.
+func WithQueryLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, queryLabelKey{}, label)
+}
+
+// QueryLabel returns the query label of ctx, empty if there's none.
+func QueryLabel(ctx context.Context) string {
+	label, _ := ctx.Value(queryLabelKey{}).(string)
+
+	return label
+}
+
+// SQLComment returns the query label of ctx as an sqlcommenter comment, e.g. /*label='checkout.list_orders'*/,
+// empty if there's none. It's appended to the queries of the persistence layer, it's exported for the raw queries run with go-pg directly.
+func SQLComment(ctx context.Context) string {
+	label := QueryLabel(ctx)
+	if label == "" {
+		return ""
+	}
+
+	value := strings.ReplaceAll(url.QueryEscape(label), "'", `\'`)
+
+	return "/*label='" + value + "'*/"
+}
+
+// QueryInfo describes a query run by a persistence layer.
+type QueryInfo struct {
+	// Label of the query, see WithQueryLabel
+	Label string
+	// Query text, formatted
+	Query string
+	// Duration of the query
+	Duration time.Duration
+	// Err is the error returned by the query, if any
+	Err error
+}
+
+// slowSamples is the number of recent slow queries kept for CollectDiagnostics.
+const slowSamples = 20
+
+// observers holds the query observers of a persistence layer.
+type observers struct {
+	fns  []func(ctx context.Context, info QueryInfo)
+	slow time.Duration
+	logf func(format string, args ...interface{})
+
+	mu      sync.Mutex
+	samples []QueryInfo
+}
+
+// sample keeps info among the recent slow queries, with the literals of its query masked since they can hold row data.
+func (o *observers) sample(info QueryInfo) {
+	info.Query = maskLiterals(info.Query)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.samples) == slowSamples {
+		o.samples = append(o.samples[:0], o.samples[1:]...)
+	}
+	o.samples = append(o.samples, info)
+}
+
+// slowQueries returns the recent slow queries, oldest first.
+func (o *observers) slowQueries() []QueryInfo {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]QueryInfo(nil), o.samples...)
+}
+
+// WithQueryObserver calls fn after each query with its label and duration, e.g. to record metrics.
+func WithQueryObserver(fn func(ctx context.Context, info QueryInfo)) Option {
+	return func(p *SQL) {
+		p.observers.fns = append(p.observers.fns, fn)
+	}
+}
+
+// WithSlowQueryLog logs the queries taking threshold or longer with logf, log.Printf if nil.
+// The most recent ones are also kept for CollectDiagnostics, with their string and numeric literals replaced by ?.
+func WithSlowQueryLog(threshold time.Duration, logf func(format string, args ...interface{})) Option {
+	return func(p *SQL) {
+		if logf == nil {
+			logf = log.Printf
+		}
+
+		p.observers.slow = threshold
+		p.observers.logf = logf
+	}
+}
+
+// observe replaces the databases of p by copies running the query hook, reporting to the observers and traces, see WithSQLTrace.
+// The databases of the caller, which other persistence layers may share, are left without the hook.
+func (p *SQL) observe() {
+	hook := &queryHook{observers: p.observers, txLimits: p.txLimits}
+	p.db = withQueryHook(p.db, hook)
+	for i, db := range p.replicas.dbs {
+		p.replicas.dbs[i] = withQueryHook(db, hook)
+	}
+}
+
+// hookedParam is the query param set on the copies of the databases running the query hook.
+const hookedParam = "persistsql_hooked"
+
+// withQueryHook returns a copy of db running hook. The copy is made by WithParam: WithContext shares the hooks of db.
+func withQueryHook(db *pg.DB, hook pg.QueryHook) *pg.DB {
+	hooked := db.WithParam(hookedParam, true)
+	hooked.AddQueryHook(hook)
+
+	return hooked
+}
+
+// labelled is an orm.DB appending the label of the context of its queries as an sqlcommenter comment, see SQLComment.
+type labelled struct {
+	orm.DB
+}
+
+// Model implements orm.DB.
+func (db labelled) Model(model ...interface{}) *orm.Query {
+	return orm.NewQuery(db, model...)
+}
+
+// ModelContext implements orm.DB.
+func (db labelled) ModelContext(ctx context.Context, model ...interface{}) *orm.Query {
+	return orm.NewQueryContext(ctx, db, model...)
+}
+
+// Exec implements orm.DB.
+func (db labelled) Exec(query interface{}, params ...interface{}) (orm.Result, error) {
+	return db.ExecContext(db.Context(), query, params...)
+}
+
+// ExecContext implements orm.DB.
+func (db labelled) ExecContext(ctx context.Context, query interface{}, params ...interface{}) (orm.Result, error) {
+	return db.DB.ExecContext(ctx, commented(ctx, query), params...)
+}
+
+// ExecOne implements orm.DB.
+func (db labelled) ExecOne(query interface{}, params ...interface{}) (orm.Result, error) {
+	return db.ExecOneContext(db.Context(), query, params...)
+}
+
+// ExecOneContext implements orm.DB.
+func (db labelled) ExecOneContext(ctx context.Context, query interface{}, params ...interface{}) (orm.Result, error) {
+	return db.DB.ExecOneContext(ctx, commented(ctx, query), params...)
+}
+
+// Query implements orm.DB.
+func (db labelled) Query(model, query interface{}, params ...interface{}) (orm.Result, error) {
+	return db.QueryContext(db.Context(), model, query, params...)
+}
+
+// QueryContext implements orm.DB.
+func (db labelled) QueryContext(ctx context.Context, model, query interface{}, params ...interface{}) (orm.Result, error) {
+	return db.DB.QueryContext(ctx, model, commented(ctx, query), params...)
+}
+
+// QueryOne implements orm.DB.
+func (db labelled) QueryOne(model, query interface{}, params ...interface{}) (orm.Result, error) {
+	return db.QueryOneContext(db.Context(), model, query, params...)
+}
+
+// QueryOneContext implements orm.DB.
+func (db labelled) QueryOneContext(ctx context.Context, model, query interface{}, params ...interface{}) (orm.Result, error) {
+	return db.DB.QueryOneContext(ctx, model, commented(ctx, query), params...)
+}
+
+// commented returns query, a string or an ORM query, with the sqlcommenter comment of ctx appended, query itself if ctx has no label.
+func commented(ctx context.Context, query interface{}) interface{} {
+	comment := SQLComment(ctx)
+	if comment == "" {
+		return query
+	}
+
+	switch query := query.(type) {
+	case string:
+		return query + " " + comment
+	case orm.QueryCommand:
+		return commentedQuery{QueryCommand: query, comment: comment}
+	default:
+		return query
+	}
+}
+
+// commentedQuery is an ORM query followed by an sqlcommenter comment.
+type commentedQuery struct {
+	orm.QueryCommand
+	comment string
+}
+
+// AppendQuery implements orm.QueryAppender.
+func (q commentedQuery) AppendQuery(fmter orm.QueryFormatter, b []byte) ([]byte, error) {
+	b, err := q.QueryCommand.AppendQuery(fmter, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append(b, ' '), q.comment...), nil
+}
+
+// AppendTemplate implements orm.TemplateAppender.
+func (q commentedQuery) AppendTemplate(b []byte) ([]byte, error) {
+	b, err := q.QueryCommand.AppendTemplate(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append(b, ' '), q.comment...), nil
+}
+
+// Clone implements orm.QueryCommand.
+func (q commentedQuery) Clone() orm.QueryCommand {
+	return commentedQuery{QueryCommand: q.QueryCommand.Clone(), comment: q.comment}
+}
+
+// queryHook reports queries to observers and traces, and enforces transaction limits.
+type queryHook struct {
+	observers *observers
+	txLimits  *txLimits
+}
+
+// BeforeQuery implements pg.QueryHook.
+func (h *queryHook) BeforeQuery(ctx context.Context, event *pg.QueryEvent) (context.Context, error) {
+	return ctx, h.txLimits.before(event)
+}
+
+// AfterQuery implements pg.QueryHook.
+func (h *queryHook) AfterQuery(ctx context.Context, event *pg.QueryEvent) error {
+	query, _ := event.FormattedQuery()
+	info := QueryInfo{
+		Label:    QueryLabel(ctx),
+		Query:    string(query),
+		Duration: time.Since(event.StartTime),
+		Err:      event.Err,
+	}
+
+	for _, fn := range h.observers.fns {
+		fn(ctx, info)
+	}
+
+	if h.observers.slow > 0 && info.Duration >= h.observers.slow {
+		label := info.Label
+		if label == "" {
+			label = "-"
+		}
+
+		h.observers.logf("persistsql: slow query [%s] %s: %s", label, info.Duration, info.Query)
+		h.observers.sample(info)
+	}
+
+	if err := h.txLimits.after(event); err != nil {
+		return err
+	}
+
+	if trace := sqlTrace(ctx); trace != nil {
+		trace.record(TracedQuery{Query: info.Query, Params: event.Params, Duration: info.Duration, Err: info.Err})
+		if info.Err != nil {
+			return &QueryError{Query: info.Query, Err: info.Err}
+		}
+	}
+
+	return nil
+}
+
+// maskLiterals returns query with its string and numeric literals replaced by ?, quoted identifiers being kept.
+func maskLiterals(query string) string {
+	var b strings.Builder
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'':
+			j := i + 1
+			for ; j < len(query); j++ {
+				if query[j] != '\'' {
+					continue
+				}
+				if j+1 < len(query) && query[j+1] == '\'' {
+					j++
+					continue
+				}
+				break
+			}
+			b.WriteByte('?')
+			i = j + 1
+		case c == '"':
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 2
+		case isDigit(c) && (i == 0 || !isIdentByte(query[i-1])):
+			j := i
+			for j < len(query) && (isDigit(query[j]) || query[j] == '.') {
+				j++
+			}
+			b.WriteByte('?')
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+// isDigit reports whether c is an ASCII digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isIdentByte reports whether c can be part of an unquoted identifier or a positional parameter, e.g. $1.
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
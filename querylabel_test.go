@@ -0,0 +1,118 @@
+package persistsql
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+type labelledOrder struct {
+	ID    int64
+	Total int
+}
+
+func TestSQLComment(t *testing.T) {
+	if comment := SQLComment(context.Background()); comment != "" {
+		t.Errorf("SQLComment() without label = %q, want empty", comment)
+	}
+
+	ctx := WithQueryLabel(context.Background(), "checkout.list_orders")
+	if comment := SQLComment(ctx); comment != "/*label='checkout.list_orders'*/" {
+		t.Errorf("SQLComment() = %q", comment)
+	}
+
+	ctx = WithQueryLabel(context.Background(), "it's ?")
+	if comment := SQLComment(ctx); strings.ContainsAny(comment[2:len(comment)-2], "?*") || strings.Count(comment, "'") != 2 {
+		t.Errorf("SQLComment() = %q, want the label escaped", comment)
+	}
+}
+
+func TestCommented(t *testing.T) {
+	ctx := context.Background()
+	if query := commented(ctx, "SELECT 1"); query != "SELECT 1" {
+		t.Errorf("commented() without label = %v", query)
+	}
+
+	ctx = WithQueryLabel(ctx, "checkout.list_orders")
+	if query := commented(ctx, "SELECT ?"); query != "SELECT ? /*label='checkout.list_orders'*/" {
+		t.Errorf("commented(string) = %v", query)
+	}
+
+	query := orm.NewSelectQuery(orm.NewQuery(nil, (*labelledOrder)(nil)).Where("total > ?", 10))
+	b, err := commented(ctx, query).(orm.QueryAppender).AppendQuery(orm.NewFormatter(), nil)
+	if err != nil {
+		t.Fatalf("AppendQuery(): %v", err)
+	}
+
+	if want := `WHERE (total > 10) /*label='checkout.list_orders'*/`; !strings.HasSuffix(string(b), want) {
+		t.Errorf("commented(query) = %s, want suffix %s", b, want)
+	}
+}
+
+func TestMaskLiterals(t *testing.T) {
+	query := `SELECT "t1"."id" FROM orders WHERE name = 'O''Hara' AND total > 10.5 AND id = $1`
+	if masked := maskLiterals(query); masked != `SELECT "t1"."id" FROM orders WHERE name = ? AND total > ? AND id = $1` {
+		t.Errorf("maskLiterals() = %s", masked)
+	}
+}
+
+func TestQueryLabelObserved(t *testing.T) {
+	db := testDB(t)
+
+	var mu sync.Mutex
+	var infos []QueryInfo
+	observe := WithQueryObserver(func(ctx context.Context, info QueryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		infos = append(infos, info)
+	})
+
+	p, err := New(db, observe)
+	if err != nil {
+		t.Fatalf("New(): %v", err)
+	}
+
+	// A second persistence layer on the same database mustn't add its hook to the first one.
+	if _, err := New(db, observe); err != nil {
+		t.Fatalf("New(): %v", err)
+	}
+
+	testTables(t, db, (*labelledOrder)(nil))
+
+	mu.Lock()
+	infos = nil
+	mu.Unlock()
+
+	ctx := WithQueryLabel(context.Background(), "checkout.list_orders")
+	if _, err := db.ExecContext(ctx, "SELECT 1"); err != nil {
+		t.Fatalf("ExecContext(): %v", err)
+	}
+
+	var orders []labelledOrder
+	if err := p.conn().ModelContext(ctx, &orders).Select(); err != nil {
+		t.Fatalf("Select(): %v", err)
+	}
+
+	var one int
+	if _, err := p.reader(ctx).QueryOneContext(ctx, pg.Scan(&one), "SELECT 1"); err != nil {
+		t.Fatalf("QueryOneContext(): %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(infos) != 2 {
+		t.Fatalf("observed %d queries, want 2: %+v", len(infos), infos)
+	}
+
+	for _, info := range infos {
+		if info.Label != "checkout.list_orders" || !strings.HasSuffix(info.Query, "/*label='checkout.list_orders'*/") {
+			t.Errorf("observed %+v, want labelled and commented", info)
+		}
+	}
+}
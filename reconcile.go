@@ -0,0 +1,133 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// ReconcileResult lists the primary keys of the resources changed by Reconcile.
+type ReconcileResult struct {
+	Created []string
+	Updated []string
+	Deleted []string
+}
+
+// Reconcile converges the resources of the collection of model, a pointer to a model, selected by scopeHook, to desired,
+// matching them by primary key, in a single transaction: missing resources are created, differing ones updated, see ComputeChangedFields,
+// soft-deleted ones undeleted, see UndeleteResource, and the resources of the scope not desired deleted, softly if the model supports it.
+// The desired resources must have their primary key set and belong to the scope. The current resources of the scope are locked
+// and the reconciliations of the collection serialized by an advisory lock, but resources inserted into the scope concurrently
+// by other writes aren't seen, and are left as is.
+func (p *SQL) Reconcile(ctx context.Context, model interface{}, desired []Resource, scopeHook QueryHook) (*ReconcileResult, error) {
+	if err := p.checkWrite(); err != nil {
+		return nil, err
+	}
+
+	table := tableOf(model)
+	var result *ReconcileResult
+
+	err := p.RunInTransaction(ctx, func(p *SQL) error {
+		result = &ReconcileResult{}
+
+		if _, err := p.tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext(?))", "persistsql.reconcile:"+unqualifiedName(table)); err != nil {
+			return err
+		}
+
+		current := reflect.New(reflect.SliceOf(reflect.PtrTo(table.Type)))
+		opts := ListOptions{ShowDeleted: table.SoftDeleteField != nil}
+		if _, err := p.ListResources(ctx, current.Interface(), opts, func(query *orm.Query) {
+			scopeHook(query)
+			query.For("UPDATE")
+		}); err != nil {
+			return err
+		}
+
+		byKey := map[string]Resource{}
+		for i := 0; i < current.Elem().Len(); i++ {
+			res := current.Elem().Index(i).Interface()
+			byKey[primaryKey(res)] = res
+		}
+
+		for _, want := range desired {
+			if reflect.TypeOf(want) != current.Type().Elem().Elem() {
+				return fmt.Errorf("can't reconcile %T into %s", want, table.TypeName)
+			}
+
+			key := primaryKey(want)
+			have, ok := byKey[key]
+			delete(byKey, key)
+
+			if !ok {
+				if _, err := p.CreateResource(ctx, want); err != nil {
+					return err
+				}
+
+				result.Created = append(result.Created, key)
+				continue
+			}
+
+			fields, err := ComputeChangedFields(have, want)
+			if err != nil {
+				return err
+			}
+
+			undelete := deleted(have)
+			if undelete {
+				// The copy keeps want from being overwritten by the stored values.
+				if _, err := p.UndeleteResource(ctx, cloneResource(want), nil); err != nil {
+					return err
+				}
+			}
+
+			if len(fields) > 0 {
+				if _, err := p.UpdateResource(ctx, want, fields, WherePK); err != nil {
+					return err
+				}
+			}
+
+			if undelete || len(fields) > 0 {
+				result.Updated = append(result.Updated, key)
+			}
+		}
+
+		stale := make([]string, 0, len(byKey))
+		for key := range byKey {
+			stale = append(stale, key)
+		}
+		sort.Strings(stale)
+
+		for _, key := range stale {
+			have := byKey[key]
+			if deleted(have) {
+				continue
+			}
+
+			if _, err := p.DeleteResource(ctx, have, nil); err != nil {
+				return err
+			}
+
+			result.Deleted = append(result.Deleted, key)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// deleted reports whether model is soft-deleted.
+func deleted(model interface{}) bool {
+	field := tableOf(model).SoftDeleteField
+	if field == nil {
+		return false
+	}
+
+	return !field.Value(reflect.Indirect(reflect.ValueOf(model))).IsZero()
+}
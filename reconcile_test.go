@@ -0,0 +1,75 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type reconciledMember struct {
+	tableName struct{} `pg:"test_reconciled_members"`
+
+	model.Common
+	Team string
+	Role string
+}
+
+func TestReconcileUndeletes(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*reconciledMember)(nil))
+
+	created, err := p.CreateResource(ctx, &reconciledMember{Team: "a", Role: "dev"})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	member := created.(*reconciledMember)
+
+	if _, err := p.DeleteResource(ctx, &reconciledMember{Common: model.Common{ID: member.ID}}, nil); err != nil {
+		t.Fatalf("DeleteResource(): %v", err)
+	}
+
+	changesCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changes, err := p.Changes(changesCtx)
+	if err != nil {
+		t.Fatalf("Changes(): %v", err)
+	}
+
+	want := &reconciledMember{Common: model.Common{ID: member.ID}, Team: "a", Role: "lead"}
+	result, err := p.Reconcile(ctx, (*reconciledMember)(nil), []Resource{want}, func(query *orm.Query) {
+		query.Where("team = ?", "a")
+	})
+	if err != nil {
+		t.Fatalf("Reconcile(): %v", err)
+	}
+
+	if len(result.Updated) != 1 || len(result.Created)+len(result.Deleted) != 0 {
+		t.Errorf("Reconcile() = %+v, want the member updated", result)
+	}
+
+	var ops []ChangeOp
+	timeout := time.After(5 * time.Second)
+	for len(ops) < 2 {
+		select {
+		case event := <-changes:
+			ops = append(ops, event.Op)
+		case <-timeout:
+			t.Fatalf("events = %v, want undelete then update", ops)
+		}
+	}
+
+	if ops[0] != ChangeUndelete || ops[1] != ChangeUpdate {
+		t.Errorf("events = %v, want undelete then update", ops)
+	}
+
+	got, err := p.GetResource(ctx, &reconciledMember{Common: model.Common{ID: member.ID}}, false, WherePK)
+	if err != nil || got == nil || got.(*reconciledMember).Role != "lead" {
+		t.Errorf("GetResource() = %+v, %v, want the member undeleted as a lead", got, err)
+	}
+}
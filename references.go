@@ -0,0 +1,101 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// BrokenReference is a reference of a resource to a row which doesn't exist.
+type BrokenReference struct {
+	// Field is the name of the struct field holding the reference
+	Field string `json:"field"`
+	// Column holding the reference
+	Column string `json:"column"`
+	// Table and TableColumn are the referenced table and column
+	Table       string `json:"table"`
+	TableColumn string `json:"table_column"`
+	// Value of the reference
+	Value interface{} `json:"value"`
+}
+
+// Error describes the broken reference.
+func (r BrokenReference) Error() string {
+	return fmt.Sprintf("%s: no %s with %s %v", r.Column, r.Table, r.TableColumn, r.Value)
+}
+
+// reference is a foreign key declared with a `references:"table(column)"` tag, the column defaulting to id.
+type reference struct {
+	field  string
+	column string
+	table  string
+	target string
+	index  []int
+}
+
+// references returns the references declared by the fields of model.
+func references(model interface{}) []reference {
+	var refs []reference
+	for _, field := range tableOf(model).Fields {
+		tag, ok := field.Field.Tag.Lookup("references")
+		if !ok {
+			continue
+		}
+
+		ref := reference{
+			field:  field.GoName,
+			column: field.SQLName,
+			table:  tag,
+			target: "id",
+			index:  field.Index,
+		}
+		if i := strings.IndexByte(tag, '('); i >= 0 && strings.HasSuffix(tag, ")") {
+			ref.table, ref.target = tag[:i], tag[i+1:len(tag)-1]
+		}
+
+		refs = append(refs, ref)
+	}
+
+	return refs
+}
+
+// ValidateReferences checks the references declared on the fields of resource with a `references:"table(column)"` tag exist,
+// the column defaulting to id, and returns the broken ones. Zero values are not checked.
+// References to soft-deleted rows of registered models are broken.
+func (p *SQL) ValidateReferences(ctx context.Context, resource Resource) ([]BrokenReference, error) {
+	v := reflect.Indirect(reflect.ValueOf(resource))
+
+	var broken []BrokenReference
+	for _, ref := range references(resource) {
+		value := v.FieldByIndex(ref.index)
+		if value.IsZero() {
+			continue
+		}
+
+		notDeleted := pg.Safe("TRUE")
+		if info := p.modelByTable(ref.table); info != nil && info.table.SoftDeleteField != nil {
+			notDeleted = pg.Safe(formatQuery("? IS NULL", info.table.SoftDeleteField.Column))
+		}
+
+		var exists bool
+		if _, err := p.conn().QueryOneContext(ctx, pg.Scan(&exists), "SELECT EXISTS (SELECT 1 FROM ? WHERE ? = ? AND ?)",
+			pg.Ident(ref.table), pg.Ident(ref.target), value.Interface(), notDeleted); err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			broken = append(broken, BrokenReference{
+				Field:       ref.field,
+				Column:      ref.column,
+				Table:       ref.table,
+				TableColumn: ref.target,
+				Value:       value.Interface(),
+			})
+		}
+	}
+
+	return broken, nil
+}
@@ -0,0 +1,91 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type referencingComment struct {
+	tableName struct{} `pg:"test_referencing_comments"`
+
+	ID       int64
+	NoteID   uuid.UUID `pg:"type:uuid" references:"test_trashed_notes"`
+	AuthorID string    `references:"test_graph_users(name)"`
+	Body     string
+}
+
+func TestReferences(t *testing.T) {
+	refs := references((*referencingComment)(nil))
+	if len(refs) != 2 {
+		t.Fatalf("references() = %+v, want 2", refs)
+	}
+
+	if ref := refs[0]; ref.field != "NoteID" || ref.column != "note_id" || ref.table != "test_trashed_notes" || ref.target != "id" {
+		t.Errorf("references()[0] = %+v, want the id of test_trashed_notes by default", ref)
+	}
+
+	if ref := refs[1]; ref.column != "author_id" || ref.table != "test_graph_users" || ref.target != "name" {
+		t.Errorf("references()[1] = %+v, want the name of test_graph_users", ref)
+	}
+
+	broken := BrokenReference{Column: "author_id", Table: "test_graph_users", TableColumn: "name", Value: "ada"}
+	if msg := broken.Error(); msg != "author_id: no test_graph_users with name ada" {
+		t.Errorf("Error() = %q", msg)
+	}
+}
+
+func TestValidateReferences(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*trashedNote)(nil), (*graphUser)(nil))
+	if err := p.Register((*trashedNote)(nil)); err != nil {
+		t.Fatalf("Register(): %v", err)
+	}
+
+	var notes []uuid.UUID
+	for i := 0; i < 2; i++ {
+		created, err := p.CreateResource(ctx, &trashedNote{Folder: "inbox"})
+		if err != nil {
+			t.Fatalf("CreateResource(): %v", err)
+		}
+		notes = append(notes, created.(*trashedNote).ID)
+	}
+
+	deleted := &trashedNote{}
+	deleted.ID = notes[1]
+	if _, err := p.DeleteResource(ctx, deleted, nil); err != nil {
+		t.Fatalf("DeleteResource(): %v", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, "INSERT INTO test_graph_users (id, name) VALUES (1, 'ada')"); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+
+	for _, tt := range []struct {
+		comment *referencingComment
+		want    []string
+	}{
+		{&referencingComment{NoteID: notes[0], AuthorID: "ada"}, nil},
+		// Zero values aren't checked.
+		{&referencingComment{}, nil},
+		{&referencingComment{NoteID: notes[1], AuthorID: "alan"}, []string{"note_id", "author_id"}},
+		{&referencingComment{NoteID: uuid.New(), AuthorID: "ada"}, []string{"note_id"}},
+	} {
+		broken, err := p.ValidateReferences(ctx, tt.comment)
+		if err != nil {
+			t.Fatalf("ValidateReferences(): %v", err)
+		}
+
+		var columns []string
+		for _, ref := range broken {
+			columns = append(columns, ref.Column)
+		}
+
+		if fmt.Sprint(columns) != fmt.Sprint(tt.want) {
+			t.Errorf("ValidateReferences(%+v) broke %v, want %v", tt.comment, columns, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package persistsql
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// registry holds the models known to a persistence layer.
+type registry struct {
+	mu           sync.RWMutex
+	models       map[reflect.Type]*modelInfo
+	denormalized []denormalization
+}
+
+// modelInfo holds what the persistence layer knows about a model.
+type modelInfo struct {
+	table     *orm.Table
+	afterLoad []AfterLoadHook
+	coded     []codedField
+	// err is why the model can't be used: invalid codec tags or names conflicting with another naming strategy
+	err           error
+	duplicates    *DuplicateCheck
+	stateMachines []*StateMachine
+	history       bool
+	features      *Features
+}
+
+// name returns the unquoted name of the table, without schema.
+func (m *modelInfo) name() string {
+	return unqualifiedName(m.table)
+}
+
+// Register declares models persisted by p, so subsystems working across models know about them.
+// Models must be pointers to structs, CreateTables registers the models it creates. Registering applies the naming strategy,
+// see WithNamingStrategy. An error is returned for invalid codec tags, see RegisterCodec, or if another persistence layer registered
+// a model with different names, the models failing to be written and read.
+func (p *SQL) Register(models ...interface{}) error {
+	for _, model := range models {
+		if info := p.model(model); info.err != nil {
+			return info.err
+		}
+	}
+
+	return nil
+}
+
+// model returns the registered information of model, registering it if needed.
+func (p *SQL) model(model interface{}) *modelInfo {
+	table := tableOf(model)
+
+	p.registry.mu.RLock()
+	info, ok := p.registry.models[table.Type]
+	p.registry.mu.RUnlock()
+	if ok {
+		return info
+	}
+
+	p.registry.mu.Lock()
+	defer p.registry.mu.Unlock()
+
+	if info, ok := p.registry.models[table.Type]; ok {
+		return info
+	}
+
+	info = &modelInfo{table: table}
+	if info.err = p.naming.apply(table); info.err == nil {
+		info.coded, info.err = codedFields(table)
+	}
+	p.registry.models[table.Type] = info
+
+	return info
+}
+
+// registered returns the registered models ordered by table name.
+func (p *SQL) registered() []*modelInfo {
+	p.registry.mu.RLock()
+	defer p.registry.mu.RUnlock()
+
+	models := make([]*modelInfo, 0, len(p.registry.models))
+	for _, info := range p.registry.models {
+		models = append(models, info)
+	}
+
+	sort.Slice(models, func(i, j int) bool { return models[i].name() < models[j].name() })
+
+	return models
+}
+
+// unqualifiedName returns the unquoted name of table, without schema.
+func unqualifiedName(table *orm.Table) string {
+	name := strings.ReplaceAll(string(table.SQLName), `"`, "")
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return name
+}
+
+// modelByTable returns the registered model stored in the table name, nil if there's none.
+func (p *SQL) modelByTable(name string) *modelInfo {
+	for _, info := range p.registered() {
+		if info.name() == name {
+			return info
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// ReparentResources moves the resources of the collection of model whose fkColumn is fromID to toID, and returns how many moved.
+// Rows are updated in primary key batches of 1000 within a single transaction, soft-deleted rows included.
+// QueryHook, if non-nil, is called on the query selecting each batch, to be used for restricting the resources moved.
+// The foreign key column can't be immutable, see UpdateResource. The update time of the moved resources is stamped from the clock
+// and a ChangeUpdate event published for each. fromID and toID must differ.
+func (p *SQL) ReparentResources(ctx context.Context, model Resource, fkColumn string, fromID, toID interface{}, queryHook QueryHook) (int, error) {
+	if err := p.checkWrite(); err != nil {
+		return 0, err
+	}
+
+	if err := checkMutable(model, []string{fkColumn}); err != nil {
+		return 0, err
+	}
+
+	if fmt.Sprint(fromID) == fmt.Sprint(toID) {
+		return 0, fmt.Errorf("reparenting %s from and to %v", fkColumn, fromID)
+	}
+
+	table := tableOf(model)
+	pks := pkColumns(table)
+	updateTime := timeField(table, updateTimeColumns)
+	now := p.now(ctx)
+
+	moved := 0
+	err := p.runInTransaction(ctx, func(tx *pg.Tx) error {
+		moved = 0
+
+		for {
+			batch := tx.Model(model).
+				ColumnExpr("?", pks).
+				AllWithDeleted().
+				Where("? = ?", pg.Ident(fkColumn), fromID).
+				OrderExpr("?", pks).
+				Limit(defaultBatchSize).
+				For("UPDATE")
+			if queryHook != nil {
+				queryHook(batch)
+			}
+
+			rows := reflect.New(reflect.SliceOf(reflect.PtrTo(table.Type)))
+			update := tx.ModelContext(ctx, rows.Interface()).
+				AllWithDeleted().
+				Set("? = ?", pg.Ident(fkColumn), toID).
+				Where("(?) IN (?)", pks, batch).
+				Returning("*")
+			if updateTime != nil {
+				update.Set("? = ?", updateTime.Column, now)
+			}
+
+			res, err := update.Update()
+			if err != nil {
+				return err
+			}
+
+			for i := 0; i < rows.Elem().Len(); i++ {
+				if err := p.notify(ctx, tx, ChangeUpdate, rows.Elem().Index(i).Interface()); err != nil {
+					return err
+				}
+			}
+
+			moved += res.RowsAffected()
+			if res.RowsAffected() < defaultBatchSize {
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return moved, nil
+}
+
+// pkColumns returns the comma-separated primary key columns of table, qualified by its alias.
+func pkColumns(table *orm.Table) pg.Safe {
+	cols := make([]string, len(table.PKs))
+	for i, pk := range table.PKs {
+		cols[i] = string(table.Alias) + "." + string(pk.Column)
+	}
+
+	return pg.Safe(strings.Join(cols, ", "))
+}
@@ -0,0 +1,50 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type reparentedChild struct {
+	tableName struct{} `pg:"test_reparented_children"`
+
+	model.Common
+	ParentID string `pg:",notnull"`
+}
+
+func TestReparentResources(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*reparentedChild)(nil))
+
+	const children = defaultBatchSize + 3
+	if _, err := p.db.ExecContext(ctx, `INSERT INTO test_reparented_children (id, create_time, update_time, parent_id)
+		SELECT gen_random_uuid(), now() - interval '1 day', now() - interval '1 day', 'a' FROM generate_series(1, ?)`, children); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if _, err := p.ReparentResources(ctx, (*reparentedChild)(nil), "parent_id", "a", "a", nil); err == nil {
+		t.Fatal("ReparentResources() to the same parent succeeded")
+	}
+
+	before := time.Now().Add(-time.Minute)
+	moved, err := p.ReparentResources(ctx, (*reparentedChild)(nil), "parent_id", "a", "b", nil)
+	if err != nil || moved != children {
+		t.Fatalf("ReparentResources() = %d, %v, want %d", moved, err, children)
+	}
+
+	var stale int
+	if _, err := p.db.QueryOneContext(ctx, pg.Scan(&stale),
+		"SELECT count(*) FROM test_reparented_children WHERE parent_id <> 'b' OR update_time < ?", before); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+
+	if stale != 0 {
+		t.Errorf("%d of %d children not moved or not stamped", stale, children)
+	}
+}
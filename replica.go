@@ -0,0 +1,131 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// errWritableReplica is returned when the transactions of a replica aren't read-only.
+var errWritableReplica = errors.New("replica is not read-only")
+
+// errNotStandby is returned when a replica isn't in recovery, being a primary, see WithNonStandbyReplicas.
+var errNotStandby = errors.New("replica is not a standby")
+
+// replicas holds the read replicas of a persistence layer, used in turn.
+type replicas struct {
+	dbs  []*pg.DB
+	next uint32
+	// nonStandby allows replicas which aren't in recovery
+	nonStandby bool
+}
+
+// WithReplicas routes reads to the replicas dbs, in turn. Writes and transactions, see WithTx, always use the primary.
+// The replicas must be hot standbys, in recovery, which New and each new connection check so that a routing mistake
+// can't write to a misconfigured host or a replica promoted later; see WithNonStandbyReplicas otherwise.
+// Their connections are also made read-only with default_transaction_read_only. It's best to pass replicas
+// without open connections, their OnConnect option is extended.
+func WithReplicas(dbs ...*pg.DB) Option {
+	return func(p *SQL) {
+		for _, db := range dbs {
+			p.replicas.readOnly(db)
+		}
+
+		p.replicas.dbs = append(p.replicas.dbs, dbs...)
+	}
+}
+
+// WithNonStandbyReplicas allows replicas which aren't hot standbys, e.g. logical replicas, see WithReplicas:
+// only default_transaction_read_only then keeps them from being written.
+func WithNonStandbyReplicas() Option {
+	return func(p *SQL) {
+		p.replicas.nonStandby = true
+	}
+}
+
+// readOnly makes the new connections of db read-only, before calling its own OnConnect, and checks the replica.
+func (r *replicas) readOnly(db *pg.DB) {
+	opt := db.Options()
+	onConnect := opt.OnConnect
+	opt.OnConnect = func(ctx context.Context, cn *pg.Conn) error {
+		if _, err := cn.ExecContext(ctx, "SET default_transaction_read_only = on"); err != nil {
+			return err
+		}
+
+		if err := r.check(ctx, cn); err != nil {
+			return err
+		}
+
+		if onConnect != nil {
+			return onConnect(ctx, cn)
+		}
+
+		return nil
+	}
+}
+
+// check returns an error unless db, a replica, is in recovery, see WithNonStandbyReplicas, and a new transaction on it is read-only.
+// A host in recovery can't be written whatever its settings, unlike a primary with read-only sessions.
+func (r *replicas) check(ctx context.Context, db orm.DB) error {
+	var inRecovery, readOnly bool
+	if _, err := db.QueryOneContext(ctx, pg.Scan(&inRecovery, &readOnly),
+		"SELECT pg_is_in_recovery(), current_setting('transaction_read_only')::bool"); err != nil {
+		return err
+	}
+
+	switch {
+	case !inRecovery && !r.nonStandby:
+		return errNotStandby
+	case !readOnly:
+		return errWritableReplica
+	}
+
+	return nil
+}
+
+// checkReplicas returns an error unless all the replicas are standbys, or allowed not to be, and read-only.
+func (p *SQL) checkReplicas(ctx context.Context) error {
+	for _, db := range p.replicas.dbs {
+		if err := p.replicas.check(ctx, db); err != nil {
+			return fmt.Errorf("replica %s: %w", db, err)
+		}
+	}
+
+	return nil
+}
+
+// reader returns the database to read from: the transaction p is bound to, the next replica, or the primary if there's none
+// or ctx asks for it, see PrimaryRead.
+// Its queries are labelled, see WithQueryLabel.
+func (p *SQL) reader(ctx context.Context) orm.DB {
+	if p.tx != nil {
+		return labelled{p.tx}
+	}
+
+	return labelled{p.readDB(ctx)}
+}
+
+// readDB returns the primary if ctx asks for it, see PrimaryRead, or else the next replica, the primary if there's none.
+func (p *SQL) readDB(ctx context.Context) *pg.DB {
+	if callOptionsOf(ctx).primaryRead {
+		return p.db
+	}
+
+	_, db := p.replica()
+	return db
+}
+
+// replica returns the next replica, or the primary if there's none, and its index, 0 for the primary and i+1 for the replica i.
+func (p *SQL) replica() (int, *pg.DB) {
+	if len(p.replicas.dbs) == 0 {
+		return 0, p.db
+	}
+
+	n := int(atomic.AddUint32(&p.replicas.next, 1)) % len(p.replicas.dbs)
+
+	return n + 1, p.replicas.dbs[n]
+}
@@ -0,0 +1,70 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+)
+
+func TestReplicaRouting(t *testing.T) {
+	// Connecting is lazy, nothing is dialled.
+	primary := pg.Connect(&pg.Options{Addr: "primary:5432"})
+	first, second := pg.Connect(&pg.Options{Addr: "first:5432"}), pg.Connect(&pg.Options{Addr: "second:5432"})
+	t.Cleanup(func() {
+		_, _, _ = primary.Close(), first.Close(), second.Close()
+	})
+
+	p := &SQL{db: primary, replicas: &replicas{}}
+	if i, db := p.replica(); i != 0 || db != primary {
+		t.Errorf("replica() without replicas = %d, %v, want the primary", i, db)
+	}
+
+	WithReplicas(first, second)(p)
+
+	seen := map[*pg.DB]int{}
+	for n := 0; n < 4; n++ {
+		i, db := p.replica()
+		if i == 0 || p.replicas.dbs[i-1] != db {
+			t.Fatalf("replica() = %d, %v, want a replica and its index", i, db)
+		}
+		seen[db]++
+	}
+
+	if seen[first] != 2 || seen[second] != 2 {
+		t.Errorf("replica() used the replicas %d and %d times, want in turn", seen[first], seen[second])
+	}
+
+	if db := p.readDB(WithCallOptions(context.Background(), PrimaryRead())); db != primary {
+		t.Errorf("readDB(PrimaryRead) = %v, want the primary", db)
+	}
+
+	if first.Options().OnConnect == nil {
+		t.Error("WithReplicas() didn't make the replica connections read-only")
+	}
+}
+
+func TestReplicas(t *testing.T) {
+	ctx := context.Background()
+
+	// The test database is a primary, not in recovery.
+	if _, err := New(testDB(t), WithReplicas(testDB(t))); !errors.Is(err, errNotStandby) {
+		t.Errorf("New() with a primary as a replica = %v, want errNotStandby", err)
+	}
+
+	p := testSQL(t, WithReplicas(testDB(t)), WithNonStandbyReplicas())
+	testTables(t, p.db, (*labelledOrder)(nil))
+
+	if _, err := p.db.ExecContext(ctx, "INSERT INTO labelled_orders (id, total) VALUES (1, 10)"); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+
+	if _, err := p.reader(ctx).ExecContext(ctx, "INSERT INTO labelled_orders (id, total) VALUES (2, 20)"); err == nil {
+		t.Error("INSERT on a replica succeeded")
+	}
+
+	if got, err := p.GetResourceByPK(ctx, &labelledOrder{ID: 1}); err != nil || got == nil {
+		t.Errorf("GetResourceByPK() from the replica = %+v, %v", got, err)
+	}
+}
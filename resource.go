@@ -3,12 +3,13 @@ package persistsql
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"sync"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/go-pg/pg/v10/orm"
-
-	"github.com/chi07/resource"
 )
 
 type QueryHook func(query *orm.Query)
@@ -23,27 +24,84 @@ type RawQuery struct {
 
 // SQL represents a persistence layer for resources based on SQL.
 type SQL struct {
-	db         *pg.DB
-	notifyStmt *pg.Stmt
+	db          *pg.DB
+	notifyStmt  *pg.Stmt
+	maintenance *maintenance
+	registry    *registry
+	replicas    *replicas
+	tables      *sync.Map
+	observers   *observers
+	clock       Clock
+	ids         IDGenerator
+	tx          *pg.Tx
+	changes     *changeFeed
+	snapshots   *snapshots
+	txLimits    *txLimits
+	shadow      *shadowReader
+	cache       *resourceCache
+	naming      *NamingStrategy
+	retries     *retries
+
+	declaredFeatures []modelFeatures
 }
 
-// New creates an SQL persistence layer backed by db.
-func New(db *pg.DB) (*SQL, error) {
+// Option configures an SQL persistence layer.
+type Option func(p *SQL)
+
+// New creates an SQL persistence layer backed by db, configured by opts. It fails if a replica isn't a read-only standby, see WithReplicas,
+// or if the features of a model are invalid, see WithModelFeatures.
+func New(db *pg.DB, opts ...Option) (*SQL, error) {
 	notifyStmt, err := db.Prepare("SELECT pg_notify('events', $1)")
 	if err != nil {
 		return nil, fmt.Errorf("db.Prepare(): %w", err)
 	}
 
-	return &SQL{
-		db:         db,
-		notifyStmt: notifyStmt,
-	}, nil
+	p := &SQL{
+		db:          db,
+		notifyStmt:  notifyStmt,
+		maintenance: &maintenance{},
+		registry:    &registry{models: map[reflect.Type]*modelInfo{}},
+		replicas:    &replicas{},
+		tables:      &sync.Map{},
+		observers:   &observers{},
+		clock:       systemClock{},
+		ids:         randomIDs{},
+		changes:     &changeFeed{subs: map[chan ChangeEvent]struct{}{}},
+		snapshots:   &snapshots{held: map[string]*heldSnapshot{}, timeout: defaultSnapshotTimeout},
+		txLimits:    &txLimits{},
+		retries:     &retries{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if err := p.registerFeatures(); err != nil {
+		return nil, err
+	}
+
+	if err := p.checkReplicas(context.Background()); err != nil {
+		return nil, err
+	}
+
+	p.observe()
+
+	return p, nil
 }
 
 // CreateTables ensures all tables needed to store the models exist, it then runs the raw queries, if non-nil.
-// All happens in a single transaction.
+// All happens in a single transaction. ErrMaintenance is returned while in maintenance mode.
+// The models are registered, see Register.
 func (p *SQL) CreateTables(ctx context.Context, models []interface{}, rawQueries []RawQuery) error {
-	return p.db.WithContext(ctx).RunInTransaction(ctx, func(tx *pg.Tx) error {
+	if err := p.checkDDL(); err != nil {
+		return err
+	}
+
+	if err := p.Register(models...); err != nil {
+		return err
+	}
+
+	return p.runInTransaction(ctx, func(tx *pg.Tx) error {
 		for _, model := range models {
 			cto := orm.CreateTableOptions{
 				IfNotExists:   true,
@@ -68,13 +126,32 @@ func (p *SQL) CreateTables(ctx context.Context, models []interface{}, rawQueries
 }
 
 // CreateResource inserts a single resource into the table representing the collection.
-func (p *SQL) CreateResource(ctx context.Context, resource resource.Resource) (resource.Resource, error) {
-	if err := p.db.WithContext(ctx).RunInTransaction(ctx, func(tx *pg.Tx) error {
+// Its creation and update times are stamped from the clock, unless already set, see WithClock.
+// A zero uuid.UUID primary key is generated, see WithIDGenerator. A DuplicateError is returned if similar resources exist, see DetectDuplicates.
+func (p *SQL) CreateResource(ctx context.Context, resource Resource) (Resource, error) {
+	if err := p.checkWrite(); err != nil {
+		return nil, err
+	}
+
+	p.assignID(ctx, resource)
+	if err := p.encodeFields(resource); err != nil {
+		return nil, err
+	}
+
+	now := p.now(ctx)
+	stamp(resource, createTimeColumns, now, true)
+	stamp(resource, updateTimeColumns, now, true)
+
+	if err := p.runInTransaction(ctx, func(tx *pg.Tx) error {
+		if err := p.checkDuplicates(ctx, tx, resource); err != nil {
+			return err
+		}
+
 		if _, err := tx.Model(resource).Insert(); err != nil {
 			return err
 		}
 
-		return nil
+		return p.notify(ctx, tx, ChangeCreate, resource)
 	}); err != nil {
 		return nil, err
 	}
@@ -90,43 +167,209 @@ func ShowDeleted(query *orm.Query, showDeleted bool) {
 	}
 }
 
-// GetResource retrieves a single resource from a collection.
+// WherePK is a QueryHook matching the primary key of the model, e.g. to update a resource by primary key.
+func WherePK(query *orm.Query) {
+	query.WherePK()
+}
+
+// GetResource retrieves a single resource from a collection, from a replica if any is configured and ctx doesn't say otherwise, see PrimaryRead.
 // The query is built without a WHERE clause and SELECT all fields of the resource.
-// showDeleted controls whether soft-deleted resources are allowed to be returned.
+// showDeleted controls whether soft-deleted resources are allowed to be returned. The resource is read as of a past time if ctx says so, see AsOf.
 // QueryHook is called before executing the query, to be used for adding a WHERE clause or for other adjustments.
-func (p *SQL) GetResource(ctx context.Context, resource resource.Resource, showDeleted bool, queryHook QueryHook) (resource.Resource, error) {
-	query := p.db.ModelContext(ctx, resource)
+func (p *SQL) GetResource(ctx context.Context, resource Resource, showDeleted bool, queryHook QueryHook) (Resource, error) {
+	mirror := p.mirror(resource)
+
+	db := p.reader(ctx)
+	query := db.ModelContext(ctx, resource)
 	ShowDeleted(query, showDeleted)
 	queryHook(query)
 
+	if err := p.asOf(ctx, db, resource, query); err != nil {
+		return nil, err
+	}
+
 	if err := query.Select(); err != nil {
 		if err == pg.ErrNoRows {
+			if mirror != nil {
+				p.shadow.get(ctx, mirror, showDeleted, queryHook, nil)
+			}
+
 			return nil, nil
 		}
 
 		return nil, err
 	}
 
+	if err := p.afterLoad(ctx, resource); err != nil {
+		return nil, err
+	}
+
+	if mirror != nil {
+		p.shadow.get(ctx, mirror, showDeleted, queryHook, resource)
+	}
+
 	return resource, nil
 }
 
+// ListOptions controls ListResources.
+type ListOptions struct {
+	// ShowDeleted allows soft-deleted resources to be returned
+	ShowDeleted bool
+	// CountDeleted counts the matching resources hidden because they're soft-deleted into ListMeta.Deleted,
+	// it's ignored if ShowDeleted is true or the model has no soft delete column.
+	CountDeleted bool
+	// MaxRows is the maximum number of resources returned, a TooManyRowsError is returned if more match,
+	// the one of the call options of the context if zero, see MaxRows, unlimited if neither is set
+	MaxRows int
+	// MaxBytes caps the size of the resources returned, as stored, the first resources fitting are returned and ListMeta.Truncated set
+	// if others are left out, unlimited if zero
+	MaxBytes int64
+}
+
+// ListMeta describes the result of ListResources.
+type ListMeta struct {
+	// Deleted is the number of matching soft-deleted resources, regardless of LIMIT and OFFSET, if ListOptions.CountDeleted is set.
+	Deleted int
+	// Truncated is set if resources were left out to fit ListOptions.MaxBytes
+	Truncated bool
+}
+
+// ListResources retrieves resources from a collection into resources, a pointer to a slice of models, from a replica if any is configured
+// and ctx doesn't say otherwise, see PrimaryRead.
+// The query is built without a WHERE clause and SELECT all fields of the resources. They're read as of a past time if ctx says so, see AsOf.
+// QueryHook is called before executing the query, to be used for adding WHERE, ORDER BY or LIMIT clauses or for other adjustments.
+// With ListOptions.MaxRows or MaxBytes, the rows are checked then read in a single REPEATABLE READ transaction, unless p is bound to one.
+func (p *SQL) ListResources(ctx context.Context, resources interface{}, opts ListOptions, queryHook QueryHook) (ListMeta, error) {
+	var meta ListMeta
+
+	if opts.MaxRows == 0 {
+		opts.MaxRows = callOptionsOf(ctx).maxRows
+	}
+
+	if p.tx == nil && (opts.MaxRows > 0 || opts.MaxBytes > 0) {
+		err := p.readDB(ctx).RunInTransaction(ctx, func(tx *pg.Tx) error {
+			if _, err := tx.ExecContext(ctx, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+				return err
+			}
+
+			var err error
+			meta, err = p.WithTx(tx).ListResources(ctx, resources, opts, queryHook)
+			return err
+		})
+
+		return meta, err
+	}
+
+	db := p.reader(ctx)
+	query := db.ModelContext(ctx, resources)
+	ShowDeleted(query, opts.ShowDeleted)
+	queryHook(query)
+
+	if err := p.asOf(ctx, db, resources, query); err != nil {
+		return meta, err
+	}
+
+	fit := -1
+	if opts.MaxBytes > 0 {
+		var err error
+		if fit, meta.Truncated, err = fitBytes(ctx, db, query, opts.MaxBytes); err != nil {
+			return meta, err
+		}
+	}
+
+	if opts.MaxRows > 0 {
+		if err := checkMaxRows(ctx, db, query, opts.MaxRows); err != nil {
+			return meta, err
+		}
+	}
+
+	switch {
+	case fit == 0:
+		list := reflect.ValueOf(resources).Elem()
+		list.Set(reflect.MakeSlice(list.Type(), 0, 0))
+	case fit > 0:
+		// fit doesn't exceed the LIMIT the hook may have set, it's replaced.
+		if err := query.Clone().Limit(fit).Select(); err != nil {
+			return meta, err
+		}
+	default:
+		if err := query.Select(); err != nil {
+			return meta, err
+		}
+	}
+
+	if err := p.afterLoad(ctx, resources); err != nil {
+		return meta, err
+	}
+
+	if opts.CountDeleted && !opts.ShowDeleted && tableOf(resources).SoftDeleteField != nil {
+		deleted, err := query.Clone().Deleted().Count()
+		if err != nil {
+			return meta, err
+		}
+		meta.Deleted = deleted
+	}
+
+	if !meta.Truncated && p.shadowed() {
+		p.shadow.list(ctx, resources, opts, queryHook)
+	}
+
+	return meta, nil
+}
+
+// errNoFields is returned when updating no fields of a model without update time, go-pg would update all of them.
+var errNoFields = errors.New("no fields to update")
+
 // UpdateResource updates a resource in a collection.
-// The query is built without a WHERE clause and updates the fields of the model listed in the fields slice and updated_at.
+// The query is built without a WHERE clause and updates the fields of the model listed in the fields slice and its update time,
+// update_time or updated_at, stamped from the clock.
+// Fields tagged `immutable:"true"` on the model can't be listed, ErrImmutableField is returned instead.
+// An error is returned if there's nothing to update, no fields being listed for a model without update time.
+// State machines are enforced, see DeclareStateMachine.
 // QueryHook is called before executing the query, to be used for adding a WHERE clause or for other adjustments.
-func (p *SQL) UpdateResource(ctx context.Context, resource resource.Resource, fields []string, queryHook QueryHook) (resource.Resource, error) {
-	if err := p.db.WithContext(ctx).RunInTransaction(ctx, func(tx *pg.Tx) error {
-		query := tx.Model(resource).Returning("*").Column("updated_at")
+func (p *SQL) UpdateResource(ctx context.Context, resource Resource, fields []string, queryHook QueryHook) (Resource, error) {
+	if err := p.checkWrite(); err != nil {
+		return nil, err
+	}
+
+	if err := checkMutable(resource, fields); err != nil {
+		return nil, err
+	}
+
+	if err := p.encodeFields(resource); err != nil {
+		return nil, err
+	}
+
+	updateTime := stamp(resource, updateTimeColumns, p.now(ctx), false)
+	if updateTime == "" && len(fields) == 0 {
+		return nil, errNoFields
+	}
+
+	if err := p.runInTransaction(ctx, func(tx *pg.Tx) error {
+		query := tx.Model(resource).Returning("*")
+		if updateTime != "" {
+			query.Column(updateTime)
+		}
 		for _, col := range fields {
 			query.Column(col)
 		}
 
 		queryHook(query)
 
+		changes, err := p.checkTransitions(ctx, tx, resource, fields, queryHook)
+		if err != nil {
+			return err
+		}
+
 		if _, err := query.Update(); err != nil {
 			return err
 		}
 
-		return nil
+		if err := p.notify(ctx, tx, ChangeUpdate, resource); err != nil {
+			return err
+		}
+
+		return p.notifyTransitions(ctx, tx, resource, changes)
 	}); err != nil {
 		if err == pg.ErrNoRows {
 			return nil, nil
@@ -135,23 +378,42 @@ func (p *SQL) UpdateResource(ctx context.Context, resource resource.Resource, fi
 		return nil, err
 	}
 
+	if err := p.afterLoad(ctx, resource); err != nil {
+		return nil, err
+	}
+
 	return resource, nil
 }
 
 // DeleteResource deletes a resource from a collection.
 // The query is built with a WHERE clause to match the primary key of the model. If QueryHook is non-nil, it is called before executing the query.
-func (p *SQL) DeleteResource(ctx context.Context, resource resource.Resource, queryHook QueryHook) (resource.Resource, error) {
-	if err := p.db.WithContext(ctx).RunInTransaction(ctx, func(tx *pg.Tx) error {
+// Soft deletes are stamped from the clock, hard deletes release the BlobRef fields of the resource, see PutBlob.
+func (p *SQL) DeleteResource(ctx context.Context, resource Resource, queryHook QueryHook) (Resource, error) {
+	if err := p.checkWrite(); err != nil {
+		return nil, err
+	}
+
+	if err := p.runInTransaction(ctx, func(tx *pg.Tx) error {
 		query := tx.Model(resource).WherePK().Returning("*")
 		if queryHook != nil {
 			queryHook(query)
 		}
 
-		if _, err := query.Delete(); err != nil {
+		if deleteTime := markDeleted(resource, p.now(ctx)); deleteTime != "" {
+			if _, err := query.Column(deleteTime).Update(); err != nil {
+				return err
+			}
+		} else if _, err := query.Delete(); err != nil {
 			return err
 		}
 
-		return nil
+		if tableOf(resource).SoftDeleteField == nil {
+			if err := p.releaseBlobRefs(ctx, tx, resource); err != nil {
+				return err
+			}
+		}
+
+		return p.notify(ctx, tx, ChangeDelete, resource)
 	}); err != nil {
 		if err == pg.ErrNoRows {
 			return nil, nil
@@ -160,14 +422,30 @@ func (p *SQL) DeleteResource(ctx context.Context, resource resource.Resource, qu
 		return nil, err
 	}
 
+	if err := p.afterLoad(ctx, resource); err != nil {
+		return nil, err
+	}
+
 	return resource, nil
 }
 
 // UndeleteResource undeletes a soft-deleted resource from a collection.
 // The query is built with a WHERE clause to match the primary key of the model. If QueryHook is non-nil, it is called before executing the query.
-func (p *SQL) UndeleteResource(ctx context.Context, resource resource.Resource, queryHook QueryHook) (resource.Resource, error) {
-	if err := p.db.WithContext(ctx).RunInTransaction(ctx, func(tx *pg.Tx) error {
-		query := tx.Model(resource).WherePK().Deleted().Column("deleted_at").Returning("*")
+func (p *SQL) UndeleteResource(ctx context.Context, resource Resource, queryHook QueryHook) (Resource, error) {
+	if err := p.checkWrite(); err != nil {
+		return nil, err
+	}
+
+	field := tableOf(resource).SoftDeleteField
+	if field == nil {
+		return nil, fmt.Errorf("%s has no soft delete column", unqualifiedName(tableOf(resource)))
+	}
+
+	v := field.Value(reflect.Indirect(reflect.ValueOf(resource)))
+	v.Set(reflect.Zero(v.Type()))
+
+	if err := p.runInTransaction(ctx, func(tx *pg.Tx) error {
+		query := tx.Model(resource).WherePK().Deleted().Column(field.SQLName).Returning("*")
 		if queryHook != nil {
 			queryHook(query)
 		}
@@ -176,7 +454,7 @@ func (p *SQL) UndeleteResource(ctx context.Context, resource resource.Resource,
 			return err
 		}
 
-		return nil
+		return p.notify(ctx, tx, ChangeUndelete, resource)
 	}); err != nil {
 		if err == pg.ErrNoRows {
 			return nil, nil
@@ -185,5 +463,9 @@ func (p *SQL) UndeleteResource(ctx context.Context, resource resource.Resource,
 		return nil, err
 	}
 
+	if err := p.afterLoad(ctx, resource); err != nil {
+		return nil, err
+	}
+
 	return resource, nil
 }
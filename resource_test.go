@@ -0,0 +1,71 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type trashedNote struct {
+	tableName struct{} `pg:"test_trashed_notes"`
+
+	model.Common
+	Folder string
+}
+
+type purgedNote struct {
+	tableName struct{} `pg:"test_purged_notes"`
+
+	ID     int64
+	Folder string
+}
+
+func TestListResourcesCountDeleted(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*trashedNote)(nil), (*purgedNote)(nil))
+
+	for i, folder := range []string{"inbox", "inbox", "inbox", "inbox", "archive"} {
+		created, err := p.CreateResource(ctx, &trashedNote{Folder: folder})
+		if err != nil {
+			t.Fatalf("CreateResource(): %v", err)
+		}
+
+		// 2 of the inbox notes and the archived one are in the trash.
+		if i >= 2 {
+			if _, err := p.DeleteResource(ctx, created, nil); err != nil {
+				t.Fatalf("DeleteResource(): %v", err)
+			}
+		}
+	}
+
+	inbox := func(query *orm.Query) {
+		query.Where("folder = ?", "inbox").Limit(1)
+	}
+
+	var notes []*trashedNote
+	meta, err := p.ListResources(ctx, &notes, ListOptions{CountDeleted: true}, inbox)
+	if err != nil {
+		t.Fatalf("ListResources(): %v", err)
+	}
+
+	if len(notes) != 1 || meta.Deleted != 2 {
+		t.Errorf("ListResources() = %d notes, %d deleted, want 1 and the 2 trashed inbox notes regardless of the limit", len(notes), meta.Deleted)
+	}
+
+	if meta, err := p.ListResources(ctx, &notes, ListOptions{}, inbox); err != nil || meta.Deleted != 0 {
+		t.Errorf("ListResources() without CountDeleted = %+v, %v, want no count", meta, err)
+	}
+
+	if meta, err := p.ListResources(ctx, &notes, ListOptions{ShowDeleted: true, CountDeleted: true}, inbox); err != nil || meta.Deleted != 0 {
+		t.Errorf("ListResources() showing deleted = %+v, %v, want no count", meta, err)
+	}
+
+	var purged []*purgedNote
+	if meta, err := p.ListResources(ctx, &purged, ListOptions{CountDeleted: true}, func(*orm.Query) {}); err != nil || meta.Deleted != 0 {
+		t.Errorf("ListResources() without soft delete = %+v, %v, want no count", meta, err)
+	}
+}
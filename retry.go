@@ -0,0 +1,228 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// SQLSTATE codes of the transaction conflicts worth retrying.
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// Delays before the retries of a transaction, see WithTxRetries.
+const (
+	defaultRetryBackoff = 10 * time.Millisecond
+	maxRetryBackoff     = time.Second
+)
+
+// RetryStats are the transaction statistics of an operation, see RetryStats.
+type RetryStats struct {
+	// Operation is the query label of the transactions, see WithQueryLabel, or else the function running them,
+	// e.g. CreateResource
+	Operation string `json:"operation"`
+	// Transactions is the number of transactions run, retries excluded
+	Transactions int64 `json:"transactions"`
+	// Attempts is the number of attempts, retries included
+	Attempts int64 `json:"attempts"`
+	// Conflicts is the number of attempts failing on a serialization failure or a deadlock
+	Conflicts int64 `json:"conflicts"`
+	// Retries is the number of attempts retried after a conflict
+	Retries int64 `json:"retries"`
+	// Exhausted is the number of transactions failing on a conflict after the last retry
+	Exhausted int64 `json:"exhausted"`
+	// Aborts counts the failed attempts by reason: the SQLSTATE code of database errors, e.g. 40001, or "error" for the others
+	Aborts map[string]int64 `json:"aborts,omitempty"`
+}
+
+// ConflictRate returns the share of the attempts failing on a conflict, 0 if there were none.
+func (s RetryStats) ConflictRate() float64 {
+	if s.Attempts == 0 {
+		return 0
+	}
+
+	return float64(s.Conflicts) / float64(s.Attempts)
+}
+
+// RetryInfo describes a failed transaction attempt, see WithRetryObserver.
+type RetryInfo struct {
+	// Operation running the transaction, see RetryStats
+	Operation string
+	// Attempt is the number of the attempt, from 1
+	Attempt int
+	// Reason is the SQLSTATE code of the error, or "error" if it isn't a database error
+	Reason string
+	// Retried is whether the transaction is retried
+	Retried bool
+	// Err is the error of the attempt
+	Err error
+}
+
+// retries holds the retry configuration and statistics of the transactions of a persistence layer.
+type retries struct {
+	max       int
+	backoff   time.Duration
+	observers []func(ctx context.Context, info RetryInfo)
+
+	mu    sync.Mutex
+	stats map[string]*RetryStats
+}
+
+// WithTxRetries retries the transactions failing on a serialization failure or a deadlock up to max times, waiting backoff,
+// 10ms if zero, doubled after each attempt up to 1s, with jitter. The functions passed to RunInTransaction are called again on retries,
+// so they shouldn't have side effects outside of the transaction. Transactions bound with WithTx aren't retried.
+func WithTxRetries(max int, backoff time.Duration) Option {
+	return func(p *SQL) {
+		if backoff == 0 {
+			backoff = defaultRetryBackoff
+		}
+
+		p.retries.max = max
+		p.retries.backoff = backoff
+	}
+}
+
+// WithRetryObserver calls fn after each failed transaction attempt, e.g. to record metrics of conflicts by operation.
+func WithRetryObserver(fn func(ctx context.Context, info RetryInfo)) Option {
+	return func(p *SQL) {
+		p.retries.observers = append(p.retries.observers, fn)
+	}
+}
+
+// RetryStats returns the transaction statistics of the operations since p was created, ordered by operation,
+// to find the resources suffering contention.
+func (p *SQL) RetryStats() []RetryStats {
+	p.retries.mu.Lock()
+	defer p.retries.mu.Unlock()
+
+	stats := make([]RetryStats, 0, len(p.retries.stats))
+	for _, s := range p.retries.stats {
+		copied := *s
+		copied.Aborts = make(map[string]int64, len(s.Aborts))
+		for reason, n := range s.Aborts {
+			copied.Aborts[reason] = n
+		}
+		stats = append(stats, copied)
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Operation < stats[j].Operation })
+
+	return stats
+}
+
+// inTransaction runs fn in a new transaction for the operation op, retrying it up to max times on conflicts, see WithTxRetries.
+func (p *SQL) inTransaction(ctx context.Context, op string, max int, fn func(tx *pg.Tx) error) error {
+	if label := QueryLabel(ctx); label != "" {
+		op = label
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := p.db.WithContext(ctx).RunInTransaction(ctx, func(tx *pg.Tx) error {
+			defer p.txLimits.track(tx)()
+			return fn(tx)
+		})
+
+		retry := err != nil && conflict(err) && attempt <= max && ctx.Err() == nil
+		p.retries.record(ctx, op, attempt, err, retry)
+		if !retry {
+			return err
+		}
+
+		delay := p.retries.backoff << (attempt - 1)
+		if delay <= 0 || delay > maxRetryBackoff {
+			delay = maxRetryBackoff
+		}
+		delay += time.Duration(rand.Int63n(int64(delay)))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// record counts an attempt of a transaction of op, failed if err isn't nil, and reports failures to the observers.
+func (r *retries) record(ctx context.Context, op string, attempt int, err error, retried bool) {
+	r.mu.Lock()
+	if r.stats == nil {
+		r.stats = map[string]*RetryStats{}
+	}
+
+	stats, ok := r.stats[op]
+	if !ok {
+		stats = &RetryStats{Operation: op, Aborts: map[string]int64{}}
+		r.stats[op] = stats
+	}
+
+	if attempt == 1 {
+		stats.Transactions++
+	}
+	stats.Attempts++
+
+	var reason string
+	if err != nil {
+		reason = abortReason(err)
+		stats.Aborts[reason]++
+
+		switch {
+		case retried:
+			stats.Conflicts++
+			stats.Retries++
+		case conflict(err):
+			stats.Conflicts++
+			stats.Exhausted++
+		}
+	}
+	r.mu.Unlock()
+
+	if err == nil {
+		return
+	}
+
+	for _, fn := range r.observers {
+		fn(ctx, RetryInfo{Operation: op, Attempt: attempt, Reason: reason, Retried: retried, Err: err})
+	}
+}
+
+// conflict reports whether err is a serialization failure or a deadlock.
+func conflict(err error) bool {
+	reason := abortReason(err)
+
+	return reason == serializationFailure || reason == deadlockDetected
+}
+
+// abortReason returns the SQLSTATE code of err, or "error" if it isn't a database error.
+func abortReason(err error) string {
+	var pgErr pg.Error
+	if errors.As(err, &pgErr) {
+		return pgErr.Field('C')
+	}
+
+	return "error"
+}
+
+// caller returns the name of the function calling the function calling caller, without package nor receiver,
+// e.g. CreateResource, to name operations.
+func caller() string {
+	pc, _, _, ok := runtime.Caller(2)
+	if !ok {
+		return "unknown"
+	}
+
+	name := runtime.FuncForPC(pc).Name()
+	if i := strings.Index(name, ".func"); i >= 0 {
+		name = name[:i]
+	}
+
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
@@ -0,0 +1,122 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// sqlStateError is a pg.Error with the SQLSTATE code code.
+type sqlStateError string
+
+func (e sqlStateError) Error() string            { return "ERROR #" + string(e) }
+func (e sqlStateError) IntegrityViolation() bool { return false }
+
+func (e sqlStateError) Field(field byte) string {
+	if field == 'C' {
+		return string(e)
+	}
+
+	return ""
+}
+
+func TestAbortReason(t *testing.T) {
+	for _, tt := range []struct {
+		err      error
+		reason   string
+		conflict bool
+	}{
+		{sqlStateError(serializationFailure), "40001", true},
+		{fmt.Errorf("updating: %w", sqlStateError(deadlockDetected)), "40P01", true},
+		{sqlStateError("23505"), "23505", false},
+		{errors.New("boom"), "error", false},
+	} {
+		if reason := abortReason(tt.err); reason != tt.reason || conflict(tt.err) != tt.conflict {
+			t.Errorf("abortReason(%v) = %s, conflict %v, want %s, %v", tt.err, reason, conflict(tt.err), tt.reason, tt.conflict)
+		}
+	}
+
+	if name := func() string { return caller() }(); name != "TestAbortReason" {
+		t.Errorf("caller() = %s, want TestAbortReason", name)
+	}
+}
+
+func TestRetryStatsRecord(t *testing.T) {
+	var infos []RetryInfo
+	p := &SQL{retries: &retries{}}
+	WithRetryObserver(func(ctx context.Context, info RetryInfo) {
+		infos = append(infos, info)
+	})(p)
+
+	ctx := context.Background()
+	p.retries.record(ctx, "UpdateResource", 1, sqlStateError(serializationFailure), true)
+	p.retries.record(ctx, "UpdateResource", 2, nil, false)
+	p.retries.record(ctx, "CreateResource", 1, sqlStateError(deadlockDetected), false)
+	p.retries.record(ctx, "CreateResource", 1, errors.New("boom"), false)
+
+	stats := p.RetryStats()
+	if len(stats) != 2 || stats[0].Operation != "CreateResource" || stats[1].Operation != "UpdateResource" {
+		t.Fatalf("RetryStats() = %+v, want ordered by operation", stats)
+	}
+
+	create, update := stats[0], stats[1]
+	if create.Transactions != 2 || create.Attempts != 2 || create.Conflicts != 1 || create.Exhausted != 1 || create.Retries != 0 ||
+		create.Aborts["40P01"] != 1 || create.Aborts["error"] != 1 {
+		t.Errorf("CreateResource stats = %+v", create)
+	}
+	if update.Transactions != 1 || update.Attempts != 2 || update.Conflicts != 1 || update.Retries != 1 || update.ConflictRate() != 0.5 {
+		t.Errorf("UpdateResource stats = %+v", update)
+	}
+
+	// The stats returned are copies.
+	update.Aborts["40001"] = 100
+	if p.RetryStats()[1].Aborts["40001"] != 1 {
+		t.Error("RetryStats() shares the aborts")
+	}
+
+	if len(infos) != 3 || infos[0] != (RetryInfo{Operation: "UpdateResource", Attempt: 1, Reason: "40001", Retried: true, Err: sqlStateError(serializationFailure)}) {
+		t.Errorf("observed %+v, want the failed attempts", infos)
+	}
+
+	if rate := (RetryStats{}).ConflictRate(); rate != 0 {
+		t.Errorf("ConflictRate() without attempts = %v", rate)
+	}
+}
+
+func TestTxRetries(t *testing.T) {
+	ctx := WithQueryLabel(context.Background(), "retried")
+	p := testSQL(t, WithTxRetries(2, time.Millisecond))
+
+	run := func(failures int, err error) (int, error) {
+		attempts := 0
+		runErr := p.RunInTransaction(ctx, func(p *SQL) error {
+			if attempts++; attempts <= failures {
+				return err
+			}
+			_, err := p.conn().ExecContext(ctx, "SELECT 1")
+			return err
+		})
+
+		return attempts, runErr
+	}
+
+	if attempts, err := run(2, sqlStateError(serializationFailure)); err != nil || attempts != 3 {
+		t.Errorf("RunInTransaction() with 2 conflicts = %d attempts, %v, want a success after 2 retries", attempts, err)
+	}
+
+	if attempts, err := run(3, sqlStateError(deadlockDetected)); !errors.Is(err, sqlStateError(deadlockDetected)) || attempts != 3 {
+		t.Errorf("RunInTransaction() with 3 conflicts = %d attempts, %v, want the conflict after 2 retries", attempts, err)
+	}
+
+	if attempts, err := run(1, sqlStateError("23505")); err == nil || attempts != 1 {
+		t.Errorf("RunInTransaction() with a unique violation = %d attempts, %v, want no retry", attempts, err)
+	}
+
+	stats := p.RetryStats()
+	if len(stats) != 1 || stats[0].Operation != "retried" || stats[0].Transactions != 3 || stats[0].Attempts != 7 ||
+		stats[0].Retries != 4 || stats[0].Exhausted != 1 {
+		t.Errorf("RetryStats() = %+v", stats)
+	}
+}
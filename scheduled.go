@@ -0,0 +1,240 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/google/uuid"
+)
+
+// ScheduledTransition sets a column of a resource to a value at a given time, see ScheduleTransition.
+type ScheduledTransition struct {
+	tableName struct{} `pg:"scheduled_transitions"`
+
+	ID         uuid.UUID `pg:",pk,type:uuid"`
+	Collection string    `pg:",notnull"`
+	Key        string    `pg:",notnull"`
+	Column     string    `pg:",notnull"`
+	// Value is the SQL literal of the value
+	Value      string    `pg:",notnull"`
+	At         time.Time `pg:",notnull"`
+	CreateTime time.Time `pg:",notnull"`
+	DoneTime   time.Time
+	Attempts   int `pg:",notnull,use_zero"`
+	LastError  string
+}
+
+// ScheduleTransition schedules setting column of resource, identified by its single column primary key, to value at the given time,
+// e.g. to expire an invitation. Transitions are applied by ApplyDueTransitions, in a process where the model is registered, see Register;
+// a soft-deleted resource isn't changed. To be atomic with another write, call it on the persistence layer bound to its transaction.
+func (p *SQL) ScheduleTransition(ctx context.Context, resource Resource, column string, value interface{}, at time.Time) (*ScheduledTransition, error) {
+	if err := p.checkWrite(); err != nil {
+		return nil, err
+	}
+
+	info := p.model(resource)
+	if len(info.table.PKs) != 1 {
+		return nil, errCompositeKey
+	}
+
+	if _, ok := info.table.FieldsMap[column]; !ok {
+		return nil, fmt.Errorf("%s has no column %s", info.name(), column)
+	}
+
+	if err := checkMutable(resource, []string{column}); err != nil {
+		return nil, err
+	}
+
+	transition := &ScheduledTransition{
+		Collection: info.name(),
+		Key:        primaryKey(resource),
+		Column:     column,
+		Value:      formatQuery("?", value),
+		At:         at,
+		CreateTime: p.now(ctx),
+	}
+	p.assignID(ctx, transition)
+
+	if err := p.ensureTable(ctx, transition); err != nil {
+		return nil, err
+	}
+
+	if _, err := p.conn().ModelContext(ctx, transition).Insert(); err != nil {
+		return nil, err
+	}
+
+	return transition, nil
+}
+
+// CancelTransitions cancels the pending transitions of column of resource, all of its columns if column is empty.
+// It returns the number of transitions cancelled.
+func (p *SQL) CancelTransitions(ctx context.Context, resource Resource, column string) (int, error) {
+	if err := p.checkWrite(); err != nil {
+		return 0, err
+	}
+
+	if err := p.ensureTable(ctx, (*ScheduledTransition)(nil)); err != nil {
+		return 0, err
+	}
+
+	query := p.conn().ModelContext(ctx, (*ScheduledTransition)(nil)).
+		Where("collection = ? AND key = ? AND done_time IS NULL", unqualifiedName(tableOf(resource)), primaryKey(resource))
+	if column != "" {
+		query.Where("\"column\" = ?", column)
+	}
+
+	res, err := query.Delete()
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+// ApplyDueTransitions applies the transitions due, oldest first, each in its own transaction, locked so concurrent workers apply different ones.
+// A failing transition records its error and is retried by the next call. It returns the number of transitions applied.
+func (p *SQL) ApplyDueTransitions(ctx context.Context) (int, error) {
+	if err := p.checkWrite(); err != nil {
+		return 0, err
+	}
+
+	if err := p.ensureTable(ctx, (*ScheduledTransition)(nil)); err != nil {
+		return 0, err
+	}
+
+	now := p.now(ctx)
+	last := &ScheduledTransition{}
+	applied := 0
+
+	for {
+		var found, done bool
+		next := last
+
+		err := p.runInTransaction(ctx, func(tx *pg.Tx) error {
+			found, done = false, false
+
+			transition := &ScheduledTransition{}
+			if err := tx.ModelContext(ctx, transition).
+				Where("done_time IS NULL AND at <= ?", now).
+				Where("(at, id) > (?, ?)", last.At, last.ID).
+				Order("at", "id").Limit(1).For("UPDATE SKIP LOCKED").Select(); err != nil {
+				if err == pg.ErrNoRows {
+					return nil
+				}
+
+				return err
+			}
+
+			found = true
+			next = transition
+
+			query := tx.ModelContext(ctx, transition).WherePK()
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT persistsql_transition"); err != nil {
+				return err
+			}
+
+			if err := p.applyTransition(ctx, tx, transition); err != nil {
+				if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT persistsql_transition"); err != nil {
+					return err
+				}
+
+				query.Set("attempts = attempts + 1, last_error = ?", err.Error())
+			} else {
+				query.Set("attempts = attempts + 1, done_time = ?", p.now(ctx))
+				done = true
+			}
+
+			_, err := query.Update()
+
+			return err
+		})
+		if err != nil || !found {
+			return applied, err
+		}
+
+		last = next
+
+		if done {
+			applied++
+		}
+	}
+}
+
+// applyTransition sets the column of the resource of transition in tx, publishing the change.
+// The state machine of the column, if any, is enforced as by UpdateResource, see DeclareStateMachine.
+func (p *SQL) applyTransition(ctx context.Context, tx *pg.Tx, transition *ScheduledTransition) error {
+	info := p.modelByTable(transition.Collection)
+	if info == nil {
+		return fmt.Errorf("model of %s not registered", transition.Collection)
+	}
+
+	res := reflect.New(info.table.Type).Interface()
+	whereKey := func(query *orm.Query) {
+		query.Where("?TableAlias.? = ?", info.table.PKs[0].Column, transition.Key)
+	}
+
+	// The new value is scanned into res for checkTransitions to compare it with the current one.
+	if field, ok := info.table.FieldsMap[transition.Column]; ok {
+		value := field.Value(reflect.ValueOf(res).Elem()).Addr().Interface()
+		if _, err := tx.QueryOneContext(ctx, pg.Scan(value), "SELECT ?", pg.Safe(transition.Value)); err != nil {
+			return err
+		}
+	}
+
+	changes, err := p.checkTransitions(ctx, tx, res, []string{transition.Column}, whereKey)
+	if err != nil {
+		return err
+	}
+
+	query := tx.ModelContext(ctx, res).
+		Set("? = ?", pg.Ident(transition.Column), pg.Safe(transition.Value)).
+		Returning("*")
+	whereKey(query)
+	if updateTime := timeField(info.table, updateTimeColumns); updateTime != nil {
+		query.Set("? = ?", updateTime.Column, p.now(ctx))
+	}
+
+	if _, err := query.Update(); err != nil {
+		if err == pg.ErrNoRows {
+			return nil
+		}
+
+		return err
+	}
+
+	if err := p.notify(ctx, tx, ChangeUpdate, res); err != nil {
+		return err
+	}
+
+	return p.notifyTransitions(ctx, tx, res, changes)
+}
+
+// RunTransitionWorker applies the due transitions every interval until ctx is done, see ApplyDueTransitions.
+// A nil alert logs errors with the standard logger.
+func (p *SQL) RunTransitionWorker(ctx context.Context, interval time.Duration, alert func(error)) {
+	if alert == nil {
+		alert = func(err error) {
+			log.Printf("persistsql: applying scheduled transitions failed: %v", err)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.ApplyDueTransitions(ctx); err != nil && ctx.Err() == nil {
+			alert(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
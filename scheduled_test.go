@@ -0,0 +1,91 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+
+	"github.com/chi07/persistsql/model"
+)
+
+type scheduledInvitation struct {
+	tableName struct{} `pg:"test_scheduled_invitations"`
+
+	model.Common
+	Email  string
+	Status string
+}
+
+func TestScheduleTransitionInvalid(t *testing.T) {
+	ctx := context.Background()
+	p := &SQL{maintenance: &maintenance{}, registry: &registry{models: map[reflect.Type]*modelInfo{}}}
+	at := time.Now()
+
+	if _, err := p.ScheduleTransition(ctx, &scheduledInvitation{}, "nope", "expired", at); err == nil {
+		t.Error("ScheduleTransition() of an unknown column succeeded")
+	}
+
+	if _, err := p.ScheduleTransition(ctx, &scheduledInvitation{}, "create_time", at, at); !errors.Is(err, ErrImmutableField) {
+		t.Errorf("ScheduleTransition() of an immutable column = %v, want ErrImmutableField", err)
+	}
+
+	if _, err := p.ScheduleTransition(ctx, &versionedNode{}, "id", 2, at); err != errCompositeKey {
+		t.Errorf("ScheduleTransition() of a composite key = %v, want errCompositeKey", err)
+	}
+}
+
+func TestApplyDueTransitions(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ctx := ContextWithClock(context.Background(), fixedClock(now))
+	p := testSQL(t)
+	testTables(t, p.db, (*ScheduledTransition)(nil), (*scheduledInvitation)(nil))
+	if err := p.Register((*scheduledInvitation)(nil)); err != nil {
+		t.Fatalf("Register(): %v", err)
+	}
+
+	var invitations []*scheduledInvitation
+	for _, email := range []string{"ada@example.com", "alan@example.com"} {
+		created, err := p.CreateResource(ctx, &scheduledInvitation{Email: email, Status: "pending"})
+		if err != nil {
+			t.Fatalf("CreateResource(): %v", err)
+		}
+		invitations = append(invitations, created.(*scheduledInvitation))
+
+		if _, err := p.ScheduleTransition(ctx, created, "status", "expired", now.Add(time.Hour)); err != nil {
+			t.Fatalf("ScheduleTransition(): %v", err)
+		}
+	}
+
+	// Alan accepted.
+	if n, err := p.CancelTransitions(ctx, invitations[1], ""); err != nil || n != 1 {
+		t.Errorf("CancelTransitions() = %d, %v, want 1", n, err)
+	}
+
+	if n, err := p.ApplyDueTransitions(ctx); err != nil || n != 0 {
+		t.Errorf("ApplyDueTransitions() before the time = %d, %v, want none", n, err)
+	}
+
+	later := ContextWithClock(context.Background(), fixedClock(now.Add(2*time.Hour)))
+	if n, err := p.ApplyDueTransitions(later); err != nil || n != 1 {
+		t.Errorf("ApplyDueTransitions() = %d, %v, want 1", n, err)
+	}
+
+	if n, err := p.ApplyDueTransitions(later); err != nil || n != 0 {
+		t.Errorf("ApplyDueTransitions() again = %d, %v, want none", n, err)
+	}
+
+	for i, want := range []string{"expired", "pending"} {
+		got, err := p.GetResource(ctx, &scheduledInvitation{}, false, func(query *orm.Query) {
+			query.Where("id = ?", invitations[i].ID)
+		})
+		if invitation, _ := got.(*scheduledInvitation); err != nil || invitation == nil || invitation.Status != want {
+			t.Errorf("invitation %d = %+v, %v, want %s", i, got, err, want)
+		} else if want == "expired" && !invitation.UpdateTime.Equal(now.Add(2*time.Hour)) {
+			t.Errorf("expired invitation updated at %v, want the time it was applied", invitation.UpdateTime)
+		}
+	}
+}
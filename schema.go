@@ -0,0 +1,258 @@
+package persistsql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// ColumnSchema describes a column of a table.
+type ColumnSchema struct {
+	Table    string `json:"table"`
+	Column   string `json:"column"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+// String formats the column as it's hashed in fingerprints.
+func (c ColumnSchema) String() string {
+	null := "not null"
+	if c.Nullable {
+		null = "null"
+	}
+
+	return fmt.Sprintf("%s.%s %s %s", c.Table, c.Column, c.Type, null)
+}
+
+// SchemaSnapshot is a canonical description of the live schema of the registered models' tables.
+// Snapshots are stored in the schema_snapshots table, once per distinct fingerprint.
+type SchemaSnapshot struct {
+	tableName struct{} `pg:"schema_snapshots"`
+
+	// Fingerprint is the SHA-256 of the columns
+	Fingerprint string `pg:",pk" json:"fingerprint"`
+	// Columns ordered by table and column name
+	Columns []ColumnSchema `pg:",notnull" json:"columns"`
+	// TakenAt is the first time the fingerprint was seen
+	TakenAt time.Time `pg:",notnull" json:"taken_at"`
+}
+
+// SnapshotSchema takes a snapshot of the live schema of the registered models' tables and stores it.
+func (p *SQL) SnapshotSchema(ctx context.Context) (*SchemaSnapshot, error) {
+	snapshot, err := p.schemaSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.ensureTable(ctx, (*SchemaSnapshot)(nil)); err != nil {
+		return nil, err
+	}
+
+	if _, err := p.db.ModelContext(ctx, snapshot).OnConflict("DO NOTHING").Insert(); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// schemaSnapshot takes a snapshot of the live schema of the registered models' tables, without storing it.
+func (p *SQL) schemaSnapshot(ctx context.Context) (*SchemaSnapshot, error) {
+	columns, err := p.liveColumns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	for _, col := range columns {
+		fmt.Fprintln(hash, col)
+	}
+
+	return &SchemaSnapshot{
+		Fingerprint: hex.EncodeToString(hash.Sum(nil)),
+		Columns:     columns,
+		TakenAt:     p.now(ctx),
+	}, nil
+}
+
+// liveColumns returns the columns of the registered models' tables, ordered by table and column name.
+func (p *SQL) liveColumns(ctx context.Context) ([]ColumnSchema, error) {
+	var tables []string
+	for _, info := range p.registered() {
+		tables = append(tables, info.name())
+	}
+
+	if len(tables) == 0 {
+		return nil, nil
+	}
+
+	var columns []ColumnSchema
+	if _, err := p.db.QueryContext(ctx, &columns, `
+		SELECT table_name AS "table", column_name AS "column", udt_name AS "type", is_nullable = 'YES' AS nullable
+		FROM information_schema.columns
+		WHERE table_schema = ANY(current_schemas(false)) AND table_name IN (?)
+		ORDER BY table_name, column_name`, pg.In(tables)); err != nil {
+		return nil, err
+	}
+
+	return columns, nil
+}
+
+// ColumnMismatch is a column whose live type differs from the model.
+type ColumnMismatch struct {
+	Expected ColumnSchema `json:"expected"`
+	Actual   ColumnSchema `json:"actual"`
+}
+
+// SchemaDrift lists the differences between the registered models and the live schema.
+type SchemaDrift struct {
+	// Missing are the columns of the models absent from the database
+	Missing []ColumnSchema `json:"missing,omitempty"`
+	// Unexpected are the columns of the database absent from the models
+	Unexpected []ColumnSchema `json:"unexpected,omitempty"`
+	// Mismatched are the columns whose type differs
+	Mismatched []ColumnMismatch `json:"mismatched,omitempty"`
+}
+
+// Empty reports whether the live schema matches the models.
+func (d *SchemaDrift) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Unexpected) == 0 && len(d.Mismatched) == 0
+}
+
+// String summarizes the drift.
+func (d *SchemaDrift) String() string {
+	var parts []string
+	for _, col := range d.Missing {
+		parts = append(parts, "missing "+col.Table+"."+col.Column)
+	}
+	for _, col := range d.Unexpected {
+		parts = append(parts, "unexpected "+col.Table+"."+col.Column)
+	}
+	for _, m := range d.Mismatched {
+		parts = append(parts, fmt.Sprintf("%s.%s is %s, expected %s", m.Actual.Table, m.Actual.Column, m.Actual.Type, m.Expected.Type))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// CheckSchemaDrift compares the live schema with the registered models.
+func (p *SQL) CheckSchemaDrift(ctx context.Context) (*SchemaDrift, error) {
+	live, err := p.liveColumns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	actual := make(map[string]ColumnSchema, len(live))
+	for _, col := range live {
+		actual[col.Table+"."+col.Column] = col
+	}
+
+	drift := &SchemaDrift{}
+	for _, info := range p.registered() {
+		for _, field := range info.table.Fields {
+			expected := ColumnSchema{
+				Table:  info.name(),
+				Column: field.SQLName,
+				Type:   normalizeType(field.SQLType),
+			}
+
+			col, ok := actual[expected.Table+"."+expected.Column]
+			delete(actual, expected.Table+"."+expected.Column)
+			switch {
+			case !ok:
+				drift.Missing = append(drift.Missing, expected)
+			case col.Type != expected.Type:
+				drift.Mismatched = append(drift.Mismatched, ColumnMismatch{Expected: expected, Actual: col})
+			}
+		}
+	}
+
+	for _, col := range live {
+		if _, ok := actual[col.Table+"."+col.Column]; ok {
+			drift.Unexpected = append(drift.Unexpected, col)
+		}
+	}
+
+	return drift, nil
+}
+
+// WatchSchemaDrift checks the schema for drift every interval until ctx is done, calling alert when drift is found.
+// A nil alert logs the drift with the standard logger. Check errors are reported the same way as a drift would.
+func (p *SQL) WatchSchemaDrift(ctx context.Context, interval time.Duration, alert func(*SchemaDrift, error)) {
+	if alert == nil {
+		alert = func(drift *SchemaDrift, err error) {
+			if err != nil {
+				log.Printf("persistsql: schema drift check failed: %v", err)
+				return
+			}
+
+			log.Printf("persistsql: schema drift: %s", drift)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		drift, err := p.CheckSchemaDrift(ctx)
+		if err != nil && ctx.Err() == nil {
+			alert(nil, err)
+		} else if err == nil && !drift.Empty() {
+			alert(drift, nil)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// typeAliases maps the SQL types go-pg generates to the names Postgres reports in information_schema.columns.udt_name.
+var typeAliases = map[string]string{
+	"bigint":                      "int8",
+	"bigserial":                   "int8",
+	"integer":                     "int4",
+	"int":                         "int4",
+	"serial":                      "int4",
+	"smallint":                    "int2",
+	"smallserial":                 "int2",
+	"boolean":                     "bool",
+	"double precision":            "float8",
+	"real":                        "float4",
+	"timestamp with time zone":    "timestamptz",
+	"timestamp without time zone": "timestamp",
+	"time with time zone":         "timetz",
+	"time without time zone":      "time",
+	"character varying":           "varchar",
+	"character":                   "bpchar",
+	"char":                        "bpchar",
+	"decimal":                     "numeric",
+}
+
+// normalizeType returns the udt_name of an SQL type, e.g. int8 for bigint or _text for text[].
+func normalizeType(sqlType string) string {
+	typ := strings.ToLower(strings.TrimSpace(sqlType))
+
+	array := strings.HasSuffix(typ, "[]")
+	typ = strings.TrimSuffix(typ, "[]")
+	if i := strings.IndexByte(typ, '('); i >= 0 {
+		typ = strings.TrimSpace(typ[:i])
+	}
+
+	if alias, ok := typeAliases[typ]; ok {
+		typ = alias
+	}
+
+	if array {
+		typ = "_" + typ
+	}
+
+	return typ
+}
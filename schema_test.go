@@ -0,0 +1,105 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizeType(t *testing.T) {
+	for sqlType, want := range map[string]string{
+		"bigint":                   "int8",
+		"BIGSERIAL":                "int8",
+		"text":                     "text",
+		"text[]":                   "_text",
+		"varchar(255)":             "varchar",
+		"numeric(10, 2)":           "numeric",
+		"timestamp with time zone": "timestamptz",
+		" jsonb ":                  "jsonb",
+	} {
+		if got := normalizeType(sqlType); got != want {
+			t.Errorf("normalizeType(%q) = %q, want %q", sqlType, got, want)
+		}
+	}
+}
+
+func TestSchemaDriftString(t *testing.T) {
+	drift := &SchemaDrift{}
+	if !drift.Empty() || drift.String() != "" {
+		t.Errorf("empty drift = %q, Empty() %v", drift, drift.Empty())
+	}
+
+	drift = &SchemaDrift{
+		Missing:    []ColumnSchema{{Table: "orders", Column: "note", Type: "text"}},
+		Unexpected: []ColumnSchema{{Table: "orders", Column: "legacy", Type: "int4"}},
+		Mismatched: []ColumnMismatch{{
+			Expected: ColumnSchema{Table: "orders", Column: "total", Type: "int8"},
+			Actual:   ColumnSchema{Table: "orders", Column: "total", Type: "int4"},
+		}},
+	}
+
+	if want := "missing orders.note, unexpected orders.legacy, orders.total is int4, expected int8"; drift.Empty() || drift.String() != want {
+		t.Errorf("String() = %q, want %q", drift, want)
+	}
+
+	if col := (ColumnSchema{Table: "orders", Column: "note", Type: "text", Nullable: true}); col.String() != "orders.note text null" {
+		t.Errorf("ColumnSchema.String() = %q", col)
+	}
+}
+
+type driftedOrder struct {
+	tableName struct{} `pg:"test_drifted_orders"`
+
+	ID    int64
+	Note  string
+	Total int
+}
+
+func TestSchemaDrift(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*SchemaSnapshot)(nil), (*driftedOrder)(nil))
+	if err := p.Register((*driftedOrder)(nil)); err != nil {
+		t.Fatalf("Register(): %v", err)
+	}
+
+	drift, err := p.CheckSchemaDrift(ctx)
+	if err != nil || !drift.Empty() {
+		t.Fatalf("CheckSchemaDrift() = %v, %v, want no drift", drift, err)
+	}
+
+	before, err := p.SnapshotSchema(ctx)
+	if err != nil {
+		t.Fatalf("SnapshotSchema(): %v", err)
+	}
+
+	if again, err := p.SnapshotSchema(ctx); err != nil || again.Fingerprint != before.Fingerprint {
+		t.Fatalf("SnapshotSchema() again = %v, %v, want fingerprint %s", again, err, before.Fingerprint)
+	}
+
+	if _, err := p.db.Exec(`ALTER TABLE test_drifted_orders DROP COLUMN note, ADD COLUMN legacy int, ALTER COLUMN total TYPE int`); err != nil {
+		t.Fatalf("Exec(): %v", err)
+	}
+
+	drift, err = p.CheckSchemaDrift(ctx)
+	if err != nil {
+		t.Fatalf("CheckSchemaDrift(): %v", err)
+	}
+
+	if want := "missing test_drifted_orders.note, unexpected test_drifted_orders.legacy, test_drifted_orders.total is int4, expected int8"; drift.String() != want {
+		t.Errorf("CheckSchemaDrift() = %s, want %s", drift, want)
+	}
+
+	after, err := p.SnapshotSchema(ctx)
+	if err != nil {
+		t.Fatalf("SnapshotSchema(): %v", err)
+	}
+
+	if after.Fingerprint == before.Fingerprint {
+		t.Error("SnapshotSchema() fingerprint unchanged by the drift")
+	}
+
+	count, err := p.db.Model((*SchemaSnapshot)(nil)).Count()
+	if err != nil || count != 2 {
+		t.Errorf("%d snapshots stored, %v, want 2", count, err)
+	}
+}
@@ -0,0 +1,111 @@
+package persistsql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"reflect"
+	"time"
+)
+
+// shadowTimeout bounds the reads mirrored to the shadow backend.
+const shadowTimeout = 10 * time.Second
+
+// shadowReader mirrors reads to an alternate backend.
+type shadowReader struct {
+	backend Backend
+	sample  float64
+	logf    func(format string, args ...interface{})
+}
+
+// WithShadowReader mirrors a sample of the reads of GetResource and ListResources to shadow, e.g. another SQL on a replica
+// or a database with a migrated schema, in the background, and logs with logf, log.Printf if nil, those whose results differ
+// from the primary ones by row count or checksum. sample is the fraction of reads mirrored, between 0 and 1.
+// Reads in transactions and lists truncated by ListOptions.MaxBytes aren't mirrored.
+func WithShadowReader(shadow Backend, sample float64, logf func(format string, args ...interface{})) Option {
+	return func(p *SQL) {
+		if logf == nil {
+			logf = log.Printf
+		}
+
+		p.shadow = &shadowReader{backend: shadow, sample: sample, logf: logf}
+	}
+}
+
+// shadowed returns whether the read about to be made by p is mirrored.
+func (p *SQL) shadowed() bool {
+	return p.shadow != nil && p.tx == nil && rand.Float64() < p.shadow.sample
+}
+
+// mirror returns a copy of res to mirror a GetResource call, nil if it isn't, see shadowed.
+func (p *SQL) mirror(res Resource) Resource {
+	if !p.shadowed() {
+		return nil
+	}
+
+	return cloneResource(res)
+}
+
+// get mirrors a GetResource call, query being a copy of the resource passed and got the result.
+func (s *shadowReader) get(ctx context.Context, query Resource, showDeleted bool, queryHook QueryHook, got Resource) {
+	rows, sum := digest(got)
+
+	go detach(ctx, shadowTimeout, func(ctx context.Context) {
+		shadow, err := s.backend.GetResource(ctx, query, showDeleted, queryHook)
+		s.compare(ctx, "GetResource", rows, sum, shadow, err)
+	})
+}
+
+// list mirrors a ListResources call, resources being the result.
+func (s *shadowReader) list(ctx context.Context, resources interface{}, opts ListOptions, queryHook QueryHook) {
+	rows, sum := digest(resources)
+
+	go detach(ctx, shadowTimeout, func(ctx context.Context) {
+		shadow := reflect.New(reflect.TypeOf(resources).Elem()).Interface()
+		_, err := s.backend.ListResources(ctx, shadow, opts, queryHook)
+		s.compare(ctx, "ListResources", rows, sum, shadow, err)
+	})
+}
+
+// compare logs the shadow read if it failed or its result differs from the primary one.
+func (s *shadowReader) compare(ctx context.Context, op string, rows int, sum string, shadow interface{}, err error) {
+	label := QueryLabel(ctx)
+	if label == "" {
+		label = "-"
+	}
+
+	if err != nil {
+		s.logf("persistsql: shadow read [%s] %s failed: %v", label, op, err)
+		return
+	}
+
+	if shadowRows, shadowSum := digest(shadow); shadowRows != rows || shadowSum != sum {
+		s.logf("persistsql: shadow read [%s] %s mismatch: %d rows, checksum %s, shadow %d rows, checksum %s",
+			label, op, rows, sum, shadowRows, shadowSum)
+	}
+}
+
+// digest returns the number of rows of a result, a resource, nil if not found, or a pointer to a slice, and the checksum of its JSON encoding.
+func digest(result interface{}) (int, string) {
+	v := reflect.ValueOf(result)
+	if !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		return 0, ""
+	}
+
+	rows := 1
+	if list := reflect.Indirect(v); list.Kind() == reflect.Slice {
+		rows = list.Len()
+	}
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		return rows, ""
+	}
+
+	sum := sha256.Sum256(b)
+
+	return rows, hex.EncodeToString(sum[:8])
+}
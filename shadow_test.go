@@ -0,0 +1,112 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// cannedBackend is a Backend reading note, or failing with err.
+type cannedBackend struct {
+	Backend
+	note *dualNote
+	err  error
+}
+
+func (b cannedBackend) GetResource(context.Context, Resource, bool, QueryHook) (Resource, error) {
+	if b.err != nil || b.note == nil {
+		return nil, b.err
+	}
+
+	return b.note, nil
+}
+
+func (b cannedBackend) ListResources(_ context.Context, resources interface{}, _ ListOptions, _ QueryHook) (ListMeta, error) {
+	if b.note != nil {
+		*resources.(*[]*dualNote) = []*dualNote{b.note}
+	}
+
+	return ListMeta{}, b.err
+}
+
+func TestDigest(t *testing.T) {
+	for _, result := range []interface{}{nil, (*dualNote)(nil)} {
+		if rows, sum := digest(result); rows != 0 || sum != "" {
+			t.Errorf("digest(%#v) = %d, %q, want nothing", result, rows, sum)
+		}
+	}
+
+	note := &dualNote{Text: "a"}
+	rows, sum := digest(note)
+	if rows != 1 || len(sum) != 16 {
+		t.Errorf("digest(note) = %d, %q", rows, sum)
+	}
+
+	if rows, listSum := digest(&[]*dualNote{note, note}); rows != 2 || listSum == sum {
+		t.Errorf("digest(list) = %d, %q", rows, listSum)
+	}
+
+	if _, other := digest(&dualNote{Text: "b"}); other == sum {
+		t.Error("digest() of different notes match")
+	}
+}
+
+func TestShadowReader(t *testing.T) {
+	p := &SQL{}
+	if p.shadowed() {
+		t.Error("shadowed() without a shadow reader")
+	}
+
+	WithShadowReader(cannedBackend{}, 1, nil)(p)
+	if !p.shadowed() || p.mirror(&dualNote{}) == nil {
+		t.Error("shadowed() with a sample of 1 = false")
+	}
+
+	p.tx = &pg.Tx{}
+	if p.shadowed() {
+		t.Error("shadowed() in a transaction")
+	}
+
+	logged := make(chan string, 1)
+	logf := func(format string, args ...interface{}) {
+		logged <- fmt.Sprintf(format, args...)
+	}
+
+	ctx := WithQueryLabel(context.Background(), "notes")
+	note := &dualNote{Text: "a"}
+	for _, tt := range []struct {
+		name    string
+		backend cannedBackend
+		want    string
+	}{
+		{"same", cannedBackend{note: &dualNote{Text: "a"}}, ""},
+		{"different", cannedBackend{note: &dualNote{Text: "b"}}, "persistsql: shadow read [notes] GetResource mismatch: 1 rows"},
+		{"missing", cannedBackend{}, "shadow 0 rows"},
+		{"failed", cannedBackend{err: errors.New("boom")}, "persistsql: shadow read [notes] GetResource failed: boom"},
+	} {
+		s := &shadowReader{backend: tt.backend, sample: 1, logf: logf}
+		s.get(ctx, &dualNote{}, false, nil, note)
+
+		select {
+		case msg := <-logged:
+			if tt.want == "" || !strings.Contains(msg, tt.want) {
+				t.Errorf("%s: logged %q, want %q", tt.name, msg, tt.want)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if tt.want != "" {
+				t.Errorf("%s: nothing logged, want %q", tt.name, tt.want)
+			}
+		}
+	}
+
+	s := &shadowReader{backend: cannedBackend{note: &dualNote{Text: "b"}}, sample: 1, logf: logf}
+	s.list(context.Background(), &[]*dualNote{note}, ListOptions{}, nil)
+	if msg := <-logged; !strings.HasPrefix(msg, "persistsql: shadow read [-] ListResources mismatch") {
+		t.Errorf("list logged %q, want a mismatch", msg)
+	}
+}
@@ -0,0 +1,146 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// ErrSnapshotExpired is returned by ListWithSnapshot when the snapshot of a token expired or was released.
+var ErrSnapshotExpired = errors.New("snapshot expired")
+
+// defaultSnapshotTimeout is how long an unused snapshot is held by default.
+const defaultSnapshotTimeout = 5 * time.Minute
+
+// snapshots holds the exported snapshots of a persistence layer, by token.
+type snapshots struct {
+	mu      sync.Mutex
+	held    map[string]*heldSnapshot
+	timeout time.Duration
+}
+
+// heldSnapshot is a REPEATABLE READ transaction kept open for its exported snapshot to be imported.
+type heldSnapshot struct {
+	db    *pg.DB
+	tx    *pg.Tx
+	timer *time.Timer
+}
+
+// WithSnapshotTimeout sets how long a snapshot of ListWithSnapshot is held after its last use, 5 minutes by default.
+func WithSnapshotTimeout(timeout time.Duration) Option {
+	return func(p *SQL) {
+		p.snapshots.timeout = timeout
+	}
+}
+
+// ListWithSnapshot lists resources like ListResources, all pages reading from the same MVCC snapshot.
+// Without token, a snapshot is taken, held server-side in an open REPEATABLE READ transaction, and its token returned.
+// With a token, the resources are read from its snapshot, on the same replica, or the primary, and the token is returned again.
+// A snapshot is released after being unused for the snapshot timeout, see WithSnapshotTimeout, or by ReleaseSnapshot,
+// ErrSnapshotExpired is returned afterwards.
+func (p *SQL) ListWithSnapshot(ctx context.Context, resources interface{}, token string, opts ListOptions, queryHook QueryHook) (ListMeta, string, error) {
+	if token == "" {
+		return p.exportSnapshot(ctx, resources, opts, queryHook)
+	}
+
+	p.snapshots.mu.Lock()
+	held, ok := p.snapshots.held[token]
+	if ok {
+		held.timer.Reset(p.snapshots.timeout)
+	}
+	p.snapshots.mu.Unlock()
+
+	if !ok {
+		return ListMeta{}, "", ErrSnapshotExpired
+	}
+
+	var meta ListMeta
+	if err := held.db.WithContext(ctx).RunInTransaction(ctx, func(tx *pg.Tx) error {
+		if _, err := tx.ExecContext(ctx, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "SET TRANSACTION SNAPSHOT ?", snapshotID(token)); err != nil {
+			return err
+		}
+
+		var err error
+		meta, err = p.WithTx(tx).ListResources(ctx, resources, opts, queryHook)
+		return err
+	}); err != nil {
+		return meta, "", err
+	}
+
+	return meta, token, nil
+}
+
+// ReleaseSnapshot releases the snapshot of token, if still held.
+func (p *SQL) ReleaseSnapshot(token string) error {
+	p.snapshots.mu.Lock()
+	held, ok := p.snapshots.held[token]
+	delete(p.snapshots.held, token)
+	p.snapshots.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	held.timer.Stop()
+
+	return held.tx.Rollback()
+}
+
+// exportSnapshot lists resources in a new REPEATABLE READ transaction, whose snapshot is exported and held.
+func (p *SQL) exportSnapshot(ctx context.Context, resources interface{}, opts ListOptions, queryHook QueryHook) (ListMeta, string, error) {
+	n, db := p.replica()
+	if callOptionsOf(ctx).primaryRead {
+		n, db = 0, p.db
+	}
+
+	// The transaction outlives ctx, until released.
+	tx, err := db.BeginContext(context.Background())
+	if err != nil {
+		return ListMeta{}, "", err
+	}
+
+	var id string
+	if _, err := tx.ExecContext(ctx, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+		_ = tx.Rollback()
+		return ListMeta{}, "", err
+	}
+
+	if _, err := tx.QueryOneContext(ctx, pg.Scan(&id), "SELECT pg_export_snapshot()"); err != nil {
+		_ = tx.Rollback()
+		return ListMeta{}, "", err
+	}
+
+	meta, err := p.WithTx(tx).ListResources(ctx, resources, opts, queryHook)
+	if err != nil {
+		_ = tx.Rollback()
+		return meta, "", err
+	}
+
+	token := fmt.Sprintf("%d/%s", n, id)
+
+	p.snapshots.mu.Lock()
+	p.snapshots.held[token] = &heldSnapshot{
+		db: db,
+		tx: tx,
+		timer: time.AfterFunc(p.snapshots.timeout, func() {
+			_ = p.ReleaseSnapshot(token)
+		}),
+	}
+	p.snapshots.mu.Unlock()
+
+	return meta, token, nil
+}
+
+// snapshotID returns the exported snapshot identifier of token, which is prefixed by the index of its replica.
+func snapshotID(token string) string {
+	return token[strings.IndexByte(token, '/')+1:]
+}
@@ -0,0 +1,78 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+func TestSnapshotTokens(t *testing.T) {
+	if id := snapshotID("2/00000003-0000001B-1"); id != "00000003-0000001B-1" {
+		t.Errorf("snapshotID() = %s", id)
+	}
+
+	p := &SQL{snapshots: &snapshots{held: map[string]*heldSnapshot{}, timeout: defaultSnapshotTimeout}}
+	var orders []*labelledOrder
+	if _, _, err := p.ListWithSnapshot(context.Background(), &orders, "0/unknown", ListOptions{}, nil); err != ErrSnapshotExpired {
+		t.Errorf("ListWithSnapshot() of an unknown token = %v, want ErrSnapshotExpired", err)
+	}
+
+	if err := p.ReleaseSnapshot("0/unknown"); err != nil {
+		t.Errorf("ReleaseSnapshot() of an unknown token = %v", err)
+	}
+}
+
+func TestListWithSnapshot(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t, WithSnapshotTimeout(time.Second))
+	testTables(t, p.db, (*labelledOrder)(nil))
+
+	if _, err := p.db.ExecContext(ctx, "INSERT INTO labelled_orders (id, total) SELECT i, i FROM generate_series(1, 4) i"); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+
+	page := func(offset int) QueryHook {
+		return func(query *orm.Query) {
+			query.Order("id").Offset(offset).Limit(2)
+		}
+	}
+
+	var orders []*labelledOrder
+	_, token, err := p.ListWithSnapshot(ctx, &orders, "", ListOptions{}, page(0))
+	if err != nil || token == "" || len(orders) != 2 {
+		t.Fatalf("ListWithSnapshot() = %d orders, %q, %v, want the first page and a token", len(orders), token, err)
+	}
+
+	// Rows written after the snapshot aren't listed by the next pages.
+	if _, err := p.db.ExecContext(ctx, "DELETE FROM labelled_orders WHERE id = 3; INSERT INTO labelled_orders (id, total) VALUES (5, 5)"); err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+
+	if _, again, err := p.ListWithSnapshot(ctx, &orders, token, ListOptions{}, page(2)); err != nil || again != token {
+		t.Fatalf("ListWithSnapshot(token) = %q, %v", again, err)
+	}
+
+	if len(orders) != 2 || orders[0].ID != 3 || orders[1].ID != 4 {
+		t.Errorf("second page = %v, want orders 3 and 4 as of the snapshot", orders)
+	}
+
+	if err := p.ReleaseSnapshot(token); err != nil {
+		t.Fatalf("ReleaseSnapshot(): %v", err)
+	}
+
+	if _, _, err := p.ListWithSnapshot(ctx, &orders, token, ListOptions{}, page(2)); err != ErrSnapshotExpired {
+		t.Errorf("ListWithSnapshot() of a released token = %v, want ErrSnapshotExpired", err)
+	}
+
+	// Unused snapshots are released after the timeout.
+	if _, token, err = p.ListWithSnapshot(ctx, &orders, "", ListOptions{}, page(0)); err != nil {
+		t.Fatalf("ListWithSnapshot(): %v", err)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if _, _, err := p.ListWithSnapshot(ctx, &orders, token, ListOptions{}, page(2)); err != ErrSnapshotExpired {
+		t.Errorf("ListWithSnapshot() of a timed out token = %v, want ErrSnapshotExpired", err)
+	}
+}
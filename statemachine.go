@@ -0,0 +1,126 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// ErrInvalidTransition is wrapped by InvalidTransitionError.
+var ErrInvalidTransition = errors.New("invalid transition")
+
+// InvalidTransitionError is returned by UpdateResource when a column moves between states its state machine doesn't allow.
+type InvalidTransitionError struct {
+	Column string
+	From   string
+	To     string
+}
+
+// Error implements error.
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("%s: %s from %q to %q", ErrInvalidTransition, e.Column, e.From, e.To)
+}
+
+// Unwrap returns ErrInvalidTransition.
+func (e *InvalidTransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// StateMachine declares the states of a column and the transitions allowed between them, states being the values of the column formatted with fmt.Sprint.
+type StateMachine struct {
+	// Column holding the state
+	Column string
+	// Transitions lists the states reachable from each state, a state missing from the keys is final
+	Transitions map[string][]string
+}
+
+// allows reports whether sm allows moving from to to, staying in a state is always allowed.
+func (sm *StateMachine) allows(from, to string) bool {
+	if from == to {
+		return true
+	}
+
+	for _, state := range sm.Transitions[from] {
+		if state == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DeclareStateMachine makes UpdateResource, UpsertResources and the scheduled transitions, see ScheduleTransition, enforce sm on the resources of the type of model:
+// updating sm.Column to a state not reachable from the current one returns an InvalidTransitionError,
+// while allowed transitions publish a ChangeTransition event, see Changes.
+func (p *SQL) DeclareStateMachine(model interface{}, sm StateMachine) {
+	info := p.model(model)
+
+	p.registry.mu.Lock()
+	defer p.registry.mu.Unlock()
+
+	info.stateMachines = append(info.stateMachines, &sm)
+}
+
+// stateChange is a change of state of a column.
+type stateChange struct {
+	column   string
+	from, to string
+}
+
+// checkTransitions returns the changes of state of the columns among fields of updated, selected by queryHook, in tx,
+// locking the row, and an InvalidTransitionError for the first change not allowed.
+func (p *SQL) checkTransitions(ctx context.Context, tx *pg.Tx, updated Resource, fields []string, queryHook QueryHook) ([]stateChange, error) {
+	info := p.model(updated)
+
+	p.registry.mu.RLock()
+	machines := info.stateMachines
+	p.registry.mu.RUnlock()
+
+	var changes []stateChange
+	for _, sm := range machines {
+		if !contains(fields, sm.Column) {
+			continue
+		}
+
+		field, ok := info.table.FieldsMap[sm.Column]
+		if !ok {
+			return nil, fmt.Errorf("state machine: unknown column %s", sm.Column)
+		}
+
+		var from string
+		query := tx.ModelContext(ctx, updated).ColumnExpr("?TableAlias.?::text", field.Column)
+		queryHook(query)
+		if err := query.For("UPDATE").Select(pg.Scan(&from)); err != nil {
+			if err == pg.ErrNoRows {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		to := fmt.Sprint(field.Value(reflect.Indirect(reflect.ValueOf(updated))).Interface())
+		if !sm.allows(from, to) {
+			return nil, &InvalidTransitionError{Column: sm.Column, From: from, To: to}
+		}
+
+		if from != to {
+			changes = append(changes, stateChange{column: sm.Column, from: from, to: to})
+		}
+	}
+
+	return changes, nil
+}
+
+// contains reports whether ss contains s.
+func contains(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,94 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var invitationStates = StateMachine{
+	Column: "status",
+	Transitions: map[string][]string{
+		"pending":  {"accepted", "expired"},
+		"accepted": {"revoked"},
+	},
+}
+
+func TestStateMachineAllows(t *testing.T) {
+	for _, tt := range []struct {
+		from, to string
+		want     bool
+	}{
+		{"pending", "accepted", true},
+		{"pending", "pending", true},
+		{"accepted", "revoked", true},
+		{"accepted", "pending", false},
+		{"expired", "accepted", false},
+		{"expired", "expired", true},
+	} {
+		if got := invitationStates.allows(tt.from, tt.to); got != tt.want {
+			t.Errorf("allows(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	err := error(&InvalidTransitionError{Column: "status", From: "expired", To: "accepted"})
+	if !errors.Is(err, ErrInvalidTransition) || err.Error() != `invalid transition: status from "expired" to "accepted"` {
+		t.Errorf("InvalidTransitionError = %q", err)
+	}
+}
+
+func TestStateMachineUpdates(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p := testSQL(t)
+	testTables(t, p.db, (*scheduledInvitation)(nil))
+	p.DeclareStateMachine((*scheduledInvitation)(nil), invitationStates)
+
+	created, err := p.CreateResource(ctx, &scheduledInvitation{Email: "ada@example.com", Status: "pending"})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	invitation := created.(*scheduledInvitation)
+
+	changes, err := p.Changes(ctx)
+	if err != nil {
+		t.Fatalf("Changes(): %v", err)
+	}
+
+	invitation.Status = "accepted"
+	if _, err := p.UpdateResource(ctx, invitation, []string{"status"}, nil); err != nil {
+		t.Fatalf("UpdateResource(accepted): %v", err)
+	}
+
+	// Other columns are updated freely.
+	invitation.Email = "ada@example.org"
+	if _, err := p.UpdateResource(ctx, invitation, []string{"email"}, nil); err != nil {
+		t.Fatalf("UpdateResource(email): %v", err)
+	}
+
+	invitation.Status = "pending"
+	_, err = p.UpdateResource(ctx, invitation, []string{"status"}, nil)
+
+	var transitionErr *InvalidTransitionError
+	if !errors.As(err, &transitionErr) || transitionErr.From != "accepted" || transitionErr.To != "pending" {
+		t.Errorf("UpdateResource(pending) = %v, want an InvalidTransitionError from accepted", err)
+	}
+
+	var transitions []ChangeEvent
+	for len(transitions) == 0 {
+		select {
+		case event := <-changes:
+			if event.Op == ChangeTransition {
+				transitions = append(transitions, event)
+			}
+		case <-ctx.Done():
+			t.Fatal("no transition event")
+		}
+	}
+
+	if event := transitions[0]; event.Key != invitation.ID.String() || event.Column != "status" || event.From != "pending" || event.To != "accepted" {
+		t.Errorf("transition event = %+v, want status from pending to accepted", event)
+	}
+}
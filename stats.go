@@ -0,0 +1,60 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// ModelStats are storage statistics of the table of a model.
+type ModelStats struct {
+	Table string `json:"table"`
+	// RowEstimate is the planner's estimate of the number of rows, -1 if the table was never analyzed
+	RowEstimate int64 `json:"row_estimate"`
+	// LiveTuples and DeadTuples are counted by the statistics collector
+	LiveTuples int64 `json:"live_tuples"`
+	DeadTuples int64 `json:"dead_tuples"`
+	// TableBytes is the size of the table including TOAST, excluding indexes
+	TableBytes int64 `json:"table_bytes"`
+	// IndexBytes is the size of all the indexes of the table
+	IndexBytes int64 `json:"index_bytes"`
+	// LastAutovacuum and LastAutoanalyze are nil if they never ran
+	LastAutovacuum  *time.Time `json:"last_autovacuum"`
+	LastAutoanalyze *time.Time `json:"last_autoanalyze"`
+	// SuggestedIndexes are the indexes recommended by SuggestIndexes
+	SuggestedIndexes []IndexSuggestion `json:"suggested_indexes,omitempty"`
+}
+
+// Stats returns storage statistics of the table of model, read from the primary, with the indexes SuggestIndexes recommends.
+func (p *SQL) Stats(ctx context.Context, model interface{}) (*ModelStats, error) {
+	table := tableOf(model)
+
+	stats := &ModelStats{Table: unqualifiedName(table)}
+	if _, err := p.db.QueryOneContext(ctx, stats, `
+		SELECT c.reltuples::bigint AS row_estimate,
+			coalesce(s.n_live_tup, 0) AS live_tuples,
+			coalesce(s.n_dead_tup, 0) AS dead_tuples,
+			pg_table_size(c.oid) AS table_bytes,
+			pg_indexes_size(c.oid) AS index_bytes,
+			s.last_autovacuum,
+			s.last_autoanalyze
+		FROM pg_class c
+		LEFT JOIN pg_stat_user_tables s ON s.relid = c.oid
+		WHERE c.oid = to_regclass(?)`, string(table.SQLName)); err != nil {
+		if err == pg.ErrNoRows {
+			return nil, fmt.Errorf("table %s does not exist", table.SQLName)
+		}
+
+		return nil, err
+	}
+
+	suggestions, err := p.SuggestIndexes(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	stats.SuggestedIndexes = suggestions
+
+	return stats, nil
+}
@@ -0,0 +1,50 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+)
+
+type statOrder struct {
+	tableName struct{} `pg:"test_stat_orders"`
+
+	ID    int64
+	Total int `pg:",use_zero"`
+}
+
+type unknownStatOrder struct {
+	tableName struct{} `pg:"test_stat_orders_missing"`
+
+	ID int64
+}
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*statOrder)(nil))
+
+	if _, err := p.db.ExecContext(ctx, "INSERT INTO test_stat_orders (id, total) SELECT i, i FROM generate_series(1, 100) i"); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+	if _, err := p.db.ExecContext(ctx, "ANALYZE test_stat_orders"); err != nil {
+		t.Fatalf("ANALYZE: %v", err)
+	}
+
+	stats, err := p.Stats(ctx, (*statOrder)(nil))
+	if err != nil {
+		t.Fatalf("Stats(): %v", err)
+	}
+
+	if stats.Table != "test_stat_orders" || stats.RowEstimate != 100 {
+		t.Errorf("Stats() = %+v, want 100 rows estimated after ANALYZE", stats)
+	}
+
+	// The primary key index.
+	if stats.TableBytes <= 0 || stats.IndexBytes <= 0 {
+		t.Errorf("Stats() sizes = %d, %d, want positive", stats.TableBytes, stats.IndexBytes)
+	}
+
+	if _, err := p.Stats(ctx, (*unknownStatOrder)(nil)); err == nil {
+		t.Error("Stats() of a missing table succeeded")
+	}
+}
@@ -0,0 +1,137 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// streamChunkSize is the number of bytes read or written at once by ReadColumn and WriteColumn.
+const streamChunkSize = 1 << 20
+
+// Modes of lo_open.
+const (
+	loWrite = 0x20000
+	loRead  = 0x40000
+)
+
+// ReadColumn copies the large object referenced by the oid column of resource, identified by its primary key, to w in chunks,
+// so large payloads aren't buffered in memory, see WriteColumn. The chunks are read from the primary in a single
+// REPEATABLE READ transaction, not retried on conflicts since the chunks copied can't be taken back.
+// It returns the number of bytes copied, 0 if the column is NULL, pg.ErrNoRows if the resource doesn't exist or is soft-deleted.
+func (p *SQL) ReadColumn(ctx context.Context, resource Resource, column string, w io.Writer) (int64, error) {
+	var copied int64
+
+	err := p.runOnce(ctx, func(tx *pg.Tx) error {
+		if p.tx == nil {
+			if _, err := tx.ExecContext(ctx, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+				return err
+			}
+		}
+
+		var oid uint32
+		if err := tx.ModelContext(ctx, resource).ColumnExpr("coalesce(?, 0)", pg.Ident(column)).
+			WherePK().Select(pg.Scan(&oid)); err != nil {
+			return err
+		}
+
+		if oid == 0 {
+			return nil
+		}
+
+		var fd int
+		if _, err := tx.QueryOneContext(ctx, pg.Scan(&fd), "SELECT lo_open(?, ?)", oid, loRead); err != nil {
+			return err
+		}
+
+		for {
+			var chunk []byte
+			if _, err := tx.QueryOneContext(ctx, pg.Scan(&chunk), "SELECT loread(?, ?)", fd, streamChunkSize); err != nil {
+				return err
+			}
+
+			if len(chunk) == 0 {
+				return nil
+			}
+
+			n, err := w.Write(chunk)
+			copied += int64(n)
+			if err != nil {
+				return err
+			}
+		}
+	})
+
+	return copied, err
+}
+
+// WriteColumn stores the content of r in a new large object, written in chunks so large payloads aren't buffered in memory,
+// and references it from the oid column of resource, identified by its primary key, e.g. a uint32 field tagged `pg:",type:oid"`.
+// The large object previously referenced is unlinked. All happens in a single transaction, not retried on conflicts, r being consumed.
+// Hard deleting the resource doesn't unlink its large objects, see vacuumlo.
+// It returns the number of bytes written, pg.ErrNoRows if the resource doesn't exist or is soft-deleted.
+func (p *SQL) WriteColumn(ctx context.Context, resource Resource, column string, r io.Reader) (int64, error) {
+	if err := p.checkWrite(); err != nil {
+		return 0, err
+	}
+
+	if err := checkMutable(resource, []string{column}); err != nil {
+		return 0, err
+	}
+
+	var written int64
+
+	err := p.runOnce(ctx, func(tx *pg.Tx) error {
+		var old uint32
+		if err := tx.ModelContext(ctx, resource).ColumnExpr("coalesce(?, 0)", pg.Ident(column)).
+			WherePK().For("UPDATE").Select(pg.Scan(&old)); err != nil {
+			return err
+		}
+
+		var oid uint32
+		if _, err := tx.QueryOneContext(ctx, pg.Scan(&oid), "SELECT lo_create(0)"); err != nil {
+			return err
+		}
+
+		var fd int
+		if _, err := tx.QueryOneContext(ctx, pg.Scan(&fd), "SELECT lo_open(?, ?)", oid, loWrite); err != nil {
+			return err
+		}
+
+		chunk := make([]byte, streamChunkSize)
+		for {
+			n, err := io.ReadFull(r, chunk)
+			if n > 0 {
+				if _, err := tx.ExecContext(ctx, "SELECT lowrite(?, ?)", fd, chunk[:n]); err != nil {
+					return err
+				}
+
+				written += int64(n)
+			}
+
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.ModelContext(ctx, resource).Set("? = ?", pg.Ident(column), oid).WherePK().Update(); err != nil {
+			return err
+		}
+
+		if old != 0 {
+			if _, err := tx.ExecContext(ctx, "SELECT lo_unlink(?)", old); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return written, err
+}
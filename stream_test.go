@@ -0,0 +1,71 @@
+package persistsql
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+)
+
+type streamedFile struct {
+	tableName struct{} `pg:"test_streamed_files"`
+
+	ID      int64  `pg:",pk"`
+	Content uint32 `pg:",type:oid"`
+}
+
+func TestWriteReadColumn(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*streamedFile)(nil))
+
+	file := &streamedFile{ID: 1}
+	if _, err := p.db.ModelContext(ctx, file).Insert(); err != nil {
+		t.Fatalf("Insert(): %v", err)
+	}
+
+	var buf bytes.Buffer
+	if n, err := p.ReadColumn(ctx, file, "content", &buf); err != nil || n != 0 {
+		t.Fatalf("ReadColumn() of NULL = %d, %v, want 0, nil", n, err)
+	}
+
+	countObjects := func() int {
+		t.Helper()
+
+		var n int
+		if _, err := p.db.QueryOneContext(ctx, pg.Scan(&n), "SELECT count(*) FROM pg_largeobject_metadata"); err != nil {
+			t.Fatalf("count large objects: %v", err)
+		}
+
+		return n
+	}
+	objects := countObjects()
+
+	for i, size := range []int{streamChunkSize*2 + 17, 5} {
+		content := bytes.Repeat([]byte{byte(i + 1), 2, 3}, size/3+1)[:size]
+
+		n, err := p.WriteColumn(ctx, file, "content", bytes.NewReader(content))
+		if err != nil || n != int64(size) {
+			t.Fatalf("WriteColumn(%d bytes) = %d, %v", size, n, err)
+		}
+
+		buf.Reset()
+		n, err = p.ReadColumn(ctx, file, "content", &buf)
+		if err != nil || n != int64(size) {
+			t.Fatalf("ReadColumn() = %d, %v, want %d", n, err, size)
+		}
+
+		if !bytes.Equal(buf.Bytes(), content) {
+			t.Errorf("ReadColumn() returned different content after writing %d bytes", size)
+		}
+
+		if got := countObjects(); got != objects+1 {
+			t.Errorf("%d large objects after writing %d bytes, want %d, the previous one being unlinked", got, size, objects+1)
+		}
+	}
+
+	if _, err := p.WriteColumn(ctx, &streamedFile{ID: 2}, "content", bytes.NewReader(nil)); err != pg.ErrNoRows {
+		t.Errorf("WriteColumn() of a missing resource = %v, want pg.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,110 @@
+package persistsql
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// SubscriptionEvent is sent by Subscribe: the first one holds the snapshot, the following ones a change.
+type SubscriptionEvent struct {
+	// Snapshot holds the matching resources when subscribing, on the first event only
+	Snapshot []Resource
+	// Key is the primary key of the changed resource
+	Key string
+	// Resource is the created or updated resource, nil if it was deleted or no longer matches
+	Resource Resource
+	// Err is set on the last event if the subscription failed
+	Err error
+}
+
+// Subscribe returns a snapshot of the resources of the collection of model, a pointer to a model, selected by queryHook as with ListResources,
+// followed by their changes, as change events arrive, see Changes: each changed resource is reloaded with queryHook to decide whether it matches.
+// Removals are only sent for resources which matched before. The channel is closed when ctx is done or the subscription fails or lags,
+// the subscriber then subscribes again. It's meant for live lists, the collection must have a single column primary key.
+// Resources are read from the primary, see PrimaryRead.
+func (p *SQL) Subscribe(ctx context.Context, model interface{}, queryHook QueryHook) (<-chan SubscriptionEvent, error) {
+	table := tableOf(model)
+	if len(table.PKs) != 1 {
+		return nil, errCompositeKey
+	}
+
+	if queryHook == nil {
+		queryHook = func(*orm.Query) {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	// Change events are published by the primary, replicas may not have the changes yet.
+	ctx = WithCallOptions(ctx, PrimaryRead())
+
+	changes, err := p.Changes(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	list := reflect.New(reflect.SliceOf(reflect.PtrTo(table.Type)))
+	if _, err := p.ListResources(ctx, list.Interface(), ListOptions{}, queryHook); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	snapshot := make([]Resource, list.Elem().Len())
+	known := map[string]bool{}
+	for i := range snapshot {
+		snapshot[i] = list.Elem().Index(i).Interface()
+		known[primaryKey(snapshot[i])] = true
+	}
+
+	events := make(chan SubscriptionEvent)
+	go func() {
+		defer close(events)
+		defer cancel()
+
+		send := func(event SubscriptionEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if !send(SubscriptionEvent{Snapshot: snapshot}) {
+			return
+		}
+
+		name := unqualifiedName(table)
+		for change := range changes {
+			if change.Table != name {
+				continue
+			}
+
+			res, err := p.getByKey(ctx, table, change.Key, queryHook)
+			if err != nil {
+				send(SubscriptionEvent{Key: change.Key, Err: err})
+				return
+			}
+
+			if res == nil && !known[change.Key] {
+				continue
+			}
+
+			known[change.Key] = res != nil
+			if !send(SubscriptionEvent{Key: change.Key, Resource: res}) {
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// getByKey returns the resource of table with the single column primary key key, selected by queryHook, nil if there's none.
+func (p *SQL) getByKey(ctx context.Context, table *orm.Table, key string, queryHook QueryHook) (Resource, error) {
+	return p.GetResource(ctx, reflect.New(table.Type).Interface(), false, func(query *orm.Query) {
+		queryHook(query)
+		query.Where("?TableAlias.? = ?", table.PKs[0].Column, key)
+	})
+}
@@ -0,0 +1,88 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+func TestSubscribeCompositeKey(t *testing.T) {
+	if _, err := (&SQL{}).Subscribe(context.Background(), (*versionedNode)(nil), nil); err != errCompositeKey {
+		t.Errorf("Subscribe() of a composite key = %v, want errCompositeKey", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := testSQL(t)
+	testTables(t, p.db, (*trashedNote)(nil))
+
+	created, err := p.CreateResource(ctx, &trashedNote{Folder: "inbox"})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	first := created.(*trashedNote)
+
+	events, err := p.Subscribe(ctx, (*trashedNote)(nil), func(query *orm.Query) {
+		query.Where("folder = ?", "inbox")
+	})
+	if err != nil {
+		t.Fatalf("Subscribe(): %v", err)
+	}
+
+	next := func() SubscriptionEvent {
+		t.Helper()
+
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatal("subscription closed")
+			}
+			if event.Err != nil {
+				t.Fatalf("subscription failed: %v", event.Err)
+			}
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no subscription event")
+		}
+
+		return SubscriptionEvent{}
+	}
+
+	if snapshot := next().Snapshot; len(snapshot) != 1 || snapshot[0].(*trashedNote).ID != first.ID {
+		t.Fatalf("snapshot = %v, want the inbox note", snapshot)
+	}
+
+	// Changes to resources never matching aren't sent.
+	if _, err := p.CreateResource(ctx, &trashedNote{Folder: "archive"}); err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+
+	created, err = p.CreateResource(ctx, &trashedNote{Folder: "inbox"})
+	if err != nil {
+		t.Fatalf("CreateResource(): %v", err)
+	}
+	second := created.(*trashedNote)
+
+	if event := next(); event.Key != second.ID.String() || event.Resource == nil {
+		t.Errorf("event = %+v, want the created inbox note", event)
+	}
+
+	// A resource no longer matching is removed.
+	first.Folder = "archive"
+	if _, err := p.UpdateResource(ctx, first, []string{"folder"}, nil); err != nil {
+		t.Fatalf("UpdateResource(): %v", err)
+	}
+
+	if event := next(); event.Key != first.ID.String() || event.Resource != nil {
+		t.Errorf("event = %+v, want the archived note removed", event)
+	}
+
+	cancel()
+	for range events {
+	}
+}
@@ -0,0 +1,96 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// IndexSuggestion recommends indexing a filter field of a model.
+type IndexSuggestion struct {
+	Column string `json:"column"`
+	// Calls is the number of statements on the table filtering on the column, from pg_stat_statements, -1 if it isn't installed
+	Calls int64 `json:"calls"`
+	// Index is the migration step building the index
+	Index CreateIndexConcurrently `json:"index"`
+}
+
+// tableScans are the scan statistics of a table.
+type tableScans struct {
+	SeqScan int64
+	IdxScan int64
+}
+
+// SuggestIndexes recommends indexes on the filter fields of model, the fields not tagged `filter:"-"`, which aren't the leading column
+// of an index yet. Nothing is suggested unless sequential scans of the table outnumber index scans, per pg_stat_user_tables.
+// If pg_stat_statements is installed, only the columns this package's statements filter on are suggested, most used first.
+func (p *SQL) SuggestIndexes(ctx context.Context, model interface{}) ([]IndexSuggestion, error) {
+	table := tableOf(model)
+	name := string(table.SQLName)
+
+	var scans tableScans
+	if _, err := p.db.QueryOneContext(ctx, &scans, `
+		SELECT coalesce(seq_scan, 0) AS seq_scan, coalesce(idx_scan, 0) AS idx_scan
+		FROM pg_stat_user_tables WHERE relid = to_regclass(?)`, name); err != nil {
+		if err == pg.ErrNoRows {
+			return nil, fmt.Errorf("table %s does not exist", table.SQLName)
+		}
+
+		return nil, err
+	}
+
+	if scans.SeqScan <= scans.IdxScan {
+		return nil, nil
+	}
+
+	var indexed []string
+	if _, err := p.db.QueryContext(ctx, &indexed, `
+		SELECT a.attname FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = i.indkey[0]
+		WHERE i.indrelid = to_regclass(?)`, name); err != nil {
+		return nil, err
+	}
+
+	var statements bool
+	if _, err := p.db.QueryOneContext(ctx, pg.Scan(&statements), "SELECT to_regclass('pg_stat_statements') IS NOT NULL"); err != nil {
+		return nil, err
+	}
+
+	var suggestions []IndexSuggestion
+	for _, field := range table.Fields {
+		if field.Field.Tag.Get("filter") == "-" || contains(indexed, field.SQLName) {
+			continue
+		}
+
+		calls := int64(-1)
+		if statements {
+			if _, err := p.db.QueryOneContext(ctx, pg.Scan(&calls), `
+				SELECT coalesce(sum(calls), 0)::bigint FROM pg_stat_statements
+				WHERE strpos(query, ?) > 0 AND query ~ ?`,
+				"FROM "+name+" ", `WHERE .*\m`+field.SQLName+`\M`); err != nil {
+				return nil, err
+			}
+
+			if calls == 0 {
+				continue
+			}
+		}
+
+		suggestions = append(suggestions, IndexSuggestion{
+			Column: field.SQLName,
+			Calls:  calls,
+			Index: CreateIndexConcurrently{
+				Name:    unqualifiedName(table) + "_" + field.SQLName + "_idx",
+				Table:   strings.ReplaceAll(name, `"`, ""),
+				Columns: []string{quoteIdent(field.SQLName)},
+			},
+		})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool { return suggestions[i].Calls > suggestions[j].Calls })
+
+	return suggestions, nil
+}
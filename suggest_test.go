@@ -0,0 +1,80 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+type suggestedOrder struct {
+	tableName struct{} `pg:"persistsql_test_billing.suggested_orders"`
+
+	ID    int64
+	Total int    `pg:",use_zero"`
+	Note  string `filter:"-"`
+}
+
+func TestSuggestIndexesQualified(t *testing.T) {
+	p := testSQL(t)
+	ctx := context.Background()
+
+	if _, err := p.db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS persistsql_test_billing"); err != nil {
+		t.Fatalf("CREATE SCHEMA: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = p.db.ExecContext(ctx, "DROP SCHEMA IF EXISTS persistsql_test_billing CASCADE")
+	})
+	testTables(t, p.db, (*suggestedOrder)(nil))
+
+	for i := 0; i < 10; i++ {
+		if _, err := p.db.ExecContext(ctx, "SELECT * FROM persistsql_test_billing.suggested_orders WHERE total = ?", i); err != nil {
+			t.Fatalf("SELECT: %v", err)
+		}
+	}
+
+	// The statistics are flushed asynchronously.
+	var suggestions []IndexSuggestion
+	for deadline := time.Now().Add(5 * time.Second); len(suggestions) == 0; time.Sleep(200 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Skip("table statistics not flushed")
+		}
+
+		if _, err := p.db.ExecContext(ctx, "SELECT pg_stat_clear_snapshot()"); err != nil {
+			t.Fatalf("pg_stat_clear_snapshot(): %v", err)
+		}
+
+		var err error
+		if suggestions, err = p.SuggestIndexes(ctx, (*suggestedOrder)(nil)); err != nil {
+			t.Fatalf("SuggestIndexes(): %v", err)
+		}
+	}
+
+	var total *IndexSuggestion
+	for i := range suggestions {
+		if suggestions[i].Column == "note" {
+			t.Errorf("suggested the non filter field note")
+		}
+		if suggestions[i].Column == "total" {
+			total = &suggestions[i]
+		}
+	}
+
+	if total == nil {
+		t.Fatalf("SuggestIndexes() = %+v, want total", suggestions)
+	}
+
+	if total.Index.Table != "persistsql_test_billing.suggested_orders" {
+		t.Errorf("suggested index on %s, want the qualified table", total.Index.Table)
+	}
+
+	if err := total.Index.Apply(ctx, p.db); err != nil {
+		t.Fatalf("Apply(): %v", err)
+	}
+
+	var exists bool
+	if _, err := p.db.QueryOneContext(ctx, pg.Scan(&exists), "SELECT to_regclass(?) IS NOT NULL", "persistsql_test_billing."+total.Index.Name); err != nil || !exists {
+		t.Errorf("index %s in the schema of the table: %v, %v", total.Index.Name, exists, err)
+	}
+}
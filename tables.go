@@ -0,0 +1,25 @@
+package persistsql
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// ensureTable creates the table of model, one of the package's own bookkeeping tables, if it doesn't exist yet.
+// The table is created outside of any transaction, once per persistence layer and model.
+func (p *SQL) ensureTable(ctx context.Context, model interface{}) error {
+	typ := reflect.TypeOf(model)
+	if _, ok := p.tables.Load(typ); ok {
+		return nil
+	}
+
+	if err := p.db.ModelContext(ctx, model).CreateTable(&orm.CreateTableOptions{IfNotExists: true}); err != nil {
+		return err
+	}
+
+	p.tables.Store(typ, struct{}{})
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TracedQuery is a query recorded by an SQLTrace.
+type TracedQuery struct {
+	// Query is the rendered SQL, go-pg binds the parameters into it
+	Query string
+	// Params of the query, as given to go-pg, for raw queries
+	Params []interface{}
+	// Duration of the query
+	Duration time.Duration
+	// Err returned by the database, if any
+	Err error
+}
+
+// SQLTrace records the queries run with a context, see WithSQLTrace.
+type SQLTrace struct {
+	mu      sync.Mutex
+	queries []TracedQuery
+}
+
+// Queries returns the queries recorded so far, in order.
+func (t *SQLTrace) Queries() []TracedQuery {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]TracedQuery(nil), t.queries...)
+}
+
+// record appends q to the trace.
+func (t *SQLTrace) record(q TracedQuery) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.queries = append(t.queries, q)
+}
+
+type sqlTraceKey struct{}
+
+// WithSQLTrace returns a copy of ctx recording the queries run with it into the returned trace, for debugging,
+// e.g. to see exactly what a QueryHook produced. Errors returned by the database for these queries are wrapped in a QueryError.
+func WithSQLTrace(ctx context.Context) (context.Context, *SQLTrace) {
+	trace := &SQLTrace{}
+
+	return context.WithValue(ctx, sqlTraceKey{}, trace), trace
+}
+
+// sqlTrace returns the trace of ctx, nil if there's none.
+func sqlTrace(ctx context.Context) *SQLTrace {
+	trace, _ := ctx.Value(sqlTraceKey{}).(*SQLTrace)
+
+	return trace
+}
+
+// QueryError is an error returned by the database for a query run with a traced context, see WithSQLTrace.
+type QueryError struct {
+	// Query is the rendered SQL
+	Query string
+	// Err returned by the database
+	Err error
+}
+
+// Error implements error.
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%v, query: %s", e.Err, e.Query)
+}
+
+// Unwrap returns the error returned by the database.
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,84 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+func TestSQLTraceHook(t *testing.T) {
+	h := &queryHook{observers: &observers{}, txLimits: &txLimits{}}
+
+	errDB := errors.New("relation does not exist")
+	event := &pg.QueryEvent{StartTime: time.Now(), Query: "SELECT * FROM missing WHERE id = 1", Err: errDB}
+
+	if err := h.AfterQuery(context.Background(), event); err != nil {
+		t.Errorf("AfterQuery() without trace = %v, want the error left alone", err)
+	}
+
+	ctx, trace := WithSQLTrace(context.Background())
+	if sqlTrace(ctx) != trace || sqlTrace(context.Background()) != nil {
+		t.Fatal("sqlTrace() doesn't return the trace of the context")
+	}
+
+	err := h.AfterQuery(ctx, event)
+
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) || !errors.Is(err, errDB) {
+		t.Fatalf("AfterQuery() = %v, want a QueryError wrapping the database error", err)
+	}
+
+	queryErr = &QueryError{Query: "SELECT * FROM missing WHERE id = 1", Err: errDB}
+	if msg := queryErr.Error(); msg != "relation does not exist, query: SELECT * FROM missing WHERE id = 1" {
+		t.Errorf("Error() = %q", msg)
+	}
+
+	event = &pg.QueryEvent{StartTime: time.Now(), Query: "SELECT 1"}
+	if err := h.AfterQuery(ctx, event); err != nil {
+		t.Errorf("AfterQuery() of a successful query = %v", err)
+	}
+
+	queries := trace.Queries()
+	if len(queries) != 2 || queries[0].Err != errDB || queries[1].Err != nil {
+		t.Errorf("Queries() = %+v, want both queries in order", queries)
+	}
+
+	// The queries returned are a copy.
+	queries[0].Err = nil
+	if trace.Queries()[0].Err == nil {
+		t.Error("Queries() returned the recorded slice")
+	}
+}
+
+func TestSQLTrace(t *testing.T) {
+	p := testSQL(t)
+	testTables(t, p.db, (*labelledOrder)(nil))
+
+	ctx, trace := WithSQLTrace(context.Background())
+
+	var orders []*labelledOrder
+	if _, err := p.ListResources(ctx, &orders, ListOptions{}, func(query *orm.Query) {
+		query.Where("total > ?", 10)
+	}); err != nil {
+		t.Fatalf("ListResources(): %v", err)
+	}
+
+	_, err := p.ListResources(ctx, &orders, ListOptions{}, func(query *orm.Query) {
+		query.Where("nope > ?", 10)
+	})
+
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) || !strings.Contains(queryErr.Query, "nope > 10") {
+		t.Errorf("ListResources() of a bad hook = %v, want a QueryError with the query", err)
+	}
+
+	queries := trace.Queries()
+	if len(queries) != 2 || !strings.Contains(queries[0].Query, "total > 10") || queries[1].Err == nil {
+		t.Errorf("Queries() = %+v, want both queries, the last failed", queries)
+	}
+}
@@ -0,0 +1,85 @@
+package persistsql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// ColumnTransition declares an online (expand-contract) replacement of the Old column of Table by the New one.
+// It's applied in three phases, each shipped as its own migration in successive releases:
+//   - Expand adds the new column and dual-writes it from the old one on every insert and update,
+//   - Backfill fills the new column of the existing rows in batches,
+//   - Contract stops dual-writing and drops the old column, once no deployed code reads it anymore.
+type ColumnTransition struct {
+	// Table holding the columns
+	Table string
+	// PK is the primary key column of the table, used to walk rows during backfill, defaults to "id".
+	PK string
+	// Old column name
+	Old string
+	// New column name
+	New string
+	// NewType is the SQL type of the new column
+	NewType string
+	// Convert is the SQL expression computing the new value, with %s standing for the old value, defaults to "%s".
+	Convert string
+	// BatchSize is the number of rows backfilled per transaction, defaults to 1000.
+	BatchSize int
+}
+
+// Expand returns the step adding the new column and the trigger dual-writing it.
+func (t ColumnTransition) Expand() Step {
+	function, call := t.function()
+	return Statements{
+		formatQuery("ALTER TABLE ? ADD COLUMN IF NOT EXISTS ? ?", pg.Ident(t.Table), pg.Ident(t.New), pg.Safe(t.NewType)),
+		formatQuery("CREATE OR REPLACE FUNCTION ? RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN NEW.? := ?; RETURN NEW; END $$",
+			call, pg.Ident(t.New), pg.Safe(t.convert("NEW."))),
+		formatQuery("DROP TRIGGER IF EXISTS ? ON ?", function, pg.Ident(t.Table)),
+		formatQuery("CREATE TRIGGER ? BEFORE INSERT OR UPDATE ON ? FOR EACH ROW EXECUTE FUNCTION ?", function, pg.Ident(t.Table), call),
+	}
+}
+
+// Backfill returns the step filling the new column of existing rows in batches, see DataMigration.
+func (t ColumnTransition) Backfill() Step {
+	return DataMigration{
+		Name:      fmt.Sprintf("backfill_%s_%s", t.Table, t.New),
+		Table:     t.Table,
+		PK:        t.PK,
+		Set:       quoteIdent(t.New) + " = " + t.convert(""),
+		BatchSize: t.BatchSize,
+	}
+}
+
+// Contract returns the step removing the dual-write trigger and dropping the old column.
+func (t ColumnTransition) Contract() Step {
+	function, call := t.function()
+	return Statements{
+		formatQuery("DROP TRIGGER IF EXISTS ? ON ?", function, pg.Ident(t.Table)),
+		formatQuery("DROP FUNCTION IF EXISTS ?", call),
+		formatQuery("ALTER TABLE ? DROP COLUMN IF EXISTS ?", pg.Ident(t.Table), pg.Ident(t.Old)),
+	}
+}
+
+// convert returns the expression computing the new value from the old column, qualified by prefix.
+func (t ColumnTransition) convert(prefix string) string {
+	format := t.Convert
+	if format == "" {
+		format = "%s"
+	}
+
+	return fmt.Sprintf(format, prefix+quoteIdent(t.Old))
+}
+
+// function returns the name of the dual-write trigger function, named after the unqualified table, and its call with no arguments,
+// qualified by the schema of the table if it is.
+func (t ColumnTransition) function() (name pg.Ident, call pg.Safe) {
+	schema, table := "", t.Table
+	if i := strings.LastIndexByte(t.Table, '.'); i >= 0 {
+		schema, table = t.Table[:i+1], t.Table[i+1:]
+	}
+
+	name = pg.Ident(fmt.Sprintf("persistsql_transition_%s_%s", table, t.New))
+	return name, pg.Safe(quoteIdent(schema+string(name)) + "()")
+}
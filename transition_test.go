@@ -0,0 +1,88 @@
+package persistsql
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+)
+
+func TestColumnTransitionStatements(t *testing.T) {
+	transition := ColumnTransition{Table: "billing.orders", Old: "price", New: "price_cents", NewType: "bigint", Convert: "(%s * 100)::bigint"}
+
+	expand := transition.Expand().(Statements)
+	if len(expand) != 4 {
+		t.Fatalf("Expand() = %d statements, want 4", len(expand))
+	}
+
+	if want := `ALTER TABLE "billing"."orders" ADD COLUMN IF NOT EXISTS "price_cents" bigint`; expand[0] != want {
+		t.Errorf("Expand()[0] = %s, want %s", expand[0], want)
+	}
+
+	// The trigger function lives in the schema of the table.
+	if !strings.Contains(expand[1], `"billing"."persistsql_transition_orders_price_cents"()`) ||
+		!strings.Contains(expand[1], `NEW."price_cents" := (NEW."price" * 100)::bigint`) {
+		t.Errorf("Expand()[1] = %s", expand[1])
+	}
+
+	backfill := transition.Backfill().(DataMigration)
+	if backfill.Set != `"price_cents" = ("price" * 100)::bigint` || backfill.Name != "backfill_billing.orders_price_cents" {
+		t.Errorf("Backfill() = %+v", backfill)
+	}
+
+	contract := transition.Contract().(Statements)
+	if want := `ALTER TABLE "billing"."orders" DROP COLUMN IF EXISTS "price"`; contract[len(contract)-1] != want {
+		t.Errorf("Contract() = %v, want to end with %s", contract, want)
+	}
+}
+
+func TestColumnTransition(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+
+	if _, err := p.db.ExecContext(ctx, "DROP TABLE IF EXISTS test_transitioned"); err != nil {
+		t.Fatalf("DROP TABLE: %v", err)
+	}
+	if _, err := p.db.ExecContext(ctx, "CREATE TABLE test_transitioned (id bigserial PRIMARY KEY, price numeric NOT NULL)"); err != nil {
+		t.Fatalf("CREATE TABLE: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = p.db.ExecContext(ctx, "DROP TABLE IF EXISTS test_transitioned")
+		_, _ = p.db.ExecContext(ctx, "DROP TABLE IF EXISTS schema_migration_progress")
+	})
+
+	if _, err := p.db.ExecContext(ctx, "INSERT INTO test_transitioned (price) SELECT n FROM generate_series(1, 5) n"); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+
+	transition := ColumnTransition{Table: "test_transitioned", Old: "price", New: "price_cents", NewType: "bigint",
+		Convert: "(%s * 100)::bigint", BatchSize: 2}
+
+	if err := transition.Expand().Apply(ctx, p.db); err != nil {
+		t.Fatalf("Expand(): %v", err)
+	}
+
+	// Rows written after the expansion are dual-written.
+	if _, err := p.db.ExecContext(ctx, "INSERT INTO test_transitioned (price) VALUES (6)"); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+
+	if err := transition.Backfill().Apply(ctx, p.db); err != nil {
+		t.Fatalf("Backfill(): %v", err)
+	}
+
+	var wrong int
+	if _, err := p.db.QueryOneContext(ctx, pg.Scan(&wrong),
+		"SELECT count(*) FROM test_transitioned WHERE price_cents IS DISTINCT FROM price * 100"); err != nil || wrong != 0 {
+		t.Errorf("%d rows not converted: %v", wrong, err)
+	}
+
+	if err := transition.Contract().Apply(ctx, p.db); err != nil {
+		t.Fatalf("Contract(): %v", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, "INSERT INTO test_transitioned (price_cents) VALUES (700)"); err != nil {
+		t.Errorf("INSERT after Contract(): %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// TraverseOptions configures Traverse.
+type TraverseOptions struct {
+	// EdgeTable holds the edges, e.g. followers
+	EdgeTable string
+	// FromColumn holds the primary key of the edge source, defaults to "from_id".
+	FromColumn string
+	// ToColumn holds the primary key of the edge target, defaults to "to_id".
+	ToColumn string
+	// MaxDepth is the maximum number of edges followed from the start, defaults to 1.
+	MaxDepth int
+	// MaxFanOut limits the number of edges followed from each resource, zero for no limit.
+	MaxFanOut int
+	// Limit caps the number of resources returned, zero for no limit.
+	Limit int
+	// ShowDeleted allows soft-deleted resources to be returned, they're traversed in any case.
+	ShowDeleted bool
+}
+
+// Traverse loads into resources, a pointer to a slice of models, the resources reachable from the one whose primary key is start
+// by following the edges of an edge table, nearest first. The start resource isn't included.
+// The graph is walked breadth first with one query per level, cycles are followed once.
+func (p *SQL) Traverse(ctx context.Context, resources interface{}, start interface{}, opts TraverseOptions) error {
+	table := tableOf(resources)
+	if len(table.PKs) != 1 {
+		return errCompositeKey
+	}
+
+	from, to := opts.FromColumn, opts.ToColumn
+	if from == "" {
+		from = "from_id"
+	}
+	if to == "" {
+		to = "to_id"
+	}
+
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = 1
+	}
+
+	db := p.reader(ctx)
+	visited := map[string]int{fmt.Sprint(start): -1}
+	frontier := []interface{}{start}
+	var reached []string
+
+	for depth := 0; depth < maxDepth && len(frontier) > 0; depth++ {
+		var next []string
+		if _, err := db.QueryContext(ctx, &next, `
+			SELECT e.id FROM (
+				SELECT ?0::text AS id, row_number() OVER (PARTITION BY ?1 ORDER BY ?0) AS n
+				FROM ?2 WHERE ?1 IN (?3)
+			) e
+			WHERE ?4 = 0 OR e.n <= ?4
+			GROUP BY e.id ORDER BY min(e.n), e.id`,
+			pg.Ident(to), pg.Ident(from), pg.Ident(opts.EdgeTable), pg.In(frontier), opts.MaxFanOut); err != nil {
+			return err
+		}
+
+		frontier = frontier[:0]
+		for _, key := range next {
+			if _, ok := visited[key]; ok {
+				continue
+			}
+
+			visited[key] = len(reached)
+			reached = append(reached, key)
+			frontier = append(frontier, key)
+		}
+
+		if opts.Limit > 0 && len(reached) >= opts.Limit {
+			reached = reached[:opts.Limit]
+			break
+		}
+	}
+
+	if len(reached) == 0 {
+		return nil
+	}
+
+	query := db.ModelContext(ctx, resources).Where("?TableAlias.? IN (?)", table.PKs[0].Column, pg.In(reached))
+	ShowDeleted(query, opts.ShowDeleted)
+
+	if err := query.Select(); err != nil {
+		return err
+	}
+
+	slice := reflect.Indirect(reflect.ValueOf(resources))
+	sort.SliceStable(slice.Interface(), func(i, j int) bool {
+		return visited[primaryKey(slice.Index(i).Interface())] < visited[primaryKey(slice.Index(j).Interface())]
+	})
+
+	return p.afterLoad(ctx, resources)
+}
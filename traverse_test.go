@@ -0,0 +1,79 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+type graphUser struct {
+	tableName struct{} `pg:"test_graph_users"`
+
+	ID   int64
+	Name string
+}
+
+// graphIDs returns the IDs of users.
+func graphIDs(users []*graphUser) string {
+	ids := make([]int64, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+
+	return fmt.Sprint(ids)
+}
+
+func TestTraverseCompositeKey(t *testing.T) {
+	var nodes []*versionedNode
+	if err := (&SQL{}).Traverse(context.Background(), &nodes, 1, TraverseOptions{EdgeTable: "edges"}); err != errCompositeKey {
+		t.Errorf("Traverse() of a composite key = %v, want errCompositeKey", err)
+	}
+}
+
+func TestTraverse(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	testTables(t, p.db, (*graphUser)(nil))
+
+	if _, err := p.db.ExecContext(ctx, "CREATE TABLE test_graph_follows (follower bigint, followed bigint)"); err != nil {
+		t.Fatalf("CREATE TABLE: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = p.db.ExecContext(ctx, "DROP TABLE IF EXISTS test_graph_follows")
+	})
+
+	// 1 follows 2, 3 and 4, 2 follows 5 who follows 1 back, 3 follows 6.
+	if _, err := p.db.ExecContext(ctx, `
+		INSERT INTO test_graph_users (id, name) SELECT i, 'user ' || i FROM generate_series(1, 6) i;
+		INSERT INTO test_graph_follows VALUES (1, 2), (1, 3), (1, 4), (2, 5), (5, 1), (3, 6)`); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+
+	opts := TraverseOptions{EdgeTable: "test_graph_follows", FromColumn: "follower", ToColumn: "followed"}
+	for _, tt := range []struct {
+		depth, fanOut, limit int
+		want                 string
+	}{
+		{0, 0, 0, "[2 3 4]"},
+		{3, 0, 0, "[2 3 4 5 6]"},
+		{3, 2, 0, "[2 3 5 6]"},
+		{3, 0, 2, "[2 3]"},
+	} {
+		opts.MaxDepth, opts.MaxFanOut, opts.Limit = tt.depth, tt.fanOut, tt.limit
+
+		var users []*graphUser
+		if err := p.Traverse(ctx, &users, 1, opts); err != nil {
+			t.Errorf("Traverse(%+v): %v", opts, err)
+			continue
+		}
+
+		if graphIDs(users) != tt.want {
+			t.Errorf("Traverse(%+v) = %s, want %s", opts, graphIDs(users), tt.want)
+		}
+	}
+
+	var users []*graphUser
+	if err := p.Traverse(ctx, &users, 6, opts); err != nil || len(users) != 0 {
+		t.Errorf("Traverse() from a leaf = %s, %v, want none", graphIDs(users), err)
+	}
+}
@@ -0,0 +1,120 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// errCompositeKey is returned by helpers requiring a single column primary key.
+var errCompositeKey = errors.New("model must have a single column primary key")
+
+// TreeOptions configures the tree helpers: Ancestors, Descendants and Subtree.
+type TreeOptions struct {
+	// ParentColumn references the parent row, defaults to "parent_id".
+	ParentColumn string
+	// PathColumn is an ltree column holding the path of each row, if set it's used instead of walking ParentColumn.
+	PathColumn string
+	// MaxDepth limits the number of levels returned, zero for no limit.
+	MaxDepth int
+	// ShowDeleted allows soft-deleted resources to be returned
+	ShowDeleted bool
+}
+
+func (opts TreeOptions) parentColumn() string {
+	if opts.ParentColumn == "" {
+		return "parent_id"
+	}
+
+	return opts.ParentColumn
+}
+
+// treeDirection is the direction a tree is walked.
+type treeDirection int
+
+const (
+	towardsRoot treeDirection = iota
+	towardsLeaves
+)
+
+// Ancestors loads into resources, a pointer to a slice of models, the ancestors of the resource whose primary key is id, nearest first.
+func (p *SQL) Ancestors(ctx context.Context, resources interface{}, id interface{}, opts TreeOptions) error {
+	return p.tree(ctx, resources, id, opts, towardsRoot, false)
+}
+
+// Descendants loads into resources, a pointer to a slice of models, the descendants of the resource whose primary key is id, level by level.
+func (p *SQL) Descendants(ctx context.Context, resources interface{}, id interface{}, opts TreeOptions) error {
+	return p.tree(ctx, resources, id, opts, towardsLeaves, false)
+}
+
+// Subtree loads into resources, a pointer to a slice of models, the resource whose primary key is id followed by its descendants, level by level.
+func (p *SQL) Subtree(ctx context.Context, resources interface{}, id interface{}, opts TreeOptions) error {
+	return p.tree(ctx, resources, id, opts, towardsLeaves, true)
+}
+
+// tree loads the rows reachable from id in direction, ordered by distance.
+func (p *SQL) tree(ctx context.Context, resources interface{}, id interface{}, opts TreeOptions, direction treeDirection, includeSelf bool) error {
+	table := tableOf(resources)
+	if len(table.PKs) != 1 {
+		return errCompositeKey
+	}
+
+	if err := p.treeQuery(ctx, resources, id, opts, direction, includeSelf).Select(); err != nil {
+		return err
+	}
+
+	return p.afterLoad(ctx, resources)
+}
+
+// treeQuery returns the query selecting the rows reachable from id in direction, the model must have a single column primary key.
+func (p *SQL) treeQuery(ctx context.Context, resources interface{}, id interface{}, opts TreeOptions, direction treeDirection, includeSelf bool) *orm.Query {
+	table := tableOf(resources)
+	pk := pg.Ident(table.PKs[0].SQLName)
+
+	var walk string
+	var params []interface{}
+	if opts.PathColumn != "" {
+		op := "@>"
+		if direction == towardsLeaves {
+			op = "<@"
+		}
+
+		walk = `SELECT c.?0 AS id, abs(nlevel(c.?1) - nlevel(r.?1)) AS depth
+			FROM ?2 AS c, ?2 AS r
+			WHERE r.?0 = ?3 AND c.?1 ?4 r.?1`
+		params = []interface{}{pk, pg.Ident(opts.PathColumn), table.SQLName, id, pg.Safe(op)}
+	} else {
+		join := "c.?1 = tree.id"
+		if direction == towardsRoot {
+			join = "c.?0 = tree.parent"
+		}
+
+		walk = `WITH RECURSIVE tree AS (
+				SELECT ?0 AS id, ?1 AS parent, 0 AS depth, ARRAY[?0] AS path FROM ?2 WHERE ?0 = ?3
+				UNION ALL
+				SELECT c.?0, c.?1, tree.depth + 1, tree.path || c.?0 FROM ?2 AS c JOIN tree ON ` + join + `
+				WHERE c.?0 <> ALL(tree.path) AND (?4 = 0 OR tree.depth < ?4)
+			)
+			SELECT id, depth FROM tree`
+		params = []interface{}{pk, pg.Ident(opts.parentColumn()), table.SQLName, id, opts.MaxDepth}
+	}
+
+	query := p.reader(ctx).ModelContext(ctx, resources).
+		Join("JOIN ("+walk+") AS _tree", params...).
+		JoinOn("_tree.id = ?TableAlias.?", pk).
+		OrderExpr("_tree.depth").
+		OrderExpr("?TableAlias.?", pk)
+	ShowDeleted(query, opts.ShowDeleted)
+
+	if !includeSelf {
+		query.Where("_tree.depth > 0")
+	}
+
+	if opts.MaxDepth > 0 {
+		query.Where("_tree.depth <= ?", opts.MaxDepth)
+	}
+
+	return query
+}
@@ -0,0 +1,106 @@
+package persistsql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+type treeNode struct {
+	tableName struct{} `pg:"test_tree_nodes"`
+
+	ID       int64
+	ParentID int64
+	Path     string `pg:"type:ltree"`
+}
+
+type versionedNode struct {
+	ID       int64 `pg:",pk"`
+	Revision int64 `pg:",pk"`
+}
+
+func TestTreeOptions(t *testing.T) {
+	if col := (TreeOptions{}).parentColumn(); col != "parent_id" {
+		t.Errorf("parentColumn() = %s, want parent_id", col)
+	}
+
+	if col := (TreeOptions{ParentColumn: "manager_id"}).parentColumn(); col != "manager_id" {
+		t.Errorf("parentColumn() = %s, want manager_id", col)
+	}
+
+	var nodes []*versionedNode
+	if err := (&SQL{}).Ancestors(context.Background(), &nodes, 1, TreeOptions{}); err != errCompositeKey {
+		t.Errorf("Ancestors() of a composite key = %v, want errCompositeKey", err)
+	}
+}
+
+// treeIDs returns the IDs of nodes.
+func treeIDs(nodes []*treeNode) string {
+	ids := make([]int64, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.ID)
+	}
+
+	return fmt.Sprint(ids)
+}
+
+func TestTree(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t)
+	if _, err := p.db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS ltree"); err != nil {
+		t.Skipf("ltree unavailable: %v", err)
+	}
+	testTables(t, p.db, (*treeNode)(nil))
+
+	// 1 ─┬─ 2 ── 4 ── 5
+	//    └─ 3
+	nodes := []*treeNode{
+		{ID: 1, Path: "1"},
+		{ID: 2, ParentID: 1, Path: "1.2"},
+		{ID: 3, ParentID: 1, Path: "1.3"},
+		{ID: 4, ParentID: 2, Path: "1.2.4"},
+		{ID: 5, ParentID: 4, Path: "1.2.4.5"},
+	}
+	if _, err := p.db.ModelContext(ctx, &nodes).Insert(); err != nil {
+		t.Fatalf("Insert(): %v", err)
+	}
+
+	for _, opts := range []TreeOptions{{}, {PathColumn: "path"}} {
+		for _, tt := range []struct {
+			name string
+			walk func(ctx context.Context, resources interface{}, id interface{}, opts TreeOptions) error
+			id   int64
+			max  int
+			want string
+		}{
+			{"Ancestors", p.Ancestors, 5, 0, "[4 2 1]"},
+			{"Ancestors", p.Ancestors, 5, 2, "[4 2]"},
+			{"Descendants", p.Descendants, 1, 0, "[2 3 4 5]"},
+			{"Descendants", p.Descendants, 2, 0, "[4 5]"},
+			{"Subtree", p.Subtree, 2, 1, "[2 4]"},
+			{"Descendants", p.Descendants, 3, 0, "[]"},
+		} {
+			opts.MaxDepth = tt.max
+
+			var got []*treeNode
+			if err := tt.walk(ctx, &got, tt.id, opts); err != nil {
+				t.Errorf("%s(%d, %+v): %v", tt.name, tt.id, opts, err)
+				continue
+			}
+
+			if treeIDs(got) != tt.want {
+				t.Errorf("%s(%d, %+v) = %s, want %s", tt.name, tt.id, opts, treeIDs(got), tt.want)
+			}
+		}
+	}
+
+	// A cycle doesn't loop forever.
+	if _, err := p.db.ExecContext(ctx, "UPDATE test_tree_nodes SET parent_id = 5 WHERE id = 1"); err != nil {
+		t.Fatalf("UPDATE: %v", err)
+	}
+
+	var ancestors []*treeNode
+	if err := p.Ancestors(ctx, &ancestors, 5, TreeOptions{}); err != nil || treeIDs(ancestors) != "[4 2 1]" {
+		t.Errorf("Ancestors() of a cycle = %s, %v, want [4 2 1]", treeIDs(ancestors), err)
+	}
+}
@@ -0,0 +1,63 @@
+package persistsql
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// WithTx returns a copy of p bound to tx: its reads and writes run in tx, which the caller commits or rolls back.
+// Tables created on demand, e.g. by MarkForDeletion, and DDL still use the database, outside of tx.
+func (p *SQL) WithTx(tx *pg.Tx) *SQL {
+	bound := *p
+	bound.tx = tx
+
+	return &bound
+}
+
+// Begin starts a transaction on the primary, to be bound with WithTx.
+func (p *SQL) Begin(ctx context.Context) (*pg.Tx, error) {
+	return p.db.BeginContext(ctx)
+}
+
+// RunInTransaction calls fn with a copy of p bound to a transaction, committed if fn returns nil and rolled back otherwise.
+// If p is already bound to a transaction, fn is called with p in that transaction. Conflicts are retried, see WithTxRetries.
+func (p *SQL) RunInTransaction(ctx context.Context, fn func(p *SQL) error) error {
+	if p.tx != nil {
+		return fn(p)
+	}
+
+	return p.inTransaction(ctx, caller(), p.retries.max, func(tx *pg.Tx) error {
+		return fn(p.WithTx(tx))
+	})
+}
+
+// runInTransaction calls fn in the transaction p is bound to, or else in a new transaction, retried on conflicts.
+// fn must reset the state it accumulates, it's called again on retries.
+func (p *SQL) runInTransaction(ctx context.Context, fn func(tx *pg.Tx) error) error {
+	if p.tx != nil {
+		return fn(p.tx)
+	}
+
+	return p.inTransaction(ctx, caller(), p.retries.max, fn)
+}
+
+// runOnce calls fn in the transaction p is bound to, or else in a new transaction which isn't retried on conflicts,
+// for functions with effects outside of the transaction, e.g. consuming a reader or calling a handler.
+func (p *SQL) runOnce(ctx context.Context, fn func(tx *pg.Tx) error) error {
+	if p.tx != nil {
+		return fn(p.tx)
+	}
+
+	return p.inTransaction(ctx, caller(), 0, fn)
+}
+
+// conn returns the transaction p is bound to, or else the primary database, labelling its queries, see WithQueryLabel.
+func (p *SQL) conn() orm.DB {
+	if p.tx != nil {
+		return labelled{p.tx}
+	}
+
+	return labelled{p.db}
+}
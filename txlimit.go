@@ -0,0 +1,133 @@
+package persistsql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// ErrTxLimit is wrapped by TxLimitError.
+var ErrTxLimit = errors.New("transaction limit exceeded")
+
+// TxLimitError is returned by a statement making a transaction exceed its limits, see WithTxLimits.
+type TxLimitError struct {
+	// Limit is the limit exceeded, "statements" or "rows"
+	Limit string
+	// Count is the number of statements or rows reached
+	Count int64
+	// Max is the number of statements or rows allowed
+	Max int64
+}
+
+// Error implements error.
+func (e *TxLimitError) Error() string {
+	return fmt.Sprintf("%s: %d %s, at most %d allowed", ErrTxLimit, e.Count, e.Limit, e.Max)
+}
+
+// Unwrap returns ErrTxLimit.
+func (e *TxLimitError) Unwrap() error {
+	return ErrTxLimit
+}
+
+// TxLimits caps the size of transactions.
+type TxLimits struct {
+	// Statements is the maximum number of statements run in a transaction, unlimited if zero
+	Statements int64
+	// Rows is the maximum number of rows written by the statements of a transaction, unlimited if zero
+	Rows int64
+}
+
+// txLimits tracks the size of the transactions of a persistence layer.
+type txLimits struct {
+	TxLimits
+	counts sync.Map
+}
+
+// txCount is the size of a transaction so far.
+type txCount struct {
+	statements int64
+	rows       int64
+}
+
+// WithTxLimits caps the size of the transactions started by a persistence layer, e.g. by RunInTransaction or UpdateResource,
+// guarding against hooks turning an update into a full table one. The statement exceeding a limit fails with a TxLimitError,
+// a statement which would be one too many isn't run, one writing too many rows is, but fails so that the transaction is rolled back.
+// Transactions started by the caller and bound with WithTx aren't limited.
+func WithTxLimits(limits TxLimits) Option {
+	return func(p *SQL) {
+		p.txLimits.TxLimits = limits
+	}
+}
+
+// track counts the statements of tx against the limits, until the returned func is called.
+func (l *txLimits) track(tx *pg.Tx) func() {
+	if l.Statements == 0 && l.Rows == 0 {
+		return func() {}
+	}
+
+	l.counts.Store(tx, &txCount{})
+
+	return func() {
+		l.counts.Delete(tx)
+	}
+}
+
+// before counts a statement about to run, returning a TxLimitError if it's one too many for its transaction.
+func (l *txLimits) before(event *pg.QueryEvent) error {
+	count := l.count(event)
+	if count == nil {
+		return nil
+	}
+
+	if n := atomic.AddInt64(&count.statements, 1); l.Statements > 0 && n > l.Statements {
+		return &TxLimitError{Limit: "statements", Count: n, Max: l.Statements}
+	}
+
+	return nil
+}
+
+// after counts the rows written by a statement, returning a TxLimitError if its transaction wrote too many.
+func (l *txLimits) after(event *pg.QueryEvent) error {
+	count := l.count(event)
+	if count == nil || l.Rows == 0 || event.Err != nil || event.Result == nil || isSelect(event) {
+		return nil
+	}
+
+	if n := atomic.AddInt64(&count.rows, int64(event.Result.RowsAffected())); n > l.Rows {
+		return &TxLimitError{Limit: "rows", Count: n, Max: l.Rows}
+	}
+
+	return nil
+}
+
+// count returns the size of the tracked transaction running the statement of event, nil if it isn't tracked.
+func (l *txLimits) count(event *pg.QueryEvent) *txCount {
+	tx, ok := event.DB.(*pg.Tx)
+	if !ok {
+		return nil
+	}
+
+	count, ok := l.counts.Load(tx)
+	if !ok {
+		return nil
+	}
+
+	return count.(*txCount)
+}
+
+// isSelect returns whether the statement of event only reads rows.
+func isSelect(event *pg.QueryEvent) bool {
+	switch q := event.Query.(type) {
+	case *orm.SelectQuery:
+		return true
+	case string:
+		return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(q)), "SELECT")
+	default:
+		return false
+	}
+}
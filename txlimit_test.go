@@ -0,0 +1,95 @@
+package persistsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// affected is an orm.Result of a statement writing n rows.
+type affected int
+
+func (affected) Model() orm.Model    { return nil }
+func (n affected) RowsAffected() int { return int(n) }
+func (affected) RowsReturned() int   { return 0 }
+
+func TestTxLimitsCount(t *testing.T) {
+	l := &txLimits{TxLimits: TxLimits{Statements: 2, Rows: 3}}
+	tx := &pg.Tx{}
+	untrack := l.track(tx)
+
+	write := &pg.QueryEvent{DB: tx, Query: "UPDATE orders SET total = 0", Result: affected(2)}
+	if err := l.before(write); err != nil {
+		t.Fatalf("before() = %v", err)
+	}
+	if err := l.after(write); err != nil {
+		t.Fatalf("after() = %v", err)
+	}
+
+	// Rows read aren't counted.
+	read := &pg.QueryEvent{DB: tx, Query: " select * from orders", Result: affected(10)}
+	if err := l.before(read); err != nil {
+		t.Fatalf("before() = %v", err)
+	}
+	if err := l.after(read); err != nil {
+		t.Errorf("after() of a select = %v", err)
+	}
+
+	err := l.before(write)
+
+	var limitErr *TxLimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "statements" || limitErr.Count != 3 || !errors.Is(err, ErrTxLimit) {
+		t.Fatalf("before() of a statement too many = %v, want a TxLimitError", err)
+	}
+
+	if msg := err.Error(); msg != "transaction limit exceeded: 3 statements, at most 2 allowed" {
+		t.Errorf("Error() = %q", msg)
+	}
+
+	if err := l.after(write); !errors.As(err, &limitErr) || limitErr.Limit != "rows" || limitErr.Count != 4 {
+		t.Errorf("after() of a row too many = %v, want a TxLimitError", err)
+	}
+
+	// Untracked transactions, e.g. bound with WithTx, aren't limited.
+	untrack()
+	if err := l.before(write); err != nil {
+		t.Errorf("before() of an untracked transaction = %v", err)
+	}
+	if err := l.before(&pg.QueryEvent{Query: "UPDATE orders SET total = 0"}); err != nil {
+		t.Errorf("before() outside of a transaction = %v", err)
+	}
+}
+
+func TestTxLimits(t *testing.T) {
+	ctx := context.Background()
+	p := testSQL(t, WithTxLimits(TxLimits{Rows: 2}))
+	testTables(t, p.db, (*labelledOrder)(nil))
+
+	if _, err := p.db.ExecContext(ctx, "INSERT INTO labelled_orders (id, total) SELECT i, 10 FROM generate_series(1, 3) i"); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+
+	err := p.RunInTransaction(ctx, func(p *SQL) error {
+		// A hook forgetting the primary key.
+		_, err := p.conn().ModelContext(ctx, (*labelledOrder)(nil)).Set("total = 0").Where("TRUE").Update()
+		return err
+	})
+	if !errors.Is(err, ErrTxLimit) {
+		t.Fatalf("RunInTransaction() = %v, want ErrTxLimit", err)
+	}
+
+	var zeroed int
+	if _, err := p.db.QueryOneContext(ctx, pg.Scan(&zeroed), "SELECT count(*) FROM labelled_orders WHERE total = 0"); err != nil || zeroed != 0 {
+		t.Errorf("%d orders zeroed, %v, want the update rolled back", zeroed, err)
+	}
+
+	if err := p.RunInTransaction(ctx, func(p *SQL) error {
+		_, err := p.conn().ModelContext(ctx, (*labelledOrder)(nil)).Set("total = 0").Where("id <= 2").Update()
+		return err
+	}); err != nil {
+		t.Errorf("RunInTransaction() within the limits = %v", err)
+	}
+}
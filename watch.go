@@ -0,0 +1,72 @@
+package persistsql
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// WatchResource returns the current version of a resource, identified by its single column primary key, followed by its updated versions
+// as its change events arrive, see Changes. Deletions send nil, an undeletion sends the resource again.
+// The channel is closed when ctx is done or watching fails or lags, the watcher then watches again.
+// It returns nil if the resource doesn't exist or is soft-deleted. Versions are read from the primary, see PrimaryRead.
+func (p *SQL) WatchResource(ctx context.Context, res Resource) (<-chan Resource, error) {
+	table := tableOf(res)
+	if len(table.PKs) != 1 {
+		return nil, errCompositeKey
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	// Change events are published by the primary, replicas may not have the changes yet.
+	ctx = WithCallOptions(ctx, PrimaryRead())
+
+	changes, err := p.Changes(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	key := primaryKey(res)
+	current, err := p.getByKey(ctx, table, key, func(*orm.Query) {})
+	if err != nil || current == nil {
+		cancel()
+		return nil, err
+	}
+
+	versions := make(chan Resource)
+	go func() {
+		defer close(versions)
+		defer cancel()
+
+		send := func(version Resource) bool {
+			select {
+			case versions <- version:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if !send(current) {
+			return
+		}
+
+		name := unqualifiedName(table)
+		for change := range changes {
+			if change.Table != name || change.Key != key {
+				continue
+			}
+
+			version, err := p.getByKey(ctx, table, key, func(*orm.Query) {})
+			if err != nil {
+				return
+			}
+
+			if !send(version) {
+				return
+			}
+		}
+	}()
+
+	return versions, nil
+}
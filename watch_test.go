@@ -0,0 +1,95 @@
+package persistsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWatchResource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := testSQL(t)
+	testTables(t, p.db, (*trashedNote)(nil))
+
+	missing := &trashedNote{}
+	missing.ID = uuid.New()
+	if versions, err := p.WatchResource(ctx, missing); err != nil || versions != nil {
+		t.Fatalf("WatchResource() of a missing resource = %v, %v, want nil", versions, err)
+	}
+
+	var notes []*trashedNote
+	for _, folder := range []string{"inbox", "archive"} {
+		created, err := p.CreateResource(ctx, &trashedNote{Folder: folder})
+		if err != nil {
+			t.Fatalf("CreateResource(): %v", err)
+		}
+		notes = append(notes, created.(*trashedNote))
+	}
+
+	watched := &trashedNote{}
+	watched.ID = notes[0].ID
+	versions, err := p.WatchResource(ctx, watched)
+	if err != nil {
+		t.Fatalf("WatchResource(): %v", err)
+	}
+
+	next := func() *trashedNote {
+		t.Helper()
+
+		select {
+		case version, ok := <-versions:
+			if !ok {
+				t.Fatal("watch closed")
+			}
+			note, _ := version.(*trashedNote)
+			return note
+		case <-time.After(5 * time.Second):
+			t.Fatal("no version")
+		}
+
+		return nil
+	}
+
+	if note := next(); note == nil || note.Folder != "inbox" {
+		t.Fatalf("current version = %+v, want the inbox note", note)
+	}
+
+	// Changes of other resources aren't sent.
+	notes[1].Folder = "trash"
+	if _, err := p.UpdateResource(ctx, notes[1], []string{"folder"}, nil); err != nil {
+		t.Fatalf("UpdateResource(): %v", err)
+	}
+
+	notes[0].Folder = "archive"
+	if _, err := p.UpdateResource(ctx, notes[0], []string{"folder"}, nil); err != nil {
+		t.Fatalf("UpdateResource(): %v", err)
+	}
+
+	if note := next(); note == nil || note.Folder != "archive" {
+		t.Errorf("updated version = %+v, want the archived note", note)
+	}
+
+	if _, err := p.DeleteResource(ctx, notes[0], nil); err != nil {
+		t.Fatalf("DeleteResource(): %v", err)
+	}
+
+	if note := next(); note != nil {
+		t.Errorf("version after deletion = %+v, want nil", note)
+	}
+
+	if _, err := p.UndeleteResource(ctx, notes[0], nil); err != nil {
+		t.Fatalf("UndeleteResource(): %v", err)
+	}
+
+	if note := next(); note == nil || note.ID != notes[0].ID {
+		t.Errorf("version after undeletion = %+v, want the note", note)
+	}
+
+	cancel()
+	for range versions {
+	}
+}